@@ -29,6 +29,37 @@ type AppProfileSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	AppNames []string `json:"appNames,omitempty"`
+	// Selects the clusters to which the profile is targeted, in addition to the
+	// ones listing it in their arlon.io/profiles annotation. The labels of an
+	// ArgoCD cluster are matched together with the ones of the corresponding
+	// Arlon cluster.
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// Helm configuration of some of the apps for the clusters the profile is
+	// targeted to
+	AppValues []AppHelmValues `json:"appValues,omitempty"`
+}
+
+// AppHelmValues supplies Helm configuration to an app. The values of the
+// profiles of a cluster are merged in profile name order, then the values of
+// the cluster itself are merged over them.
+type AppHelmValues struct {
+	// Name of the app
+	AppName string `json:"appName"`
+	// A block of Helm values in YAML
+	Values string `json:"values,omitempty"`
+	// Additional value files, relative to the source path of the app
+	ValueFiles []string `json:"valueFiles,omitempty"`
+	// Helm parameters, applied over the values
+	Parameters []HelmParameter `json:"parameters,omitempty"`
+}
+
+// HelmParameter sets a single Helm value, as with helm's --set
+type HelmParameter struct {
+	// Dotted path of the value, e.g. controller.replicaCount
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	// Do not interpret booleans and numbers
+	ForceString bool `json:"forceString,omitempty"`
 }
 
 // AppProfileStatus defines the observed state of AppProfile
@@ -40,6 +71,31 @@ type AppProfileStatus struct {
 	Health string `json:"health,omitempty"`
 	// Names of apps that don't exist
 	InvalidAppNames []string `json:"invalidAppNames,omitempty"`
+	// Names of the clusters the profile is currently targeted to
+	Clusters []string `json:"clusters,omitempty"`
+	// Sync and health of the application generated for each valid app of the
+	// profile and each of its clusters
+	AppStatuses []AppClusterStatus `json:"appStatuses,omitempty"`
+}
+
+// AppClusterStatus reports the state of an app on a cluster, as observed from
+// the Argo CD application generated by the app's ApplicationSet.
+type AppClusterStatus struct {
+	AppName     string `json:"appName"`
+	ClusterName string `json:"clusterName"`
+	// Name of the generated Argo CD application, empty if it does not exist yet
+	ApplicationName string `json:"applicationName,omitempty"`
+	// Argo CD sync status, for e.g. Synced, OutOfSync
+	Sync string `json:"sync,omitempty"`
+	// Argo CD health status, for e.g. Healthy, Progressing, Degraded
+	Health string `json:"health,omitempty"`
+	// Details about an error preventing the app from being deployed
+	Message string `json:"message,omitempty"`
+}
+
+// Healthy returns whether the app is deployed and healthy on the cluster.
+func (acs *AppClusterStatus) Healthy() bool {
+	return acs.Sync == "Synced" && acs.Health == "Healthy"
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,127 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BundleSpec defines the desired state of Bundle. A static bundle contains
+// its manifests in Data, a dynamic bundle references them with RepoUrl.
+type BundleSpec struct {
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Manifests of a static bundle
+	Data string `json:"data,omitempty"`
+	// Encryption of the manifests of a static bundle. With sops, the
+	// manifests are encrypted by SOPS with age keys, are committed to git
+	// encrypted and are decrypted by the arlon-sops Argo CD plugin.
+	// +kubebuilder:validation:Enum=sops
+	Encryption string `json:"encryption,omitempty"`
+	// URL of the git repository, Helm repository or OCI registry of a dynamic bundle
+	RepoUrl string `json:"repoUrl,omitempty"`
+	// Path within the git repository
+	RepoPath string `json:"repoPath,omitempty"`
+	// Git revision (tag/branch/commit), or chart version of a chart bundle
+	RepoRevision string `json:"repoRevision,omitempty"`
+	// Name of the Helm chart of a chart bundle
+	Chart string `json:"chart,omitempty"`
+	// Manifest source type (directory/helm/ksonnet/kustomize, empty means autodetect)
+	SrcType string `json:"srcType,omitempty"`
+	// Deployment settings of the bundle's application
+	DeploySettings *DeploySettings `json:"deploySettings,omitempty"`
+}
+
+// BundleStatus defines the observed state of Bundle
+type BundleStatus struct {
+	// Version of the latest revision of the bundle
+	Version int32 `json:"version,omitempty"`
+	// Hash of the content of the latest revision
+	ContentHash string `json:"contentHash,omitempty"`
+	// The generation of the Bundle spec most recently recorded as a revision
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.status.version`
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repoUrl`
+//+kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.repoPath`,priority=1
+//+kubebuilder:printcolumn:name="Chart",type=string,JSONPath=`.spec.chart`,priority=1
+//+kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.spec.repoRevision`
+//+kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Bundle is the Schema for the bundles API
+type Bundle struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BundleSpec   `json:"spec,omitempty"`
+	Status BundleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BundleList contains a list of Bundle
+type BundleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Bundle `json:"items"`
+}
+
+// BundleRevisionSpec is an immutable record of the content of a bundle
+type BundleRevisionSpec struct {
+	// Name of the bundle
+	Bundle string `json:"bundle"`
+	// Version number of the revision, starting at 1
+	Version int32 `json:"version"`
+	// Hash of the content
+	ContentHash string `json:"contentHash"`
+	// Reason of the change, for e.g. created, updated, rollback to version 2
+	ChangeCause string `json:"changeCause,omitempty"`
+	// Content of the bundle. Description and tags are not recorded.
+	Content BundleSpec `json:"content"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Bundle",type=string,JSONPath=`.spec.bundle`
+//+kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.spec.version`
+//+kubebuilder:printcolumn:name="Change",type=string,JSONPath=`.spec.changeCause`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// BundleRevision is the Schema for the bundlerevisions API
+type BundleRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="bundle revisions are immutable"
+	Spec BundleRevisionSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// BundleRevisionList contains a list of BundleRevision
+type BundleRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BundleRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Bundle{}, &BundleList{}, &BundleRevision{}, &BundleRevisionList{})
+}
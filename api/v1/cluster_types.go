@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -33,6 +34,9 @@ type ClusterSpec struct {
 	Autoscaler *AutoscalerSpec `json:"autoscaler,omitempty"`
 	// Optional Arlon Helm chart specification if defaults are not desired
 	ArlonHelmChart *RepoSpec `json:"arlonHelmChart,omitempty"`
+	// Helm configuration of some of the apps deployed to the cluster by its
+	// app profiles, merged over the one supplied by the profiles
+	AppValues []AppHelmValues `json:"appValues,omitempty"`
 }
 
 type RepoSpec struct {
@@ -42,8 +46,51 @@ type RepoSpec struct {
 }
 
 type OverrideSpec struct {
-	Patch string   `json:"patch"`
-	Repo  RepoSpec `json:"repo"`
+	// Strategic merge patch of the cluster template resources. Optional
+	// when patches or a topology override are specified.
+	Patch string `json:"patch,omitempty"`
+	// Patches of the cluster template resources, applied after the patch
+	Patches []OverridePatch `json:"patches,omitempty"`
+	Repo    RepoSpec        `json:"repo"`
+	// Optional override of the topology of a cluster template whose Cluster
+	// is based on a ClusterClass
+	Topology *TopologyOverride `json:"topology,omitempty"`
+}
+
+// Types of override patches
+const (
+	PatchTypeStrategicMerge = "StrategicMerge"
+	PatchTypeJSON6902       = "JSON6902"
+)
+
+// OverridePatch is a patch of the cluster template resources that its
+// target selects, with the names the resources have in the template. A
+// patch without target applies to the resource it names, which requires a
+// strategic merge patch.
+type OverridePatch struct {
+	// Type of the patch, StrategicMerge if unset. A JSON6902 patch is a
+	// list of operations.
+	// +kubebuilder:validation:Enum=StrategicMerge;JSON6902
+	Type   string           `json:"type,omitempty"`
+	Target *KustomizeTarget `json:"target,omitempty"`
+	Patch  string           `json:"patch"`
+}
+
+// TopologyOverride sets the Kubernetes version and variables of the
+// topology of a ClusterClass based Cluster. Variables are merged by name
+// with the ones of the cluster template.
+type TopologyOverride struct {
+	// Kubernetes version of the cluster, for e.g. v1.24.6
+	Version string `json:"version,omitempty"`
+	// Variables of the ClusterClass
+	Variables []TopologyVariable `json:"variables,omitempty"`
+}
+
+// TopologyVariable is the value of a variable defined by a ClusterClass.
+type TopologyVariable struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:XPreserveUnknownFields
+	Value apiextensionsv1.JSON `json:"value"`
 }
 
 type AutoscalerSpec struct {
@@ -63,17 +110,105 @@ type ClusterStatus struct {
 	// Empty value means that the cluster template has not yet been validated.
 	InnerClusterName string `json:"innerClusterName,omitempty"`
 
+	// The cluster template location that was last validated. A change of
+	// spec.clusterTemplate causes the template to be validated again.
+	ValidatedTemplate *RepoSpec `json:"validatedTemplate,omitempty"`
+
 	// Indicates whether the override portion of the cluster
 	// (the patch files in git) has been successfully created. Only
 	// applicable to a cluster that specifies an override.
 	OverrideSuccessful bool `json:"overrideSuccessful,omitempty"`
 
+	// Hash of the override patch, override location and cluster template
+	// location that the override directory in git was last generated from
+	OverrideHash string `json:"overrideHash,omitempty"`
+
+	// URL of the pull request most recently opened to write the override
+	// directory to git, when the override repository uses the pull-request
+	// write mode
+	PullRequestUrl string `json:"pullRequestUrl,omitempty"`
+
 	// An optional message with details about the error for a 'retrying' state
 	Message string `json:"message,omitempty"`
+
+	// Provisioning progress of the workload cluster, refreshed periodically
+	// once the cluster app has been created
+	Provisioning ProvisioningStatus `json:"provisioning,omitempty"`
+
+	// The generation of the Cluster spec most recently processed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the progress of each step of the cluster's
+	// reconciliation. The Ready condition summarizes all other conditions.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ProvisioningStatus reports the health of the workload cluster as observed
+// from its CAPI resources and from the Argo CD applications deploying it.
+type ProvisioningStatus struct {
+	// Phase of the CAPI Cluster resource, for e.g. Provisioning, Provisioned, Failed.
+	// Empty if the CAPI Cluster resource does not exist yet.
+	Phase string `json:"phase,omitempty"`
+	// Whether the infrastructure provider reports the infrastructure as ready
+	InfrastructureReady bool `json:"infrastructureReady,omitempty"`
+	// Whether the control plane provider reports the control plane as ready
+	ControlPlaneReady bool `json:"controlPlaneReady,omitempty"`
+	// Worker replica counts summed over the cluster's MachineDeployments
+	Workers WorkerReplicas `json:"workers,omitempty"`
+	// Sync and health of the <name> Argo CD application
+	ClusterApp ApplicationHealth `json:"clusterApp,omitempty"`
+	// Sync and health of the <name>-arlon Argo CD application
+	ArlonApp ApplicationHealth `json:"arlonApp,omitempty"`
+}
+
+type WorkerReplicas struct {
+	Desired   int32 `json:"desired,omitempty"`
+	Ready     int32 `json:"ready,omitempty"`
+	Available int32 `json:"available,omitempty"`
+	Updated   int32 `json:"updated,omitempty"`
+}
+
+type ApplicationHealth struct {
+	// Argo CD sync status, for e.g. Synced, OutOfSync
+	Sync string `json:"sync,omitempty"`
+	// Argo CD health status, for e.g. Healthy, Progressing, Degraded
+	Health string `json:"health,omitempty"`
+	// The time at which Argo CD last compared the application with its source
+	ReconciledAt *metav1.Time `json:"reconciledAt,omitempty"`
 }
 
+// Condition types reported in ClusterStatus.Conditions
+const (
+	// ClusterTemplateValidatedCondition is true when the cluster template
+	// in git has been validated and its inner cluster name is known.
+	ClusterTemplateValidatedCondition = "TemplateValidated"
+	// ClusterOverrideReadyCondition is true when the override patch has been
+	// written to git, or when the cluster has no override.
+	ClusterOverrideReadyCondition = "OverrideReady"
+	// ClusterArlonAppCreatedCondition is true when the <name>-arlon Argo CD
+	// application exists.
+	ClusterArlonAppCreatedCondition = "ArlonAppCreated"
+	// ClusterClusterAppCreatedCondition is true when the <name> Argo CD
+	// application containing the cluster template exists.
+	ClusterClusterAppCreatedCondition = "ClusterAppCreated"
+	// ClusterProvisionedCondition reflects whether the workload cluster
+	// described by the cluster template has been provisioned.
+	ClusterProvisionedCondition = "ClusterProvisioned"
+	// ClusterReadyCondition summarizes the other conditions.
+	ClusterReadyCondition = "Ready"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.provisioning.phase`
+//+kubebuilder:printcolumn:name="Workers",type=string,JSONPath=`.status.provisioning.workers.ready`,priority=1
+//+kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.provisioning.clusterApp.health`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Cluster is the Schema for the clusters API
 type Cluster struct {
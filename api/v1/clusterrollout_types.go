@@ -0,0 +1,137 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterRolloutSpec defines the desired state of ClusterRollout
+type ClusterRolloutSpec struct {
+	// Selects the Clusters to upgrade, in the namespace of the ClusterRollout
+	Selector metav1.LabelSelector `json:"selector"`
+	// The spec.clusterTemplate.revision to move the selected Clusters to
+	TargetRevision string `json:"targetRevision"`
+	// The number of Clusters upgraded at the same time. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WaveSize int32 `json:"waveSize,omitempty"`
+	// The maximum number of seconds for the Clusters of a wave to become
+	// healthy before the wave is considered failed. Defaults to 1800.
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Pauses the rollout before the next wave. Set by the controller
+	// when a wave fails; clear it to retry the failed wave.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// ClusterRolloutStatus defines the observed state of ClusterRollout
+type ClusterRolloutStatus struct {
+	// Phase has these possible values
+	// - empty string: never processed by controller
+	// - Progressing: a wave is being upgraded
+	// - Paused: the rollout is paused, possibly because a wave failed
+	// - Completed: all the selected Clusters are at the target revision
+	Phase string `json:"phase,omitempty"`
+
+	// An optional message with details about the phase
+	Message string `json:"message,omitempty"`
+
+	// The target revision the Clusters of the rollout were selected for.
+	// A change of spec.targetRevision restarts the rollout.
+	TargetRevision string `json:"targetRevision,omitempty"`
+
+	// The wave being upgraded, starting at 1
+	CurrentWave int32 `json:"currentWave,omitempty"`
+
+	// The total number of waves
+	Waves int32 `json:"waves,omitempty"`
+
+	// The time at which the current wave started
+	WaveStartTime *metav1.Time `json:"waveStartTime,omitempty"`
+
+	// The Clusters selected by the rollout, in upgrade order
+	Clusters []RolloutClusterStatus `json:"clusters,omitempty"`
+
+	// The generation of the ClusterRollout spec most recently processed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// RolloutClusterStatus is the upgrade progress of a Cluster of a rollout.
+type RolloutClusterStatus struct {
+	// Name of the Cluster
+	Name string `json:"name"`
+	// The wave of the Cluster, starting at 1. Zero for a Cluster that was
+	// already at the target revision.
+	Wave int32 `json:"wave,omitempty"`
+	// The revision of the Cluster before the rollout
+	PreviousRevision string `json:"previousRevision,omitempty"`
+	// State is one of Pending, Upgrading, Upgraded, Skipped or Failed
+	State string `json:"state"`
+	// The generation of the Cluster resulting from the revision change
+	Generation int64 `json:"generation,omitempty"`
+	// An optional message with details about a failure
+	Message string `json:"message,omitempty"`
+}
+
+// Phases of a ClusterRollout
+const (
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhasePaused      = "Paused"
+	RolloutPhaseCompleted   = "Completed"
+)
+
+// States of a Cluster in a ClusterRollout
+const (
+	RolloutClusterPending   = "Pending"
+	RolloutClusterUpgrading = "Upgrading"
+	RolloutClusterUpgraded  = "Upgraded"
+	RolloutClusterSkipped   = "Skipped"
+	RolloutClusterFailed    = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.spec.targetRevision`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Wave",type=integer,JSONPath=`.status.currentWave`
+//+kubebuilder:printcolumn:name="Waves",type=integer,JSONPath=`.status.waves`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterRollout is the Schema for the clusterrollouts API. It moves the
+// selected Clusters to a new cluster template revision in waves.
+type ClusterRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterRolloutSpec   `json:"spec,omitempty"`
+	Status ClusterRolloutStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterRolloutList contains a list of ClusterRollout
+type ClusterRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterRollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterRollout{}, &ClusterRolloutList{})
+}
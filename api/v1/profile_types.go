@@ -29,10 +29,19 @@ type ProfileSpec struct {
 	Description string   `json:"description,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
 
-	// Names of bundles in this profile. Order is not significant.
+	// Names of bundles in this profile. Order is not significant, the
+	// deployment of bundles is ordered by BundleDependencies.
 	Bundles []string `json:"bundles,omitempty"`
-	// Optional parameter overrides for specific bundles
+	// Optional Helm and kustomize overrides for specific bundles
 	Overrides []Override `json:"overrides,omitempty"`
+	// Optional deployment settings overrides for specific bundles
+	BundleSettings []BundleSettings `json:"bundleSettings,omitempty"`
+	// Optional versions that specific bundles are pinned to. The other
+	// bundles are used at their latest version.
+	BundleVersions []BundleVersion `json:"bundleVersions,omitempty"`
+	// Optional dependencies between bundles. A bundle is synced once the
+	// bundles it depends on are synced and healthy.
+	BundleDependencies []BundleDependency `json:"bundleDependencies,omitempty"`
 	// URL of git repository where dynamic profile shall be stored
 	RepoUrl string `json:"repoUrl,omitempty"`
 	// Path within git repository
@@ -41,10 +50,112 @@ type ProfileSpec struct {
 	RepoRevision string `json:"repoRevision,omitempty"`
 }
 
+// Override customizes the manifests of a bundle for the clusters using the
+// profile. Helm overrides (a parameter or a block of values) apply to Helm
+// and chart bundles, kustomize overrides (images and patches) to kustomize
+// and static bundles.
 type Override struct {
 	Bundle string `json:"bundle"`
-	Key    string `json:"key"`
-	Value  string `json:"value"`
+	// Name of a Helm parameter, for e.g. image.tag
+	Key string `json:"key,omitempty"`
+	// Value of the Helm parameter
+	Value string `json:"value,omitempty"`
+	// YAML block of Helm values, merged over the values of the chart
+	Values string `json:"values,omitempty"`
+	// Kustomize image overrides, of the form [name=]newName[:newTag|@digest]
+	Images []string `json:"images,omitempty"`
+	// Kustomize patches of the resources of the bundle
+	Patches []KustomizePatch `json:"patches,omitempty"`
+}
+
+// KustomizePatch is a strategic merge patch, or a JSON 6902 patch when it is
+// a list of operations. A patch without target applies to the resource it
+// names, which requires a strategic merge patch.
+type KustomizePatch struct {
+	Patch  string           `json:"patch"`
+	Target *KustomizeTarget `json:"target,omitempty"`
+}
+
+// KustomizeTarget selects the resources a patch applies to
+type KustomizeTarget struct {
+	Group              string `json:"group,omitempty"`
+	Version            string `json:"version,omitempty"`
+	Kind               string `json:"kind,omitempty"`
+	Name               string `json:"name,omitempty"`
+	Namespace          string `json:"namespace,omitempty"`
+	LabelSelector      string `json:"labelSelector,omitempty"`
+	AnnotationSelector string `json:"annotationSelector,omitempty"`
+}
+
+// BundleSettings overrides the deployment settings of a bundle for the
+// clusters using the profile. Unset fields keep the bundle's own settings.
+type BundleSettings struct {
+	Bundle         string `json:"bundle"`
+	DeploySettings `json:",inline"`
+}
+
+// BundleDependency declares the bundles that a bundle depends on
+type BundleDependency struct {
+	Bundle string `json:"bundle"`
+	// Bundles of the profile to deploy before the bundle
+	DependsOn []string `json:"dependsOn"`
+}
+
+// BundleVersion pins a bundle to one of its revisions
+type BundleVersion struct {
+	Bundle string `json:"bundle"`
+	// Version number of the revision, as shown by arlon bundle history
+	Version int32 `json:"version"`
+}
+
+// DeploySettings controls how a bundle is deployed to a workload cluster by
+// its Argo CD application.
+type DeploySettings struct {
+	// Namespace of the workload cluster the bundle is deployed to, "default" if unset
+	DestinationNamespace string `json:"destinationNamespace,omitempty"`
+	// Whether Argo CD creates the destination namespace
+	CreateNamespace *bool `json:"createNamespace,omitempty"`
+	// Argo CD project of the application, "default" if unset
+	Project string `json:"project,omitempty"`
+	// Whether resources removed from the bundle are deleted, true if unset
+	Prune *bool `json:"prune,omitempty"`
+	// Whether changes made to the resources outside of git are reverted
+	SelfHeal *bool `json:"selfHeal,omitempty"`
+	// Argo CD sync options, for e.g. ServerSideApply=true
+	SyncOptions []string `json:"syncOptions,omitempty"`
+	// Retry policy of failed syncs
+	Retry *SyncRetry `json:"retry,omitempty"`
+	// Differences ignored when comparing the live resources with the bundle
+	IgnoreDifferences []IgnoreDifference `json:"ignoreDifferences,omitempty"`
+	// Sync wave of the application among the applications of the cluster
+	SyncWave *int32 `json:"syncWave,omitempty"`
+}
+
+type SyncRetry struct {
+	// Maximum number of attempts, unlimited if negative
+	Limit int64 `json:"limit,omitempty"`
+	// Backoff between attempts
+	Backoff *SyncRetryBackoff `json:"backoff,omitempty"`
+}
+
+type SyncRetryBackoff struct {
+	// Delay before the first retry, for e.g. 5s
+	Duration string `json:"duration,omitempty"`
+	// Factor multiplying the delay after each failed attempt
+	Factor *int64 `json:"factor,omitempty"`
+	// Maximum delay, for e.g. 3m
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+// IgnoreDifference selects the fields of resources that Argo CD ignores when
+// comparing the live resources with the bundle
+type IgnoreDifference struct {
+	Group             string   `json:"group,omitempty"`
+	Kind              string   `json:"kind"`
+	Name              string   `json:"name,omitempty"`
+	Namespace         string   `json:"namespace,omitempty"`
+	JSONPointers      []string `json:"jsonPointers,omitempty"`
+	JQPathExpressions []string `json:"jqPathExpressions,omitempty"`
 }
 
 // ProfileStatus defines the observed state of Profile
@@ -29,8 +29,9 @@ func createAppCommand() *cobra.Command {
 	var outputYaml bool
 	var autoSync bool
 	var autoPrune bool
+	var helm bool
 	command := &cobra.Command{
-		Use:   "create appName repoUrl repoPath [--repo-revision revision][--output-yaml][--autosync][--autoprune][other flags]",
+		Use:   "create appName repoUrl repoPath [--repo-revision revision][--output-yaml][--autosync][--autoprune][--helm][other flags]",
 		Short: "create new Arlon app",
 		Long:  "create new Arlon app, which is represented as a specialized ArgoCD ApplicationSet resource",
 		Args:  cobra.ExactArgs(3),
@@ -38,7 +39,7 @@ func createAppCommand() *cobra.Command {
 			appName = args[0]
 			repoUrl = args[1]
 			repoPath = args[2]
-			app := app.Create(argocdNs, appName, destNs, project, repoPath, repoUrl, repoRevision, autoSync, autoPrune)
+			app := app.Create(argocdNs, appName, destNs, project, repoPath, repoUrl, repoRevision, autoSync, autoPrune, helm)
 			if outputYaml {
 				scheme := runtime.NewScheme()
 				if err := appset.AddToScheme(scheme); err != nil {
@@ -79,5 +80,6 @@ func createAppCommand() *cobra.Command {
 	command.Flags().BoolVar(&outputYaml, "output-yaml", false, "output YAML instead of deploying to management cluster")
 	command.Flags().BoolVar(&autoSync, "autosync", true, "enable ArgoCD auto-sync")
 	command.Flags().BoolVar(&autoPrune, "autoprune", true, "enable ArgoCD auto-prune, only meaningful if auto-sync enabled")
+	command.Flags().BoolVar(&helm, "helm", false, "template the per-cluster Helm values supplied by app profiles and clusters into the app's Helm chart")
 	return command
 }
@@ -17,5 +17,6 @@ func NewCommand() *cobra.Command {
 	command.AddCommand(validateGitBaseClusterCommand())
 	command.AddCommand(prepareBaseClusterCommand())
 	command.AddCommand(prepareGitBaseClusterCommand())
+	command.AddCommand(renderBaseClusterCommand())
 	return command
 }
@@ -40,11 +40,14 @@ func prepareGitBaseClusterCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to get repository credentials: %s", err)
 			}
-			clusterName, changed, err := bcl.PrepareGitDir(creds,
+			clusterName, changed, prUrl, err := bcl.PrepareGitDir(creds,
 				repoUrl, repoRevision, repoPath, casMax, casMin)
 			if err != nil {
 				return fmt.Errorf("git preparation failed: %s", err)
 			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
 			if changed {
 				fmt.Println("preparation successful, cluster name:", clusterName)
 			} else {
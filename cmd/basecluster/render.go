@@ -0,0 +1,78 @@
+package basecluster
+
+import (
+	"fmt"
+	"os"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	bcl "github.com/arlonproj/arlon/pkg/basecluster"
+	gyaml "github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+)
+
+func renderBaseClusterCommand() *cobra.Command {
+	var clusterName string
+	var patchFile string
+	var patchesFile string
+	var topologyFile string
+	var webhookRewrite bool
+	command := &cobra.Command{
+		Use:   "render <directory> --cluster-name name [--patch-file file] [--patches-file file] [--topology-file file] [--webhook-rewrite]",
+		Short: "render the manifests of a cluster created from a cluster template",
+		Long: `render the manifests that the cluster app of an arlon cluster would deploy from a local, prepared cluster template directory,
+with the name prefix and namespace of the cluster and the override patches and topology override if any. No access to Argo CD or to the management cluster is needed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			var opts bcl.RenderOptions
+			opts.WebhookRewrite = webhookRewrite
+			if patchFile != "" {
+				patch, err := os.ReadFile(patchFile)
+				if err != nil {
+					return fmt.Errorf("failed to read patch file: %s", err)
+				}
+				opts.Patch = patch
+			}
+			if patchesFile != "" {
+				data, err := os.ReadFile(patchesFile)
+				if err != nil {
+					return fmt.Errorf("failed to read patches file: %s", err)
+				}
+				if err := gyaml.Unmarshal(data, &opts.Patches); err != nil {
+					return fmt.Errorf("failed to parse patches file: %s", err)
+				}
+			}
+			if topologyFile != "" {
+				data, err := os.ReadFile(topologyFile)
+				if err != nil {
+					return fmt.Errorf("failed to read topology file: %s", err)
+				}
+				opts.Topology = &arlonv1.TopologyOverride{}
+				if err := gyaml.Unmarshal(data, opts.Topology); err != nil {
+					return fmt.Errorf("failed to parse topology file: %s", err)
+				}
+			}
+			objs, err := bcl.Render(args[0], clusterName, opts)
+			if err != nil {
+				return err
+			}
+			for i, obj := range objs {
+				data, err := gyaml.Marshal(obj.Object)
+				if err != nil {
+					return fmt.Errorf("failed to serialize %s %s: %s", obj.GetKind(), obj.GetName(), err)
+				}
+				if i > 0 {
+					fmt.Println("---")
+				}
+				fmt.Print(string(data))
+			}
+			return nil
+		},
+	}
+	command.Flags().StringVar(&clusterName, "cluster-name", "", "the name of the arlon cluster")
+	command.Flags().StringVar(&patchFile, "patch-file", "", "the override patch file of the cluster")
+	command.Flags().StringVar(&patchesFile, "patches-file", "", "a YAML list of override patches of the cluster, as in spec.override.patches")
+	command.Flags().StringVar(&topologyFile, "topology-file", "", "the topology override of the cluster, as in spec.override.topology")
+	command.Flags().BoolVar(&webhookRewrite, "webhook-rewrite", false, "rename the Cluster resource like the arlon webhook does when it is installed")
+	_ = command.MarkFlagRequired("cluster-name")
+	return command
+}
@@ -1,39 +1,86 @@
 package basecluster
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+
 	bcl "github.com/arlonproj/arlon/pkg/basecluster"
 	"github.com/spf13/cobra"
-	// "k8s.io/client-go/tools/clientcmd"
 )
 
+// validationResult is the JSON output of the validate command.
+type validationResult struct {
+	// Name of the cluster of the template, if it has exactly one
+	ClusterName string        `json:"clusterName,omitempty"`
+	Findings    []bcl.Finding `json:"findings"`
+}
+
 func validateBaseClusterCommand() *cobra.Command {
-	/*
-		var clientConfig clientcmd.ClientConfig
-		var argocdNs string
-		var arlonNs string
-	*/
+	var output string
+	var clusterNameLength int
 	command := &cobra.Command{
-		Use:   "validate <filename> [flags]",
+		Use:   "validate <filename|directory> [--output text|json] [--cluster-name-length length]",
 		Short: "validate cluster template files",
-		Long:  "validate cluster template files",
-		Args:  cobra.ExactArgs(1),
+		Long: `validate cluster template files, reporting every problem found as a finding with a rule ID and a severity.
+The command fails if a finding has the error severity, so that it can gate template changes in CI.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(c *cobra.Command, args []string) error {
-			/*
-				argoIf := argocd.NewArgocdClientOrDie("")
-				config, err := clientConfig.ClientConfig()
-				if err != nil {
-					return fmt.Errorf("failed to get k8s client config: %s", err)
-				}
-			*/
-			fileName := args[0]
-			clusterName, err := bcl.Validate(fileName)
+			if output != "text" && output != "json" {
+				return fmt.Errorf("invalid output format %s, must be text or json", output)
+			}
+			target := args[0]
+			info, err := os.Stat(target)
+			if err != nil {
+				return fmt.Errorf("failed to check for %s: %s", target, err)
+			}
+			opts := bcl.LintOptions{ClusterNameLength: clusterNameLength}
+			var findings []bcl.Finding
+			var clusterName string
+			if info.IsDir() {
+				findings, clusterName, err = bcl.LintDir(target, opts)
+			} else {
+				findings, clusterName, err = bcl.LintFile(target, opts)
+			}
 			if err != nil {
 				return err
 			}
-			fmt.Println("validation successful, cluster name:", clusterName)
+			if output == "json" {
+				if findings == nil {
+					findings = []bcl.Finding{}
+				}
+				result := validationResult{ClusterName: clusterName, Findings: findings}
+				data, err := json.MarshalIndent(&result, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to serialize findings: %s", err)
+				}
+				fmt.Println(string(data))
+			} else {
+				for _, f := range findings {
+					location := f.File
+					if f.Resource != "" {
+						if location != "" {
+							location += ": "
+						}
+						location += f.Resource
+					}
+					if location != "" {
+						location += ": "
+					}
+					fmt.Printf("%s [%s] %s%s\n", f.Severity, f.RuleID, location, f.Message)
+				}
+				if !bcl.HasErrors(findings) {
+					fmt.Println("validation successful, cluster name:", clusterName)
+				}
+			}
+			if bcl.HasErrors(findings) {
+				c.SilenceUsage = true
+				return fmt.Errorf("cluster template validation failed")
+			}
 			return nil
 		},
 	}
+	command.Flags().StringVar(&output, "output", "text", "output format of the findings, text or json")
+	command.Flags().IntVar(&clusterNameLength, "cluster-name-length", bcl.DefaultClusterNameLength, "length of the cluster names assumed when checking the length of the resource names, which the cluster name prefixes")
 	return command
 }
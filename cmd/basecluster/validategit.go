@@ -5,6 +5,7 @@ import (
 	"github.com/argoproj/argo-cd/v2/util/cli"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	bcl "github.com/arlonproj/arlon/pkg/basecluster"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/arlonproj/arlon/pkg/gitrepo"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,6 +18,7 @@ func validateGitBaseClusterCommand() *cobra.Command {
 	var repoAlias string
 	var repoPath string
 	var repoRevision string
+	var namespace string
 	command := &cobra.Command{
 		Use:   "validategit --repo-url repoUrl [--repo-revision revision] [--repo-path path]",
 		Short: "validate cluster template directory in git",
@@ -37,7 +39,15 @@ func validateGitBaseClusterCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to get repository credentials: %s", err)
 			}
-			clusterName, err := bcl.ValidateGitDir(creds, repoUrl, repoRevision, repoPath)
+			var getter bcl.ObjectGetter
+			if namespace != "" {
+				cli, err := ctrlruntimeclient.NewClient(config)
+				if err != nil {
+					return fmt.Errorf("failed to get controller runtime client: %s", err)
+				}
+				getter = bcl.NewObjectGetter(cli, namespace)
+			}
+			clusterName, err := bcl.ValidateGitDir(creds, repoUrl, repoRevision, repoPath, getter)
 			if err != nil {
 				return err
 			}
@@ -51,6 +61,7 @@ func validateGitBaseClusterCommand() *cobra.Command {
 	command.Flags().StringVar(&repoAlias, "repo-alias", gitrepo.RepoDefaultCtx, "git repository alias to use")
 	command.Flags().StringVar(&repoRevision, "repo-revision", "main", "the git revision for cluster template directory")
 	command.Flags().StringVar(&repoPath, "repo-path", "", "the git repository path for cluster template directory")
+	command.Flags().StringVar(&namespace, "cluster-namespace", "", "the management cluster namespace in which to look up the ClusterClass and templates that the cluster template does not contain")
 	command.MarkFlagsMutuallyExclusive("repo-url", "repo-alias")
 	return command
 }
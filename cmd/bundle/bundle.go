@@ -18,5 +18,9 @@ func NewCommand() *cobra.Command {
 	command.AddCommand(createBundleCommand())
 	command.AddCommand(deleteBundleCommand())
 	command.AddCommand(updateBundleCommand())
+	command.AddCommand(historyBundleCommand())
+	command.AddCommand(rollbackBundleCommand())
+	command.AddCommand(migrateBundlesCommand())
+	command.AddCommand(sopsPluginCommand())
 	return command
 }
@@ -2,10 +2,11 @@ package bundle
 
 import (
 	"fmt"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/arlonproj/arlon/pkg/gitrepo"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -19,15 +20,20 @@ func createBundleCommand() *cobra.Command {
 	var repoAlias string
 	var repoPath string
 	var repoRevision string
+	var chart string
 	var srcType string
 	var desc string
 	var tags string
+	var getSettings func() (arlonv1.DeploySettings, error)
 	command := &cobra.Command{
 		Use:   "create",
 		Short: "Create configuration bundle",
 		Long:  "Create configuration bundle",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(c *cobra.Command, args []string) error {
+			if chart != "" && repoUrl == "" {
+				return fmt.Errorf("a chart bundle requires the URL of its chart repository in --repo-url")
+			}
 			if fromFile == "" && repoUrl == "" {
 				var err error
 				repoUrl, err = gitrepo.GetRepoUrl(repoAlias)
@@ -35,13 +41,20 @@ func createBundleCommand() *cobra.Command {
 					return err
 				}
 			}
+			settings, err := getSettings()
+			if err != nil {
+				return err
+			}
 			config, err := clientConfig.ClientConfig()
 			if err != nil {
 				return fmt.Errorf("failed to get k8s client config: %s", err)
 			}
-			kubeClient := kubernetes.NewForConfigOrDie(config)
-			return bundle.Create(kubeClient, ns, args[0], fromFile, repoUrl,
-				repoPath, repoRevision, srcType, desc, tags)
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			return bundle.Create(ctrlClient, ns, args[0], fromFile, repoUrl,
+				repoPath, repoRevision, chart, srcType, desc, tags, settings)
 		},
 	}
 	clientConfig = cli.AddKubectlFlagsToCmd(command)
@@ -50,10 +63,14 @@ func createBundleCommand() *cobra.Command {
 	command.Flags().StringVar(&repoUrl, "repo-url", "", "create a dynamic bundle from this repo URL")
 	command.Flags().StringVar(&repoAlias, "repo-alias", gitrepo.RepoDefaultCtx, "the git repository alias to use")
 	command.Flags().StringVar(&repoPath, "repo-path", "", "optional path in repo specified by --from-repo")
-	command.Flags().StringVar(&repoRevision, "repo-revision", "", "git revision (unspecified implies HEAD of default branch)")
+	command.Flags().StringVar(&repoRevision, "repo-revision", "", "git revision (unspecified implies HEAD of default branch), or chart version of a chart bundle")
+	command.Flags().StringVar(&chart, "chart", "", "create a dynamic bundle from this Helm chart of the Helm repository or OCI registry (oci://) specified by --repo-url, at the version specified by --repo-revision")
 	command.Flags().StringVar(&srcType, "srctype", "", "manifest source type (directory/helm/ksonnet/kustomize, empty means autodetect)")
 	command.Flags().StringVar(&desc, "desc", "", "description")
 	command.Flags().StringVar(&tags, "tags", "", "comma separated list of tags")
+	getSettings = addDeploySettingsFlags(command)
 	command.MarkFlagsMutuallyExclusive("repo-alias", "repo-url", "from-file")
+	command.MarkFlagsMutuallyExclusive("chart", "from-file")
+	command.MarkFlagsMutuallyExclusive("chart", "repo-path")
 	return command
 }
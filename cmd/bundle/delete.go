@@ -3,8 +3,8 @@ package bundle
 import (
 	"fmt"
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -33,6 +33,9 @@ func deleteBundleCommand() *cobra.Command {
 }
 
 func deleteBundle(config *restclient.Config, ns string, bundleName string) error {
-	kubeClient := kubernetes.NewForConfigOrDie(config)
-	return bundle.Delete(kubeClient, ns, bundleName)
+	cli, err := ctrlruntimeclient.NewClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to get controller runtime client: %s", err)
+	}
+	return bundle.Delete(cli, ns, bundleName)
 }
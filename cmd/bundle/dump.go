@@ -1,16 +1,15 @@
 package bundle
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/spf13/cobra"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -42,25 +41,23 @@ func dumpBundle(config *restclient.Config, ns string, bundleName string) error {
 	if !bundle.IsValidK8sName(bundleName) {
 		return fmt.Errorf("%w: %s", bundle.ErrInvalidName, bundleName)
 	}
-	kubeClient := kubernetes.NewForConfigOrDie(config)
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(ns)
-	secret, err := secretsApi.Get(context.Background(), bundleName, metav1.GetOptions{})
+	cli, err := ctrlruntimeclient.NewClient(config)
 	if err != nil {
-		return fmt.Errorf("failed to get bundle secret: %s", err)
+		return fmt.Errorf("failed to get controller runtime client: %s", err)
 	}
-	if secret.Labels["arlon-type"] != "config-bundle" {
-		return fmt.Errorf("secret is missing expected label")
+	b, err := bundle.Get(context.Background(), cli, ns, bundleName)
+	if err != nil {
+		return err
 	}
-	if secret.Labels["bundle-type"] != "static" {
+	if b.Spec.RepoUrl != "" {
 		return fmt.Errorf("bundle is not of static type")
 	}
-	if secret.Data["data"] == nil {
+	if b.Spec.Data == "" {
 		return fmt.Errorf("bundle has no data")
 	}
-	_, err = io.Copy(os.Stdout, bytes.NewReader(secret.Data["data"]))
+	_, err = io.Copy(os.Stdout, strings.NewReader(b.Spec.Data))
 	if err != nil {
-		return fmt.Errorf("failed to copy secret data: %s", err)
+		return fmt.Errorf("failed to copy bundle data: %s", err)
 	}
 	return nil
 }
@@ -0,0 +1,99 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/argoproj/argo-cd/v2/util/cli"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func historyBundleCommand() *cobra.Command {
+	var clientConfig clientcmd.ClientConfig
+	var ns string
+	command := &cobra.Command{
+		Use:   "history",
+		Short: "List the revisions of a configuration bundle",
+		Long:  "List the revisions of a configuration bundle",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			config, err := clientConfig.ClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get k8s client config: %s", err)
+			}
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			revs, err := bundle.History(context.Background(), ctrlClient, ns, args[0])
+			if err != nil {
+				return err
+			}
+			if len(revs) == 0 {
+				fmt.Println("no revisions found")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintf(w, "VERSION\tCURRENT\tCREATED\tHASH\tCHANGE\n")
+			for _, rev := range revs {
+				current := ""
+				if rev.Current {
+					current = "*"
+				}
+				_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", rev.Version, current,
+					rev.Created.Format("2006-01-02 15:04:05"), shortHash(rev.ContentHash), rev.ChangeCause)
+			}
+			_ = w.Flush()
+			return nil
+		},
+	}
+	clientConfig = cli.AddKubectlFlagsToCmd(command)
+	command.Flags().StringVar(&ns, "ns", "arlon", "the arlon namespace")
+	return command
+}
+
+func rollbackBundleCommand() *cobra.Command {
+	var clientConfig clientcmd.ClientConfig
+	var ns string
+	var version int
+	command := &cobra.Command{
+		Use:   "rollback",
+		Short: "Restore a configuration bundle to a previous revision",
+		Long:  "Restore a configuration bundle to a previous revision. The restored content is recorded as a new revision.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			config, err := clientConfig.ClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get k8s client config: %s", err)
+			}
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			newVersion, err := bundle.Rollback(context.Background(), ctrlClient, ns, args[0], int32(version))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("bundle %s rolled back, now at version %d\n", args[0], newVersion)
+			return nil
+		},
+	}
+	clientConfig = cli.AddKubectlFlagsToCmd(command)
+	command.Flags().StringVar(&ns, "ns", "arlon", "the arlon namespace")
+	command.Flags().IntVar(&version, "to-version", 0, "the version to restore (unspecified implies the version preceding the current one)")
+	return command
+}
+
+// shortHash abbreviates a content hash for display.
+func shortHash(hash string) string {
+	const length = len("sha256:") + 12
+	if len(hash) > length {
+		return hash[:length]
+	}
+	return hash
+}
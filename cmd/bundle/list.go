@@ -3,6 +3,7 @@ package bundle
 import (
 	"fmt"
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/spf13/cobra"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -33,7 +34,11 @@ func listBundlesCommand() *cobra.Command {
 }
 
 func listBundles(config *restclient.Config, ns string) error {
-	bundles, err := bundle.List(config, ns)
+	cli, err := ctrlruntimeclient.NewClient(config)
+	if err != nil {
+		return fmt.Errorf("failed to get controller runtime client: %s", err)
+	}
+	bundles, err := bundle.List(cli, ns)
 	if err != nil {
 		return err
 	}
@@ -42,10 +47,10 @@ func listBundles(config *restclient.Config, ns string) error {
 		return nil
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintf(w, "NAME\tTYPE\tTAGS\tREPO\tPATH\tREVISION\tSRCTYPE\tDESCRIPTION\n")
+	_, _ = fmt.Fprintf(w, "NAME\tTYPE\tVERSION\tTAGS\tREPO\tPATH\tCHART\tREVISION\tSRCTYPE\tDESCRIPTION\n")
 	for _, bundleItm := range bundles {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", bundleItm.Name,
-			bundleItm.Type, bundleItm.Tags, bundleItm.Repo, bundleItm.Path, bundleItm.Revision, bundleItm.SrcType, bundleItm.Description)
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", bundleItm.Name,
+			bundleItm.Type, bundleItm.Version, bundleItm.Tags, bundleItm.Repo, bundleItm.Path, bundleItm.Chart, bundleItm.Revision, bundleItm.SrcType, bundleItm.Description)
 	}
 	_ = w.Flush()
 	return nil
@@ -0,0 +1,49 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argoproj/argo-cd/v2/util/cli"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func migrateBundlesCommand() *cobra.Command {
+	var clientConfig clientcmd.ClientConfig
+	var ns string
+	var deleteSecrets bool
+	command := &cobra.Command{
+		Use:   "migrate",
+		Short: "Convert configuration bundles stored as secrets to Bundle resources",
+		Long:  "Convert configuration bundles stored as secrets by earlier versions of arlon to Bundle resources, along with their revisions",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			config, err := clientConfig.ClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get k8s client config: %s", err)
+			}
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			migrated, err := bundle.Migrate(context.Background(), ctrlClient, ns, deleteSecrets)
+			for _, name := range migrated {
+				fmt.Printf("migrated bundle %s\n", name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(migrated) == 0 {
+				fmt.Println("no bundles to migrate")
+			}
+			return nil
+		},
+	}
+	clientConfig = cli.AddKubectlFlagsToCmd(command)
+	command.Flags().StringVar(&ns, "ns", "arlon", "the arlon namespace")
+	command.Flags().BoolVar(&deleteSecrets, "delete-secrets", false, "delete the secrets of the migrated bundles")
+	return command
+}
@@ -0,0 +1,66 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	gyaml "github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+)
+
+// addDeploySettingsFlags adds the flags controlling the deployment settings
+// of a bundle to a command, and returns a function collecting the settings
+// specified on the command line: those of the settings file, overridden by
+// the individual flags that were set.
+func addDeploySettingsFlags(command *cobra.Command) func() (arlonv1.DeploySettings, error) {
+	var settingsFile string
+	var destNs string
+	var createNs bool
+	var project string
+	var prune bool
+	var selfHeal bool
+	var syncOptions []string
+	var syncWave int32
+	command.Flags().StringVar(&settingsFile, "deploy-settings", "", "YAML file containing the deploy settings of the bundle")
+	command.Flags().StringVar(&destNs, "dest-ns", "", "namespace of the workload cluster to deploy the bundle to (default \"default\")")
+	command.Flags().BoolVar(&createNs, "create-namespace", false, "create the destination namespace if it does not exist")
+	command.Flags().StringVar(&project, "project", "", "Argo CD project of the bundle's application (default \"default\")")
+	command.Flags().BoolVar(&prune, "prune", true, "delete resources removed from the bundle")
+	command.Flags().BoolVar(&selfHeal, "self-heal", false, "revert changes made to the bundle's resources outside of git")
+	command.Flags().StringSliceVar(&syncOptions, "sync-option", nil, "Argo CD sync option, for e.g. ServerSideApply=true (can be repeated)")
+	command.Flags().Int32Var(&syncWave, "sync-wave", 0, "sync wave of the bundle's application")
+	return func() (arlonv1.DeploySettings, error) {
+		var settings arlonv1.DeploySettings
+		if settingsFile != "" {
+			data, err := os.ReadFile(settingsFile)
+			if err != nil {
+				return settings, fmt.Errorf("failed to read deploy settings file: %s", err)
+			}
+			if err := gyaml.Unmarshal(data, &settings); err != nil {
+				return settings, fmt.Errorf("failed to parse deploy settings file: %s", err)
+			}
+		}
+		flags := command.Flags()
+		var fromFlags arlonv1.DeploySettings
+		fromFlags.DestinationNamespace = destNs
+		fromFlags.Project = project
+		if flags.Changed("create-namespace") {
+			fromFlags.CreateNamespace = &createNs
+		}
+		if flags.Changed("prune") {
+			fromFlags.Prune = &prune
+		}
+		if flags.Changed("self-heal") {
+			fromFlags.SelfHeal = &selfHeal
+		}
+		if flags.Changed("sync-option") {
+			fromFlags.SyncOptions = syncOptions
+		}
+		if flags.Changed("sync-wave") {
+			fromFlags.SyncWave = &syncWave
+		}
+		return bundle.MergeDeploySettings(settings, fromFlags), nil
+	}
+}
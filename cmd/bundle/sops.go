@@ -0,0 +1,45 @@
+package bundle
+
+import (
+	"fmt"
+
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/spf13/cobra"
+)
+
+func sopsPluginCommand() *cobra.Command {
+	var argocdNs string
+	var keySecret string
+	var image string
+	var repoServerPatch bool
+	command := &cobra.Command{
+		Use:   "sops-plugin",
+		Short: "Print the Argo CD plugin configuration decrypting SOPS encrypted bundles",
+		Long: `Print the Argo CD plugin configuration decrypting the static bundles encrypted by SOPS with age keys.
+The output is a ConfigMap to apply to the Argo CD namespace, or with --repo-server-patch the patch adding the
+plugin's sidecar to the Argo CD repo server:
+
+  arlon bundle sops-plugin | kubectl apply -f -
+  kubectl -n argocd patch deployment argocd-repo-server --patch "$(arlon bundle sops-plugin --repo-server-patch)"
+
+The age private keys are read from the keys.txt key of the secret specified by --key-secret in the Argo CD namespace.`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			configMap, patch, err := bundle.SopsPluginManifests(argocdNs, keySecret, image)
+			if err != nil {
+				return err
+			}
+			if repoServerPatch {
+				fmt.Print(patch)
+			} else {
+				fmt.Print(configMap)
+			}
+			return nil
+		},
+	}
+	command.Flags().StringVar(&argocdNs, "argocd-ns", "argocd", "the argocd namespace")
+	command.Flags().StringVar(&keySecret, "key-secret", bundle.DefaultSopsKeySecret, "the secret holding the age private keys")
+	command.Flags().StringVar(&image, "image", bundle.DefaultSopsPluginImage, "the image of the plugin's sidecar, which must provide sh and sops")
+	command.Flags().BoolVar(&repoServerPatch, "repo-server-patch", false, "print the patch of the argocd-repo-server deployment instead of the plugin configuration")
+	return command
+}
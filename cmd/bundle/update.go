@@ -4,10 +4,11 @@ import (
 	"fmt"
 
 	"github.com/argoproj/argo-cd/v2/util/cli"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/arlonproj/arlon/pkg/gitrepo"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -18,8 +19,10 @@ func updateBundleCommand() *cobra.Command {
 	var repoUrl string
 	var repoAlias string
 	var repoPath string
+	var chartVersion string
 	var desc string
 	var tags string
+	var getSettings func() (arlonv1.DeploySettings, error)
 	command := &cobra.Command{
 		Use:   "update",
 		Short: "update configuration bundle",
@@ -33,12 +36,19 @@ func updateBundleCommand() *cobra.Command {
 					return err
 				}
 			}
+			settings, err := getSettings()
+			if err != nil {
+				return err
+			}
 			config, err := clientConfig.ClientConfig()
 			if err != nil {
 				return fmt.Errorf("failed to get k8s client config: %s", err)
 			}
-			kubeClient := kubernetes.NewForConfigOrDie(config)
-			return bundle.Update(kubeClient, ns, args[0], fromFile, repoUrl, repoPath, desc, tags)
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			return bundle.Update(ctrlClient, ns, args[0], fromFile, repoUrl, repoPath, chartVersion, desc, tags, &settings)
 		},
 	}
 	clientConfig = cli.AddKubectlFlagsToCmd(command)
@@ -47,8 +57,10 @@ func updateBundleCommand() *cobra.Command {
 	command.Flags().StringVar(&repoUrl, "repo-url", "", "update a dynamic bundle from this repo URL")
 	command.Flags().StringVar(&repoAlias, "repo-alias", "", "the git repository alias to use")
 	command.Flags().StringVar(&repoPath, "repo-path", "", "optional path in repo specified by --from-repo")
+	command.Flags().StringVar(&chartVersion, "chart-version", "", "new chart version of a chart bundle")
 	command.Flags().StringVar(&desc, "desc", "", "description")
 	command.Flags().StringVar(&tags, "tags", "", "comma separated list of tags")
+	getSettings = addDeploySettingsFlags(command)
 	command.MarkFlagsMutuallyExclusive("from-file", "repo-alias", "repo-url")
 	return command
 }
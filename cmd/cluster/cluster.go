@@ -35,6 +35,7 @@ func NewCommand() *cobra.Command {
 	command.AddCommand(deleteClusterCommand())
 	command.AddCommand(ngupdateClusterCommand())
 	command.AddCommand(setAppProfilesCommand())
+	command.AddCommand(upgradeClusterCommand())
 	return command
 }
 
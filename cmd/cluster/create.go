@@ -13,8 +13,10 @@ import (
 	"github.com/arlonproj/arlon/pkg/argocd"
 	bcl "github.com/arlonproj/arlon/pkg/basecluster"
 	"github.com/arlonproj/arlon/pkg/cluster"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	"github.com/arlonproj/arlon/pkg/gitrepo"
 	"github.com/arlonproj/arlon/pkg/profile"
+	gyaml "github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -37,6 +39,7 @@ func createClusterCommand() *cobra.Command {
 	var clusterRepoPath string
 	var clusterName string
 	var overridesPath string
+	var patchesPath string
 	var outputYaml bool
 	var profileName string
 	var gen2CASEnabled bool //gen2 specific flag to enable cluster autoscaler
@@ -63,26 +66,53 @@ func createClusterCommand() *cobra.Command {
 				return fmt.Errorf("failed to get repository credentials: %s", err)
 			}
 			overridden := false
-			if overridesPath != "" {
+			if overridesPath != "" || patchesPath != "" {
 				_, err = appIf.Get(context.Background(),
 					&argoapp.ApplicationQuery{Name: &clusterName})
 				if err == nil {
 					return fmt.Errorf("arlon cluster already exists")
 				}
-				patchContent, err := os.ReadFile(overridesPath)
+				var patchContent []byte
+				if overridesPath != "" {
+					patchContent, err = os.ReadFile(overridesPath)
+					if err != nil {
+						return fmt.Errorf("failed to read patch file: %s", err)
+					}
+				}
+				var patches []arlonv1.OverridePatch
+				if patchesPath != "" {
+					data, err := os.ReadFile(patchesPath)
+					if err != nil {
+						return fmt.Errorf("failed to read patches file: %s", err)
+					}
+					if err := gyaml.Unmarshal(data, &patches); err != nil {
+						return fmt.Errorf("failed to parse patches file: %s", err)
+					}
+				}
+				err = bcl.ValidatePatchesFromGitDir(creds, clusterRepoUrl, clusterRepoRevision,
+					clusterRepoPath, patchContent, patches)
 				if err != nil {
-					return fmt.Errorf("failed to read patch file: %s", err)
+					return fmt.Errorf("override patches do not apply to the cluster template: %s", err)
 				}
-				err = cluster.CreatePatchDir(config, clusterName, patchRepoUrl, argocdNs,
-					patchRepoPath, patchRepoRevision, clusterRepoRevision, patchContent, clusterRepoUrl, clusterRepoPath)
+				prUrl, err := cluster.CreatePatchDir(config, clusterName, patchRepoUrl, argocdNs,
+					patchRepoPath, patchRepoRevision, clusterRepoRevision, patchContent, patches, nil,
+					clusterRepoUrl, clusterRepoPath)
 				if err != nil {
 					return fmt.Errorf("failed to create patch files directory: %s", err)
 				}
+				if prUrl != "" {
+					fmt.Println("opened pull request:", prUrl)
+				}
 				overridden = true
 			}
 			createInArgoCd := !outputYaml
+			cli, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
 			baseClusterName, err := bcl.ValidateGitDir(creds,
-				clusterRepoUrl, clusterRepoRevision, clusterRepoPath)
+				clusterRepoUrl, clusterRepoRevision, clusterRepoPath,
+				bcl.NewObjectGetter(cli, clusterName))
 			if err != nil {
 				return fmt.Errorf("failed to validate cluster template: %s", err)
 			}
@@ -98,7 +128,7 @@ func createClusterCommand() *cobra.Command {
 				}
 			}
 			// Create "arlon app" for cluster
-			arlonApp, err := cluster.Create(appIf, config, argocdNs, arlonNs,
+			arlonApp, _, err := cluster.Create(appIf, config, argocdNs, arlonNs,
 				clusterName, baseClusterName, arlonRepoUrl, arlonRepoRevision,
 				arlonRepoPath, "",
 				nil, createInArgoCd, config.Host, gen2CASEnabled)
@@ -173,6 +203,7 @@ func createClusterCommand() *cobra.Command {
 	command.Flags().StringVar(&clusterRepoPath, "repo-path", "", "the git repository path for cluster template")
 	command.Flags().StringVar(&clusterName, "cluster-name", "", "the cluster name")
 	command.Flags().StringVar(&overridesPath, "overrides-path", "", "path to the corresponding patch file to the cluster")
+	command.Flags().StringVar(&patchesPath, "patches-path", "", "path to a YAML list of override patches of the cluster, each with a patch, an optional type (StrategicMerge or JSON6902) and an optional target")
 	command.Flags().BoolVar(&outputYaml, "output-yaml", false, "output root applications YAML instead of deploying to ArgoCD")
 	command.Flags().StringVar(&profileName, "profile", "", "profile name (if specified, must refer to dynamic profile)")
 	command.Flags().BoolVar(&gen2CASEnabled, "autoscaler", false, "enable CAPI cluster autoscaler for cluster template based clusters")
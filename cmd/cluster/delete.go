@@ -26,10 +26,13 @@ func deleteClusterCommand() *cobra.Command {
 				return fmt.Errorf("failed to delete k8s client config: %s", err)
 			}
 			clusterName := args[0]
-			err = cluster.Delete(argoIf, config, argocdNs, clusterName)
+			prUrl, err := cluster.Delete(argoIf, config, argocdNs, clusterName)
 			if err != nil {
 				return fmt.Errorf("failed to delete cluster: %s", err)
 			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
 			return nil
 		},
 	}
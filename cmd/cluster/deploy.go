@@ -65,12 +65,15 @@ func deployClusterCommand() *cobra.Command {
 					return fmt.Errorf("gen2 clusters currently don't have profiles (coming soon)")
 				}
 			}
-			rootApp, err := cluster.Create(appIf, config, argocdNs, arlonNs,
+			rootApp, prUrl, err := cluster.Create(appIf, config, argocdNs, arlonNs,
 				clusterName, "", repoUrl, repoBranch, basePath, clusterSpecName,
 				prof, createInArgoCd, config.Host, false)
 			if err != nil {
 				return fmt.Errorf("failed to create cluster: %s", err)
 			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
 			if outputYaml {
 				scheme := runtime.NewScheme()
 				if err := v1alpha1.AddToScheme(scheme); err != nil {
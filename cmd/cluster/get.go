@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/argoproj/argo-cd/v2/util/cli"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/cluster"
 	"github.com/spf13/cobra"
@@ -33,6 +34,16 @@ func getClusterCommand() *cobra.Command {
 				return fmt.Errorf("failed to get cluster: %s", err)
 			}
 			fmt.Println(clust)
+			if clust.BaseCluster == nil {
+				return nil
+			}
+			status, err := cluster.GetClusterResourceStatus(config, arlonNs, clusterName)
+			if err != nil {
+				return fmt.Errorf("failed to get cluster status: %s", err)
+			}
+			if status != nil {
+				printClusterStatus(status)
+			}
 			return nil
 		},
 	}
@@ -41,3 +52,23 @@ func getClusterCommand() *cobra.Command {
 	command.Flags().StringVar(&arlonNs, "arlon-ns", "arlon", "the arlon namespace")
 	return command
 }
+
+func printClusterStatus(status *arlonv1.ClusterStatus) {
+	prov := status.Provisioning
+	fmt.Println("State:", status.State)
+	if status.Message != "" {
+		fmt.Println("Message:", status.Message)
+	}
+	fmt.Println("Provisioning phase:", prov.Phase)
+	fmt.Println("Infrastructure ready:", prov.InfrastructureReady)
+	fmt.Println("Control plane ready:", prov.ControlPlaneReady)
+	fmt.Printf("Workers: %d desired, %d ready, %d available, %d updated\n",
+		prov.Workers.Desired, prov.Workers.Ready, prov.Workers.Available,
+		prov.Workers.Updated)
+	fmt.Printf("Cluster app: %s, %s\n", prov.ClusterApp.Sync, prov.ClusterApp.Health)
+	fmt.Printf("Arlon app: %s, %s\n", prov.ArlonApp.Sync, prov.ArlonApp.Health)
+	for _, cond := range status.Conditions {
+		fmt.Printf("Condition %s: %s (%s) %s\n", cond.Type, cond.Status,
+			cond.Reason, cond.Message)
+	}
+}
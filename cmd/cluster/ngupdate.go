@@ -3,12 +3,14 @@ package cluster
 import (
 	"context"
 	"fmt"
+	"os"
 
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	"github.com/argoproj/argo-cd/v2/util/cli"
 	"github.com/argoproj/argo-cd/v2/util/io"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/cluster"
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -19,6 +21,7 @@ func ngupdateClusterCommand() *cobra.Command {
 	var arlonNs string
 	var profileName string
 	var deleteProfileName bool
+	var planOnly bool
 	command := &cobra.Command{
 		Use:   "ngupdate <clustername> [flags]",
 		Short: "update existing next-gen cluster",
@@ -41,8 +44,19 @@ func ngupdateClusterCommand() *cobra.Command {
 			if len(apps.Items) == 0 {
 				return fmt.Errorf("failed to get the given cluster")
 			}
+			if planOnly {
+				if deleteProfileName {
+					return fmt.Errorf("--plan cannot be combined with --delete-profile")
+				}
+				pl := &plan.Plan{}
+				_, err = cluster.NgUpdate(appIf, config, argocdNs, arlonNs, name, profileName, true, pl)
+				if err != nil {
+					return fmt.Errorf("error: %s", err)
+				}
+				return pl.Print(os.Stdout)
+			}
 			if !deleteProfileName {
-				_, err = cluster.NgUpdate(appIf, config, argocdNs, arlonNs, name, profileName, true)
+				_, err = cluster.NgUpdate(appIf, config, argocdNs, arlonNs, name, profileName, true, nil)
 				if err != nil {
 
 					return fmt.Errorf("error: %s", err)
@@ -61,5 +75,6 @@ func ngupdateClusterCommand() *cobra.Command {
 	command.Flags().StringVar(&arlonNs, "arlon-ns", "arlon", "the arlon namespace")
 	command.Flags().StringVar(&profileName, "profile", "", "the configuration profile to use")
 	command.Flags().BoolVar(&deleteProfileName, "delete-profile", false, "delete the existing profile app from the cluster")
+	command.Flags().BoolVar(&planOnly, "plan", false, "output the diff of the changes to the profile apps instead of making them")
 	return command
 }
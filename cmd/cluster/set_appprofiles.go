@@ -1,14 +1,24 @@
 package cluster
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
+	"os"
+
+	"github.com/argoproj/argo-cd/v2/util/cli"
+	"github.com/arlonproj/arlon/pkg/appprofile"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/cluster"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func setAppProfilesCommand() *cobra.Command {
+	var clientConfig clientcmd.ClientConfig
+	var planOnly bool
 	command := &cobra.Command{
 		Use:   "setappprofiles <clustername> <comma_separated_app_profiles>",
 		Short: "set a cluster's list of application profiles ",
@@ -20,12 +30,35 @@ func setAppProfilesCommand() *cobra.Command {
 			defer conn.Close()
 			clusterName := args[0]
 			commaSeparatedAppProfiles := args[1]
-			err := cluster.SetAppProfiles(appIf, clusterName, commaSeparatedAppProfiles)
+			if planOnly {
+				config, err := clientConfig.ClientConfig()
+				if err != nil {
+					return fmt.Errorf("failed to get k8s client config: %s", err)
+				}
+				cli, err := ctrlruntimeclient.NewClient(config)
+				if err != nil {
+					return fmt.Errorf("failed to get controller runtime client: %s", err)
+				}
+				pl := &plan.Plan{}
+				err = cluster.SetAppProfiles(appIf, clusterName, commaSeparatedAppProfiles, pl)
+				if err != nil {
+					return fmt.Errorf("failed to plan cluster's app profiles list: %s", err)
+				}
+				err = appprofile.PlanMembership(context.Background(), cli, argoIf,
+					clusterName, commaSeparatedAppProfiles, pl)
+				if err != nil {
+					return fmt.Errorf("failed to plan apps of the cluster: %s", err)
+				}
+				return pl.Print(os.Stdout)
+			}
+			err := cluster.SetAppProfiles(appIf, clusterName, commaSeparatedAppProfiles, nil)
 			if err != nil {
 				return fmt.Errorf("failed to set cluster's app profiles list: %s", err)
 			}
 			return nil
 		},
 	}
+	clientConfig = cli.AddKubectlFlagsToCmd(command)
+	command.Flags().BoolVar(&planOnly, "plan", false, "output the diff of the changes to the cluster and to the clusters of apps instead of making them")
 	return command
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/argoproj/argo-cd/v2/util/cli"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/cluster"
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/arlonproj/arlon/pkg/profile"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -26,6 +27,7 @@ func updateClusterCommand() *cobra.Command {
 	var clusterSpecName string
 	var profileName string
 	var outputYaml bool
+	var planOnly bool
 	command := &cobra.Command{
 		Use:   "update <clustername> [flags]",
 		Short: "update existing cluster",
@@ -45,6 +47,9 @@ func updateClusterCommand() *cobra.Command {
 				return fmt.Errorf("failed to update cluster: %s", err)
 			}
 			if clust.IsExternal {
+				if planOnly {
+					return fmt.Errorf("--plan is not supported for external clusters")
+				}
 				if clusterSpecName != "" {
 					return fmt.Errorf("external cluster cannot accept a cluster spec")
 				}
@@ -69,12 +74,22 @@ func updateClusterCommand() *cobra.Command {
 				return nil
 			}
 			updateInArgoCd := !outputYaml
-			rootApp, err := cluster.Update(appIf, config, argocdNs, arlonNs,
+			var pl *plan.Plan
+			if planOnly {
+				pl = &plan.Plan{}
+			}
+			rootApp, prUrl, err := cluster.Update(appIf, config, argocdNs, arlonNs,
 				clusterName, clusterSpecName, profileName, updateInArgoCd,
-				config.Host)
+				config.Host, pl)
 			if err != nil {
 				return fmt.Errorf("failed to update cluster: %s", err)
 			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
+			if pl != nil {
+				return pl.Print(os.Stdout)
+			}
 			if outputYaml {
 				scheme := runtime.NewScheme()
 				if err := v1alpha1.AddToScheme(scheme); err != nil {
@@ -100,5 +115,6 @@ func updateClusterCommand() *cobra.Command {
 	command.Flags().StringVar(&profileName, "profile", "", "the configuration profile to use")
 	command.Flags().StringVar(&clusterSpecName, "cluster-spec", "", "the clusterspec to use")
 	command.Flags().BoolVar(&outputYaml, "output-yaml", false, "output root application YAML instead of updating ArgoCD root app")
+	command.Flags().BoolVar(&planOnly, "plan", false, "output the diff of the changes to git and ArgoCD instead of making them")
 	return command
 }
@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/util/cli"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/cluster"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func upgradeClusterCommand() *cobra.Command {
+	var clientConfig clientcmd.ClientConfig
+	var arlonNs string
+	var selector string
+	var revision string
+	var waveSize int32
+	var progressDeadline time.Duration
+	var pause bool
+	var resume bool
+	var watch bool
+	command := &cobra.Command{
+		Use:   "upgrade <rolloutname> [flags]",
+		Short: "move clusters to a new cluster template revision in waves",
+		Long: "move the declarative clusters matching a label selector to a new cluster template revision " +
+			"in waves, waiting for each wave to be healthy before starting the next one. " +
+			"Without --revision, shows the progress of an existing rollout, " +
+			"or pauses or resumes it with --pause and --resume.",
+		Example: "arlon cluster upgrade prod-v2 --selector env=prod --revision v2 --wave-size 3",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			config, err := clientConfig.ClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to get k8s client config: %s", err)
+			}
+			ctrlClient, err := ctrlruntimeclient.NewClient(config)
+			if err != nil {
+				return fmt.Errorf("failed to get controller runtime client: %s", err)
+			}
+			ctx := context.Background()
+			name := args[0]
+			switch {
+			case pause && resume:
+				return fmt.Errorf("--pause and --resume are mutually exclusive")
+			case revision != "":
+				if pause || resume {
+					return fmt.Errorf("--pause and --resume only apply to an existing rollout")
+				}
+				if selector == "" {
+					return fmt.Errorf("--selector is required to start a rollout")
+				}
+				_, err = cluster.CreateRollout(ctx, ctrlClient, arlonNs, name, selector,
+					revision, waveSize, progressDeadline)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("cluster rollout %s created\n", name)
+			case pause || resume:
+				err = cluster.SetRolloutPaused(ctx, ctrlClient, arlonNs, name, pause)
+				if err != nil {
+					return err
+				}
+			}
+			if watch {
+				return watchRollout(ctx, ctrlClient, arlonNs, name)
+			}
+			ro, err := cluster.GetRollout(ctx, ctrlClient, arlonNs, name)
+			if err != nil {
+				return fmt.Errorf("failed to get cluster rollout: %s", err)
+			}
+			printRollout(ro)
+			return nil
+		},
+	}
+	clientConfig = cli.AddKubectlFlagsToCmd(command)
+	command.Flags().StringVar(&arlonNs, "arlon-ns", "arlon", "the arlon namespace")
+	command.Flags().StringVarP(&selector, "selector", "l", "", "label selector of the clusters to upgrade")
+	command.Flags().StringVar(&revision, "revision", "", "the cluster template revision to move the clusters to")
+	command.Flags().Int32Var(&waveSize, "wave-size", 1, "the number of clusters upgraded at the same time")
+	command.Flags().DurationVar(&progressDeadline, "progress-deadline", cluster.DefaultRolloutProgressDeadline,
+		"the time a wave has to become healthy before the rollout is paused")
+	command.Flags().BoolVar(&pause, "pause", false, "pause the rollout before its next wave")
+	command.Flags().BoolVar(&resume, "resume", false, "resume a paused rollout, retrying its failed wave")
+	command.Flags().BoolVar(&watch, "watch", false, "wait until the rollout is completed or paused, printing its progress")
+	return command
+}
+
+func watchRollout(ctx context.Context, ctrlClient client.Client, ns string, name string) error {
+	lastMsg := ""
+	for {
+		ro, err := cluster.GetRollout(ctx, ctrlClient, ns, name)
+		if err != nil {
+			return fmt.Errorf("failed to get cluster rollout: %s", err)
+		}
+		upToDate := ro.Status.ObservedGeneration == ro.Generation
+		if upToDate && ro.Status.Message != lastMsg {
+			fmt.Printf("%s: %s\n", ro.Status.Phase, ro.Status.Message)
+			lastMsg = ro.Status.Message
+		}
+		if upToDate && (ro.Status.Phase == arlonv1.RolloutPhaseCompleted ||
+			ro.Status.Phase == arlonv1.RolloutPhasePaused) {
+			printRollout(ro)
+			if ro.Status.Phase == arlonv1.RolloutPhasePaused {
+				return fmt.Errorf("cluster rollout %s is paused", name)
+			}
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func printRollout(ro *arlonv1.ClusterRollout) {
+	st := &ro.Status
+	fmt.Println("Target revision:", ro.Spec.TargetRevision)
+	fmt.Println("Phase:", st.Phase)
+	if st.Message != "" {
+		fmt.Println("Message:", st.Message)
+	}
+	fmt.Printf("Wave: %d of %d\n", st.CurrentWave, st.Waves)
+	for _, rcs := range st.Clusters {
+		fmt.Printf("Cluster %s: wave %d, %s (from %s) %s\n", rcs.Name, rcs.Wave,
+			rcs.State, rcs.PreviousRevision, rcs.Message)
+	}
+}
@@ -3,6 +3,7 @@ package clustercontroller
 import (
 	"github.com/argoproj/argo-cd/v2/util/cli"
 	"github.com/arlonproj/arlon/pkg/controller"
+	"github.com/arlonproj/arlon/pkg/gitcache"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -37,5 +38,12 @@ func NewCommand() *cobra.Command {
 	command.Flags().BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	command.Flags().StringVar(&gitcache.Dir, "git-cache-dir", gitcache.Dir, "The directory of the local cache of git repositories.")
+	command.Flags().Int64Var(&gitcache.MaxSize, "git-cache-max-size", gitcache.MaxSize,
+		"The size in bytes above which the least recently used repositories are evicted from the git cache.")
+	command.Flags().IntVar(&gitcache.DefaultDepth, "git-fetch-depth", gitcache.DefaultDepth,
+		"The number of commits fetched into the git cache. Zero fetches the full history.")
+	command.Flags().BoolVar(&gitcache.Disabled, "disable-git-cache", gitcache.Disabled,
+		"Clone git repositories on every operation instead of using the git cache.")
 	return command
 }
@@ -68,6 +68,23 @@ const (
 	defaultK8sVersion                                = "v1.23.14"
 )
 
+// applicationHealthCheck restores the health assessment of Argo CD
+// Applications, so that the sync waves of the applications of bundles with
+// dependencies wait for the applications of their dependencies to be healthy.
+const applicationHealthCheck = `hs = {}
+hs.status = "Progressing"
+hs.message = ""
+if obj.status ~= nil then
+  if obj.status.health ~= nil then
+    hs.status = obj.status.health.status
+    if obj.status.health.message ~= nil then
+      hs.message = obj.status.health.message
+    end
+  end
+end
+return hs
+`
+
 type porfForwardCfg struct {
 	ctx        context.Context
 	hostPort   uint16
@@ -335,6 +352,7 @@ func beginArlonInstall(ctx context.Context, client k8sclient.Client, kubeClient
 	}
 	argoCm.Data = map[string]string{
 		"accounts.arlon": "apiKey, login",
+		"resource.customizations.health.argoproj.io_Application": applicationHealthCheck,
 	}
 	argoRbacCm, err := kubeClient.CoreV1().ConfigMaps(argoNs).Get(ctx, "argocd-rbac-cm", metav1.GetOptions{})
 	if err != nil {
@@ -363,12 +381,15 @@ func beginArlonInstall(ctx context.Context, client k8sclient.Client, kubeClient
 		config.CRDClusterReg,
 		config.CRDCallHomeConfig,
 		config.CRDAppProfile,
+		config.CRDBundle,
+		config.CRDBundleRevision,
 	}
 	deplManifests := [][]byte{
 		deploy.YAMLdeploy,
 		deploy.YAMLrbacCHC,
 		deploy.YAMLrbacClusterReg,
 		deploy.YAMLrbacAppProf,
+		deploy.YAMLrbacBundle,
 	}
 	decodedCrds := [][]*unstructured.Unstructured{}
 	for _, crd := range crds {
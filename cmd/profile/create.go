@@ -5,8 +5,11 @@ import (
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/gitrepo"
 	"github.com/arlonproj/arlon/pkg/profile"
+	gyaml "github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -25,6 +28,11 @@ func createProfileCommand() *cobra.Command {
 	var repoBasePath string
 	var repoBranch string
 	var overrides []string
+	var values []string
+	var images []string
+	var overridesFile string
+	var pins []string
+	var dependencies []string
 	command := &cobra.Command{
 		Use:   "create",
 		Short: "Create profile",
@@ -46,8 +54,28 @@ func createProfileCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to process overrides: %s", err)
 			}
-			return profile.Create(config, argocdNs, arlonNs, args[0], repoUrl,
-				repoBasePath, repoBranch, bundles, desc, tags, o)
+			typed, err := processTypedOverrides(values, images, overridesFile)
+			if err != nil {
+				return fmt.Errorf("failed to process overrides: %s", err)
+			}
+			o = append(o, typed...)
+			versions, err := processPins(pins)
+			if err != nil {
+				return fmt.Errorf("failed to process pinned versions: %s", err)
+			}
+			deps, err := processDependencies(dependencies)
+			if err != nil {
+				return fmt.Errorf("failed to process dependencies: %s", err)
+			}
+			prUrl, err := profile.Create(config, argocdNs, arlonNs, args[0], repoUrl,
+				repoBasePath, repoBranch, bundles, desc, tags, o, versions, deps)
+			if err != nil {
+				return err
+			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
+			return nil
 		},
 	}
 	clientConfig = cli.AddKubectlFlagsToCmd(command)
@@ -61,7 +89,12 @@ func createProfileCommand() *cobra.Command {
 	command.Flags().StringVar(&repoAlias, "repo-alias", gitrepo.RepoDefaultCtx, "the git repository alias to use")
 	command.Flags().StringVar(&repoBasePath, "repo-base-path", "profiles", "optional git base path for dynamic profile. The profile directory will be created under this.")
 	command.Flags().StringVar(&repoBranch, "repo-branch", "main", "optional git branch for dynamic profile (requires --repo-url)")
-	command.Flags().StringArrayVarP(&overrides, "param", "p", nil, "a single parameter override of the form bundle,key,value ... can be repeated")
+	command.Flags().StringArrayVarP(&overrides, "param", "p", nil, "a single Helm parameter override of the form bundle,key,value ... can be repeated")
+	command.Flags().StringArrayVar(&values, "values", nil, "override the Helm values of a bundle with a YAML file, of the form bundle=file ... can be repeated")
+	command.Flags().StringArrayVar(&images, "image", nil, "a single kustomize image override of the form bundle=[name=]newName[:newTag|@digest] ... can be repeated")
+	command.Flags().StringVar(&overridesFile, "overrides-file", "", "YAML file with a list of overrides, including kustomize patches")
+	command.Flags().StringArrayVar(&pins, "pin", nil, "pin a bundle to a version, of the form bundle=version ... can be repeated")
+	command.Flags().StringArrayVar(&dependencies, "depends-on", nil, "deploy a bundle after the bundles it depends on, of the form bundle=dependency1,dependency2 ... can be repeated")
 	command.MarkFlagsMutuallyExclusive("static", "repo-url", "repo-alias")
 	_ = command.MarkFlagRequired("bundles")
 	return command
@@ -81,3 +114,86 @@ func processOverrides(overrides []string) (res []arlonv1.Override, err error) {
 	}
 	return
 }
+
+// processTypedOverrides returns the overrides setting the Helm values of
+// bundles from files, of the form bundle=file, and the kustomize images of
+// bundles, of the form bundle=image, followed by the overrides of a file.
+func processTypedOverrides(values []string, images []string, overridesFile string) (res []arlonv1.Override, err error) {
+	for _, v := range values {
+		items := strings.SplitN(v, "=", 2)
+		if len(items) != 2 || items[0] == "" || items[1] == "" {
+			return nil, fmt.Errorf("malformed values override, it should be of the form bundle=file")
+		}
+		data, err := os.ReadFile(items[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %s", err)
+		}
+		res = append(res, arlonv1.Override{Bundle: items[0], Values: string(data)})
+	}
+	// the images of a bundle are set by a single override
+	imageOverrides := make(map[string]*arlonv1.Override)
+	var bundles []string
+	for _, i := range images {
+		items := strings.SplitN(i, "=", 2)
+		if len(items) != 2 || items[0] == "" || items[1] == "" {
+			return nil, fmt.Errorf("malformed image override, it should be of the form bundle=image")
+		}
+		o := imageOverrides[items[0]]
+		if o == nil {
+			o = &arlonv1.Override{Bundle: items[0]}
+			imageOverrides[items[0]] = o
+			bundles = append(bundles, items[0])
+		}
+		o.Images = append(o.Images, items[1])
+	}
+	for _, name := range bundles {
+		res = append(res, *imageOverrides[name])
+	}
+	if overridesFile != "" {
+		data, err := os.ReadFile(overridesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read overrides file: %s", err)
+		}
+		var fileOverrides []arlonv1.Override
+		if err := gyaml.Unmarshal(data, &fileOverrides); err != nil {
+			return nil, fmt.Errorf("failed to parse overrides file: %s", err)
+		}
+		res = append(res, fileOverrides...)
+	}
+	return
+}
+
+func processPins(pins []string) (res []arlonv1.BundleVersion, err error) {
+	for _, p := range pins {
+		items := strings.Split(p, "=")
+		if len(items) != 2 {
+			return nil, fmt.Errorf("malformed pinned version, it should be of the form bundle=version")
+		}
+		version, err := strconv.ParseInt(items[1], 10, 32)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid version %s for bundle %s", items[1], items[0])
+		}
+		res = append(res, arlonv1.BundleVersion{
+			Bundle:  items[0],
+			Version: int32(version),
+		})
+	}
+	return
+}
+
+func processDependencies(dependencies []string) (res []arlonv1.BundleDependency, err error) {
+	for _, d := range dependencies {
+		items := strings.Split(d, "=")
+		if len(items) != 2 || items[0] == "" {
+			return nil, fmt.Errorf("malformed dependency, it should be of the form bundle=dependency1,dependency2")
+		}
+		dep := arlonv1.BundleDependency{Bundle: items[0], DependsOn: []string{}}
+		for _, name := range strings.Split(items[1], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				dep.DependsOn = append(dep.DependsOn, name)
+			}
+		}
+		res = append(res, dep)
+	}
+	return
+}
@@ -2,6 +2,9 @@ package profile
 
 import (
 	"fmt"
+	"os"
+
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/arlonproj/arlon/pkg/profile"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
@@ -18,6 +21,13 @@ func updateProfileCommand() *cobra.Command {
 	var tags string
 	var clear bool
 	var overrides []string
+	var values []string
+	var images []string
+	var overridesFile string
+	var pins []string
+	var unpins []string
+	var dependencies []string
+	var planOnly bool
 	command := &cobra.Command{
 		Use:   "update",
 		Short: "Update profile",
@@ -42,11 +52,34 @@ func updateProfileCommand() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to process overrides: %s", err)
 			}
-			modified, err := profile.Update(config, argocdNs, arlonNs, args[0],
-				bundlesPtr, desc, tags, o)
+			typed, err := processTypedOverrides(values, images, overridesFile)
+			if err != nil {
+				return fmt.Errorf("failed to process overrides: %s", err)
+			}
+			o = append(o, typed...)
+			versions, err := processPins(pins)
+			if err != nil {
+				return fmt.Errorf("failed to process pinned versions: %s", err)
+			}
+			deps, err := processDependencies(dependencies)
+			if err != nil {
+				return fmt.Errorf("failed to process dependencies: %s", err)
+			}
+			var pl *plan.Plan
+			if planOnly {
+				pl = &plan.Plan{}
+			}
+			modified, prUrl, err := profile.Update(config, argocdNs, arlonNs, args[0],
+				bundlesPtr, desc, tags, o, versions, unpins, deps, pl)
 			if err != nil {
 				return err
 			}
+			if prUrl != "" {
+				fmt.Println("opened pull request:", prUrl)
+			}
+			if pl != nil {
+				return pl.Print(os.Stdout)
+			}
 			if !modified {
 				fmt.Println("profile not modified")
 			}
@@ -60,6 +93,13 @@ func updateProfileCommand() *cobra.Command {
 	command.Flags().StringSliceVar(&bundles, "bundles", nil, "comma separated list of bundles")
 	command.Flags().StringVar(&tags, "tags", "", "comma separated list of tags")
 	command.Flags().BoolVar(&clear, "clear", false, "set the bundle list to the empty set")
-	command.Flags().StringArrayVarP(&overrides, "param", "p", nil, "add a single parameter override of the form bundle,key,value ... can be repeated")
+	command.Flags().StringArrayVarP(&overrides, "param", "p", nil, "add a single Helm parameter override of the form bundle,key,value ... can be repeated")
+	command.Flags().StringArrayVar(&values, "values", nil, "replace the Helm values of a bundle with a YAML file, of the form bundle=file ... can be repeated")
+	command.Flags().StringArrayVar(&images, "image", nil, "a single kustomize image override of the form bundle=[name=]newName[:newTag|@digest], replacing the images and patches of the bundle ... can be repeated")
+	command.Flags().StringVar(&overridesFile, "overrides-file", "", "YAML file with a list of overrides, including kustomize patches")
+	command.Flags().StringArrayVar(&pins, "pin", nil, "pin a bundle to a version, of the form bundle=version ... can be repeated")
+	command.Flags().StringSliceVar(&unpins, "unpin", nil, "comma separated list of bundles to use at their latest version")
+	command.Flags().StringArrayVar(&dependencies, "depends-on", nil, "replace the dependencies of a bundle, of the form bundle=dependency1,dependency2 (no dependency removes them) ... can be repeated")
+	command.Flags().BoolVar(&planOnly, "plan", false, "output the diff of the changes to the profile and to git instead of making them")
 	return command
 }
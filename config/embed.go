@@ -11,4 +11,8 @@ var (
 	CRDProfile []byte
 	//go:embed crd/bases/core.arlon.io_appprofiles.yaml
 	CRDAppProfile []byte
+	//go:embed crd/bases/core.arlon.io_bundles.yaml
+	CRDBundle []byte
+	//go:embed crd/bases/core.arlon.io_bundlerevisions.yaml
+	CRDBundleRevision []byte
 )
@@ -19,6 +19,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"reflect"
+
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	argoclient "github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
@@ -27,8 +29,11 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 // ApplicationReconciler reconciles a AppProfile object
@@ -59,7 +64,7 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 // SetupWithManager sets up the controller with the Manager.
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&argoapp.Application{}).
+		For(&argoapp.Application{}, builder.WithPredicates(applicationChangedPredicate)).
 		Complete(r)
 }
 
@@ -82,6 +87,11 @@ func reconcileApplication(
 			return ctrl.Result{Requeue: true}, nil
 		}
 	}
+	if isGeneratedByApplicationSet(&app) {
+		// The state of the app is reported in the status of app profiles
+		log.V(1).Info("reconciling application generated by applicationset")
+		return appprofile.ReconcileEverything(ctx, cli, argocli, log)
+	}
 	if app.Labels == nil || app.Labels["arlon-type"] != "cluster-app" {
 		log.V(1).Info("application is not an arlon cluster, skipping...")
 		return ctrl.Result{}, nil
@@ -89,3 +99,38 @@ func reconcileApplication(
 	log.V(1).Info("reconciling application implementing arlon cluster")
 	return appprofile.ReconcileEverything(ctx, cli, argocli, log)
 }
+
+func isGeneratedByApplicationSet(app *argoapp.Application) bool {
+	for _, owner := range app.OwnerReferences {
+		if owner.Kind == "ApplicationSet" {
+			return true
+		}
+	}
+	return false
+}
+
+// applicationChangedPredicate ignores the frequent status updates of
+// applications that change neither their sync nor their health status.
+var applicationChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldApp, ok := e.ObjectOld.(*argoapp.Application)
+		newApp, ok2 := e.ObjectNew.(*argoapp.Application)
+		if !ok || !ok2 {
+			return true
+		}
+		return oldApp.Generation != newApp.Generation ||
+			!reflect.DeepEqual(oldApp.Labels, newApp.Labels) ||
+			!reflect.DeepEqual(oldApp.Annotations, newApp.Annotations) ||
+			oldApp.Status.Sync.Status != newApp.Status.Sync.Status ||
+			oldApp.Status.Health.Status != newApp.Status.Health.Status ||
+			len(oldApp.Status.Conditions) != len(newApp.Status.Conditions) ||
+			operationPhase(oldApp) != operationPhase(newApp)
+	},
+}
+
+func operationPhase(app *argoapp.Application) string {
+	if app.Status.OperationState == nil {
+		return ""
+	}
+	return string(app.Status.OperationState.Phase)
+}
@@ -23,8 +23,12 @@ import (
 	"github.com/arlonproj/arlon/pkg/appprofile"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // AppProfileReconciler reconciles a AppProfile object
@@ -37,6 +41,7 @@ type AppProfileReconciler struct {
 //+kubebuilder:rbac:groups=core.arlon.io,resources=appprofiles,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core.arlon.io,resources=appprofiles/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core.arlon.io,resources=appprofiles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core.arlon.io,resources=clusters,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -59,5 +64,12 @@ func (r *AppProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 func (r *AppProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.AppProfile{}).
+		// Profiles may select clusters by label, and clusters may supply
+		// Helm values to their apps. Any request reconciles every profile,
+		// so the cluster name is enqueued as is.
+		Watches(&source.Kind{Type: &corev1.Cluster{}},
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{},
+				predicate.GenerationChangedPredicate{}))).
 		Complete(r)
 }
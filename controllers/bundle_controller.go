@@ -0,0 +1,72 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// BundleReconciler reconciles a Bundle object
+type BundleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=core.arlon.io,resources=bundles,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core.arlon.io,resources=bundles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core.arlon.io,resources=bundlerevisions,verbs=get;list;watch;create;delete
+
+// Reconcile records a revision of a Bundle whose content was changed
+// without the arlon CLI, for e.g. with kubectl or by a GitOps tool.
+func (r *BundleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).Info("arlon Bundle")
+	var b arlonv1.Bundle
+	if err := r.Get(ctx, req.NamespacedName, &b); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("bundle is gone -- ok")
+			return ctrl.Result{}, nil
+		}
+		log.Info(fmt.Sprintf("unable to get bundle (%s) ... requeuing", err))
+		return ctrl.Result{Requeue: true}, nil
+	}
+	recorded, err := bundle.RecordRevision(ctx, r.Client, &b)
+	if err != nil {
+		log.Error(err, "unable to record bundle revision")
+		return ctrl.Result{}, err
+	}
+	if recorded {
+		log.Info(fmt.Sprintf("recorded version %d of bundle", b.Status.Version))
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&arlonv1.Bundle{}).
+		Complete(r)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/common"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBundleOutOfBandChange(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, arlonv1.AddToScheme(scheme))
+	b := &arlonv1.Bundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "arlon"},
+		Spec:       arlonv1.BundleSpec{Data: "replicas: 1"},
+	}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(b).Build()
+	r := &BundleReconciler{Client: cli, Scheme: scheme}
+	ctx := context.Background()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "arlon", Name: "guestbook"}}
+
+	// a bundle created with kubectl gets its first revision
+	_, err := r.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	revs, err := bundle.History(ctx, cli, "arlon", "guestbook")
+	assert.NoError(t, err)
+	assert.Len(t, revs, 1)
+
+	// a spec change made with kubectl or a GitOps tool records a revision
+	// with the change cause of the annotation
+	assert.NoError(t, cli.Get(ctx, req.NamespacedName, b))
+	b.Spec.Data = "replicas: 2"
+	b.Annotations = map[string]string{common.ChangeCauseAnnotationKey: "scale up"}
+	assert.NoError(t, cli.Update(ctx, b))
+	_, err = r.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	revs, err = bundle.History(ctx, cli, "arlon", "guestbook")
+	assert.NoError(t, err)
+	assert.Len(t, revs, 2)
+	assert.Equal(t, int32(2), revs[1].Version)
+	assert.Equal(t, "scale up", revs[1].ChangeCause)
+	assert.True(t, revs[1].Current)
+
+	// reconciling an unchanged bundle records nothing
+	_, err = r.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	revs, err = bundle.History(ctx, cli, "arlon", "guestbook")
+	assert.NoError(t, err)
+	assert.Len(t, revs, 2)
+}
@@ -18,9 +18,13 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/argoproj/argo-cd/v2/util/io"
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	corev1 "github.com/arlonproj/arlon/api/v1"
@@ -31,10 +35,14 @@ import (
 	"github.com/go-logr/logr"
 	grpccodes "google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,6 +53,11 @@ import (
 
 var retryDelayAsResult = ctrl.Result{RequeueAfter: time.Second * 10}
 
+// How often the provisioning status of a created cluster is refreshed,
+// depending on whether the workload cluster is provisioned yet
+var provisioningPollDelayAsResult = ctrl.Result{RequeueAfter: time.Second * 30}
+var healthPollDelayAsResult = ctrl.Result{RequeueAfter: time.Minute * 3}
+
 // Default git location of Helm chart for Arlon app (for a cluster)
 var defaultArlonChart = arlonv1.RepoSpec{
 	Url:      "https://github.com/arlonproj/arlon.git",
@@ -65,6 +78,9 @@ type ClusterReconciler struct {
 //+kubebuilder:rbac:groups=core.arlon.io,resources=clusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core.arlon.io,resources=clusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core.arlon.io,resources=clusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;machinedeployments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusterclasses,verbs=get
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io;controlplane.cluster.x-k8s.io;bootstrap.cluster.x-k8s.io,resources=*,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -88,6 +104,7 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		log.Info(fmt.Sprintf("unable to get cluster (%s) ... requeuing", err))
 		return ctrl.Result{Requeue: true}, nil
 	}
+	origStatus := cl.Status.DeepCopy()
 	// Initialize the patch helper. It stores a "before" copy of the current object.
 	patchHelper, err := patch.NewHelper(&cl, r.Client)
 	if err != nil {
@@ -111,10 +128,10 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if !controllerutil.ContainsFinalizer(&cl, arlonv1.ClusterFinalizer) {
 		controllerutil.AddFinalizer(&cl, arlonv1.ClusterFinalizer)
 		// patch and return right away instead of reusing the main defer,
-		// because the main defer may take too much time to get cluster status
-		// Patch ObservedGeneration only if the reconciliation completed successfully
-		patchOpts := []patch.Option{patch.WithStatusObservedGeneration{}}
-		if err := patchHelper.Patch(ctx, &cl, patchOpts...); err != nil {
+		// because the main defer may take too much time to get cluster status.
+		// ObservedGeneration is left alone; UpdateState sets it once the
+		// spec has actually been processed.
+		if err := patchHelper.Patch(ctx, &cl); err != nil {
 			log.Error(err, "Failed to patch cluster to add finalizer")
 			return ctrl.Result{}, err
 		}
@@ -124,20 +141,40 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	repoUrl := ctmpl.Url
 	repoRevision := ctmpl.Revision
 	repoPath := ctmpl.Path
-	if cl.Status.InnerClusterName == "" {
+	if cl.Status.InnerClusterName != "" && cl.Status.ValidatedTemplate == nil {
+		// validated by an earlier version that did not record the template
+		cl.Status.ValidatedTemplate = ctmpl.DeepCopy()
+	}
+	if cl.Status.InnerClusterName == "" || *cl.Status.ValidatedTemplate != *ctmpl {
 		log.Info("validating cluster template ...")
 		_, creds, err := argocd.GetKubeclientAndRepoCreds(r.Config, r.ArgoCdNs,
 			repoUrl)
 		if err != nil {
 			msg := fmt.Sprintf("failed to get repo creds: %s", err)
-			return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterTemplateValidatedCondition,
+				"RepoCredsUnavailable", msg)
 		}
-		innerClusterName, err := bcl.ValidateGitDir(creds, repoUrl, repoRevision, repoPath)
+		// The ClusterClass of a topology based cluster may be installed in
+		// the namespace that the cluster is deployed to instead of being in
+		// the template.
+		innerClusterName, err := bcl.ValidateGitDir(creds, repoUrl, repoRevision, repoPath,
+			bcl.NewObjectGetter(r.Client, cl.Name))
 		if err != nil {
 			msg := fmt.Sprintf("failed to validate cluster template: %s", err)
-			return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterTemplateValidatedCondition,
+				"ValidationFailed", msg)
+		}
+		if cl.Status.InnerClusterName != "" && innerClusterName != cl.Status.InnerClusterName {
+			// Renaming the CAPI cluster would destroy the workload cluster
+			msg := fmt.Sprintf("cluster template changes inner cluster name from %s to %s, which is not allowed",
+				cl.Status.InnerClusterName, innerClusterName)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterTemplateValidatedCondition,
+				"InnerClusterNameChanged", msg)
 		}
 		cl.Status.InnerClusterName = innerClusterName
+		cl.Status.ValidatedTemplate = ctmpl.DeepCopy()
+		setCondition(&cl, arlonv1.ClusterTemplateValidatedCondition, metav1.ConditionTrue,
+			"Validated", fmt.Sprintf("inner cluster name is %s", innerClusterName))
 		return r.UpdateState(ctx, log, &cl, "template-validated",
 			"cluster template validation successful", ctrl.Result{})
 	}
@@ -145,16 +182,56 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	ovr := cl.Spec.Override
 	overridden := ovr != nil
 	if overridden {
-		if !cl.Status.OverrideSuccessful {
-			// Handle override
-			err = cluster.CreatePatchDir(r.Config, cl.Name, ovr.Repo.Url, r.ArgoCdNs,
+		ovrHash := overrideHash(&cl.Spec)
+		if overrideNeedsUpdate(&cl.Status, ovrHash) {
+			// Handle override. The directory in git is regenerated entirely,
+			// so this also applies changes to the patch or the template location.
+			var topologyPatch []byte
+			_, creds, err := argocd.GetKubeclientAndRepoCreds(r.Config, r.ArgoCdNs,
+				repoUrl)
+			if err != nil {
+				msg := fmt.Sprintf("failed to get repo creds: %s", err)
+				return r.retryStep(ctx, log, &cl, arlonv1.ClusterOverrideReadyCondition,
+					"RepoCredsUnavailable", msg)
+			}
+			if ovr.Patch != "" || len(ovr.Patches) > 0 {
+				// a patch that matches no resource fails instead of being ignored
+				err = bcl.ValidatePatchesFromGitDir(creds, repoUrl, repoRevision, repoPath,
+					[]byte(ovr.Patch), ovr.Patches)
+				if err != nil {
+					msg := fmt.Sprintf("override patches do not apply to the cluster template: %s", err)
+					return r.retryStep(ctx, log, &cl, arlonv1.ClusterOverrideReadyCondition,
+						"InvalidPatch", msg)
+				}
+			}
+			if ovr.Topology != nil {
+				topologyPatch, err = bcl.TopologyPatchFromGitDir(creds, repoUrl, repoRevision,
+					repoPath, bcl.NewObjectGetter(r.Client, cl.Name), ovr.Topology)
+				if err != nil {
+					msg := fmt.Sprintf("failed to generate topology override: %s", err)
+					return r.retryStep(ctx, log, &cl, arlonv1.ClusterOverrideReadyCondition,
+						"InvalidTopology", msg)
+				}
+			}
+			prUrl, err := cluster.CreatePatchDir(r.Config, cl.Name, ovr.Repo.Url, r.ArgoCdNs,
 				ovr.Repo.Path, ovr.Repo.Revision,
-				repoRevision, []byte(ovr.Patch), repoUrl, repoPath)
+				repoRevision, []byte(ovr.Patch), ovr.Patches, topologyPatch, repoUrl, repoPath)
 			if err != nil {
 				msg := fmt.Sprintf("failed to create override patch in git: %s", err)
-				return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+				return r.retryStep(ctx, log, &cl, arlonv1.ClusterOverrideReadyCondition,
+					"PatchCreationFailed", msg)
 			}
 			cl.Status.OverrideSuccessful = true
+			cl.Status.OverrideHash = ovrHash
+			cl.Status.PullRequestUrl = prUrl
+			if prUrl != "" {
+				// The cluster app syncs the override once the pull request is merged
+				setCondition(&cl, arlonv1.ClusterOverrideReadyCondition, metav1.ConditionTrue,
+					"PullRequestOpened", "override patch proposed in pull request "+prUrl)
+			} else {
+				setCondition(&cl, arlonv1.ClusterOverrideReadyCondition, metav1.ConditionTrue,
+					"PatchCreated", "override patch created in git")
+			}
 			return r.UpdateState(ctx, log, &cl, "override-created",
 				"override patch creation successful", ctrl.Result{})
 		}
@@ -162,53 +239,77 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		repoUrl = ovr.Repo.Url
 		repoRevision = ovr.Repo.Revision
 		repoPath = ovr.Repo.Path
+	} else {
+		// The override directory of a removed override is deleted from git
+		// below, so an override added back must be created again
+		resetOverrideStatus(&cl.Status)
+		setCondition(&cl, arlonv1.ClusterOverrideReadyCondition, metav1.ConditionTrue,
+			"NoOverride", "cluster does not specify an override")
 	}
 
 	// Check if arlon app already exists
 	aan := arlonAppName(cl.Name)
-	_, err = appIf.Get(ctx, &argoapp.ApplicationQuery{Name: &aan})
+	casMgmtClusterHost := ""
+	gen2CASEnabled := cl.Spec.Autoscaler != nil
+	if gen2CASEnabled {
+		casMgmtClusterHost = cl.Spec.Autoscaler.MgmtClusterHost
+	}
+	arlonHelmChart := cl.Spec.ArlonHelmChart
+	if arlonHelmChart == nil {
+		arlonHelmChart = &defaultArlonChart
+	}
+	arlonApp, err := appIf.Get(ctx, &argoapp.ApplicationQuery{Name: &aan})
 	if err != nil {
 		grpcStatus, ok := grpcstatus.FromError(err)
 		if !ok {
-			return r.UpdateState(ctx, log, &cl, "retrying",
-				"failed to get grpc status from argocd API", retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterArlonAppCreatedCondition,
+				"ArgoCDError", "failed to get grpc status from argocd API")
 		}
 		if grpcStatus.Code() != grpccodes.NotFound {
-			return r.UpdateState(ctx, log, &cl, "retrying",
-				fmt.Sprintf("unexpected grpc status: %d", grpcStatus.Code()),
-				retryDelayAsResult)
-		}
-		casMgmtClusterHost := ""
-		innerClusterName := cl.Status.InnerClusterName
-		gen2CASEnabled := cl.Spec.Autoscaler != nil
-		if gen2CASEnabled {
-			casMgmtClusterHost = cl.Spec.Autoscaler.MgmtClusterHost
-		}
-		arlonHelmChart := cl.Spec.ArlonHelmChart
-		if arlonHelmChart == nil {
-			arlonHelmChart = &defaultArlonChart
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterArlonAppCreatedCondition,
+				"ArgoCDError", fmt.Sprintf("unexpected grpc status: %d", grpcStatus.Code()))
 		}
-		_, err = cluster.Create(appIf, r.Config, r.ArgoCdNs, r.ArlonNs,
-			cl.Name, innerClusterName, arlonHelmChart.Url, arlonHelmChart.Revision,
+		_, _, err = cluster.Create(appIf, r.Config, r.ArgoCdNs, r.ArlonNs,
+			cl.Name, cl.Status.InnerClusterName, arlonHelmChart.Url, arlonHelmChart.Revision,
 			arlonHelmChart.Path, "",
 			nil, true, casMgmtClusterHost, gen2CASEnabled)
 		if err != nil {
 			msg := fmt.Sprintf("failed to create arlon application: %s", err)
-			return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterArlonAppCreatedCondition,
+				"CreateFailed", msg)
+		}
+	} else {
+		// Apply changes of spec.arlonHelmChart or spec.autoscaler
+		desired, err := cluster.ConstructRootApp(r.ArgoCdNs, cl.Name,
+			cl.Status.InnerClusterName, arlonHelmChart.Url, arlonHelmChart.Revision,
+			arlonHelmChart.Path, "", nil, "", casMgmtClusterHost, gen2CASEnabled)
+		if err != nil {
+			msg := fmt.Sprintf("failed to construct arlon application: %s", err)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterArlonAppCreatedCondition,
+				"UpdateFailed", msg)
+		}
+		updated, err := cluster.SyncApp(appIf, arlonApp, desired, nil)
+		if err != nil {
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterArlonAppCreatedCondition,
+				"UpdateFailed", err.Error())
+		}
+		if updated {
+			log.Info("updated arlon app to match cluster spec")
 		}
 	}
+	setCondition(&cl, arlonv1.ClusterArlonAppCreatedCondition, metav1.ConditionTrue,
+		"Created", fmt.Sprintf("application %s exists", aan))
 	// Check if cluster app already exists
 	clusterApp, err := appIf.Get(ctx, &argoapp.ApplicationQuery{Name: &cl.Name})
 	if err != nil {
 		grpcStatus, ok := grpcstatus.FromError(err)
 		if !ok {
-			return r.UpdateState(ctx, log, &cl, "retrying",
-				"failed to get grpc status from argocd API", retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"ArgoCDError", "failed to get grpc status from argocd API")
 		}
 		if grpcStatus.Code() != grpccodes.NotFound {
-			return r.UpdateState(ctx, log, &cl, "retrying",
-				fmt.Sprintf("unexpected grpc status: %d", grpcStatus.Code()),
-				retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"ArgoCDError", fmt.Sprintf("unexpected grpc status: %d", grpcStatus.Code()))
 		}
 		// Create cluster app
 		_, err = cluster.CreateClusterApp(appIf, r.ArgoCdNs,
@@ -216,41 +317,134 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			repoPath, true, overridden)
 		if err != nil {
 			msg := fmt.Sprintf("failed to create cluster application: %s", err)
-			return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"CreateFailed", msg)
 		}
+		setCondition(&cl, arlonv1.ClusterClusterAppCreatedCondition, metav1.ConditionTrue,
+			"Created", fmt.Sprintf("application %s exists", cl.Name))
+		setCondition(&cl, arlonv1.ClusterProvisionedCondition, metav1.ConditionUnknown,
+			"ProvisioningStarted", "waiting for the workload cluster to be provisioned")
 		return r.UpdateState(ctx, log, &cl, "created",
-			"cluster app creation successful", ctrl.Result{})
+			"cluster app creation successful", provisioningPollDelayAsResult)
 	}
-
-	// Sync profile annotation from Cluster to cluster app if necessary
-	sync := false
-	if cl.Annotations != nil && (clusterApp.Annotations == nil ||
-		cl.Annotations[arlonapp.ProfilesAnnotationKey] !=
-			clusterApp.Annotations[arlonapp.ProfilesAnnotationKey]) {
-		if clusterApp.Annotations == nil {
-			clusterApp.Annotations = make(map[string]string)
+	// Apply changes of spec.clusterTemplate or spec.override
+	desiredClusterApp := cluster.ConstructClusterApp(r.ArgoCdNs, cl.Name,
+		cl.Status.InnerClusterName, repoUrl, repoRevision, repoPath, overridden)
+	if cluster.AppNeedsSync(clusterApp, desiredClusterApp, cluster.ClusterAppAnnotationKeys) {
+		oldClusterApp := clusterApp.DeepCopy()
+		_, err = cluster.SyncApp(appIf, clusterApp, desiredClusterApp,
+			cluster.ClusterAppAnnotationKeys)
+		if err != nil {
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"UpdateFailed", err.Error())
+		}
+		log.Info("updated cluster app to match cluster spec")
+		if cluster.OverrideMoved(oldClusterApp, desiredClusterApp) {
+			// The previous override directory is no longer referenced
+			kubeClient, err := kubernetes.NewForConfig(r.Config)
+			var prUrl string
+			if err == nil {
+				prUrl, err = cluster.DeleteOverridesDir(oldClusterApp, kubeClient,
+					r.ArgoCdNs, cl.Name)
+			}
+			if err != nil {
+				log.Info(fmt.Sprintf("failed to delete previous overrides directory: %s", err))
+			} else if prUrl != "" {
+				log.Info("opened pull request to delete previous overrides directory",
+					"pullRequest", prUrl)
+			}
+		}
+		// Get the updated app, which the profiles annotation sync below modifies
+		clusterApp, err = appIf.Get(ctx, &argoapp.ApplicationQuery{Name: &cl.Name})
+		if err != nil {
+			msg := fmt.Sprintf("failed to get updated cluster application: %s", err)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"ArgoCDError", msg)
 		}
-		clusterApp.Annotations[arlonapp.ProfilesAnnotationKey] = cl.Annotations[arlonapp.ProfilesAnnotationKey]
-		sync = true
-	} else if cl.Annotations == nil && clusterApp.Annotations != nil {
-		clusterApp.Annotations = nil
-		sync = true
 	}
-	if sync {
+	setCondition(&cl, arlonv1.ClusterClusterAppCreatedCondition, metav1.ConditionTrue,
+		"Created", fmt.Sprintf("application %s exists", cl.Name))
+
+	// Sync profile annotation from Cluster to cluster app if necessary
+	if syncProfilesAnnotation(&cl, clusterApp) {
 		log.Info("updating profiles annotation of cluster app")
 		_, err = appIf.Update(ctx, &argoapp.ApplicationUpdateRequest{
 			Application: clusterApp,
 		})
 		if err != nil {
-			msg := fmt.Sprintf("failed to update cluster application: %s", err)
-			return r.UpdateState(ctx, log, &cl, "retrying", msg, retryDelayAsResult)
+			msg := fmt.Sprintf("failed to update profiles annotation of cluster application: %s", err)
+			return r.retryStep(ctx, log, &cl, arlonv1.ClusterClusterAppCreatedCondition,
+				"UpdateFailed", msg)
 		}
 	}
+	result := provisioningPollDelayAsResult
+	if r.refreshProvisioningStatus(ctx, log, &cl, arlonApp, clusterApp) {
+		result = healthPollDelayAsResult
+	}
+	msg := cl.Status.Message
 	if cl.Status.State != "created" {
-		return r.UpdateState(ctx, log, &cl, "created",
-			"cluster app already exists but state needs updating -- ok", ctrl.Result{})
+		msg = "cluster app already exists but state needs updating -- ok"
 	}
-	return ctrl.Result{}, nil
+	// Only write the status if something changed, since each write
+	// triggers another reconciliation
+	setReadyCondition(&cl, "created", msg)
+	if cl.Status.State != "created" || cl.Status.ObservedGeneration != cl.Generation ||
+		!apiequality.Semantic.DeepEqual(origStatus, &cl.Status) {
+		return r.UpdateState(ctx, log, &cl, "created", msg, result)
+	}
+	return result, nil
+}
+
+// refreshProvisioningStatus updates the provisioning status and the
+// ClusterProvisioned condition from the CAPI resources of the workload cluster
+// and the Argo CD applications. It returns true if the workload cluster
+// is provisioned.
+func (r *ClusterReconciler) refreshProvisioningStatus(
+	ctx context.Context,
+	log logr.Logger,
+	cr *arlonv1.Cluster,
+	arlonApp *argoappv1.Application,
+	clusterApp *argoappv1.Application,
+) bool {
+	capiClusterName := cluster.CAPIClusterName(cr.Name, cr.Status.InnerClusterName)
+	status, err := cluster.GetProvisioningStatus(ctx, r.Client, cr.Name,
+		cr.Status.InnerClusterName)
+	if err != nil {
+		log.Info(fmt.Sprintf("failed to get provisioning status: %s", err))
+		// keep the last known CAPI status but refresh the applications
+		status = cr.Status.Provisioning
+	}
+	status.ArlonApp = cluster.ApplicationHealthFromApp(arlonApp)
+	status.ClusterApp = cluster.ApplicationHealthFromApp(clusterApp)
+	cr.Status.Provisioning = status
+	provisioned := false
+	switch {
+	case err != nil:
+		setCondition(cr, arlonv1.ClusterProvisionedCondition, metav1.ConditionUnknown,
+			"StatusUnavailable", err.Error())
+	case status.Phase == "":
+		setCondition(cr, arlonv1.ClusterProvisionedCondition, metav1.ConditionUnknown,
+			"WaitingForClusterResource",
+			fmt.Sprintf("CAPI cluster %s does not exist yet", capiClusterName))
+	case status.Phase == string(capi.ClusterPhaseFailed):
+		setCondition(cr, arlonv1.ClusterProvisionedCondition, metav1.ConditionFalse,
+			"ProvisioningFailed",
+			fmt.Sprintf("CAPI cluster %s is in %s phase", capiClusterName, status.Phase))
+	case status.Phase == string(capi.ClusterPhaseProvisioned) &&
+		status.InfrastructureReady && status.ControlPlaneReady:
+		provisioned = true
+		setCondition(cr, arlonv1.ClusterProvisionedCondition, metav1.ConditionTrue,
+			"Provisioned",
+			fmt.Sprintf("CAPI cluster %s is provisioned, %d/%d workers ready",
+				capiClusterName, status.Workers.Ready, status.Workers.Desired))
+	default:
+		setCondition(cr, arlonv1.ClusterProvisionedCondition, metav1.ConditionFalse,
+			"Provisioning",
+			fmt.Sprintf("CAPI cluster %s is in %s phase (infrastructure ready: %v, control plane ready: %v)",
+				capiClusterName, status.Phase, status.InfrastructureReady,
+				status.ControlPlaneReady))
+	}
+	return provisioned
 }
 
 func (r *ClusterReconciler) UpdateState(
@@ -263,6 +457,8 @@ func (r *ClusterReconciler) UpdateState(
 ) (ctrl.Result, error) {
 	cr.Status.State = state
 	cr.Status.Message = msg
+	cr.Status.ObservedGeneration = cr.Generation
+	setReadyCondition(cr, state, msg)
 	log.Info(fmt.Sprintf("%s ... setting state to '%s'", msg, cr.Status.State))
 	if err := r.Status().Update(ctx, cr); err != nil {
 		log.Error(err, "unable to update clusterregistration status")
@@ -271,6 +467,81 @@ func (r *ClusterReconciler) UpdateState(
 	return result, nil
 }
 
+// retryStep marks the condition associated with a failed reconciliation step
+// as false, then sets the state to 'retrying'. Conditions of the other steps
+// are preserved so that their messages are not lost.
+func (r *ClusterReconciler) retryStep(
+	ctx context.Context,
+	log logr.Logger,
+	cr *arlonv1.Cluster,
+	condType string,
+	reason string,
+	msg string,
+) (ctrl.Result, error) {
+	setCondition(cr, condType, metav1.ConditionFalse, reason, msg)
+	return r.UpdateState(ctx, log, cr, "retrying", msg, retryDelayAsResult)
+}
+
+// readyDependencies lists the conditions that must all be true for a
+// Cluster to be considered ready.
+var readyDependencies = []string{
+	arlonv1.ClusterTemplateValidatedCondition,
+	arlonv1.ClusterOverrideReadyCondition,
+	arlonv1.ClusterArlonAppCreatedCondition,
+	arlonv1.ClusterClusterAppCreatedCondition,
+	arlonv1.ClusterProvisionedCondition,
+}
+
+func setCondition(
+	cr *arlonv1.Cluster,
+	condType string,
+	status metav1.ConditionStatus,
+	reason string,
+	msg string,
+) {
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: cr.Generation,
+		Reason:             reason,
+		Message:            msg,
+	})
+}
+
+// setReadyCondition computes the Ready condition from the other conditions
+// and the state the cluster is transitioning to.
+func setReadyCondition(cr *arlonv1.Cluster, state string, msg string) {
+	if !cr.DeletionTimestamp.IsZero() {
+		setCondition(cr, arlonv1.ClusterReadyCondition, metav1.ConditionFalse,
+			"Deleting", msg)
+		return
+	}
+	pending := ""
+	for _, condType := range readyDependencies {
+		cond := meta.FindStatusCondition(cr.Status.Conditions, condType)
+		if cond != nil && cond.Status == metav1.ConditionFalse {
+			setCondition(cr, arlonv1.ClusterReadyCondition, metav1.ConditionFalse,
+				cond.Reason, fmt.Sprintf("%s: %s", condType, cond.Message))
+			return
+		}
+		if pending == "" && (cond == nil || cond.Status != metav1.ConditionTrue) {
+			pending = condType
+		}
+	}
+	if state == "retrying" {
+		setCondition(cr, arlonv1.ClusterReadyCondition, metav1.ConditionFalse,
+			"Retrying", msg)
+		return
+	}
+	if pending != "" {
+		setCondition(cr, arlonv1.ClusterReadyCondition, metav1.ConditionFalse,
+			"Reconciling", fmt.Sprintf("waiting for %s", pending))
+		return
+	}
+	setCondition(cr, arlonv1.ClusterReadyCondition, metav1.ConditionTrue,
+		"Created", msg)
+}
+
 func (r *ClusterReconciler) reconcileDelete(
 	ctx context.Context,
 	log logr.Logger,
@@ -289,13 +560,14 @@ func (r *ClusterReconciler) reconcileDelete(
 				return r.UpdateState(ctx, log, cr, "error-deleting-override",
 					msg, retryDelayAsResult)
 			}
-			err = cluster.DeleteOverridesDir(clusterApp, kubeClient, r.ArgoCdNs,
+			prUrl, err := cluster.DeleteOverridesDir(clusterApp, kubeClient, r.ArgoCdNs,
 				clusterApp.Name)
 			if err != nil {
 				msg := fmt.Sprintf("failed to delete overrides directory: %s", err)
 				return r.UpdateState(ctx, log, cr, "error-deleting-override",
 					msg, retryDelayAsResult)
 			}
+			cr.Status.PullRequestUrl = prUrl
 			// Flip this flag to indicate override no longer needs deletion
 			cr.Status.OverrideSuccessful = false
 			return r.UpdateState(ctx, log, cr, "override-deleted",
@@ -380,6 +652,68 @@ func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+// overrideNeedsUpdate returns true if the override directory in git must be
+// generated for an override with the hash ovrHash.
+func overrideNeedsUpdate(status *arlonv1.ClusterStatus, ovrHash string) bool {
+	return !status.OverrideSuccessful || status.OverrideHash != ovrHash
+}
+
+// resetOverrideStatus forgets the override directory generated in git.
+func resetOverrideStatus(status *arlonv1.ClusterStatus) {
+	status.OverrideSuccessful = false
+	status.OverrideHash = ""
+	status.PullRequestUrl = ""
+}
+
+// syncProfilesAnnotation copies the profiles annotation of a Cluster to its
+// cluster app, leaving the other annotations of the app alone. It returns
+// true if the app was modified.
+func syncProfilesAnnotation(cl *arlonv1.Cluster, clusterApp *argoappv1.Application) bool {
+	key := arlonapp.ProfilesAnnotationKey
+	profiles, found := cl.Annotations[key]
+	appProfiles, appFound := clusterApp.Annotations[key]
+	if !found {
+		if !appFound {
+			return false
+		}
+		delete(clusterApp.Annotations, key)
+		return true
+	}
+	if appFound && appProfiles == profiles {
+		return false
+	}
+	if clusterApp.Annotations == nil {
+		clusterApp.Annotations = make(map[string]string)
+	}
+	clusterApp.Annotations[key] = profiles
+	return true
+}
+
+// overrideHash returns a hash of the parts of the spec that the override
+// directory in git is generated from.
+func overrideHash(spec *arlonv1.ClusterSpec) string {
+	h := sha256.New()
+	ovr := spec.Override
+	ctmpl := &spec.ClusterTemplate
+	for _, s := range []string{ovr.Patch, ovr.Repo.Url, ovr.Repo.Path, ovr.Repo.Revision,
+		ctmpl.Url, ctmpl.Path, ctmpl.Revision} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	if len(ovr.Patches) > 0 {
+		// a list of structs of strings always serializes
+		patches, _ := json.Marshal(ovr.Patches)
+		h.Write(patches)
+		h.Write([]byte{0})
+	}
+	if ovr.Topology != nil {
+		// a struct of strings and raw JSON values always serializes
+		topology, _ := json.Marshal(ovr.Topology)
+		h.Write(topology)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func arlonAppName(clusterName string) string {
 	return fmt.Sprintf("%s-arlon", clusterName)
 }
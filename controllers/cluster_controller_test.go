@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"testing"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	arlonapp "github.com/arlonproj/arlon/pkg/app"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOverrideRemovedThenReadded(t *testing.T) {
+	spec := arlonv1.ClusterSpec{
+		ClusterTemplate: arlonv1.RepoSpec{Url: "https://github.com/org/templates.git", Path: "aws", Revision: "main"},
+		Override: &arlonv1.OverrideSpec{
+			Patch: "kind: AWSCluster\n",
+			Repo:  arlonv1.RepoSpec{Url: "https://github.com/org/overrides.git", Path: "clusters", Revision: "main"},
+		},
+	}
+	var status arlonv1.ClusterStatus
+	ovrHash := overrideHash(&spec)
+	assert.True(t, overrideNeedsUpdate(&status, ovrHash))
+	status.OverrideSuccessful = true
+	status.OverrideHash = ovrHash
+	assert.False(t, overrideNeedsUpdate(&status, ovrHash))
+
+	// removing the override deletes its directory in git
+	resetOverrideStatus(&status)
+	// adding the same override back must create the directory again
+	assert.True(t, overrideNeedsUpdate(&status, overrideHash(&spec)))
+}
+
+func TestSyncProfilesAnnotation(t *testing.T) {
+	key := arlonapp.ProfilesAnnotationKey
+	appAnnotations := func() map[string]string {
+		return map[string]string{
+			"arlon.io/basecluster-repo-url": "https://github.com/org/templates.git",
+			key:                             "p1",
+		}
+	}
+	cl := &arlonv1.Cluster{}
+	app := &argoappv1.Application{ObjectMeta: metav1.ObjectMeta{Annotations: appAnnotations()}}
+	// a Cluster without annotations only removes the profiles annotation
+	assert.True(t, syncProfilesAnnotation(cl, app))
+	assert.Equal(t, map[string]string{"arlon.io/basecluster-repo-url": "https://github.com/org/templates.git"},
+		app.Annotations)
+	assert.False(t, syncProfilesAnnotation(cl, app))
+
+	cl.Annotations = map[string]string{key: "p2"}
+	assert.True(t, syncProfilesAnnotation(cl, app))
+	assert.Equal(t, "p2", app.Annotations[key])
+	assert.Equal(t, 2, len(app.Annotations))
+	assert.False(t, syncProfilesAnnotation(cl, app))
+
+	app = &argoappv1.Application{}
+	assert.True(t, syncProfilesAnnotation(cl, app))
+	assert.Equal(t, map[string]string{key: "p2"}, app.Annotations)
+}
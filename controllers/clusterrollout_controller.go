@@ -0,0 +1,92 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/cluster"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ClusterRolloutReconciler reconciles a ClusterRollout object
+type ClusterRolloutReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=core.arlon.io,resources=clusterrollouts,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=core.arlon.io,resources=clusterrollouts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core.arlon.io,resources=clusters,verbs=get;list;watch;update
+
+// Reconcile moves the Clusters selected by a ClusterRollout to its target
+// revision, one wave at a time.
+func (r *ClusterRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).Info("arlon ClusterRollout")
+	var ro arlonv1.ClusterRollout
+	if err := r.Get(ctx, req.NamespacedName, &ro); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("cluster rollout is gone -- ok")
+			return ctrl.Result{}, nil
+		}
+		log.Info(fmt.Sprintf("unable to get cluster rollout (%s) ... requeuing", err))
+		return ctrl.Result{Requeue: true}, nil
+	}
+	origStatus := ro.Status.DeepCopy()
+	paused := ro.Spec.Paused
+	requeueAfter, err := cluster.AdvanceRollout(ctx, r.Client, &ro, time.Now())
+	if err != nil {
+		log.Info(fmt.Sprintf("failed to advance cluster rollout: %s", err))
+		ro.Status.Message = err.Error()
+		requeueAfter = retryDelayAsResult.RequeueAfter
+	}
+	if ro.Spec.Paused != paused {
+		// A failed wave pauses the rollout
+		status := ro.Status.DeepCopy()
+		if err := r.Update(ctx, &ro); err != nil {
+			log.Error(err, "unable to pause cluster rollout")
+			return ctrl.Result{}, err
+		}
+		ro.Status = *status
+		log.Info(fmt.Sprintf("paused cluster rollout: %s", ro.Status.Message))
+	}
+	ro.Status.ObservedGeneration = ro.Generation
+	if !apiequality.Semantic.DeepEqual(origStatus, &ro.Status) {
+		log.Info(fmt.Sprintf("%s ... setting phase to '%s'", ro.Status.Message, ro.Status.Phase))
+		if err := r.Status().Update(ctx, &ro); err != nil {
+			log.Error(err, "unable to update cluster rollout status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&arlonv1.ClusterRollout{}).
+		Complete(r)
+}
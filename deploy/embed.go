@@ -11,4 +11,6 @@ var (
 	YAMLrbacClusterReg []byte
 	//go:embed manifests/rbac_appprofile.yaml
 	YAMLrbacAppProf []byte
+	//go:embed manifests/rbac_bundle.yaml
+	YAMLrbacBundle []byte
 )
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
@@ -16,6 +17,35 @@ import (
 
 const ProfilesAnnotationKey = "arlon.io/profiles"
 
+// Parameters supplied to the template of an app by the elements of its list
+// generator, one element per cluster the app is deployed to
+const (
+	ClusterNameParam   = "cluster_name"
+	ClusterServerParam = "cluster_server"
+	// HelmValuesParam holds the Helm values of the cluster, merged from its
+	// app profiles and its own spec
+	HelmValuesParam = "helm_values"
+	// helmValueFileParamPrefix, followed by an index, holds a value file of
+	// the cluster
+	helmValueFileParamPrefix = "helm_value_file_"
+)
+
+// HelmValuesTemplate is the Helm values of an app templating the per-cluster
+// Helm configuration
+const HelmValuesTemplate = "{{" + HelmValuesParam + "}}"
+
+// HelmValueFileParam returns the name of the parameter holding the i-th
+// value file of a cluster.
+func HelmValueFileParam(i int) string {
+	return fmt.Sprintf("%s%d", helmValueFileParamPrefix, i)
+}
+
+// IsHelmValueFileSlot returns whether a value file of the template of an app
+// is a per-cluster value file.
+func IsHelmValueFileSlot(valueFile string) bool {
+	return strings.HasPrefix(valueFile, "{{"+helmValueFileParamPrefix)
+}
+
 func List(config *restclient.Config, ns string) (apslist []argoappv1.ApplicationSet, err error) {
 	cli, err := ctrlruntimeclient.NewClient(config)
 	if err != nil {
@@ -49,6 +79,7 @@ func Create(
 	srcTargetRevision string,
 	autoSync bool,
 	autoPrune bool,
+	helm bool,
 ) argoappv1.ApplicationSet {
 	aps := argoappv1.ApplicationSet{
 		TypeMeta: metav1.TypeMeta{
@@ -73,12 +104,12 @@ func Create(
 			},
 			Template: argoappv1.ApplicationSetTemplate{
 				ApplicationSetTemplateMeta: argoappv1.ApplicationSetTemplateMeta{
-					Name: fmt.Sprintf("{{cluster_name}}-app-%s", name),
+					Name: fmt.Sprintf("{{%s}}-app-%s", ClusterNameParam, name),
 				},
 				Spec: argoappv1.ApplicationSpec{
 					Destination: argoappv1.ApplicationDestination{
 						Namespace: destNs,
-						Server:    "{{cluster_server}}",
+						Server:    "{{" + ClusterServerParam + "}}",
 					},
 					Project: project,
 					Source: argoappv1.ApplicationSource{
@@ -91,6 +122,11 @@ func Create(
 			},
 		},
 	}
+	if helm {
+		aps.Spec.Template.Spec.Source.Helm = &argoappv1.ApplicationSourceHelm{
+			Values: HelmValuesTemplate,
+		}
+	}
 	if autoSync {
 		aps.Spec.Template.Spec.SyncPolicy.Automated = &argoappv1.SyncPolicyAutomated{
 			Prune: autoPrune,
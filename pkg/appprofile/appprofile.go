@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	restclient "k8s.io/client-go/rest"
 	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,15 +33,30 @@ func ListToStdout(config *restclient.Config, ns string) error {
 		return fmt.Errorf("failed to list application profiles: %s", err)
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintf(w, "NAME\tAPPS\tHEALTH\tINVALID_APPS\n")
+	_, _ = fmt.Fprintf(w, "NAME\tAPPS\tCLUSTER_SELECTOR\tHEALTH\tINVALID_APPS\tCLUSTERS\tFAILING_APPS\n")
 	for _, prof := range profiles {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 			prof.Name,
 			prof.Spec.AppNames,
+			metav1.FormatLabelSelector(prof.Spec.ClusterSelector),
 			prof.Status.Health,
 			prof.Status.InvalidAppNames,
+			prof.Status.Clusters,
+			failingApps(&prof),
 		)
 	}
 	_ = w.Flush()
 	return nil
 }
+
+// failingApps returns the app@cluster pairs of a profile whose generated
+// application is missing, out of sync or unhealthy.
+func failingApps(prof *v1.AppProfile) []string {
+	failing := []string{}
+	for _, acs := range prof.Status.AppStatuses {
+		if !acs.Healthy() {
+			failing = append(failing, fmt.Sprintf("%s@%s", acs.AppName, acs.ClusterName))
+		}
+	}
+	return failing
+}
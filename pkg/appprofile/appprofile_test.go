@@ -15,6 +15,7 @@ import (
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	arlonapp "github.com/arlonproj/arlon/pkg/app"
+	"github.com/arlonproj/arlon/pkg/plan"
 	sets "github.com/deckarep/golang-set/v2"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
@@ -59,6 +60,8 @@ var (
 	gApplicationList    *v1alpha1.ApplicationList
 	gApplicationSetList *argoapp.ApplicationSetList
 	gProfileList        *arlonv1.AppProfileList
+	gArlonClusterList   = &arlonv1.ClusterList{}
+	gGeneratedAppList   = &argoapp.ApplicationList{}
 )
 
 func init() {
@@ -276,9 +279,14 @@ type mockCtrlRuntClient struct {
 
 func (mcrc *mockCtrlRuntClient) List(ctx context.Context,
 	list client.ObjectList, opts ...client.ListOption) error {
-	if appSetListPtr, ok := list.(*argoapp.ApplicationSetList); ok {
-		*appSetListPtr = *gApplicationSetList
-	} else {
+	switch listPtr := list.(type) {
+	case *argoapp.ApplicationSetList:
+		*listPtr = *gApplicationSetList
+	case *arlonv1.ClusterList:
+		*listPtr = *gArlonClusterList
+	case *argoapp.ApplicationList:
+		*listPtr = *gGeneratedAppList
+	default:
 		profileListPtr := list.(*arlonv1.AppProfileList)
 		*profileListPtr = *gProfileList
 	}
@@ -456,6 +464,389 @@ func TestAppProfileReconcileEverything(t *testing.T) {
 	dumpApplicationSets(t)
 }
 
+func TestAppProfileClusterSelector(t *testing.T) {
+	log := zap.New(zap.UseFlagOptions(&zap.Options{
+		Development: true,
+		TimeEncoder: zapcore.RFC3339NanoTimeEncoder,
+	}))
+	var mcr *mockCtrlRuntClient
+	var mac *mockArgoClient
+
+	gClusterList = &v1alpha1.ClusterList{
+		Items: []v1alpha1.Cluster{
+			{
+				Name:   "arlon-cluster-1",
+				Server: "arlon-cluster-1.local",
+			},
+			{
+				Name:   "arlon-cluster-2",
+				Server: "arlon-cluster-2.local",
+				Annotations: map[string]string{
+					"arlon.io/profiles": "qa",
+				},
+			},
+			{
+				Name:   "external-cluster",
+				Server: "external-cluster.local",
+				Labels: map[string]string{"env": "prod"},
+			},
+		},
+	}
+	gApplicationList = &v1alpha1.ApplicationList{
+		Items: []v1alpha1.Application{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "arlon-cluster-1",
+					Labels: map[string]string{
+						"arlon-type": "cluster-app",
+						"managed-by": "arlon",
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "arlon-cluster-2",
+					Labels: map[string]string{
+						"arlon-type": "cluster-app",
+						"managed-by": "arlon",
+					},
+					Annotations: map[string]string{
+						"arlon.io/profiles": "qa",
+					},
+				},
+			},
+		},
+	}
+	gArlonClusterList = &arlonv1.ClusterList{
+		Items: []arlonv1.Cluster{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "arlon-cluster-1",
+					Namespace: "arlon",
+					Labels:    map[string]string{"env": "prod"},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "arlon-cluster-2",
+					Namespace: "arlon",
+					Labels:    map[string]string{"env": "dev"},
+				},
+			},
+		},
+	}
+	appSet := func(name string) argoapp.ApplicationSet {
+		return argoapp.ApplicationSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"arlon-type": "application"},
+			},
+			Spec: argoapp.ApplicationSetSpec{
+				Generators: []argoapp.ApplicationSetGenerator{
+					{List: &argoapp.ListGenerator{}},
+				},
+			},
+		}
+	}
+	gApplicationSetList = &argoapp.ApplicationSetList{
+		Items: []argoapp.ApplicationSet{appSet("wordpress"), appSet("teamcity")},
+	}
+	gProfileList = &arlonv1.AppProfileList{
+		Items: []arlonv1.AppProfile{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "production"},
+				Spec: arlonv1.AppProfileSpec{
+					AppNames: []string{"wordpress"},
+					ClusterSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"env": "prod"},
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "qa"},
+				Spec:       arlonv1.AppProfileSpec{AppNames: []string{"teamcity"}},
+			},
+		},
+	}
+
+	// labels of arlon clusters and external argocd clusters are matched,
+	// in addition to the annotation
+	reconcile(t, mcr, mac, log)
+	assert.True(t, arlonAppTargetsTheseClusters(t, "wordpress", []string{"arlon-cluster-1", "external-cluster"}))
+	assert.True(t, arlonAppTargetsTheseClusters(t, "teamcity", []string{"arlon-cluster-2"}))
+	// the annotation is not modified
+	assert.True(t, argoClusterHasProfiles(t, "arlon-cluster-1", nil))
+
+	// a new cluster with the right labels picks up the apps
+	gClusterList.Items = append(gClusterList.Items, v1alpha1.Cluster{
+		Name:   "arlon-cluster-3",
+		Server: "arlon-cluster-3.local",
+	})
+	gArlonClusterList.Items = append(gArlonClusterList.Items, arlonv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "arlon-cluster-3",
+			Namespace: "arlon",
+			Labels:    map[string]string{"env": "prod"},
+		},
+	})
+	reconcile(t, mcr, mac, log)
+	assert.True(t, arlonAppTargetsTheseClusters(t, "wordpress",
+		[]string{"arlon-cluster-1", "arlon-cluster-3", "external-cluster"}))
+
+	// relabeling a cluster moves the apps away from it
+	gArlonClusterList.Items[0].Labels["env"] = "dev"
+	reconcile(t, mcr, mac, log)
+	assert.True(t, arlonAppTargetsTheseClusters(t, "wordpress", []string{"arlon-cluster-3", "external-cluster"}))
+}
+
+func TestAppProfileHelmValues(t *testing.T) {
+	log := zap.New(zap.UseFlagOptions(&zap.Options{
+		Development: true,
+		TimeEncoder: zapcore.RFC3339NanoTimeEncoder,
+	}))
+	var mcr *mockCtrlRuntClient
+	var mac *mockArgoClient
+
+	gClusterList = &v1alpha1.ClusterList{
+		Items: []v1alpha1.Cluster{
+			{Name: "eu-1", Server: "eu-1.local", Labels: map[string]string{"region": "eu"}},
+			{Name: "us-1", Server: "us-1.local", Labels: map[string]string{"region": "us"}},
+		},
+	}
+	gApplicationList = &v1alpha1.ApplicationList{}
+	gArlonClusterList = &arlonv1.ClusterList{
+		Items: []arlonv1.Cluster{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "us-1", Namespace: "arlon"},
+				Spec: arlonv1.ClusterSpec{
+					AppValues: []arlonv1.AppHelmValues{
+						{AppName: "nginx", Values: "ingress:\n  host: us-1.example.com\n"},
+					},
+				},
+			},
+		},
+	}
+	helmApp := arlonapp.Create("argocd", "nginx", "default", "default",
+		"charts/nginx", "https://github.com/org/repo", "HEAD", true, true, true)
+	helmApp.Spec.Template.Spec.Source.Helm.ValueFiles = []string{"values-prod.yaml"}
+	gApplicationSetList = &argoapp.ApplicationSetList{Items: []argoapp.ApplicationSet{helmApp}}
+	selector := func(region string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{"region": region}}
+	}
+	gProfileList = &arlonv1.AppProfileList{
+		Items: []arlonv1.AppProfile{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "us"},
+				Spec: arlonv1.AppProfileSpec{
+					AppNames:        []string{"nginx"},
+					ClusterSelector: selector("us"),
+					AppValues: []arlonv1.AppHelmValues{{
+						AppName:    "nginx",
+						Values:     "replicaCount: 5\ningress:\n  enabled: true\n",
+						ValueFiles: []string{"values-us.yaml", "values-large.yaml"},
+					}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "base"},
+				Spec: arlonv1.AppProfileSpec{
+					AppNames: []string{"nginx"},
+					ClusterSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "region", Operator: metav1.LabelSelectorOpExists},
+					}},
+					AppValues: []arlonv1.AppHelmValues{{
+						AppName: "nginx",
+						Values:  "replicaCount: 1\n",
+						Parameters: []arlonv1.HelmParameter{
+							{Name: "ingress.enabled", Value: "false"},
+							{Name: "image.tag", Value: "1.23", ForceString: true},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	reconcile(t, mcr, mac, log)
+	helm := gApplicationSetList.Items[0].Spec.Template.Spec.Source.Helm
+	assert.Equal(t, []string{"values-prod.yaml", "{{helm_value_file_0}}", "{{helm_value_file_1}}"},
+		helm.ValueFiles)
+	elements := map[string]map[string]interface{}{}
+	for _, elem := range gApplicationSetList.Items[0].Spec.Generators[0].List.Elements {
+		var element map[string]interface{}
+		assert.NoError(t, json.Unmarshal(elem.Raw, &element))
+		elements[element["cluster_name"].(string)] = element
+	}
+	// profiles are merged in name order, then the cluster's values
+	assert.Equal(t, map[string]interface{}{
+		"cluster_name":      "us-1",
+		"cluster_server":    "us-1.local",
+		"helm_values":       "image:\n  tag: \"1.23\"\ningress:\n  enabled: true\n  host: us-1.example.com\nreplicaCount: 5\n",
+		"helm_value_file_0": "values-us.yaml",
+		"helm_value_file_1": "values-large.yaml",
+	}, elements["us-1"])
+	// unused value file slots repeat the last value file
+	assert.Equal(t, map[string]interface{}{
+		"cluster_name":      "eu-1",
+		"cluster_server":    "eu-1.local",
+		"helm_values":       "image:\n  tag: \"1.23\"\ningress:\n  enabled: false\nreplicaCount: 1\n",
+		"helm_value_file_0": "values-prod.yaml",
+		"helm_value_file_1": "values-prod.yaml",
+	}, elements["eu-1"])
+
+	// the value file slots follow the clusters' value files
+	gProfileList.Items[0].Spec.AppValues[0].ValueFiles = nil
+	reconcile(t, mcr, mac, log)
+	helm = gApplicationSetList.Items[0].Spec.Template.Spec.Source.Helm
+	assert.Equal(t, []string{"values-prod.yaml"}, helm.ValueFiles)
+
+	// arlon clusters of the same name in several namespaces don't shadow
+	// each other, their values are ignored
+	gArlonClusterList.Items = append(gArlonClusterList.Items, arlonv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "us-1", Namespace: "other"},
+		Spec: arlonv1.ClusterSpec{
+			AppValues: []arlonv1.AppHelmValues{
+				{AppName: "nginx", Values: "ingress:\n  host: other.example.com\n"},
+			},
+		},
+	})
+	reconcile(t, mcr, mac, log)
+	elements = map[string]map[string]interface{}{}
+	for _, elem := range gApplicationSetList.Items[0].Spec.Generators[0].List.Elements {
+		var element map[string]interface{}
+		assert.NoError(t, json.Unmarshal(elem.Raw, &element))
+		elements[element["cluster_name"].(string)] = element
+	}
+	assert.Equal(t, "image:\n  tag: \"1.23\"\ningress:\n  enabled: true\nreplicaCount: 5\n",
+		elements["us-1"]["helm_values"])
+
+	// an empty selector selects no cluster
+	gProfileList.Items[1].Spec.ClusterSelector = &metav1.LabelSelector{}
+	reconcile(t, mcr, mac, log)
+	var clusterNames []string
+	for _, elem := range gApplicationSetList.Items[0].Spec.Generators[0].List.Elements {
+		var element map[string]interface{}
+		assert.NoError(t, json.Unmarshal(elem.Raw, &element))
+		clusterNames = append(clusterNames, element["cluster_name"].(string))
+	}
+	assert.Equal(t, []string{"us-1"}, clusterNames)
+}
+
+func TestMergeHelmValuesParameters(t *testing.T) {
+	values, _, err := mergeHelmValues([]arlonv1.AppHelmValues{{
+		AppName: "nginx",
+		Values:  "tolerations:\n- key: dedicated\n  value: infra\n",
+		Parameters: []arlonv1.HelmParameter{
+			{Name: "tolerations[0].value", Value: "gpu"},
+			{Name: "tolerations[2].key", Value: "spot"},
+			{Name: `podAnnotations.prometheus\.io/scrape`, Value: "true", ForceString: true},
+			{Name: "matrix[0][1]", Value: "1"},
+		},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, `matrix:
+- - null
+  - 1
+podAnnotations:
+  prometheus.io/scrape: "true"
+tolerations:
+- key: dedicated
+  value: gpu
+- null
+- key: spot
+`, values)
+
+	_, _, err = mergeHelmValues([]arlonv1.AppHelmValues{{
+		AppName:    "nginx",
+		Parameters: []arlonv1.HelmParameter{{Name: "a..b", Value: "1"}},
+	}})
+	assert.Error(t, err)
+}
+
+func TestAppProfileStatus(t *testing.T) {
+	log := zap.New(zap.UseFlagOptions(&zap.Options{
+		Development: true,
+		TimeEncoder: zapcore.RFC3339NanoTimeEncoder,
+	}))
+	var mcr *mockCtrlRuntClient
+	var mac *mockArgoClient
+
+	gClusterList = &v1alpha1.ClusterList{
+		Items: []v1alpha1.Cluster{
+			{Name: "c1", Server: "c1.local", Labels: map[string]string{"env": "prod"}},
+			{Name: "c2", Server: "c2.local", Labels: map[string]string{"env": "prod"}},
+		},
+	}
+	gApplicationList = &v1alpha1.ApplicationList{}
+	gArlonClusterList = &arlonv1.ClusterList{}
+	gApplicationSetList = &argoapp.ApplicationSetList{Items: []argoapp.ApplicationSet{
+		arlonapp.Create("argocd", "wordpress", "default", "default",
+			"apps/wordpress", "https://github.com/org/repo", "HEAD", true, true, false),
+	}}
+	generatedApp := func(clustName string) argoapp.Application {
+		return argoapp.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clustName + "-app-wordpress",
+				Namespace: "argocd",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ApplicationSet", Name: "wordpress"},
+				},
+			},
+			Spec: argoapp.ApplicationSpec{
+				Destination: argoapp.ApplicationDestination{Server: clustName + ".local"},
+			},
+			Status: argoapp.ApplicationStatus{
+				Sync:   argoapp.SyncStatus{Status: argoapp.SyncStatusCodeSynced},
+				Health: argoapp.HealthStatus{Status: "Healthy"},
+			},
+		}
+	}
+	gGeneratedAppList = &argoapp.ApplicationList{Items: []argoapp.Application{generatedApp("c1")}}
+	gProfileList = &arlonv1.AppProfileList{
+		Items: []arlonv1.AppProfile{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+				Spec: arlonv1.AppProfileSpec{
+					AppNames: []string{"wordpress", "nonexistent"},
+					ClusterSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"env": "prod"},
+					},
+				},
+			},
+		},
+	}
+
+	reconcile(t, mcr, mac, log)
+	status := &gProfileList.Items[0].Status
+	assert.Equal(t, []string{"c1", "c2"}, status.Clusters)
+	assert.Equal(t, []arlonv1.AppClusterStatus{
+		{AppName: "wordpress", ClusterName: "c1", ApplicationName: "c1-app-wordpress",
+			Sync: "Synced", Health: "Healthy"},
+		{AppName: "wordpress", ClusterName: "c2", Message: "application not generated yet"},
+	}, status.AppStatuses)
+	assert.Equal(t, []string{"wordpress@c2"}, failingApps(&gProfileList.Items[0]))
+
+	// the state of the generated applications is reported
+	degraded := generatedApp("c2")
+	degraded.Status.Health.Status = "Degraded"
+	degraded.Status.Conditions = []argoapp.ApplicationCondition{
+		{Type: argoapp.ApplicationConditionSyncError, Message: "pods are crashing"},
+	}
+	gGeneratedAppList.Items = append(gGeneratedAppList.Items, degraded)
+	reconcile(t, mcr, mac, log)
+	assert.Equal(t, arlonv1.AppClusterStatus{
+		AppName: "wordpress", ClusterName: "c2", ApplicationName: "c2-app-wordpress",
+		Sync: "Synced", Health: "Degraded", Message: "pods are crashing",
+	}, status.AppStatuses[1])
+
+	// clusters no longer selected are removed
+	gClusterList.Items[1].Labels = nil
+	reconcile(t, mcr, mac, log)
+	assert.Equal(t, []string{"c1"}, status.Clusters)
+	assert.Len(t, status.AppStatuses, 1)
+	assert.Empty(t, failingApps(&gProfileList.Items[0]))
+}
+
 func reconcile(t *testing.T, mcr *mockCtrlRuntClient, mac *mockArgoClient, log logr.Logger) {
 	_, err := ReconcileEverything(context.TODO(), mcr, mac, log)
 	if err != nil {
@@ -569,3 +960,68 @@ func ensureProfileInvalidApps(t *testing.T, profName string, desiredInvApps []st
 		}
 	}
 }
+
+func TestPlanMembership(t *testing.T) {
+	var mcr *mockCtrlRuntClient
+	var mac *mockArgoClient
+
+	gClusterList = &v1alpha1.ClusterList{
+		Items: []v1alpha1.Cluster{
+			{Name: "c1", Server: "c1.local"},
+			{Name: "c2", Server: "c2.local", Annotations: map[string]string{"arlon.io/profiles": "qa"}},
+		},
+	}
+	clusterApp := func(name string, profiles string) v1alpha1.Application {
+		return v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{"arlon-type": "cluster-app", "managed-by": "arlon"},
+				Annotations: map[string]string{"arlon.io/profiles": profiles},
+			},
+		}
+	}
+	gApplicationList = &v1alpha1.ApplicationList{
+		Items: []v1alpha1.Application{clusterApp("c1", ""), clusterApp("c2", "qa")},
+	}
+	gArlonClusterList = &arlonv1.ClusterList{}
+	gGeneratedAppList = &argoapp.ApplicationList{}
+	gApplicationSetList = &argoapp.ApplicationSetList{Items: []argoapp.ApplicationSet{
+		arlonapp.Create("argocd", "wordpress", "default", "default",
+			"apps/wordpress", "https://github.com/org/repo", "HEAD", true, true, false),
+		arlonapp.Create("argocd", "teamcity", "default", "default",
+			"apps/teamcity", "https://github.com/org/repo", "HEAD", true, true, false),
+	}}
+	gProfileList = &arlonv1.AppProfileList{
+		Items: []arlonv1.AppProfile{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "qa"},
+				Spec:       arlonv1.AppProfileSpec{AppNames: []string{"teamcity"}},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "marketing"},
+				Spec:       arlonv1.AppProfileSpec{AppNames: []string{"wordpress"}},
+			},
+		},
+	}
+	reconcile(t, mcr, mac, logr.Discard())
+	assert.True(t, arlonAppTargetsTheseClusters(t, "teamcity", []string{"c2"}))
+
+	var pl plan.Plan
+	err := PlanMembership(context.TODO(), mcr, mac, "c1", "qa,marketing", &pl)
+	assert.NoError(t, err)
+	assert.Len(t, pl.Changes, 2)
+	changes := map[string]string{}
+	for _, c := range pl.Changes {
+		assert.Equal(t, "ApplicationSet", c.Kind)
+		changes[c.Name] = c.Diff
+	}
+	assert.Contains(t, changes["wordpress"], "+- cluster_name: c1\n")
+	assert.Contains(t, changes["teamcity"], "+- cluster_name: c1\n")
+	assert.Contains(t, changes["teamcity"], " - cluster_name: c2\n")
+
+	// nothing was changed
+	assert.True(t, arlonAppTargetsTheseClusters(t, "wordpress", []string{}))
+	assert.True(t, arlonAppTargetsTheseClusters(t, "teamcity", []string{"c2"}))
+	assert.True(t, argoClusterHasProfiles(t, "c1", nil))
+	assert.Empty(t, gApplicationList.Items[0].Annotations["arlon.io/profiles"])
+}
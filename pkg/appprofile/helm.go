@@ -0,0 +1,156 @@
+package appprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	argoappapi "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	arlonapp "github.com/arlonproj/arlon/pkg/app"
+	"github.com/arlonproj/arlon/pkg/helmvalues"
+	gyaml "github.com/ghodss/yaml"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// defaultValueFile pads the value file slots of a cluster that has none,
+// see appElements.
+const defaultValueFile = "values.yaml"
+
+// usesHelmValues returns whether the ApplicationSet of an app templates the
+// per-cluster Helm values into its source.
+func usesHelmValues(app *argoappapi.ApplicationSet) bool {
+	helm := app.Spec.Template.Spec.Source.Helm
+	return helm != nil && strings.Contains(helm.Values, arlonapp.HelmValuesTemplate)
+}
+
+// appElements computes the list generator elements of an app targeted to
+// the given clusters. For an app templating the Helm values, it also adjusts
+// the value file slots of the template to the largest number of value files
+// of a cluster, and returns whether the template changed.
+//
+// The number of value files of the template is the same for every cluster,
+// so the unused slots of a cluster repeat its last value file, which is a
+// no-op for Helm. A cluster with no value file at all repeats the last value
+// file of the template or the default value file of the chart.
+func appElements(
+	app *argoappapi.ApplicationSet,
+	clustNames []string,
+	clustNameToServer map[string]string,
+	valuesByCluster map[string][]arlonv1.AppHelmValues,
+) (elems []apiextensionsv1.JSON, templateChanged bool, err error) {
+	helm := usesHelmValues(app)
+	type clusterHelm struct {
+		values     string
+		valueFiles []string
+	}
+	helmByCluster := make(map[string]clusterHelm)
+	slots := 0
+	if helm {
+		for _, clustName := range clustNames {
+			values, valueFiles, err := mergeHelmValues(valuesByCluster[clustName])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid helm values for cluster %s: %s", clustName, err)
+			}
+			helmByCluster[clustName] = clusterHelm{values, valueFiles}
+			if len(valueFiles) > slots {
+				slots = len(valueFiles)
+			}
+		}
+		templateChanged = setValueFileSlots(app, slots)
+	}
+	baseValueFiles := templateValueFiles(app)
+	for _, clustName := range clustNames {
+		element := map[string]string{
+			arlonapp.ClusterNameParam:   clustName,
+			arlonapp.ClusterServerParam: clustNameToServer[clustName],
+		}
+		if helm {
+			ch := helmByCluster[clustName]
+			element[arlonapp.HelmValuesParam] = ch.values
+			for i := 0; i < slots; i++ {
+				element[arlonapp.HelmValueFileParam(i)] = padValueFile(ch.valueFiles, baseValueFiles, i)
+			}
+		}
+		raw, err := json.Marshal(element)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to marshal list element: %s", err)
+		}
+		elems = append(elems, apiextensionsv1.JSON{Raw: raw})
+	}
+	return elems, templateChanged, nil
+}
+
+// padValueFile returns the value file of a cluster for slot i.
+func padValueFile(valueFiles []string, baseValueFiles []string, i int) string {
+	if i < len(valueFiles) {
+		return valueFiles[i]
+	}
+	if len(valueFiles) > 0 {
+		return valueFiles[len(valueFiles)-1]
+	}
+	if len(baseValueFiles) > 0 {
+		return baseValueFiles[len(baseValueFiles)-1]
+	}
+	return defaultValueFile
+}
+
+// templateValueFiles returns the value files of the template of an app,
+// excluding the per-cluster slots.
+func templateValueFiles(app *argoappapi.ApplicationSet) []string {
+	helm := app.Spec.Template.Spec.Source.Helm
+	if helm == nil {
+		return nil
+	}
+	var valueFiles []string
+	for _, valueFile := range helm.ValueFiles {
+		if !arlonapp.IsHelmValueFileSlot(valueFile) {
+			valueFiles = append(valueFiles, valueFile)
+		}
+	}
+	return valueFiles
+}
+
+// setValueFileSlots makes the template of an app end with the given number
+// of per-cluster value file slots, and returns whether it changed.
+func setValueFileSlots(app *argoappapi.ApplicationSet, slots int) bool {
+	helm := app.Spec.Template.Spec.Source.Helm
+	valueFiles := templateValueFiles(app)
+	for i := 0; i < slots; i++ {
+		valueFiles = append(valueFiles, "{{"+arlonapp.HelmValueFileParam(i)+"}}")
+	}
+	if reflect.DeepEqual(valueFiles, helm.ValueFiles) ||
+		(len(valueFiles) == 0 && len(helm.ValueFiles) == 0) {
+		return false
+	}
+	helm.ValueFiles = valueFiles
+	return true
+}
+
+// mergeHelmValues merges Helm configurations in order, and returns the
+// resulting values block and value files.
+func mergeHelmValues(helmValues []arlonv1.AppHelmValues) (string, []string, error) {
+	values := map[string]interface{}{}
+	var valueFiles []string
+	for _, hv := range helmValues {
+		if hv.Values != "" {
+			var src map[string]interface{}
+			if err := gyaml.Unmarshal([]byte(hv.Values), &src); err != nil {
+				return "", nil, fmt.Errorf("failed to parse values of app %s: %s", hv.AppName, err)
+			}
+			helmvalues.Merge(values, src)
+		}
+		for _, param := range hv.Parameters {
+			if err := helmvalues.SetParameter(values, param.Name, param.Value, param.ForceString); err != nil {
+				return "", nil, fmt.Errorf("failed to set parameter of app %s: %s", hv.AppName, err)
+			}
+		}
+		valueFiles = append(valueFiles, hv.ValueFiles...)
+	}
+	out, err := gyaml.Marshal(values)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal values: %s", err)
+	}
+	return string(out), valueFiles, nil
+}
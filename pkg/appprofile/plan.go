@@ -0,0 +1,181 @@
+package appprofile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	argoclient "github.com/argoproj/argo-cd/v2/pkg/apiclient"
+	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	clusterpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
+	argoappapi "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	arlonapp "github.com/arlonproj/arlon/pkg/app"
+	"github.com/arlonproj/arlon/pkg/plan"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PlanMembership records in pl the changes of the clusters targeted by
+// arlon apps that the reconciliation would make once the app profiles of a
+// cluster are set to commaSeparatedAppProfiles. The reconciliation runs
+// against the current state, with the writes of the reconciler discarded,
+// so that nothing is changed.
+func PlanMembership(
+	ctx context.Context,
+	cli client.Client,
+	argocli argoclient.Client,
+	clusterName string,
+	commaSeparatedAppProfiles string,
+	pl *plan.Plan,
+) error {
+	planCli := &planClient{Client: cli, before: make(map[string][]apiextensionsv1.JSON)}
+	planArgoCli := &planArgoClient{
+		Client:      argocli,
+		clusterName: clusterName,
+		profiles:    commaSeparatedAppProfiles,
+	}
+	if _, err := ReconcileEverything(ctx, planCli, planArgoCli, logr.Discard()); err != nil {
+		return err
+	}
+	for _, appSet := range planCli.updated {
+		before, err := clusterElements(planCli.before[appSet.Name])
+		if err != nil {
+			return err
+		}
+		after, err := clusterElements(appSet.Spec.Generators[0].List.Elements)
+		if err != nil {
+			return err
+		}
+		if err := pl.AddObjectDiff("ApplicationSet", appSet.Name, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clusterElements decodes the elements of a list generator, sorted by
+// cluster name.
+func clusterElements(elems []apiextensionsv1.JSON) ([]map[string]interface{}, error) {
+	res := []map[string]interface{}{}
+	for _, elem := range elems {
+		var element map[string]interface{}
+		if err := json.Unmarshal(elem.Raw, &element); err != nil {
+			return nil, fmt.Errorf("failed to decode list generator element: %s", err)
+		}
+		res = append(res, element)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		ci, _ := res[i][arlonapp.ClusterNameParam].(string)
+		cj, _ := res[j][arlonapp.ClusterNameParam].(string)
+		return ci < cj
+	})
+	return res, nil
+}
+
+// planClient reads through a client, and records the applicationsets that
+// are updated instead of updating them. Other writes are discarded.
+type planClient struct {
+	client.Client
+	// list generator elements of the applicationsets that were listed
+	before  map[string][]apiextensionsv1.JSON
+	updated []*argoappapi.ApplicationSet
+}
+
+func (c *planClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	if appSetList, ok := list.(*argoappapi.ApplicationSetList); ok {
+		// the reconciler modifies the listed applicationsets, which must not
+		// share their generators with a cache
+		appSetList.Items = appSetList.DeepCopy().Items
+		for _, appSet := range appSetList.Items {
+			if len(appSet.Spec.Generators) == 1 && appSet.Spec.Generators[0].List != nil {
+				c.before[appSet.Name] = appSet.DeepCopy().Spec.Generators[0].List.Elements
+			}
+		}
+	}
+	return nil
+}
+
+func (c *planClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if appSet, ok := obj.(*argoappapi.ApplicationSet); ok {
+		c.updated = append(c.updated, appSet.DeepCopy())
+	}
+	return nil
+}
+
+func (c *planClient) Status() client.StatusWriter {
+	return planStatusWriter{}
+}
+
+type planStatusWriter struct {
+	client.StatusWriter
+}
+
+func (planStatusWriter) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return nil
+}
+
+// planArgoClient serves the arlon cluster applications with the app profiles
+// annotation of one cluster replaced, and discards the updates of Argo CD
+// clusters.
+type planArgoClient struct {
+	argoclient.Client
+	clusterName string
+	profiles    string
+}
+
+func (c *planArgoClient) NewClusterClient() (io.Closer, clusterpkg.ClusterServiceClient, error) {
+	conn, clApi, err := c.Client.NewClusterClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, &planClusterClient{ClusterServiceClient: clApi}, nil
+}
+
+func (c *planArgoClient) NewApplicationClient() (io.Closer, argoapp.ApplicationServiceClient, error) {
+	conn, appApi, err := c.Client.NewApplicationClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, &planApplicationClient{ApplicationServiceClient: appApi, parent: c}, nil
+}
+
+type planClusterClient struct {
+	clusterpkg.ClusterServiceClient
+}
+
+func (c *planClusterClient) Update(_ context.Context, in *clusterpkg.ClusterUpdateRequest,
+	_ ...grpc.CallOption) (*argoappapi.Cluster, error) {
+	return in.Cluster, nil
+}
+
+type planApplicationClient struct {
+	argoapp.ApplicationServiceClient
+	parent *planArgoClient
+}
+
+func (c *planApplicationClient) List(ctx context.Context, in *argoapp.ApplicationQuery,
+	opts ...grpc.CallOption) (*argoappapi.ApplicationList, error) {
+	apps, err := c.ApplicationServiceClient.List(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	apps = apps.DeepCopy()
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if app.Name != c.parent.clusterName {
+			continue
+		}
+		if app.Annotations == nil {
+			app.Annotations = make(map[string]string)
+		}
+		app.Annotations[arlonapp.ProfilesAnnotationKey] = c.parent.profiles
+	}
+	return apps, nil
+}
@@ -10,6 +10,8 @@ import (
 	argoappapi "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 
 	//appset "github.com/argoproj/argo-cd/v2/pkg/apis/applicationset/v1alpha1"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -20,8 +22,10 @@ import (
 	"github.com/go-logr/logr"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -108,6 +112,15 @@ func ReconcileEverything(
 	}
 	log.V(1).Info("apps counted", "count", len(appList.Items))
 
+	// Get the applications generated by the applicationsets, whose state is
+	// reported in the status of the profiles
+	var genAppList argoappapi.ApplicationList
+	err = cli.List(ctx, &genAppList, &client.ListOptions{Namespace: "argocd"})
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list argocd applications: %s", err)
+	}
+	generated := generatedApps(genAppList.Items, validAppNames)
+
 	// Get profiles
 	var profList arlonv1.AppProfileList
 	err = cli.List(ctx, &profList)
@@ -115,6 +128,47 @@ func ReconcileEverything(
 		return ctrl.Result{}, fmt.Errorf("failed to list app profiles: %s", err)
 	}
 	log.V(1).Info("app profiles counted", "count", len(profList.Items))
+	// The Helm values of the profiles of a cluster are merged in name order
+	profiles := make([]arlonv1.AppProfile, len(profList.Items))
+	copy(profiles, profList.Items)
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	profileSelectors := make(map[string]labels.Selector)
+	for _, prof := range profList.Items {
+		if isEmptySelector(prof.Spec.ClusterSelector) {
+			// an empty selector would select every cluster, the webhook
+			// rejects it
+			continue
+		}
+		profSel, err := metav1.LabelSelectorAsSelector(prof.Spec.ClusterSelector)
+		if err != nil {
+			log.Info("ignoring invalid cluster selector of app profile",
+				"profileName", prof.Name, "error", err.Error())
+			continue
+		}
+		profileSelectors[prof.Name] = profSel
+	}
+
+	// Get arlon cluster resources, whose labels are matched by the selectors
+	// and which may supply Helm values to their apps
+	var clusterList arlonv1.ClusterList
+	err = cli.List(ctx, &clusterList)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list arlon clusters: %s", err)
+	}
+	// An argo cluster is named after its arlon cluster, so the arlon clusters
+	// are looked up by name, and those of the same name in several namespaces
+	// are ignored rather than shadowing each other.
+	clusterResources := make(map[types.NamespacedName]*arlonv1.Cluster)
+	clusterKeys := make(map[string][]types.NamespacedName)
+	for i := range clusterList.Items {
+		cl := &clusterList.Items[i]
+		key := client.ObjectKeyFromObject(cl)
+		clusterResources[key] = cl
+		clusterKeys[cl.Name] = append(clusterKeys[cl.Name], key)
+	}
+	clusterAppValues := make(map[string][]arlonv1.AppHelmValues)
 
 	// Reconcile clusters
 	profileToClusters := make(map[string]sets.Set[string])
@@ -124,6 +178,18 @@ func ReconcileEverything(
 			argoClust.Annotations = make(map[string]string)
 		}
 		clustNameToServer[argoClust.Name] = argoClust.Server
+		var clusterResourceLabels map[string]string
+		if keys := clusterKeys[argoClust.Name]; len(keys) == 1 {
+			cl := clusterResources[keys[0]]
+			clusterResourceLabels = cl.Labels
+			clusterAppValues[argoClust.Name] = cl.Spec.AppValues
+		} else if len(keys) > 1 {
+			log.Info("ignoring labels and values of arlon clusters with the same name in several namespaces",
+				"clustName", argoClust.Name, "count", len(keys))
+		}
+		addSelectedProfiles(argoClust.Name, profileSelectors, profileToClusters,
+			argoClust.Labels, arlonClusterMap[argoClust.Name].Labels,
+			clusterResourceLabels)
 		argoClustAnnotation := argoClust.Annotations[arlonapp.ProfilesAnnotationKey]
 		dirty := false
 		arlonClust, ok := arlonClusterMap[argoClust.Name]
@@ -178,7 +244,8 @@ func ReconcileEverything(
 	// Reconcile profiles
 	profNames := sets.NewSet[string]()
 	appToClusters := make(map[string]sets.Set[string])
-	for _, prof := range profList.Items {
+	appClusterValues := make(map[string]map[string][]arlonv1.AppHelmValues)
+	for _, prof := range profiles {
 		profNames.Add(prof.Name)
 		dirty := false
 		beforeInvalidNames := sets.NewSet[string](prof.Status.InvalidAppNames...)
@@ -197,10 +264,27 @@ func ReconcileEverything(
 				}
 			}
 		}
+		profApps := sets.NewSet[string](prof.Spec.AppNames...)
+		for _, hv := range prof.Spec.AppValues {
+			if !profApps.Contains(hv.AppName) || clustersUsingThisProfile == nil {
+				continue
+			}
+			for clustName := range clustersUsingThisProfile.Iter() {
+				addAppClusterValues(appClusterValues, hv, clustName)
+			}
+		}
 		if !beforeInvalidNames.Equal(afterInvalidNames) {
 			prof.Status.InvalidAppNames = afterInvalidNames.ToSlice()
 			dirty = true
 		}
+		clusters, appStatuses := profileStatus(&prof, validAppNames,
+			clustersUsingThisProfile, clustNameToServer, generated)
+		if !reflect.DeepEqual(clusters, prof.Status.Clusters) ||
+			!reflect.DeepEqual(appStatuses, prof.Status.AppStatuses) {
+			prof.Status.Clusters = clusters
+			prof.Status.AppStatuses = appStatuses
+			dirty = true
+		}
 		beforeHealth := prof.Status.Health
 		var afterHealth string
 		if len(prof.Status.InvalidAppNames) > 0 {
@@ -222,6 +306,15 @@ func ReconcileEverything(
 		}
 	}
 
+	// The Helm values of a cluster are merged over the ones of its profiles
+	for clustName, clustValues := range clusterAppValues {
+		for _, hv := range clustValues {
+			if appToClusters[hv.AppName] != nil && appToClusters[hv.AppName].Contains(clustName) {
+				addAppClusterValues(appClusterValues, hv, clustName)
+			}
+		}
+	}
+
 	// Reconcile apps
 	for _, app := range appList.Items {
 		if app.Spec.Generators == nil || len(app.Spec.Generators) != 1 {
@@ -235,39 +328,24 @@ func ReconcileEverything(
 				"appSetName", app.Name)
 			continue
 		}
-		elems := clustGen.Elements
-		beforeClusters := sets.NewSet[string]()
-		for _, elem := range elems {
-			var element map[string]interface{}
-			err = json.Unmarshal(elem.Raw, &element)
-			if err != nil {
-				log.Error(err, "error decoding json element", "appSetName", app.Name)
-				break
-			}
-			for key, value := range element {
-				if key == "cluster_name" {
-					clust, ok := value.(string)
-					if !ok {
-						log.Info("value of cluster key is not a string", "appSetName", app.Name)
-						continue
-					}
-					beforeClusters.Add(clust)
-				}
-			}
+		var afterClusters []string
+		if appToClusters[app.Name] != nil {
+			afterClusters = appToClusters[app.Name].ToSlice()
+			sort.Strings(afterClusters)
 		}
-		afterClusters := appToClusters[app.Name]
-		if afterClusters == nil {
-			afterClusters = sets.NewSet[string]()
+		newElems, templateChanged, err := appElements(&app, afterClusters,
+			clustNameToServer, appClusterValues[app.Name])
+		if err != nil {
+			log.Info("failed to compute app's list generator's elements",
+				"app", app.Name, "error", err.Error())
+			continue
 		}
-		if afterClusters.Equal(beforeClusters) {
+		if !templateChanged && elementsEqual(clustGen.Elements, newElems) {
 			continue // no update needed
 		}
 		// Update applicationset's generator with new element list
-		newElems := []apiextensionsv1.JSON{}
-		for clustName := range afterClusters.Iter() {
-			jsonStr := fmt.Sprintf(`{"cluster_name":"%s", "cluster_server":"%s"}`,
-				clustName, clustNameToServer[clustName])
-			newElems = append(newElems, apiextensionsv1.JSON{Raw: []byte(jsonStr)})
+		if newElems == nil {
+			newElems = []apiextensionsv1.JSON{}
 		}
 		app.Spec.Generators[0].List.Elements = newElems
 		log.Info("updating app's list generator's elements",
@@ -297,3 +375,70 @@ func updateProfileToClustersMap(
 		profileToClusters[profile].Add(clustName)
 	}
 }
+
+// isEmptySelector returns true if a label selector is nil or has no
+// requirement.
+func isEmptySelector(sel *metav1.LabelSelector) bool {
+	return sel == nil || (len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0)
+}
+
+// addSelectedProfiles adds a cluster to every profile whose cluster selector
+// matches the union of the labels of the cluster's representations.
+func addSelectedProfiles(
+	clustName string,
+	profileSelectors map[string]labels.Selector,
+	profileToClusters map[string]sets.Set[string],
+	labelMaps ...map[string]string,
+) {
+	if len(profileSelectors) == 0 {
+		return
+	}
+	clustLabels := labels.Set{}
+	for _, labelMap := range labelMaps {
+		for key, value := range labelMap {
+			clustLabels[key] = value
+		}
+	}
+	for profName, profSel := range profileSelectors {
+		if !profSel.Matches(clustLabels) {
+			continue
+		}
+		if profileToClusters[profName] == nil {
+			profileToClusters[profName] = sets.NewSet[string]()
+		}
+		profileToClusters[profName].Add(clustName)
+	}
+}
+
+func addAppClusterValues(
+	appClusterValues map[string]map[string][]arlonv1.AppHelmValues,
+	hv arlonv1.AppHelmValues,
+	clustName string,
+) {
+	if appClusterValues[hv.AppName] == nil {
+		appClusterValues[hv.AppName] = make(map[string][]arlonv1.AppHelmValues)
+	}
+	appClusterValues[hv.AppName][clustName] = append(appClusterValues[hv.AppName][clustName], hv)
+}
+
+// elementsEqual returns whether two lists of list generator elements contain
+// the same elements, in any order.
+func elementsEqual(before []apiextensionsv1.JSON, after []apiextensionsv1.JSON) bool {
+	if len(before) != len(after) {
+		return false
+	}
+	decode := func(elems []apiextensionsv1.JSON) map[string]map[string]interface{} {
+		byCluster := make(map[string]map[string]interface{})
+		for _, elem := range elems {
+			var element map[string]interface{}
+			if err := json.Unmarshal(elem.Raw, &element); err != nil {
+				return nil
+			}
+			clustName, _ := element[arlonapp.ClusterNameParam].(string)
+			byCluster[clustName] = element
+		}
+		return byCluster
+	}
+	beforeByCluster := decode(before)
+	return beforeByCluster != nil && reflect.DeepEqual(beforeByCluster, decode(after))
+}
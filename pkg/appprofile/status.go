@@ -0,0 +1,97 @@
+package appprofile
+
+import (
+	"sort"
+
+	argoappapi "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	sets "github.com/deckarep/golang-set/v2"
+)
+
+// generatedApps indexes the Argo CD applications generated by the
+// ApplicationSets of arlon apps, by app name then by destination cluster
+// server or name.
+func generatedApps(
+	apps []argoappapi.Application,
+	validAppNames sets.Set[string],
+) map[string]map[string]*argoappapi.Application {
+	index := make(map[string]map[string]*argoappapi.Application)
+	for i := range apps {
+		app := &apps[i]
+		for _, owner := range app.OwnerReferences {
+			if owner.Kind != "ApplicationSet" || !validAppNames.Contains(owner.Name) {
+				continue
+			}
+			if index[owner.Name] == nil {
+				index[owner.Name] = make(map[string]*argoappapi.Application)
+			}
+			dest := app.Spec.Destination.Server
+			if dest == "" {
+				dest = app.Spec.Destination.Name
+			}
+			index[owner.Name][dest] = app
+		}
+	}
+	return index
+}
+
+// profileStatus computes the sorted clusters of a profile and the state of
+// each of its valid apps on each of those clusters.
+func profileStatus(
+	prof *arlonv1.AppProfile,
+	validAppNames sets.Set[string],
+	clusters sets.Set[string],
+	clustNameToServer map[string]string,
+	generated map[string]map[string]*argoappapi.Application,
+) ([]string, []arlonv1.AppClusterStatus) {
+	if clusters == nil || clusters.Cardinality() == 0 {
+		return nil, nil
+	}
+	clustNames := clusters.ToSlice()
+	sort.Strings(clustNames)
+	appNames := sets.NewSet[string]()
+	for _, appName := range prof.Spec.AppNames {
+		if validAppNames.Contains(appName) {
+			appNames.Add(appName)
+		}
+	}
+	sortedAppNames := appNames.ToSlice()
+	sort.Strings(sortedAppNames)
+	var statuses []arlonv1.AppClusterStatus
+	for _, appName := range sortedAppNames {
+		for _, clustName := range clustNames {
+			acs := arlonv1.AppClusterStatus{
+				AppName:     appName,
+				ClusterName: clustName,
+			}
+			app := generated[appName][clustNameToServer[clustName]]
+			if app == nil {
+				app = generated[appName][clustName]
+			}
+			if app == nil {
+				acs.Message = "application not generated yet"
+			} else {
+				acs.ApplicationName = app.Name
+				acs.Sync = string(app.Status.Sync.Status)
+				acs.Health = string(app.Status.Health.Status)
+				acs.Message = applicationError(app)
+			}
+			statuses = append(statuses, acs)
+		}
+	}
+	return clustNames, statuses
+}
+
+// applicationError returns the message of the first error condition of an
+// application, or of its last failed operation.
+func applicationError(app *argoappapi.Application) string {
+	for _, cond := range app.Status.Conditions {
+		if cond.IsError() {
+			return cond.Message
+		}
+	}
+	if op := app.Status.OperationState; op != nil && op.Phase.Completed() && !op.Phase.Successful() {
+		return op.Message
+	}
+	return ""
+}
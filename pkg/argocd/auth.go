@@ -0,0 +1,269 @@
+package argocd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	argogit "github.com/argoproj/argo-cd/v2/util/git"
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	githubApiUrl              = "https://api.github.com"
+	githubAccessTokenUsername = "x-access-token"
+)
+
+// AuthMethod returns the go-git authentication method for the credentials,
+// in the same order of precedence as Argo CD: SSH private key, GitHub App,
+// then username and password. TLS client certificates, the insecure flag and
+// the proxy of https repositories are applied to all subsequent git
+// operations on the repository, including push.
+func (creds *RepoCreds) AuthMethod(repoUrl string) (transport.AuthMethod, error) {
+	if isSSH, user := argogit.IsSSHURL(repoUrl); isSSH {
+		if creds.SSHPrivateKey == "" {
+			return nil, nil
+		}
+		return creds.sshAuth(user)
+	}
+	if err := registerRepoSettings(repoUrl, creds); err != nil {
+		return nil, err
+	}
+	if creds.GithubAppPrivateKey != "" {
+		token, err := creds.githubAppToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get github app access token: %s", err)
+		}
+		return &githttp.BasicAuth{Username: githubAccessTokenUsername, Password: token}, nil
+	}
+	if creds.Username == "" && creds.Password == "" {
+		return nil, nil
+	}
+	return &githttp.BasicAuth{Username: creds.Username, Password: creds.Password}, nil
+}
+
+// -----------------------------------------------------------------------------
+
+func (creds *RepoCreds) sshAuth(user string) (transport.AuthMethod, error) {
+	if user == "" {
+		user = gitssh.DefaultUsername
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(creds.SSHPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh private key: %s", err)
+	}
+	auth := &gitssh.PublicKeys{User: user, Signer: signer}
+	if creds.Insecure {
+		auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return auth, nil
+	}
+	if creds.SSHKnownHosts == "" {
+		// fall back to the known_hosts files of the local user
+		auth.HostKeyCallback, err = gitssh.NewKnownHostsCallback()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ssh known hosts: %s", err)
+		}
+		return auth, nil
+	}
+	f, err := os.CreateTemp("", "arlon-known-hosts-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create known hosts file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(creds.SSHKnownHosts)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write known hosts file: %s", err)
+	}
+	auth.HostKeyCallback, err = gitssh.NewKnownHostsCallback(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh known hosts: %s", err)
+	}
+	return auth, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// githubAppTransports caches the transports used to fetch installation access
+// tokens. A transport reuses its token until it expires.
+var githubAppTransports sync.Map
+
+func (creds *RepoCreds) githubAppToken() (string, error) {
+	baseUrl := githubApiUrl
+	if creds.GithubAppEnterpriseBaseUrl != "" {
+		baseUrl = strings.TrimSuffix(creds.GithubAppEnterpriseBaseUrl, "/")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s %d %d %s %s",
+		creds.GithubAppPrivateKey, creds.GithubAppId,
+		creds.GithubAppInstallationId, baseUrl, settingsHash(creds)))))
+	if t, found := githubAppTransports.Load(key); found {
+		return t.(*ghinstallation.Transport).Token(ctx)
+	}
+	// the API requests use the TLS and proxy settings of the credentials
+	// directly, they are not routed like the git requests
+	transport, err := credsTransport(creds)
+	if err != nil {
+		return "", err
+	}
+	itr, err := ghinstallation.New(transport, creds.GithubAppId,
+		creds.GithubAppInstallationId, []byte(creds.GithubAppPrivateKey))
+	if err != nil {
+		return "", err
+	}
+	itr.BaseURL = baseUrl
+	githubAppTransports.Store(key, itr)
+	return itr.Token(ctx)
+}
+
+// -----------------------------------------------------------------------------
+
+// go-git selects the transport of a git operation by URL scheme only, so a
+// single http client is installed for https. Its round tripper routes each
+// request to the transport registered for the longest repository URL that
+// the request URL starts with, which applies the TLS and proxy settings of
+// the repository's credentials. Since transports are registered per
+// repository URL, repositories of the same host with different settings
+// don't replace each other's transport. The TLS settings are set in the TLS
+// configuration of the transport, so that they also apply to the connections
+// tunneled through a proxy. The transport of a repository is only replaced
+// when its settings change, and is never modified once in use.
+
+// repoTransport is the transport of an https repository with specific
+// settings.
+type repoTransport struct {
+	// Hash of the settings of the transport
+	key       string
+	transport *http.Transport
+}
+
+var (
+	// repoTransports holds the transports by repository key, see repoKey
+	repoTransports   sync.Map
+	defaultTransport = newTransport(nil, nil)
+	installOnce      sync.Once
+)
+
+// repoKey returns the host and path of a URL, which identify the
+// repository, or the repositories of a credential template, that it
+// belongs to.
+func repoKey(u *url.URL) string {
+	return strings.ToLower(u.Host) + strings.TrimSuffix(u.Path, "/")
+}
+
+func registerRepoSettings(repoUrl string, creds *RepoCreds) error {
+	u, err := url.Parse(repoUrl)
+	if err != nil || u.Scheme != "https" {
+		return nil
+	}
+	installHttpsClient()
+	key := repoKey(u)
+	if !creds.Insecure && creds.TLSClientCertData == "" && creds.Proxy == "" {
+		repoTransports.Delete(key)
+		return nil
+	}
+	settingsKey := settingsHash(creds)
+	if rt, found := repoTransports.Load(key); found && rt.(*repoTransport).key == settingsKey {
+		return nil
+	}
+	transport, err := credsTransport(creds)
+	if err != nil {
+		return err
+	}
+	repoTransports.Store(key, &repoTransport{key: settingsKey, transport: transport})
+	return nil
+}
+
+// settingsHash returns a hash of the TLS and proxy settings of credentials.
+func settingsHash(creds *RepoCreds) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%t\x00%s\x00%s\x00%s",
+		creds.Insecure, creds.TLSClientCertData, creds.TLSClientCertKey, creds.Proxy))))
+}
+
+// credsTransport returns a transport applying the TLS and proxy settings of
+// credentials.
+func credsTransport(creds *RepoCreds) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: creds.Insecure}
+	if creds.TLSClientCertData != "" {
+		cert, err := tls.X509KeyPair([]byte(creds.TLSClientCertData),
+			[]byte(creds.TLSClientCertKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	var proxy *url.URL
+	if creds.Proxy != "" {
+		var err error
+		proxy, err = url.Parse(creds.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse proxy url: %s", err)
+		}
+	}
+	return newTransport(tlsConfig, proxy), nil
+}
+
+// newTransport returns a transport with a TLS configuration and a proxy,
+// the proxy of the environment if proxy is nil.
+func newTransport(tlsConfig *tls.Config, proxy *url.URL) *http.Transport {
+	t := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+		ForceAttemptHTTP2:   true,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t
+}
+
+// lookupTransport returns the transport of the repository of a request URL.
+func lookupTransport(u *url.URL) *http.Transport {
+	reqKey := repoKey(u)
+	var match string
+	var transport *http.Transport
+	repoTransports.Range(func(k, v interface{}) bool {
+		key := k.(string)
+		if (reqKey == key || strings.HasPrefix(reqKey, key+"/")) && len(key) > len(match) {
+			match = key
+			transport = v.(*repoTransport).transport
+		}
+		return true
+	})
+	if transport == nil {
+		return defaultTransport
+	}
+	return transport
+}
+
+// repoRoundTripper sends a request with the transport of its repository.
+type repoRoundTripper struct{}
+
+func (repoRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return lookupTransport(req.URL).RoundTrip(req)
+}
+
+// installHttpsClient installs the http client routing the requests of go-git
+// to the transports of their repository.
+func installHttpsClient() {
+	installOnce.Do(func() {
+		client.InstallProtocol("https", githttp.NewClient(&http.Client{
+			Transport: repoRoundTripper{},
+		}))
+	})
+}
@@ -3,50 +3,100 @@ package argocd
 import (
 	"context"
 	"fmt"
+	argogit "github.com/argoproj/argo-cd/v2/util/git"
+	"github.com/arlonproj/arlon/pkg/gitcache"
 	gogit "github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"os"
-	"strings"
+	"strconv"
 )
 
+const (
+	secretTypeLabel      = "argocd.argoproj.io/secret-type"
+	secretTypeRepository = "repository"
+	secretTypeRepoCreds  = "repo-creds"
+	sshKnownHostsCmName  = "argocd-ssh-known-hosts-cm"
+	sshKnownHostsKey     = "ssh_known_hosts"
+
+	// Annotations of argocd repository (or repo-creds) secrets that
+	// configure how arlon writes to the repository
+	GitWriteModeAnnotation      = "arlon.io/git-write-mode"
+	GitProviderAnnotation       = "arlon.io/git-provider"
+	GitProviderApiUrlAnnotation = "arlon.io/git-provider-api-url"
+	// Key of argocd repository (or repo-creds) secrets holding the API
+	// token with which arlon opens pull requests
+	GitProviderTokenKey = "gitProviderToken"
+
+	// GitWriteModePush pushes commits directly to the target branch
+	GitWriteModePush = "push"
+	// GitWriteModePullRequest pushes commits to a new branch and opens a
+	// pull request to merge it into the target branch
+	GitWriteModePullRequest = "pull-request"
+)
+
+// RepoCreds holds the credentials of a git repository registered with argocd,
+// using the same fields as argocd repository secrets.
 type RepoCreds struct {
-	Url      string
-	Username string
-	Password string
+	Url                        string
+	Username                   string
+	Password                   string
+	SSHPrivateKey              string
+	SSHKnownHosts              string
+	TLSClientCertData          string
+	TLSClientCertKey           string
+	GithubAppPrivateKey        string
+	GithubAppId                int64
+	GithubAppInstallationId    int64
+	GithubAppEnterpriseBaseUrl string
+	Insecure                   bool
+	Proxy                      string
+
+	// How changes are written to the repository, GitWriteModePush if empty
+	WriteMode string
+	// Type and API URL of the git provider used to open pull requests
+	GitProvider       string
+	GitProviderApiUrl string
+	// API token of the git provider, if different from the password
+	GitProviderToken string
+}
+
+// HasCredentials returns true if any kind of credential is set.
+func (creds *RepoCreds) HasCredentials() bool {
+	return creds.Username != "" || creds.Password != "" ||
+		creds.SSHPrivateKey != "" || creds.TLSClientCertData != "" ||
+		creds.GithubAppPrivateKey != ""
 }
 
 // -----------------------------------------------------------------------------
 
-// CloneRepo clones a git repo registered with argocd into a local repository
+// CloneRepo checks out a branch of a git repo registered with argocd into a
+// temporary directory, using the local repository cache. The caller must
+// remove the directory when done. The returned authentication method must be
+// used to push to the repository.
 func CloneRepo(
 	creds *RepoCreds,
 	repoUrl string,
 	repoBranch string,
-) (repo *gogit.Repository, tmpDir string, auth *http.BasicAuth, err error) {
-	auth = &http.BasicAuth{
-		Username: creds.Username,
-		Password: creds.Password,
-	}
-	tmpDir, err = os.MkdirTemp("", "arlon-")
+) (repo *gogit.Repository, tmpDir string, auth transport.AuthMethod, err error) {
+	return CloneRepoWithOptions(creds, repoUrl, repoBranch, nil)
+}
+
+// CloneRepoWithOptions is CloneRepo with shallow and sparse checkout options.
+func CloneRepoWithOptions(
+	creds *RepoCreds,
+	repoUrl string,
+	repoBranch string,
+	opts *gitcache.Options,
+) (repo *gogit.Repository, tmpDir string, auth transport.AuthMethod, err error) {
+	auth, err = creds.AuthMethod(repoUrl)
 	if err != nil {
-		return nil, "", nil, err
-	}
-	branchRef := plumbing.NewBranchReferenceName(repoBranch)
-	repo, err = gogit.PlainCloneContext(context.Background(), tmpDir, false, &gogit.CloneOptions{
-		URL:           repoUrl,
-		Auth:          auth,
-		RemoteName:    gogit.DefaultRemoteName,
-		ReferenceName: branchRef,
-		SingleBranch:  true,
-		NoCheckout:    false,
-		Progress:      nil,
-		Tags:          gogit.NoTags,
-		CABundle:      nil,
-	})
+		return nil, "", nil, fmt.Errorf("failed to get git credentials: %s", err)
+	}
+	repo, tmpDir, err = gitcache.Checkout(context.Background(), repoUrl, repoBranch, auth, opts)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to clone repository: %s", err)
 	}
@@ -78,32 +128,189 @@ func GetKubeclientAndRepoCreds(
 
 // -----------------------------------------------------------------------------
 
+// GetRepoCredsFromArgoCd returns the credentials of a repository registered
+// with argocd. As in argocd, a repository without credentials inherits them
+// from the repo-creds template with the longest URL prefix matching the
+// repository URL. A matching template is also used if the repository itself
+// is not registered.
 func GetRepoCredsFromArgoCd(
-	kubeClient *kubernetes.Clientset,
+	kubeClient kubernetes.Interface,
 	argocdNs string,
 	repoUrl string,
 ) (creds *RepoCreds, err error) {
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(argocdNs)
-	opts := metav1.ListOptions{
-		LabelSelector: "argocd.argoproj.io/secret-type=repository",
-	}
-	secrets, err := secretsApi.List(context.Background(), opts)
+	secretsApi := kubeClient.CoreV1().Secrets(argocdNs)
+	secrets, err := secretsApi.List(context.Background(), metav1.ListOptions{
+		LabelSelector: secretTypeLabel + "=" + secretTypeRepository,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %s", err)
 	}
 	for _, repoSecret := range secrets.Items {
-		if strings.Compare(repoUrl, string(repoSecret.Data["url"])) == 0 {
-			creds = &RepoCreds{
-				Url:      string(repoSecret.Data["url"]),
-				Username: string(repoSecret.Data["username"]),
-				Password: string(repoSecret.Data["password"]),
+		if isHelmRepoSecret(&repoSecret) {
+			continue
+		}
+		if argogit.SameURL(repoUrl, string(repoSecret.Data["url"])) {
+			creds, err = repoCredsFromSecret(&repoSecret)
+			if err != nil {
+				return nil, err
 			}
 			break
 		}
 	}
+	if creds == nil || !creds.HasCredentials() {
+		tmpl, err := getRepoCredsTemplate(kubeClient, argocdNs, repoUrl)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl != nil {
+			if creds != nil {
+				// keep the repository's own connection settings
+				tmpl.Insecure = tmpl.Insecure || creds.Insecure
+				if creds.Proxy != "" {
+					tmpl.Proxy = creds.Proxy
+				}
+			}
+			if creds != nil && creds.WriteMode != "" {
+				tmpl.WriteMode = creds.WriteMode
+				tmpl.GitProvider = creds.GitProvider
+				tmpl.GitProviderApiUrl = creds.GitProviderApiUrl
+			}
+			if creds != nil && creds.GitProviderToken != "" {
+				tmpl.GitProviderToken = creds.GitProviderToken
+			}
+			tmpl.Url = repoUrl
+			creds = tmpl
+		}
+	}
 	if creds == nil {
 		return nil, fmt.Errorf("did not find argocd repository matching %s (did you register it?)", repoUrl)
 	}
+	if isSSH, _ := argogit.IsSSHURL(repoUrl); isSSH && !creds.Insecure {
+		creds.SSHKnownHosts, err = getSSHKnownHosts(kubeClient, argocdNs)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return creds, nil
 }
+
+// -----------------------------------------------------------------------------
+
+// getRepoCredsTemplate returns the repo-creds template with the longest URL
+// that is a prefix of the repository URL, or nil if none matches.
+func getRepoCredsTemplate(
+	kubeClient kubernetes.Interface,
+	argocdNs string,
+	repoUrl string,
+) (*RepoCreds, error) {
+	secrets, err := kubeClient.CoreV1().Secrets(argocdNs).List(context.Background(),
+		metav1.ListOptions{
+			LabelSelector: secretTypeLabel + "=" + secretTypeRepoCreds,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo-creds secrets: %s", err)
+	}
+	normalizedUrl := argogit.NormalizeGitURL(repoUrl)
+	var best *corev1.Secret
+	var bestLen int
+	for i, secret := range secrets.Items {
+		if isHelmRepoSecret(&secret) {
+			continue
+		}
+		prefix := argogit.NormalizeGitURL(string(secret.Data["url"]))
+		if prefix == "" || len(prefix) <= bestLen {
+			continue
+		}
+		if len(normalizedUrl) >= len(prefix) && normalizedUrl[:len(prefix)] == prefix {
+			best = &secrets.Items[i]
+			bestLen = len(prefix)
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+	return repoCredsFromSecret(best)
+}
+
+// -----------------------------------------------------------------------------
+
+func repoCredsFromSecret(secret *corev1.Secret) (*RepoCreds, error) {
+	creds := &RepoCreds{
+		Url:                        string(secret.Data["url"]),
+		Username:                   string(secret.Data["username"]),
+		Password:                   string(secret.Data["password"]),
+		SSHPrivateKey:              string(secret.Data["sshPrivateKey"]),
+		TLSClientCertData:          string(secret.Data["tlsClientCertData"]),
+		TLSClientCertKey:           string(secret.Data["tlsClientCertKey"]),
+		GithubAppPrivateKey:        string(secret.Data["githubAppPrivateKey"]),
+		GithubAppEnterpriseBaseUrl: string(secret.Data["githubAppEnterpriseBaseUrl"]),
+		Proxy:                      string(secret.Data["proxy"]),
+		WriteMode:                  secret.Annotations[GitWriteModeAnnotation],
+		GitProvider:                secret.Annotations[GitProviderAnnotation],
+		GitProviderApiUrl:          secret.Annotations[GitProviderApiUrlAnnotation],
+		GitProviderToken:           string(secret.Data[GitProviderTokenKey]),
+	}
+	switch creds.WriteMode {
+	case "", GitWriteModePush, GitWriteModePullRequest:
+	default:
+		return nil, fmt.Errorf("invalid %s annotation in secret %s: %s",
+			GitWriteModeAnnotation, secret.Name, creds.WriteMode)
+	}
+	var err error
+	if creds.Insecure, err = secretBool(secret, "insecure"); err != nil {
+		return nil, err
+	}
+	if creds.GithubAppId, err = secretInt(secret, "githubAppID"); err != nil {
+		return nil, err
+	}
+	if creds.GithubAppInstallationId, err = secretInt(secret, "githubAppInstallationID"); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func isHelmRepoSecret(secret *corev1.Secret) bool {
+	repoType := string(secret.Data["type"])
+	return repoType != "" && repoType != "git"
+}
+
+func secretBool(secret *corev1.Secret, key string) (bool, error) {
+	val, ok := secret.Data[key]
+	if !ok || len(val) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(string(val))
+	if err != nil {
+		return false, fmt.Errorf("invalid value of %s in secret %s: %s",
+			key, secret.Name, err)
+	}
+	return b, nil
+}
+
+func secretInt(secret *corev1.Secret, key string) (int64, error) {
+	val, ok := secret.Data[key]
+	if !ok || len(val) == 0 {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value of %s in secret %s: %s",
+			key, secret.Name, err)
+	}
+	return i, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// getSSHKnownHosts returns the ssh known hosts configured in argocd.
+func getSSHKnownHosts(kubeClient kubernetes.Interface, argocdNs string) (string, error) {
+	cm, err := kubeClient.CoreV1().ConfigMaps(argocdNs).Get(context.Background(),
+		sshKnownHostsCmName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get ssh known hosts: %s", err)
+	}
+	return cm.Data[sshKnownHostsKey], nil
+}
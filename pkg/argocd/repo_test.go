@@ -0,0 +1,167 @@
+package argocd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func argocdSecret(name string, secretType string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "argocd",
+			Labels:    map[string]string{secretTypeLabel: secretType},
+		},
+		Data: map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func TestGetRepoCredsFromArgoCd(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset(
+		argocdSecret("repo-1", secretTypeRepository, map[string]string{
+			"url":      "https://github.com/org/repo1.git",
+			"username": "user1",
+			"password": "pass1",
+		}),
+		argocdSecret("repo-2", secretTypeRepository, map[string]string{
+			"url":      "https://github.com/org/repo2",
+			"insecure": "true",
+		}),
+		argocdSecret("helm-1", secretTypeRepository, map[string]string{
+			"url":  "https://github.com/org/repo3",
+			"type": "helm",
+		}),
+		argocdSecret("creds-org", secretTypeRepoCreds, map[string]string{
+			"url":      "https://github.com/org",
+			"username": "org-user",
+			"password": "org-pass",
+		}),
+		argocdSecret("creds-team", secretTypeRepoCreds, map[string]string{
+			"url":                     "https://github.com/org/team",
+			"githubAppPrivateKey":     "key",
+			"githubAppID":             "12",
+			"githubAppInstallationID": "34",
+		}),
+	)
+
+	// registered repository with its own credentials
+	creds, err := GetRepoCredsFromArgoCd(kubeClient, "argocd", "https://github.com/org/repo1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", creds.Username)
+	assert.Equal(t, "pass1", creds.Password)
+
+	// registered repository without credentials inherits from the template
+	creds, err = GetRepoCredsFromArgoCd(kubeClient, "argocd", "https://github.com/org/repo2")
+	assert.NoError(t, err)
+	assert.Equal(t, "org-user", creds.Username)
+	assert.True(t, creds.Insecure)
+	assert.Equal(t, "https://github.com/org/repo2", creds.Url)
+
+	// helm repositories are ignored, the longest matching template wins
+	creds, err = GetRepoCredsFromArgoCd(kubeClient, "argocd", "https://github.com/org/repo3")
+	assert.NoError(t, err)
+	assert.Equal(t, "org-user", creds.Username)
+	creds, err = GetRepoCredsFromArgoCd(kubeClient, "argocd", "https://github.com/org/team/repo4.git")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", creds.GithubAppPrivateKey)
+	assert.Equal(t, int64(12), creds.GithubAppId)
+	assert.Equal(t, int64(34), creds.GithubAppInstallationId)
+
+	_, err = GetRepoCredsFromArgoCd(kubeClient, "argocd", "https://gitlab.com/org/repo1")
+	assert.Error(t, err)
+}
+
+func TestAuthMethod(t *testing.T) {
+	creds := &RepoCreds{Username: "user", Password: "pass"}
+	auth, err := creds.AuthMethod("https://github.com/org/repo.git")
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "user", Password: "pass"}, auth)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	assert.NoError(t, err)
+	creds = &RepoCreds{
+		SSHPrivateKey: string(pem.EncodeToMemory(block)),
+		Insecure:      true,
+	}
+	auth, err = creds.AuthMethod("git@github.com:org/repo.git")
+	assert.NoError(t, err)
+	pk, ok := auth.(*gitssh.PublicKeys)
+	assert.True(t, ok)
+	assert.Equal(t, "git", pk.User)
+
+	creds.SSHPrivateKey = "not a key"
+	_, err = creds.AuthMethod("ssh://git@github.com/org/repo.git")
+	assert.Error(t, err)
+}
+
+func TestRegisterRepoSettings(t *testing.T) {
+	repoUrl := "https://git.example.com/org/repo.git"
+	otherUrl := "https://git.example.com/org/other.git"
+	proxied := &RepoCreds{Insecure: true, Proxy: "http://proxy.example.com:3128"}
+	assert.NoError(t, registerRepoSettings(repoUrl, proxied))
+	req, err := http.NewRequest("GET", repoUrl+"/info/refs?service=git-upload-pack", nil)
+	assert.NoError(t, err)
+	transport := lookupTransport(req.URL)
+	// The TLS settings must be in the TLS configuration to apply through
+	// the proxy
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+	proxy, err := transport.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.String())
+
+	// The same settings reuse the transport
+	assert.NoError(t, registerRepoSettings(repoUrl, &RepoCreds{
+		Insecure: true, Proxy: "http://proxy.example.com:3128",
+	}))
+	assert.Same(t, transport, lookupTransport(req.URL))
+
+	// Another repository of the host with other settings or none doesn't
+	// affect the transport of the repository
+	otherReq, err := http.NewRequest("GET", otherUrl+"/info/refs", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, registerRepoSettings(otherUrl, &RepoCreds{Insecure: true}))
+	assert.NotSame(t, transport, lookupTransport(otherReq.URL))
+	assert.Same(t, transport, lookupTransport(req.URL))
+	assert.NoError(t, registerRepoSettings(otherUrl, &RepoCreds{}))
+	assert.Same(t, defaultTransport, lookupTransport(otherReq.URL))
+	assert.Same(t, transport, lookupTransport(req.URL))
+
+	// The settings of a credential template apply to the repositories under
+	// its URL, unless a repository has its own
+	assert.NoError(t, registerRepoSettings("https://git.example.com/org", &RepoCreds{Insecure: true}))
+	templateTransport := lookupTransport(otherReq.URL)
+	assert.NotSame(t, defaultTransport, templateTransport)
+	assert.Same(t, transport, lookupTransport(req.URL))
+	orgsReq, err := http.NewRequest("GET", "https://git.example.com/organization/repo.git/info/refs", nil)
+	assert.NoError(t, err)
+	assert.Same(t, defaultTransport, lookupTransport(orgsReq.URL))
+
+	// Changed settings replace the transport without modifying it
+	assert.NoError(t, registerRepoSettings(repoUrl, &RepoCreds{Insecure: true}))
+	assert.NotSame(t, transport, lookupTransport(req.URL))
+	proxy, err = transport.Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.String())
+
+	assert.NoError(t, registerRepoSettings(repoUrl, &RepoCreds{}))
+	assert.Same(t, templateTransport, lookupTransport(req.URL))
+	assert.NoError(t, registerRepoSettings("https://git.example.com/org", &RepoCreds{}))
+	assert.Same(t, defaultTransport, lookupTransport(req.URL))
+}
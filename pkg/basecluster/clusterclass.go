@@ -0,0 +1,301 @@
+package basecluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/argocd"
+	gyaml "github.com/ghodss/yaml"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ObjectGetter looks up a resource that a cluster template refers to
+// without containing it, for e.g. a ClusterClass installed in the
+// management cluster. It returns nil if the resource does not exist.
+type ObjectGetter func(apiVersion string, kind string, name string) (*unstructured.Unstructured, error)
+
+// NewObjectGetter returns an ObjectGetter looking up resources in a
+// namespace of the management cluster.
+func NewObjectGetter(cli client.Client, namespace string) ObjectGetter {
+	return func(apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		err := cli.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, obj)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s: %s", kind, name, err)
+		}
+		return obj, nil
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// isTopologyCluster returns true if a Cluster resource is based on a
+// ClusterClass.
+func isTopologyCluster(cluster *unstructured.Unstructured) bool {
+	_, found, _ := unstructured.NestedMap(cluster.Object, "spec", "topology")
+	return found
+}
+
+// findObject returns the resource of a cluster template, or else the one
+// returned by getter, that has the group of apiVersion, kind and name.
+func findObject(objs []*unstructured.Unstructured, getter ObjectGetter,
+	apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+	group := schema.FromAPIVersionAndKind(apiVersion, kind).Group
+	for _, obj := range objs {
+		if obj.GetKind() == kind && obj.GetName() == name &&
+			obj.GroupVersionKind().Group == group {
+			return obj, nil
+		}
+	}
+	if getter == nil {
+		return nil, nil
+	}
+	return getter(apiVersion, kind, name)
+}
+
+// validateTopology verifies that the ClusterClass of a topology based
+// Cluster and the templates that the ClusterClass refers to exist, either
+// in the cluster template or through getter, and that the topology is
+// consistent with the ClusterClass.
+func validateTopology(cluster *unstructured.Unstructured, objs []*unstructured.Unstructured,
+	getter ObjectGetter) error {
+	className, _, _ := unstructured.NestedString(cluster.Object, "spec", "topology", "class")
+	if className == "" {
+		return fmt.Errorf("%w: spec.topology.class is not set", ErrInvalidTopology)
+	}
+	version, _, _ := unstructured.NestedString(cluster.Object, "spec", "topology", "version")
+	if version == "" {
+		return fmt.Errorf("%w: spec.topology.version is not set", ErrInvalidTopology)
+	}
+	cc, err := findObject(objs, getter, cluster.GetAPIVersion(), "ClusterClass", className)
+	if err != nil {
+		return err
+	}
+	if cc == nil {
+		return fmt.Errorf("%w: %s", ErrNoClusterClass, className)
+	}
+	for _, ref := range classRefs(cc) {
+		tmpl, err := findObject(objs, getter, ref.apiVersion, ref.kind, ref.name)
+		if err != nil {
+			return err
+		}
+		if tmpl == nil {
+			return fmt.Errorf("%w: cluster class: %s, kind: %s, name: %s",
+				ErrNoClassTemplate, className, ref.kind, ref.name)
+		}
+	}
+	mdClasses := map[string]bool{}
+	mds, _, _ := unstructured.NestedSlice(cc.Object, "spec", "workers", "machineDeployments")
+	for _, md := range mds {
+		if mdMap, ok := md.(map[string]interface{}); ok {
+			class, _, _ := unstructured.NestedString(mdMap, "class")
+			mdClasses[class] = true
+		}
+	}
+	topoMds, _, _ := unstructured.NestedSlice(cluster.Object, "spec", "topology", "workers", "machineDeployments")
+	for _, md := range topoMds {
+		mdMap, ok := md.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		class, _, _ := unstructured.NestedString(mdMap, "class")
+		if !mdClasses[class] {
+			return fmt.Errorf("%w: machine deployment class %s is not defined by cluster class %s",
+				ErrInvalidTopology, class, className)
+		}
+	}
+	return validateVariables(cluster, cc)
+}
+
+// objectRef is a reference from a resource to another one by name.
+type objectRef struct {
+	// Path of the reference in the referencing resource
+	field      string
+	apiVersion string
+	kind       string
+	name       string
+}
+
+// classRefPaths are the paths of the references of a ClusterClass to its
+// templates, besides the ones of its machine deployment classes.
+var classRefPaths = [][]string{
+	{"spec", "infrastructure", "ref"},
+	{"spec", "controlPlane", "ref"},
+	{"spec", "controlPlane", "machineInfrastructure", "ref"},
+}
+
+// classRefs returns the references of a ClusterClass to its templates.
+func classRefs(cc *unstructured.Unstructured) []objectRef {
+	var refs []objectRef
+	add := func(obj map[string]interface{}, fieldPath []string, field string) {
+		ref, found, _ := unstructured.NestedMap(obj, fieldPath...)
+		if !found {
+			return
+		}
+		apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		refs = append(refs, objectRef{field: field, apiVersion: apiVersion, kind: kind, name: name})
+	}
+	for _, refPath := range classRefPaths {
+		add(cc.Object, refPath, fieldString(refPath))
+	}
+	mds, _, _ := unstructured.NestedSlice(cc.Object, "spec", "workers", "machineDeployments")
+	for i, md := range mds {
+		mdMap, ok := md.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"bootstrap", "infrastructure"} {
+			add(mdMap, []string{"template", field, "ref"},
+				fmt.Sprintf("spec.workers.machineDeployments[%d].template.%s.ref", i, field))
+		}
+	}
+	return refs
+}
+
+// validateVariables verifies that the variables of a topology are defined
+// by its ClusterClass, and that the required ones are set.
+func validateVariables(cluster *unstructured.Unstructured, cc *unstructured.Unstructured) error {
+	defined := map[string]bool{}
+	required := []string{}
+	defs, _, _ := unstructured.NestedSlice(cc.Object, "spec", "variables")
+	for _, def := range defs {
+		defMap, ok := def.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(defMap, "name")
+		defined[name] = true
+		if req, _, _ := unstructured.NestedBool(defMap, "required"); req {
+			required = append(required, name)
+		}
+	}
+	set := map[string]bool{}
+	vars, _, _ := unstructured.NestedSlice(cluster.Object, "spec", "topology", "variables")
+	for _, v := range vars {
+		vMap, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(vMap, "name")
+		if !defined[name] {
+			return fmt.Errorf("%w: variable %s is not defined by cluster class %s",
+				ErrInvalidTopology, name, cc.GetName())
+		}
+		set[name] = true
+	}
+	for _, name := range required {
+		if !set[name] {
+			return fmt.Errorf("%w: required variable %s is not set", ErrInvalidTopology, name)
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// TopologyPatch returns a strategic merge patch of the topology based Cluster
+// of a cluster template that applies a topology override. The variables of
+// the override replace the ones of the template with the same name, or are
+// added to them. Since a patch replaces the whole variables list of the
+// Cluster, the patch contains the merged list. The Cluster with the override
+// applied is validated against its ClusterClass.
+func TopologyPatch(objs []*unstructured.Unstructured, getter ObjectGetter,
+	ovr *arlonv1.TopologyOverride) ([]byte, error) {
+	var cluster *unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetKind() == "Cluster" {
+			cluster = obj.DeepCopy()
+			break
+		}
+	}
+	if cluster == nil {
+		return nil, ErrNoClusterResource
+	}
+	if !isTopologyCluster(cluster) {
+		return nil, ErrNoTopology
+	}
+	topology := map[string]interface{}{}
+	if ovr.Version != "" {
+		topology["version"] = ovr.Version
+		if err := unstructured.SetNestedField(cluster.Object, ovr.Version,
+			"spec", "topology", "version"); err != nil {
+			return nil, fmt.Errorf("failed to set topology version: %s", err)
+		}
+	}
+	if len(ovr.Variables) > 0 {
+		vars, _, _ := unstructured.NestedSlice(cluster.Object, "spec", "topology", "variables")
+		for _, ovrVar := range ovr.Variables {
+			var value interface{}
+			if err := json.Unmarshal(ovrVar.Value.Raw, &value); err != nil {
+				return nil, fmt.Errorf("failed to decode value of variable %s: %s", ovrVar.Name, err)
+			}
+			newVar := map[string]interface{}{"name": ovrVar.Name, "value": value}
+			replaced := false
+			for i, v := range vars {
+				if vMap, ok := v.(map[string]interface{}); ok && vMap["name"] == ovrVar.Name {
+					vars[i] = newVar
+					replaced = true
+				}
+			}
+			if !replaced {
+				vars = append(vars, newVar)
+			}
+		}
+		topology["variables"] = vars
+		if err := unstructured.SetNestedSlice(cluster.Object, vars,
+			"spec", "topology", "variables"); err != nil {
+			return nil, fmt.Errorf("failed to set topology variables: %s", err)
+		}
+	}
+	if err := validateTopology(cluster, objs, getter); err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{
+		"apiVersion": cluster.GetAPIVersion(),
+		"kind":       cluster.GetKind(),
+		"metadata":   map[string]interface{}{"name": cluster.GetName()},
+		"spec":       map[string]interface{}{"topology": topology},
+	}
+	data, err := gyaml.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize topology patch: %s", err)
+	}
+	return data, nil
+}
+
+// TopologyPatchFromGitDir returns the topology patch of the cluster template
+// in a git directory.
+func TopologyPatchFromGitDir(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	repoRevision string,
+	repoPath string,
+	getter ObjectGetter,
+	ovr *arlonv1.TopologyOverride,
+) ([]byte, error) {
+	var patch []byte
+	err := cloneGitDir(creds, repoUrl, repoRevision, repoPath,
+		func(dirPath string, infos []os.FileInfo) error {
+			objs, err := buildTemplateDir(dirPath, infos)
+			if err != nil {
+				return err
+			}
+			patch, err = TopologyPatch(objs, getter, ovr)
+			return err
+		})
+	return patch, err
+}
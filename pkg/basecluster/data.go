@@ -1,7 +1,9 @@
 package basecluster
 
 const kustomizationYamlTemplate = `resources:
-- {{.ManifestFileName}}
+{{- range .Resources}}
+- {{.}}
+{{- end}}
 
 configurations:
 - configurations.yaml
@@ -23,12 +25,30 @@ nameReference:
     kind: MachinePool
   - path: spec/template/spec/clusterName
     kind: MachinePool
+- kind: ClusterClass
+  group: cluster.x-k8s.io
+  version: v1beta1
+  fieldSpecs:
+  - path: spec/topology/class
+    kind: Cluster
 - kind: AWSCluster
   group: infrastructure.cluster.x-k8s.io
   version: v1beta1
   fieldSpecs:
   - path: spec/infrastructureRef/name
     kind: Cluster
+- kind: AWSClusterTemplate
+  group: infrastructure.cluster.x-k8s.io
+  version: v1beta2
+  fieldSpecs:
+  - path: spec/infrastructure/ref/name
+    kind: ClusterClass
+- kind: AWSClusterTemplate
+  group: infrastructure.cluster.x-k8s.io
+  version: v1beta1
+  fieldSpecs:
+  - path: spec/infrastructure/ref/name
+    kind: ClusterClass
 - kind: KubeadmControlPlane
   group: controlplane.cluster.x-k8s.io
   version: v1beta1
@@ -49,12 +69,24 @@ nameReference:
   fieldSpecs:
   - path: spec/controlPlaneRef/name
     kind: Cluster
+- kind: AWSManagedControlPlaneTemplate
+  group: controlplane.cluster.x-k8s.io
+  version: v1beta2
+  fieldSpecs:
+  - path: spec/controlPlane/ref/name
+    kind: ClusterClass
 - kind: AWSManagedCluster
   group: infrastructure.cluster.x-k8s.io
   version: v1beta2
   fieldSpecs:
   - path: spec/infrastructureRef/name
     kind: Cluster
+- kind: AWSManagedClusterTemplate
+  group: infrastructure.cluster.x-k8s.io
+  version: v1beta2
+  fieldSpecs:
+  - path: spec/infrastructure/ref/name
+    kind: ClusterClass
 - kind: AWSMachine
   group: infrastructure.cluster.x-k8s.io
   version: v1beta1
@@ -77,6 +109,10 @@ nameReference:
     kind: MachineDeployment
   - path: spec/machineTemplate/infrastructureRef/name
     kind: KubeadmControlPlane
+  - path: spec/controlPlane/machineInfrastructure/ref/name
+    kind: ClusterClass
+  - path: spec/workers/machineDeployments/template/infrastructure/ref/name
+    kind: ClusterClass
 - kind: AWSMachineTemplate
   group: infrastructure.cluster.x-k8s.io
   version: v1beta2
@@ -93,24 +129,34 @@ nameReference:
     kind: MachineDeployment
   - path: spec/machineTemplate/infrastructureRef/name
     kind: KubeadmControlPlane
+  - path: spec/controlPlane/machineInfrastructure/ref/name
+    kind: ClusterClass
+  - path: spec/workers/machineDeployments/template/infrastructure/ref/name
+    kind: ClusterClass
 - kind: KubeadmConfigTemplate
   group: bootstrap.cluster.x-k8s.io
   version: v1beta1
   fieldSpecs:
   - path: spec/template/spec/bootstrap/configRef/name
     kind: MachineDeployment
+  - path: spec/workers/machineDeployments/template/bootstrap/ref/name
+    kind: ClusterClass
 - kind: EKSConfigTemplate
   group: bootstrap.cluster.x-k8s.io
   version: v1beta2
   fieldSpecs:
   - path: spec/template/spec/bootstrap/configRef/name
     kind: MachineDeployment
+  - path: spec/workers/machineDeployments/template/bootstrap/ref/name
+    kind: ClusterClass
 - kind: EKSConfigTemplate
   group: bootstrap.cluster.x-k8s.io
   version: v1beta1
   fieldSpecs:
   - path: spec/template/spec/bootstrap/configRef/name
     kind: MachineDeployment
+  - path: spec/workers/machineDeployments/template/bootstrap/ref/name
+    kind: ClusterClass
 - kind: DockerCluster
   group: infrastructure.cluster.x-k8s.io
   version: v1beta1
@@ -131,6 +177,22 @@ nameReference:
     kind: MachineDeployment
   - path: spec/machineTemplate/infrastructureRef/name
     kind: KubeadmControlPlane
+  - path: spec/controlPlane/machineInfrastructure/ref/name
+    kind: ClusterClass
+  - path: spec/workers/machineDeployments/template/infrastructure/ref/name
+    kind: ClusterClass
+- kind: DockerClusterTemplate
+  group: infrastructure.cluster.x-k8s.io
+  version: v1beta1
+  fieldSpecs:
+  - path: spec/infrastructure/ref/name
+    kind: ClusterClass
+- kind: KubeadmControlPlaneTemplate
+  group: controlplane.cluster.x-k8s.io
+  version: v1beta1
+  fieldSpecs:
+  - path: spec/controlPlane/ref/name
+    kind: ClusterClass
 - kind: AWSManagedMachinePool
   group: infrastructure.cluster.x-k8s.io
   version: v1beta2
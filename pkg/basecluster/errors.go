@@ -5,13 +5,19 @@ import (
 )
 
 var (
-	ErrMultipleManifests    = errors.New("multiple manifests found")
-	ErrNoManifest           = errors.New("failed to find cluster template manifest file")
+	ErrNoManifest           = errors.New("cluster template renders no resources")
 	ErrNoKustomizationYaml  = errors.New("kustomization.yaml is missing")
 	ErrNoConfigurationsYaml = errors.New("configurations.yaml is missing")
 	ErrMultipleClusters     = errors.New("there are 2 or more clusters")
 	ErrNoClusterResource    = errors.New("no cluster resource found")
 	ErrBuilderFailedRun     = errors.New("builder failed to run")
+	ErrKustomizeBuildFailed = errors.New("kustomize build failed")
 	ErrResourceHasNamespace = errors.New("resource has a namespace defined")
 	Err2orMoreClusters      = errors.New("there are 2 or more clusters")
+	ErrInvalidTopology      = errors.New("invalid cluster topology")
+	ErrNoClusterClass       = errors.New("cluster class not found")
+	ErrNoClassTemplate      = errors.New("template referenced by cluster class not found")
+	ErrNoTopology           = errors.New("cluster is not based on a cluster class")
+	ErrInvalidPatch         = errors.New("invalid override patch")
+	ErrPatchNoMatch         = errors.New("override patch matches no resource")
 )
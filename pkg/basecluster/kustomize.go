@@ -0,0 +1,213 @@
+package basecluster
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// buildDir runs a kustomize build of a cluster template directory on the
+// native file system, and returns the rendered resources along with the
+// files, relative to the directory, that they come from. The file of a
+// resource from a remote base or a generator is empty.
+func buildDir(dirPath string) (objs []*unstructured.Unstructured, files []string, err error) {
+	return buildDirWithPrefix(dirPath, "")
+}
+
+// buildDirWithPrefix is buildDir with a name prefix set in the kustomization
+// of the directory, like Argo CD does for the name prefix of an application.
+func buildDirWithPrefix(dirPath string, namePrefix string) (
+	objs []*unstructured.Unstructured, files []string, err error) {
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path of %s: %s", dirPath, err)
+	}
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	fSys := &originFs{FileSystem: filesys.MakeFsOnDisk(), dirPath: absPath, namePrefix: namePrefix}
+	resMap, err := k.Run(fSys, absPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrKustomizeBuildFailed, err)
+	}
+	for _, res := range resMap.Resources() {
+		var file string
+		origin, err := res.GetOrigin()
+		if err == nil && origin != nil && origin.Repo == "" {
+			file = origin.Path
+		}
+		if !fSys.userOrigins {
+			if err := res.SetOrigin(nil); err != nil {
+				return nil, nil, fmt.Errorf("%w: resource %s: %s", ErrKustomizeBuildFailed, res.CurId(), err)
+			}
+		}
+		data, err := res.MarshalJSON()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: resource %s: %s", ErrKustomizeBuildFailed, res.CurId(), err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(data); err != nil {
+			return nil, nil, fmt.Errorf("%w: resource %s: %s", ErrKustomizeBuildFailed, res.CurId(), err)
+		}
+		objs = append(objs, obj)
+		files = append(files, file)
+	}
+	return objs, files, nil
+}
+
+// originFs serves the kustomization of the directory being built with the
+// origin annotations build option, so that the file of each rendered
+// resource is known, and with the name prefix if any.
+type originFs struct {
+	filesys.FileSystem
+	dirPath    string
+	namePrefix string
+	// whether the kustomization enables origin annotations itself
+	userOrigins bool
+}
+
+func (fs *originFs) ReadFile(filePath string) ([]byte, error) {
+	data, err := fs.FileSystem.ReadFile(filePath)
+	if err != nil || !isKustomization(filePath) {
+		return data, err
+	}
+	if absPath, err := filepath.Abs(filePath); err != nil || filepath.Dir(absPath) != fs.dirPath {
+		return data, nil
+	}
+	kust := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &kust); err != nil {
+		// reported by the build
+		return data, nil
+	}
+	if fs.namePrefix != "" {
+		kust["namePrefix"] = fs.namePrefix
+	}
+	buildMetadata, _ := kust["buildMetadata"].([]interface{})
+	for _, opt := range buildMetadata {
+		if opt == types.OriginAnnotations {
+			fs.userOrigins = true
+		}
+	}
+	if !fs.userOrigins {
+		kust["buildMetadata"] = append(buildMetadata, types.OriginAnnotations)
+	}
+	return yaml.Marshal(kust)
+}
+
+func isKustomization(filePath string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if filepath.Base(filePath) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+// findKustomization returns the name of the kustomization file of a
+// directory, or an empty string if it has none.
+func findKustomization(fs billy.Filesystem, dirRelPath string) (string, error) {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		_, err := fs.Stat(path.Join(dirRelPath, name))
+		if err == nil {
+			return name, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check for %s: %s", name, err)
+		}
+	}
+	return "", nil
+}
+
+// isRemoteResource returns true if a kustomization resource refers to a
+// remote base or file rather than a path in the repository.
+func isRemoteResource(resource string) bool {
+	return strings.Contains(resource, "://") || strings.HasPrefix(resource, "git::") ||
+		strings.HasPrefix(resource, "github.com/") || strings.HasPrefix(resource, "git@")
+}
+
+// resourceFiles returns the paths of the local manifest files that the
+// kustomization of a directory includes as resources, directly or through
+// the kustomizations of its bases and components. Remote resources are
+// ignored since they can't be modified.
+func resourceFiles(fs billy.Filesystem, dirRelPath string) ([]string, error) {
+	kustName, err := findKustomization(fs, dirRelPath)
+	if err != nil {
+		return nil, err
+	}
+	if kustName == "" {
+		return nil, fmt.Errorf("no kustomization file in %s", dirRelPath)
+	}
+	kustPath := path.Join(dirRelPath, kustName)
+	data, err := util.ReadFile(fs, kustPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", kustPath, err)
+	}
+	var kust types.Kustomization
+	if err := yaml.Unmarshal(data, &kust); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", kustPath, err)
+	}
+	kust.FixKustomizationPostUnmarshalling()
+	var files []string
+	for _, res := range append(kust.Resources, kust.Components...) {
+		if isRemoteResource(res) {
+			continue
+		}
+		resPath := path.Join(dirRelPath, res)
+		info, err := fs.Stat(resPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find resource %s of %s: %s", res, kustPath, err)
+		}
+		if !info.IsDir() {
+			files = append(files, resPath)
+			continue
+		}
+		subFiles, err := resourceFiles(fs, resPath)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, subFiles...)
+	}
+	return files, nil
+}
+
+// defaultResources returns the resources of a kustomization generated for a
+// directory that has none: its manifest files, and its subdirectories that
+// have a kustomization.
+func defaultResources(fs billy.Filesystem, dirRelPath string) ([]string, error) {
+	infos, err := fs.ReadDir(dirRelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo directory: %s", err)
+	}
+	var resources []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			kustName, err := findKustomization(fs, path.Join(dirRelPath, name))
+			if err != nil {
+				return nil, err
+			}
+			if kustName != "" {
+				resources = append(resources, name)
+			}
+			continue
+		}
+		if name == "configurations.yaml" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := path.Ext(name); ext == ".yaml" || ext == ".yml" {
+			resources = append(resources, name)
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,464 @@
+package basecluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Severity of a lint finding. Findings with the error severity make a
+// template invalid.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rule IDs of the lint findings
+const (
+	RuleTemplateFiles         = "template-files"
+	RuleClusterCount          = "cluster-count"
+	RuleNamespace             = "namespace"
+	RuleRefTarget             = "ref-target"
+	RulePrefixedRef           = "prefixed-ref"
+	RuleClusterNameRef        = "cluster-name-ref"
+	RuleNameLength            = "name-length"
+	RuleAutoscalerAnnotations = "autoscaler-annotations"
+	RuleSSHKey                = "ssh-key"
+	RuleTopology              = "topology"
+)
+
+// DefaultClusterNameLength is the length of the names of the arlon clusters
+// assumed when checking the length of the names of template resources.
+const DefaultClusterNameLength = 20
+
+// Finding is a problem of a cluster template found by LintFile or LintDir.
+type Finding struct {
+	RuleID   string   `json:"ruleID"`
+	Severity Severity `json:"severity"`
+	// File of the resource relative to the template directory, if known
+	File string `json:"file,omitempty"`
+	// Kind and name of the resource, as Kind/name
+	Resource string `json:"resource,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintOptions configures LintFile and LintDir.
+type LintOptions struct {
+	// Length of the names of the arlon clusters created from the template,
+	// which prefix the names of its resources. Zero means
+	// DefaultClusterNameLength.
+	ClusterNameLength int
+}
+
+// HasErrors returns true if some findings have the error severity.
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+
+// LintFile checks all the resources of a manifest file, and returns every
+// problem found instead of stopping at the first one, along with the name of
+// the cluster of the template if it has exactly one.
+func LintFile(fileName string, opts LintOptions) (findings []Finding, clusterName string, err error) {
+	objs, err := loadManifest(fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	files := make([]string, len(objs))
+	for i := range files {
+		files[i] = fileName
+	}
+	l := &linter{objs: objs, files: files, opts: opts}
+	return l.lint(), l.clusterName, nil
+}
+
+// LintDir checks a cluster template directory and the resources rendered by
+// a kustomize build of it. If the directory has not been prepared yet, the
+// resources that preparation would include are checked instead. If it has
+// been prepared, it is also built with the name prefix of a cluster to check
+// that its configurations.yaml keeps the references between resources. The
+// name of the cluster of the template is returned if it has exactly one.
+func LintDir(dirPath string, opts LintOptions) (findings []Finding, clusterName string, err error) {
+	kustName, err := findKustomization(osfs.New(dirPath), ".")
+	if err != nil {
+		return nil, "", err
+	}
+	prepared := kustName != ""
+	if !prepared {
+		findings = append(findings, missingFileFinding("kustomization.yaml"))
+	}
+	_, err = os.Stat(path.Join(dirPath, "configurations.yaml"))
+	if os.IsNotExist(err) {
+		findings = append(findings, missingFileFinding("configurations.yaml"))
+	} else if err != nil {
+		return nil, "", fmt.Errorf("failed to check for configurations.yaml: %s", err)
+	}
+	var objs, prefixed []*unstructured.Unstructured
+	var files []string
+	if !prepared {
+		resources, err := defaultResources(osfs.New(dirPath), ".")
+		if err != nil {
+			return nil, "", err
+		}
+		for _, res := range resources {
+			resPath := path.Join(dirPath, res)
+			info, err := os.Stat(resPath)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to check for %s: %s", res, err)
+			}
+			var resObjs []*unstructured.Unstructured
+			var resFiles []string
+			if info.IsDir() {
+				resObjs, resFiles, err = buildDir(resPath)
+				for i := range resFiles {
+					resFiles[i] = path.Join(res, resFiles[i])
+				}
+			} else {
+				resObjs, err = loadManifest(resPath)
+				for range resObjs {
+					resFiles = append(resFiles, res)
+				}
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			objs = append(objs, resObjs...)
+			files = append(files, resFiles...)
+		}
+	} else {
+		objs, files, err = buildDir(dirPath)
+		if err != nil {
+			return nil, "", err
+		}
+		prefixed, _, err = buildDirWithPrefix(dirPath, lintNamePrefix)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	l := &linter{objs: objs, files: files, prefixed: prefixed, opts: opts}
+	return append(findings, l.lint()...), l.clusterName, nil
+}
+
+// missingFileFinding returns the finding of a missing prep file.
+func missingFileFinding(name string) Finding {
+	return Finding{
+		RuleID:   RuleTemplateFiles,
+		Severity: SeverityError,
+		File:     name,
+		Message:  name + " is missing, the directory must be prepared",
+	}
+}
+
+// lintNamePrefix is the name prefix of the cluster with which LintDir builds
+// a prepared template.
+const lintNamePrefix = "lint-"
+
+// -----------------------------------------------------------------------------
+
+// lint applies all rules to the resources of a cluster template.
+func (l *linter) lint() []Finding {
+	objs := l.objs
+	if l.opts.ClusterNameLength == 0 {
+		l.opts.ClusterNameLength = DefaultClusterNameLength
+	}
+	var clusters []int
+	for i, obj := range objs {
+		if obj.GetKind() == "Cluster" {
+			clusters = append(clusters, i)
+		}
+	}
+	if len(clusters) == 0 {
+		l.add(-1, RuleClusterCount, SeverityError, ErrNoClusterResource.Error())
+	}
+	for n, i := range clusters {
+		if n > 0 {
+			l.add(i, RuleClusterCount, SeverityError, ErrMultipleClusters.Error())
+		}
+	}
+	var cluster *unstructured.Unstructured
+	if len(clusters) > 0 {
+		cluster = objs[clusters[0]]
+	}
+	if len(clusters) == 1 {
+		l.clusterName = cluster.GetName()
+	}
+	for i, obj := range objs {
+		if obj.GetNamespace() != "" {
+			l.add(i, RuleNamespace, SeverityError,
+				fmt.Sprintf("namespace %s is set, it must be removed for the cluster namespace to apply",
+					obj.GetNamespace()))
+		}
+		l.lintRefs(i)
+		l.lintPrefixedRefs(i)
+		if cluster != nil {
+			l.lintClusterName(i, cluster.GetName())
+		}
+		l.lintNameLength(i)
+		l.lintAutoscaler(i)
+		l.lintSSHKeys(i)
+	}
+	if cluster != nil && isTopologyCluster(cluster) {
+		l.lintTopology(clusters[0])
+	}
+	return l.findings
+}
+
+type linter struct {
+	objs []*unstructured.Unstructured
+	// files holds the file of each resource
+	files []string
+	// prefixed holds the resources built with a cluster name prefix, in the
+	// same order as objs, if the template was built
+	prefixed []*unstructured.Unstructured
+	opts     LintOptions
+	findings []Finding
+	// clusterName is the name of the cluster of the template, if it has
+	// exactly one
+	clusterName string
+}
+
+// add records a finding about the resource at index i, or about the whole
+// template if i is negative.
+func (l *linter) add(i int, ruleID string, severity Severity, msg string) {
+	f := Finding{RuleID: ruleID, Severity: severity, Message: msg}
+	if i >= 0 {
+		f.File = l.files[i]
+		f.Resource = l.objs[i].GetKind() + "/" + l.objs[i].GetName()
+	}
+	l.findings = append(l.findings, f)
+}
+
+// refFields are the paths of the references to other resources of the
+// template, by kind of the referencing resource.
+var refFields = map[string][][]string{
+	"Cluster": {
+		{"spec", "infrastructureRef"},
+		{"spec", "controlPlaneRef"},
+	},
+	"MachineDeployment": {
+		{"spec", "template", "spec", "infrastructureRef"},
+		{"spec", "template", "spec", "bootstrap", "configRef"},
+	},
+	"MachinePool": {
+		{"spec", "template", "spec", "infrastructureRef"},
+		{"spec", "template", "spec", "bootstrap", "configRef"},
+	},
+	"KubeadmControlPlane": {
+		{"spec", "machineTemplate", "infrastructureRef"},
+	},
+}
+
+// fieldRefs returns the references of a resource listed in refFields.
+func fieldRefs(obj *unstructured.Unstructured) []objectRef {
+	var refs []objectRef
+	for _, fieldPath := range refFields[obj.GetKind()] {
+		ref, found, _ := unstructured.NestedMap(obj.Object, fieldPath...)
+		if !found {
+			continue
+		}
+		apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		refs = append(refs, objectRef{field: fieldString(fieldPath), apiVersion: apiVersion, kind: kind, name: name})
+	}
+	return refs
+}
+
+// lintRefs checks that the resources referenced by a resource exist in the
+// template.
+func (l *linter) lintRefs(i int) {
+	for _, ref := range fieldRefs(l.objs[i]) {
+		target, _ := findObject(l.objs, nil, ref.apiVersion, ref.kind, ref.name)
+		if target == nil {
+			l.add(i, RuleRefTarget, SeverityError, fmt.Sprintf("%s refers to %s %s, which is not in the template",
+				ref.field, ref.kind, ref.name))
+		}
+	}
+}
+
+// allRefs returns the references of a resource to other resources,
+// including the ones of ClusterClasses and topology based Clusters.
+func allRefs(obj *unstructured.Unstructured) []objectRef {
+	refs := fieldRefs(obj)
+	switch {
+	case obj.GetKind() == "ClusterClass":
+		refs = append(refs, classRefs(obj)...)
+	case obj.GetKind() == "Cluster" && isTopologyCluster(obj):
+		className, _, _ := unstructured.NestedString(obj.Object, "spec", "topology", "class")
+		refs = append(refs, objectRef{field: "spec.topology.class",
+			apiVersion: obj.GetAPIVersion(), kind: "ClusterClass", name: className})
+	}
+	return refs
+}
+
+// lintPrefixedRefs checks that the references of a resource to other
+// resources of the template still resolve once the names are prefixed with
+// the name of a cluster, which requires configurations.yaml to declare them.
+func (l *linter) lintPrefixedRefs(i int) {
+	if len(l.prefixed) != len(l.objs) {
+		return
+	}
+	refs := allRefs(l.objs[i])
+	prefixedRefs := allRefs(l.prefixed[i])
+	if len(prefixedRefs) != len(refs) {
+		return
+	}
+	for n, ref := range refs {
+		if target, _ := findObject(l.objs, nil, ref.apiVersion, ref.kind, ref.name); target == nil {
+			continue
+		}
+		pref := prefixedRefs[n]
+		if target, _ := findObject(l.prefixed, nil, pref.apiVersion, pref.kind, pref.name); target == nil {
+			l.add(i, RulePrefixedRef, SeverityError,
+				fmt.Sprintf("%s refers to %s %s, which is not renamed along with it once prefixed "+
+					"by the cluster name, configurations.yaml lacks a name reference for it",
+					ref.field, ref.kind, ref.name))
+		}
+	}
+}
+
+// lintClusterName checks that the machine deployments and pools belong to
+// the cluster of the template.
+func (l *linter) lintClusterName(i int, clusterName string) {
+	obj := l.objs[i]
+	if obj.GetKind() != "MachineDeployment" && obj.GetKind() != "MachinePool" {
+		return
+	}
+	for _, fieldPath := range [][]string{
+		{"spec", "clusterName"},
+		{"spec", "template", "spec", "clusterName"},
+	} {
+		name, found, _ := unstructured.NestedString(obj.Object, fieldPath...)
+		if found && name != clusterName {
+			l.add(i, RuleClusterNameRef, SeverityError, fmt.Sprintf("%s is %s instead of the cluster %s",
+				fieldString(fieldPath), name, clusterName))
+		}
+	}
+}
+
+// labelNameKinds are the kinds of the resources whose name Cluster API uses
+// as a label value, which is limited to 63 characters.
+var labelNameKinds = map[string]bool{
+	"Cluster":           true,
+	"MachineDeployment": true,
+	"MachinePool":       true,
+	"MachineSet":        true,
+}
+
+// lintNameLength checks that the name of a resource is not too long once
+// prefixed with the name of an arlon cluster.
+func (l *linter) lintNameLength(i int) {
+	obj := l.objs[i]
+	maxLen := 253
+	if labelNameKinds[obj.GetKind()] {
+		maxLen = 63
+	}
+	length := l.opts.ClusterNameLength + 1 + len(obj.GetName())
+	if length > maxLen {
+		l.add(i, RuleNameLength, SeverityError,
+			fmt.Sprintf("name is %d characters long once prefixed by a cluster name of %d characters, more than %d",
+				length, l.opts.ClusterNameLength, maxLen))
+	}
+}
+
+// lintAutoscaler checks that the machine deployments have the annotations
+// that the cluster autoscaler requires.
+func (l *linter) lintAutoscaler(i int) {
+	obj := l.objs[i]
+	if obj.GetKind() != "MachineDeployment" {
+		return
+	}
+	annotations := obj.GetAnnotations()
+	for _, key := range []string{casMinAnnotationMachineDeployments, casMaxAnnotationMachineDeployments} {
+		if annotations[key] == "" {
+			l.add(i, RuleAutoscalerAnnotations, SeverityWarning,
+				fmt.Sprintf("annotation %s is missing, the cluster autoscaler can't scale it", key))
+		}
+	}
+}
+
+// sshKeyFields are the fields of provider resources holding SSH key names or
+// public keys.
+var sshKeyFields = map[string]bool{
+	"sshKeyName":        true,
+	"sshAuthorizedKeys": true,
+	"sshPublicKey":      true,
+}
+
+// lintSSHKeys reports SSH keys hardcoded in a resource, which all the
+// clusters created from the template would share.
+func (l *linter) lintSSHKeys(i int) {
+	var walk func(value interface{}, fieldPath []string)
+	walk = func(value interface{}, fieldPath []string) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				child := v[key]
+				childPath := append(append([]string{}, fieldPath...), key)
+				if sshKeyFields[key] && !isEmpty(child) {
+					l.add(i, RuleSSHKey, SeverityWarning,
+						fmt.Sprintf("%s hardcodes an SSH key shared by all clusters, override it per cluster instead",
+							fieldString(childPath)))
+					continue
+				}
+				walk(child, childPath)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, fieldPath)
+			}
+		}
+	}
+	walk(l.objs[i].Object["spec"], []string{"spec"})
+}
+
+// lintTopology checks the ClusterClass of a topology based cluster. The
+// ClusterClass and its templates may be installed in the management cluster
+// instead of being in the template, so their absence is only a warning.
+func (l *linter) lintTopology(i int) {
+	err := validateTopology(l.objs[i], l.objs, nil)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNoClusterClass) || errors.Is(err, ErrNoClassTemplate):
+		l.add(i, RuleTopology, SeverityWarning, err.Error()+" in template, it must exist in the management cluster")
+	default:
+		l.add(i, RuleTopology, SeverityError, err.Error())
+	}
+}
+
+func fieldString(fieldPath []string) string {
+	return strings.Join(fieldPath, ".")
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
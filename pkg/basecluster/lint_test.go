@@ -0,0 +1,155 @@
+package basecluster
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLintFile(t *testing.T) {
+	fileName := "testdata/lint/manifest.yaml"
+	findings, clusterName, err := LintFile(fileName, LintOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, clusterName, "c1")
+	assert.DeepEqual(t, findings, []Finding{
+		{
+			RuleID:   RuleRefTarget,
+			Severity: SeverityError,
+			File:     fileName,
+			Resource: "KubeadmControlPlane/c1-control-plane",
+			Message:  "spec.machineTemplate.infrastructureRef refers to DockerMachineTemplate c1-control-plane, which is not in the template",
+		},
+		{
+			RuleID:   RuleSSHKey,
+			Severity: SeverityWarning,
+			File:     fileName,
+			Resource: "KubeadmControlPlane/c1-control-plane",
+			Message:  "spec.kubeadmConfigSpec.users.sshAuthorizedKeys hardcodes an SSH key shared by all clusters, override it per cluster instead",
+		},
+		{
+			RuleID:   RuleNamespace,
+			Severity: SeverityError,
+			File:     fileName,
+			Resource: "MachineDeployment/c1-md-0-with-a-name-that-is-far-too-long-for-a-label-value",
+			Message:  "namespace default is set, it must be removed for the cluster namespace to apply",
+		},
+		{
+			RuleID:   RuleClusterNameRef,
+			Severity: SeverityError,
+			File:     fileName,
+			Resource: "MachineDeployment/c1-md-0-with-a-name-that-is-far-too-long-for-a-label-value",
+			Message:  "spec.clusterName is c2 instead of the cluster c1",
+		},
+		{
+			RuleID:   RuleNameLength,
+			Severity: SeverityError,
+			File:     fileName,
+			Resource: "MachineDeployment/c1-md-0-with-a-name-that-is-far-too-long-for-a-label-value",
+			Message:  "name is 79 characters long once prefixed by a cluster name of 20 characters, more than 63",
+		},
+	})
+	assert.Assert(t, HasErrors(findings))
+
+	// shorter cluster names fit
+	findings, _, err = LintFile(fileName, LintOptions{ClusterNameLength: 2})
+	assert.NilError(t, err)
+	for _, f := range findings {
+		assert.Assert(t, f.RuleID != RuleNameLength)
+	}
+}
+
+func TestLintDir(t *testing.T) {
+	findings, clusterName, err := LintDir("testdata/14_nested_bases", LintOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, clusterName, "capi-quickstart")
+	assert.DeepEqual(t, findings, []Finding{
+		{
+			RuleID:   RuleRefTarget,
+			Severity: SeverityError,
+			File:     "base/cluster.yaml",
+			Resource: "Cluster/capi-quickstart",
+			Message:  "spec.controlPlaneRef refers to KubeadmControlPlane capi-quickstart-control-plane, which is not in the template",
+		},
+		{
+			RuleID:   RulePrefixedRef,
+			Severity: SeverityError,
+			File:     "base/cluster.yaml",
+			Resource: "Cluster/capi-quickstart",
+			Message: "spec.infrastructureRef refers to AWSCluster capi-quickstart, which is not renamed along with it " +
+				"once prefixed by the cluster name, configurations.yaml lacks a name reference for it",
+		},
+		{
+			RuleID:   RuleSSHKey,
+			Severity: SeverityWarning,
+			File:     "base/awscluster.yaml",
+			Resource: "AWSCluster/capi-quickstart",
+			Message:  "spec.sshKeyName hardcodes an SSH key shared by all clusters, override it per cluster instead",
+		},
+		{
+			RuleID:   RuleAutoscalerAnnotations,
+			Severity: SeverityWarning,
+			File:     "workers.yaml",
+			Resource: "MachineDeployment/capi-quickstart-md-0",
+			Message:  "annotation cluster.x-k8s.io/cluster-api-autoscaler-node-group-min-size is missing, the cluster autoscaler can't scale it",
+		},
+		{
+			RuleID:   RuleAutoscalerAnnotations,
+			Severity: SeverityWarning,
+			File:     "workers.yaml",
+			Resource: "MachineDeployment/capi-quickstart-md-0",
+			Message:  "annotation cluster.x-k8s.io/cluster-api-autoscaler-node-group-max-size is missing, the cluster autoscaler can't scale it",
+		},
+	})
+
+	// an unprepared directory is linted as it would be prepared
+	findings, _, err = LintDir("testdata/requires_prep", LintOptions{})
+	assert.NilError(t, err)
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.RuleID)
+	}
+	assert.DeepEqual(t, rules, []string{RuleTemplateFiles, RuleTemplateFiles,
+		RuleRefTarget, RuleNamespace, RuleSSHKey})
+	assert.Equal(t, findings[3].File, "manifest.yaml")
+
+	// a kustomization.yml is recognized like a kustomization.yaml
+	findings, clusterName, err = LintDir("testdata/16_kustomization_yml", LintOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, clusterName, "capi-quickstart")
+	for _, f := range findings {
+		assert.Assert(t, f.RuleID != RuleTemplateFiles)
+	}
+
+	// the ClusterClass of a topology may be in the management cluster
+	findings, _, err = LintDir("testdata/11_no_cluster_class", LintOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(findings), 1)
+	assert.Equal(t, findings[0].Severity, SeverityWarning)
+	assert.Equal(t, findings[0].RuleID, RuleTopology)
+	assert.Assert(t, !HasErrors(findings))
+
+	// the references of a ClusterClass resolve once prefixed by the cluster
+	// name with the configurations.yaml written by preparation
+	findings, _, err = LintDir("testdata/15_aws_clusterclass", LintOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(findings), 0)
+
+	// but not without it
+	findings, _, err = LintDir("testdata/10_clusterclass_ok", LintOptions{})
+	assert.NilError(t, err)
+	var fields []string
+	for _, f := range findings {
+		if f.RuleID == RulePrefixedRef {
+			fields = append(fields, strings.SplitN(f.Message, " ", 2)[0])
+		}
+	}
+	assert.DeepEqual(t, fields, []string{
+		"spec.infrastructure.ref",
+		"spec.controlPlane.ref",
+		"spec.controlPlane.machineInfrastructure.ref",
+		"spec.workers.machineDeployments[0].template.bootstrap.ref",
+		"spec.workers.machineDeployments[0].template.infrastructure.ref",
+		"spec.topology.class",
+	})
+}
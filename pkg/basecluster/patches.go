@@ -0,0 +1,131 @@
+package basecluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/gitutils"
+	gyaml "github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+)
+
+// ValidatePatches verifies that the override patches of a cluster select
+// at least one resource of the prepared cluster template in a directory,
+// and that the patch and the patches apply to the template.
+func ValidatePatches(dirPath string, patch []byte, patches []arlonv1.OverridePatch) error {
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of %s: %s", dirPath, err)
+	}
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), absPath)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrKustomizeBuildFailed, err)
+	}
+	for i, kp := range gitutils.KustomizePatches(patches) {
+		sel, err := patchSelector(&patches[i])
+		if err != nil {
+			return fmt.Errorf("%w: patches[%d]: %s", ErrInvalidPatch, i, err)
+		}
+		if kp.Target != nil {
+			sel = *kp.Target
+		}
+		matches, err := resMap.Select(sel)
+		if err != nil {
+			return fmt.Errorf("%w: patches[%d]: invalid target: %s", ErrInvalidPatch, i, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("%w: patches[%d] selects %s", ErrPatchNoMatch, i, selectorString(sel))
+		}
+	}
+	_, err = buildOverride(dirPath, "", patch, patches, nil)
+	return err
+}
+
+// CheckPatch verifies that the content of an override patch matches its
+// type, independently of the cluster template it applies to.
+func CheckPatch(p *arlonv1.OverridePatch) error {
+	_, err := patchSelector(p)
+	return err
+}
+
+// ValidatePatchesFromGitDir validates the override patches of a cluster
+// against the cluster template in a git directory.
+func ValidatePatchesFromGitDir(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	repoRevision string,
+	repoPath string,
+	patch []byte,
+	patches []arlonv1.OverridePatch,
+) error {
+	return cloneGitDir(creds, repoUrl, repoRevision, repoPath,
+		func(dirPath string, infos []os.FileInfo) error {
+			if _, err := buildTemplateDir(dirPath, infos); err != nil {
+				return err
+			}
+			return ValidatePatches(dirPath, patch, patches)
+		})
+}
+
+// patchSelector checks that the content of an override patch matches its
+// type, and returns the selector of the resource that a strategic merge
+// patch without target names.
+func patchSelector(p *arlonv1.OverridePatch) (types.Selector, error) {
+	var obj interface{}
+	if err := gyaml.Unmarshal([]byte(p.Patch), &obj); err != nil {
+		return types.Selector{}, fmt.Errorf("not valid YAML: %s", err)
+	}
+	switch p.Type {
+	case arlonv1.PatchTypeJSON6902:
+		if _, ok := obj.([]interface{}); !ok {
+			return types.Selector{}, fmt.Errorf("a JSON6902 patch must be a list of operations")
+		}
+		if p.Target == nil {
+			return types.Selector{}, fmt.Errorf("a JSON6902 patch requires a target")
+		}
+		return types.Selector{}, nil
+	case "", arlonv1.PatchTypeStrategicMerge:
+	default:
+		return types.Selector{}, fmt.Errorf("unknown patch type %s", p.Type)
+	}
+	named, ok := obj.(map[string]interface{})
+	if !ok {
+		return types.Selector{}, fmt.Errorf("a strategic merge patch must be a resource")
+	}
+	if p.Target != nil {
+		return types.Selector{}, nil
+	}
+	apiVersion, _ := named["apiVersion"].(string)
+	kind, _ := named["kind"].(string)
+	metadata, _ := named["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if kind == "" || name == "" {
+		return types.Selector{}, fmt.Errorf("a patch without target must have a kind and a metadata.name")
+	}
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	return types.Selector{
+		ResId: resid.ResId{
+			Gvk:  resid.Gvk{Group: gvk.Group, Version: gvk.Version, Kind: kind},
+			Name: name,
+		},
+	}, nil
+}
+
+func selectorString(sel types.Selector) string {
+	s := fmt.Sprintf("kind: %s, name: %s", sel.Kind, sel.Name)
+	if sel.LabelSelector != "" {
+		s += ", labels: " + sel.LabelSelector
+	}
+	if sel.AnnotationSelector != "" {
+		s += ", annotations: " + sel.AnnotationSelector
+	}
+	return s + ", which matches no resource"
+}
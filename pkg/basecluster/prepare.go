@@ -32,6 +32,27 @@ const (
 // An error is returned if other types of (non-namespace related) issues
 // are found in the manifest.
 func Prepare(fileName string, validateOnly bool, casMax, casMin int) (clusterName string, modifiedYaml []byte, err error) {
+	clusterNames, modifiedYaml, err := prepareFile(fileName, casMax, casMin)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(clusterNames) > 1 {
+		return "", nil, Err2orMoreClusters
+	}
+	if len(clusterNames) == 0 {
+		return "", nil, fmt.Errorf("failed to find cluster resource")
+	}
+	if validateOnly {
+		modifiedYaml = nil
+	}
+	return clusterNames[0], modifiedYaml, nil
+}
+
+// prepareFile checks one manifest file of a cluster template, which may be
+// one of several, and returns the names of the clusters that it contains.
+// If some resources need modifications, a modified copy is returned as
+// modifiedYaml, which is nil otherwise.
+func prepareFile(fileName string, casMax, casMin int) (clusterNames []string, modifiedYaml []byte, err error) {
 	var buf bytes.Buffer
 	dirty := false
 	enc := yaml.NewEncoder(&buf)
@@ -48,11 +69,7 @@ func Prepare(fileName string, validateOnly bool, casMax, casMin int) (clusterNam
 	for _, info := range infos {
 		gvk := info.Object.GetObjectKind().GroupVersionKind()
 		if gvk.Kind == "Cluster" {
-			if clusterName != "" {
-				err = Err2orMoreClusters
-				return
-			}
-			clusterName = info.Name
+			clusterNames = append(clusterNames, info.Name)
 		}
 		var modified bool
 		modified, err = prepareCAPIManifestThenEncode(info.Object, enc, casMax, casMin)
@@ -64,10 +81,7 @@ func Prepare(fileName string, validateOnly bool, casMax, casMin int) (clusterNam
 			dirty = true
 		}
 	}
-	if clusterName == "" {
-		return "", nil, fmt.Errorf("failed to find cluster resource")
-	}
-	if !validateOnly && dirty {
+	if dirty {
 		modifiedYaml = buf.Bytes()
 	}
 	return
@@ -105,9 +119,11 @@ func prepareCAPIManifestThenEncode(obj runtime.Object, enc *yaml.Encoder, casMax
 // -----------------------------------------------------------------------------
 
 type KustomizationTemplateParams struct {
-	ManifestFileName string
+	Resources []string
 }
 
+// PrepareGitDir prepares a cluster template directory in git. In pull
+// request write mode, the URL of the pull request is returned.
 func PrepareGitDir(
 	creds *argocd.RepoCreds,
 	repoUrl string,
@@ -115,39 +131,21 @@ func PrepareGitDir(
 	repoPath string,
 	casMax int,
 	casMin int,
-) (clusterName string, changed bool, err error) {
-	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, repoRevision)
-	defer os.RemoveAll(tmpDir)
-	if err != nil {
-		return "", false, fmt.Errorf("failed to clone repo: %s", err)
-	}
-	wt, err := repo.Worktree()
-	if err != nil {
-		return "", false, fmt.Errorf("failed to get repo worktree: %s", err)
-	}
-	fs := wt.Filesystem
-	manifestFileName, clusterName, err := prepareDir(fs, repoPath, tmpDir, casMax, casMin)
-	if err != nil {
-		return "", false, fmt.Errorf("failed to prepare directory: %s", err)
-	}
-	changed, err = gitutils.CommitChanges(tmpDir, wt,
-		"prepare cluster template files for "+path.Join(repoPath, manifestFileName))
+) (clusterName string, changed bool, prUrl string, err error) {
+	changed, prUrl, err = gitutils.WriteToGit(creds, repoUrl, repoRevision,
+		func(wt *gogit.Worktree, rootDir string) (string, error) {
+			var err error
+			clusterName, err = prepareDir(wt.Filesystem, repoPath, rootDir, casMax, casMin)
+			if err != nil {
+				return "", fmt.Errorf("failed to prepare directory: %s", err)
+			}
+			return "prepare cluster template files in " + repoPath, nil
+		})
 	if err != nil {
-		err = fmt.Errorf("failed to commit changes: %s", err)
-		return
-	}
-	if !changed {
-		return
+		return "", false, "", err
 	}
-	err = repo.Push(&gogit.PushOptions{
-		RemoteName: gogit.DefaultRemoteName,
-		Auth:       auth,
-		Progress:   nil,
-		CABundle:   nil,
-	})
-	if err != nil {
-		err = fmt.Errorf("failed to push to remote repository: %s", err)
-		return
+	if prUrl != "" {
+		logpkg.GetLogger().Info("opened pull request", "prUrl", prUrl)
 	}
 	return
 }
@@ -158,102 +156,92 @@ func PrepareGitDir(
 // name of the cluster resource. dirRelPath is the name of the directory
 // relative to the file system, and actualFsRootDir is the actual path
 // of the file system's root directory on the native file system.
+// The manifests may be split across several files and kustomize bases in
+// subdirectories. If the directory has no kustomization, one including its
+// manifest files and the subdirectories having a kustomization is generated.
+// All the local manifest files included by the kustomization are prepared,
+// and the cluster is looked up in the output of a kustomize build.
 func prepareDir(
 	fs billy.Filesystem,
 	dirRelPath string,
 	actualFsRootDir string,
 	casMax int,
 	casMin int,
-) (manifestFileName string, clusterName string, err error) {
-	var kustomizationFound bool
-	var configurationsFound bool
-	infos, err := fs.ReadDir(dirRelPath)
+) (clusterName string, err error) {
+	kustName, err := findKustomization(fs, dirRelPath)
 	if err != nil {
-		err = fmt.Errorf("failed to list repo directory: %s", err)
-		return
+		return "", err
 	}
-	for _, info := range infos {
-		if info.IsDir() {
-			err = fmt.Errorf("found subdirectory: %s", info.Name())
-			return
-		}
-		if info.Name() == "kustomization.yaml" {
-			kustomizationFound = true
-			continue
+	if kustName == "" {
+		resources, err := defaultResources(fs, dirRelPath)
+		if err != nil {
+			return "", err
 		}
-		if info.Name() == "configurations.yaml" {
-			configurationsFound = true
-			continue
+		if len(resources) == 0 {
+			return "", fmt.Errorf("failed to find cluster template manifest file")
 		}
-		if manifestFileName != "" {
-			err = fmt.Errorf("multiple manifests found: (%s, %s)",
-				manifestFileName, info.Name())
-			return
+		tmpl, err := template.New("kust").Parse(kustomizationYamlTemplate)
+		if err != nil {
+			return "", fmt.Errorf("failed to create kustomization template: %s", err)
 		}
-		manifestFileName = info.Name()
-	}
-	if manifestFileName == "" {
-		err = fmt.Errorf("failed to find cluster template manifest file")
-		return
-	}
-	manifestRelPath := path.Join(dirRelPath, manifestFileName)
-	manifestAbsPath := path.Join(actualFsRootDir, manifestRelPath)
-	clusterName, modifiedYaml, err := Prepare(manifestAbsPath, false, casMax, casMin)
-	if err != nil {
-		err = fmt.Errorf("failed to prepare manifest: %s", err)
-		return
-	}
-	if modifiedYaml != nil {
-		// The manifest contains namespaces. Overwrite it with the modified
-		// copy that has the namespaces reomoved.
-		var file billy.File
-		file, err = fs.OpenFile(manifestRelPath, os.O_WRONLY|os.O_TRUNC, 0644)
+		file, err := fs.Create(path.Join(dirRelPath, "kustomization.yaml"))
 		if err != nil {
-			err = fmt.Errorf("failed to open manifest for writing: %s", err)
-			return
+			return "", fmt.Errorf("failed to create kustomization.yaml: %s", err)
 		}
-		_, err = bytes.NewBuffer(modifiedYaml).WriteTo(file)
+		err = tmpl.Execute(file, &KustomizationTemplateParams{resources})
 		_ = file.Close()
 		if err != nil {
-			err = fmt.Errorf("failed to write to manifest: %s", err)
-			return
+			return "", fmt.Errorf("failed to write to kustomization.yaml: %s", err)
 		}
 	}
-	if !kustomizationFound {
-		var tmpl *template.Template
-		tmpl, err = template.New("kust").Parse(kustomizationYamlTemplate)
+	manifestRelPaths, err := resourceFiles(fs, dirRelPath)
+	if err != nil {
+		return "", err
+	}
+	for _, manifestRelPath := range manifestRelPaths {
+		manifestAbsPath := path.Join(actualFsRootDir, manifestRelPath)
+		_, modifiedYaml, err := prepareFile(manifestAbsPath, casMax, casMin)
 		if err != nil {
-			err = fmt.Errorf("failed to create kustomization template: %s", err)
-			return
+			return "", fmt.Errorf("failed to prepare manifest %s: %s", manifestRelPath, err)
+		}
+		if modifiedYaml == nil {
+			continue
 		}
-		var file billy.File
-		file, err = fs.Create(path.Join(dirRelPath, "kustomization.yaml"))
+		// The manifest contains namespaces or lacks autoscaler annotations.
+		// Overwrite it with the modified copy.
+		file, err := fs.OpenFile(manifestRelPath, os.O_WRONLY|os.O_TRUNC, 0644)
 		if err != nil {
-			err = fmt.Errorf("failed to create kustomization.yaml: %s", err)
-			return
+			return "", fmt.Errorf("failed to open manifest for writing: %s", err)
 		}
-		err = tmpl.Execute(file, &KustomizationTemplateParams{manifestFileName})
+		_, err = bytes.NewBuffer(modifiedYaml).WriteTo(file)
 		_ = file.Close()
 		if err != nil {
-			err = fmt.Errorf("failed to write to kustomization.yaml: %s", err)
-			return
+			return "", fmt.Errorf("failed to write to manifest: %s", err)
 		}
 	}
-	if !configurationsFound {
-		var file billy.File
-		file, err = fs.Create(path.Join(dirRelPath, "configurations.yaml"))
+	configurationsPath := path.Join(dirRelPath, "configurations.yaml")
+	if _, err := fs.Stat(configurationsPath); os.IsNotExist(err) {
+		file, err := fs.Create(configurationsPath)
 		if err != nil {
-			err = fmt.Errorf("failed to create configurations.yaml: %s", err)
-			return
+			return "", fmt.Errorf("failed to create configurations.yaml: %s", err)
 		}
 		_, err = file.Write([]byte(ConfigurationsYaml))
 		_ = file.Close()
 		if err != nil {
-			err = fmt.Errorf("failed to write to configurations.yaml: %s", err)
-			return
+			return "", fmt.Errorf("failed to write to configurations.yaml: %s", err)
 		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to check for configurations.yaml: %s", err)
 	}
-	return
+	objs, _, err := buildDir(path.Join(actualFsRootDir, dirRelPath))
+	if err != nil {
+		return "", err
+	}
+	cluster, err := findCluster(objs)
+	if err != nil {
+		return "", err
+	}
+	return cluster.GetName(), nil
 }
 
 func addClusterAutoscalerAnnotations(annotations map[string]string, casMax, casMin int) (map[string]string, bool) {
@@ -24,6 +24,7 @@ type testCase struct {
 var testCases = []testCase{
 	{"requires_prep", nil},
 	{"requires_prep_2", Err2orMoreClusters},
+	{"requires_prep_3", nil},
 }
 
 func TestPreparation(t *testing.T) {
@@ -41,26 +42,25 @@ func testOneDir(t *testing.T, tc testCase) {
 	assert.NilError(t, err, "failed to copy directory %s: %s", tmpDir, err)
 	fileInfos, err := readDir(tmpDir)
 	assert.NilError(t, err, "failed to read directory %s: %s", tmpDir, err)
-	_, err = validateDir(tmpDir, fileInfos)
+	_, err = validateDir(tmpDir, fileInfos, nil)
 	if err == nil {
 		t.Fatalf("validation returned no error")
 	}
 	fmt.Println("validation returned expected error:", err)
 	fs := osfs.New(tmpDir)
-	manifestFileName, clusterName, err := prepareDir(fs, ".", tmpDir, defaultCasMax, defaultCasMin)
+	clusterName, err := prepareDir(fs, ".", tmpDir, defaultCasMax, defaultCasMin)
 	if tc.expectedPrepErr == nil {
 		assert.NilError(t, err, "expected nil error")
 	} else {
 		assert.ErrorContains(t, err, tc.expectedPrepErr.Error())
 		return
 	}
-	assert.Equal(t, manifestFileName, "manifest.yaml")
 	assert.Equal(t, clusterName, "capi-quickstart")
 
 	// validate again
 	fileInfos, err = readDir(tmpDir)
 	assert.NilError(t, err)
-	clusterName, err = validateDir(tmpDir, fileInfos)
+	clusterName, err = validateDir(tmpDir, fileInfos, nil)
 	assert.NilError(t, err)
 	assert.Equal(t, clusterName, "capi-quickstart")
 }
@@ -20,14 +20,14 @@ func TestGitPreparation(t *testing.T) {
 	t.Log("repo dir:", srcGitDir)
 	creds := &argocd.RepoCreds{}
 	repoUrl := "file://" + srcGitDir
-	_, err := ValidateGitDir(creds, repoUrl, repoRevision, subdirName)
+	_, err := ValidateGitDir(creds, repoUrl, repoRevision, subdirName, nil)
 	assert.Assert(t, errors.Is(err, ErrNoKustomizationYaml), "unexpected validation error: %s", err)
 	t.Log("got expected error:", err)
-	clustName, changed, err := PrepareGitDir(creds, repoUrl, repoRevision, subdirName, defaultCasMax, defaultCasMin)
+	clustName, changed, _, err := PrepareGitDir(creds, repoUrl, repoRevision, subdirName, defaultCasMax, defaultCasMin)
 	assert.NilError(t, err, "failed to prepare git directory")
 	assert.Assert(t, changed, "git dir preparation resulted in no changes")
 	assert.Equal(t, clustName, "capi-quickstart", "unexpected cluster name: %s", clustName)
-	_, err = ValidateGitDir(creds, repoUrl, repoRevision, subdirName)
+	_, err = ValidateGitDir(creds, repoUrl, repoRevision, subdirName, nil)
 	assert.NilError(t, err, "unexpected 2nd validation error: %s", err)
 }
 
@@ -0,0 +1,141 @@
+package basecluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/gitutils"
+	gyaml "github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// InstanceLabel is the label with which Argo CD tracks the resources of an
+// application. The arlon webhook prefixes the name of a Cluster with it.
+const InstanceLabel = "app.kubernetes.io/instance"
+
+// RenderOptions configures Render.
+type RenderOptions struct {
+	// Override patch of the arlon cluster, as in its spec.override.patch
+	Patch []byte
+	// Override patches of the arlon cluster, as in its spec.override.patches
+	Patches []arlonv1.OverridePatch
+	// Topology override of the arlon cluster, as in its spec.override.topology
+	Topology *arlonv1.TopologyOverride
+	// Whether to rename the Cluster like the arlon webhook does when it is
+	// installed in the management cluster
+	WebhookRewrite bool
+}
+
+// Render returns the resources that the cluster app of an arlon cluster
+// deploys from a prepared cluster template directory, without access to Argo
+// CD or to the management cluster. Like the cluster app, it builds the
+// template, or an override directory patching it if there are patches, with
+// the name prefix of the cluster, then sets the namespace and the Argo CD
+// instance label of the resources. A topology override is validated against
+// the ClusterClass of the template, which must be in the template since the
+// management cluster is not accessed.
+func Render(dirPath string, clusterName string, opts RenderOptions) ([]*unstructured.Unstructured, error) {
+	infos, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template directory: %s", err)
+	}
+	objs, err := buildTemplateDir(dirPath, infos)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findCluster(objs); err != nil {
+		return nil, err
+	}
+	var topologyPatch []byte
+	if opts.Topology != nil {
+		topologyPatch, err = TopologyPatch(objs, nil, opts.Topology)
+		if err != nil {
+			return nil, err
+		}
+	}
+	namePrefix := clusterName + "-"
+	if len(opts.Patch) > 0 || len(opts.Patches) > 0 {
+		if err := ValidatePatches(dirPath, opts.Patch, opts.Patches); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Patch) == 0 && len(opts.Patches) == 0 && len(topologyPatch) == 0 {
+		objs, _, err = buildDirWithPrefix(dirPath, namePrefix)
+	} else {
+		objs, err = buildOverride(dirPath, namePrefix, opts.Patch, opts.Patches, topologyPatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(clusterName)
+		}
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[InstanceLabel] = clusterName
+		obj.SetLabels(labels)
+		if opts.WebhookRewrite && obj.GetKind() == "Cluster" {
+			obj.SetName(clusterName + "-" + obj.GetName())
+		}
+	}
+	return objs, nil
+}
+
+// buildOverride builds an override directory of a cluster template like the
+// one that gitutils.CopyPatchManifests writes, in a temporary directory.
+func buildOverride(dirPath string, namePrefix string, patch []byte,
+	patches []arlonv1.OverridePatch, topologyPatch []byte) ([]*unstructured.Unstructured, error) {
+	tmpDir, err := ioutil.TempDir("", "arlon-render-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of %s: %s", dirPath, err)
+	}
+	base, err := filepath.Rel(tmpDir, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path of %s: %s", dirPath, err)
+	}
+	kust := types.Kustomization{
+		TypeMeta: types.TypeMeta{
+			APIVersion: types.KustomizationVersion,
+			Kind:       types.KustomizationKind,
+		},
+		Resources:      []string{base},
+		Configurations: []string{"configurations.yaml"},
+		Patches:        gitutils.KustomizePatches(patches),
+		NamePrefix:     namePrefix,
+	}
+	files := map[string][]byte{
+		"configurations.yaml": []byte(ConfigurationsYaml),
+	}
+	if len(patch) > 0 {
+		kust.PatchesStrategicMerge = append(kust.PatchesStrategicMerge, "patches.yaml")
+		files["patches.yaml"] = patch
+	}
+	if len(topologyPatch) > 0 {
+		kust.PatchesStrategicMerge = append(kust.PatchesStrategicMerge, "topology.yaml")
+		files["topology.yaml"] = topologyPatch
+	}
+	kustData, err := gyaml.Marshal(&kust)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize kustomization: %s", err)
+	}
+	files["kustomization.yaml"] = kustData
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %s", name, err)
+		}
+	}
+	objs, _, err := buildDir(tmpDir)
+	return objs, err
+}
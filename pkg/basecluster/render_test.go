@@ -0,0 +1,145 @@
+package basecluster
+
+import (
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"gotest.tools/v3/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRender(t *testing.T) {
+	objs, err := Render("testdata/render", "c1", RenderOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(objs), 2)
+	for _, obj := range objs {
+		assert.Equal(t, obj.GetNamespace(), "c1")
+		assert.Equal(t, obj.GetLabels()[InstanceLabel], "c1")
+	}
+	cluster, awsCluster := objs[0], objs[1]
+	assert.Equal(t, cluster.GetName(), "c1-capi-quickstart")
+	assert.Equal(t, awsCluster.GetName(), "c1-capi-quickstart")
+	ref, _, _ := unstructured.NestedString(cluster.Object, "spec", "infrastructureRef", "name")
+	assert.Equal(t, ref, "c1-capi-quickstart")
+	region, _, _ := unstructured.NestedString(awsCluster.Object, "spec", "region")
+	assert.Equal(t, region, "us-west-2")
+
+	patch := []byte(`apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: AWSCluster
+metadata:
+  name: capi-quickstart
+spec:
+  region: eu-west-1
+`)
+	objs, err = Render("testdata/render", "c1", RenderOptions{Patch: patch, WebhookRewrite: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(objs), 2)
+	cluster, awsCluster = objs[0], objs[1]
+	assert.Equal(t, cluster.GetName(), "c1-c1-capi-quickstart")
+	ref, _, _ = unstructured.NestedString(cluster.Object, "spec", "infrastructureRef", "name")
+	assert.Equal(t, ref, "c1-capi-quickstart")
+	region, _, _ = unstructured.NestedString(awsCluster.Object, "spec", "region")
+	assert.Equal(t, region, "eu-west-1")
+
+	// patches of resources that the template does not have fail
+	_, err = Render("testdata/render", "c1", RenderOptions{Patch: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+`)})
+	assert.ErrorIs(t, err, ErrKustomizeBuildFailed)
+
+	_, err = Render("testdata/requires_prep", "c1", RenderOptions{})
+	assert.ErrorIs(t, err, ErrNoKustomizationYaml)
+}
+
+func TestValidatePatches(t *testing.T) {
+	smp := arlonv1.OverridePatch{Patch: `apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: AWSCluster
+metadata:
+  name: capi-quickstart
+spec:
+  region: eu-west-1
+`}
+	json6902 := arlonv1.OverridePatch{
+		Type:   arlonv1.PatchTypeJSON6902,
+		Target: &arlonv1.KustomizeTarget{Kind: "AWSCluster"},
+		Patch:  "- op: replace\n  path: /spec/sshKeyName\n  value: c1\n",
+	}
+	err := ValidatePatches("testdata/render", nil, []arlonv1.OverridePatch{smp, json6902})
+	assert.NilError(t, err)
+
+	objs, err := Render("testdata/render", "c1", RenderOptions{Patches: []arlonv1.OverridePatch{smp, json6902}})
+	assert.NilError(t, err)
+	awsCluster := objs[1]
+	region, _, _ := unstructured.NestedString(awsCluster.Object, "spec", "region")
+	assert.Equal(t, region, "eu-west-1")
+	sshKeyName, _, _ := unstructured.NestedString(awsCluster.Object, "spec", "sshKeyName")
+	assert.Equal(t, sshKeyName, "c1")
+
+	// patches selecting no resource are rejected
+	noTarget := arlonv1.OverridePatch{Patch: "apiVersion: cluster.x-k8s.io/v1beta1\nkind: MachineDeployment\nmetadata:\n  name: md-0\nspec:\n  replicas: 3\n"}
+	err = ValidatePatches("testdata/render", nil, []arlonv1.OverridePatch{smp, noTarget})
+	assert.ErrorIs(t, err, ErrPatchNoMatch)
+	assert.ErrorContains(t, err, "patches[1]")
+	noMatch := json6902
+	noMatch.Target = &arlonv1.KustomizeTarget{Kind: "AWSCluster", LabelSelector: "env=prod"}
+	err = ValidatePatches("testdata/render", nil, []arlonv1.OverridePatch{noMatch})
+	assert.ErrorIs(t, err, ErrPatchNoMatch)
+
+	// the content must match the type
+	wrongType := smp
+	wrongType.Type = arlonv1.PatchTypeJSON6902
+	wrongType.Target = &arlonv1.KustomizeTarget{Kind: "AWSCluster"}
+	err = ValidatePatches("testdata/render", nil, []arlonv1.OverridePatch{wrongType})
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+
+	// patches that select a resource but do not apply fail the build
+	badPath := json6902
+	badPath.Patch = "- op: remove\n  path: /spec/missing\n"
+	err = ValidatePatches("testdata/render", nil, []arlonv1.OverridePatch{badPath})
+	assert.ErrorIs(t, err, ErrKustomizeBuildFailed)
+	err = ValidatePatches("testdata/render", []byte(noTarget.Patch), nil)
+	assert.ErrorIs(t, err, ErrKustomizeBuildFailed)
+}
+
+func TestRenderTopology(t *testing.T) {
+	objs, err := Render("testdata/10_clusterclass_ok", "c1", RenderOptions{
+		Topology: &arlonv1.TopologyOverride{
+			Version: "v1.24.6",
+			Variables: []arlonv1.TopologyVariable{
+				{Name: "etcdImageTag", Value: apiextensionsv1.JSON{Raw: []byte(`"3.5.3-0"`)}},
+			},
+		},
+	})
+	assert.NilError(t, err)
+	var cluster *unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetKind() == "Cluster" {
+			cluster = obj
+		}
+	}
+	assert.Assert(t, cluster != nil)
+	assert.Equal(t, cluster.GetName(), "c1-capi-quickstart")
+	version, _, _ := unstructured.NestedString(cluster.Object, "spec", "topology", "version")
+	assert.Equal(t, version, "v1.24.6")
+	vars, _, _ := unstructured.NestedSlice(cluster.Object, "spec", "topology", "variables")
+	var etcdImageTag interface{}
+	for _, v := range vars {
+		if vMap := v.(map[string]interface{}); vMap["name"] == "etcdImageTag" {
+			etcdImageTag = vMap["value"]
+		}
+	}
+	assert.Equal(t, etcdImageTag, "3.5.3-0")
+
+	// variables that the ClusterClass does not define are rejected
+	_, err = Render("testdata/10_clusterclass_ok", "c1", RenderOptions{
+		Topology: &arlonv1.TopologyOverride{
+			Variables: []arlonv1.TopologyVariable{
+				{Name: "kubeProxyMode", Value: apiextensionsv1.JSON{Raw: []byte(`"ipvs"`)}},
+			},
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidTopology)
+}
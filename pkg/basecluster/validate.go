@@ -4,15 +4,74 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 
 	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/gitcache"
+	"github.com/go-git/go-billy/v5/osfs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/resource"
 )
 
 // Validate verifies whether the resources in the specified file contain one and
 // only one cluster, and that no resources have a namespace specified.
+// If the cluster is based on a ClusterClass, the ClusterClass and its
+// templates must be in the file as well.
 // If successful, the function returns the name of the cluster.
 func Validate(fileName string) (clusterName string, err error) {
+	return validateFile(fileName, nil)
+}
+
+// validateFile is like Validate, but also looks up the ClusterClass of the
+// cluster and its templates with getter if it is not nil.
+func validateFile(fileName string, getter ObjectGetter) (clusterName string, err error) {
+	objs, err := loadManifest(fileName)
+	if err != nil {
+		return "", err
+	}
+	return validateObjects(objs, getter)
+}
+
+// validateObjects validates the resources of a cluster template, and
+// returns the name of its cluster.
+func validateObjects(objs []*unstructured.Unstructured, getter ObjectGetter) (clusterName string, err error) {
+	cluster, err := findCluster(objs)
+	if err != nil {
+		return "", err
+	}
+	if isTopologyCluster(cluster) {
+		if err := validateTopology(cluster, objs, getter); err != nil {
+			return "", err
+		}
+	}
+	return cluster.GetName(), nil
+}
+
+// findCluster verifies that the resources of a cluster template contain one
+// and only one cluster, and that no resources have a namespace specified.
+// It returns the cluster.
+func findCluster(objs []*unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	var cluster *unstructured.Unstructured
+	for _, obj := range objs {
+		if obj.GetNamespace() != "" {
+			return nil, fmt.Errorf("%w: resource: %s, kind: %s", ErrResourceHasNamespace, obj.GetName(), obj.GetKind())
+		}
+		if obj.GetKind() == "Cluster" {
+			if cluster != nil {
+				return nil, ErrMultipleClusters
+			}
+			cluster = obj
+		}
+	}
+	if cluster == nil {
+		return nil, ErrNoClusterResource
+	}
+	return cluster, nil
+}
+
+// loadManifest reads the resources of a manifest file.
+func loadManifest(fileName string) ([]*unstructured.Unstructured, error) {
 	bld := resource.NewLocalBuilder()
 	opts := resource.FilenameOptions{
 		Filenames: []string{fileName},
@@ -20,86 +79,114 @@ func Validate(fileName string) (clusterName string, err error) {
 	res := bld.Unstructured().FilenameParam(false, &opts).Do()
 	infos, err := res.Infos()
 	if err != nil {
-		return "", fmt.Errorf("%w: %s", ErrBuilderFailedRun, err)
+		return nil, fmt.Errorf("%w: %s", ErrBuilderFailedRun, err)
 	}
+	objs := make([]*unstructured.Unstructured, 0, len(infos))
 	for _, info := range infos {
-		gvk := info.Object.GetObjectKind().GroupVersionKind()
-		if info.Namespace != "" {
-			return "", fmt.Errorf("%w: resource: %s, kind: %s", ErrResourceHasNamespace, info.Name, gvk.Kind)
-		}
-		if gvk.Kind == "Cluster" {
-			if clusterName != "" {
-				return "", ErrMultipleClusters
+		obj, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert object: %s", err)
 			}
-			clusterName = info.Name
+			obj = &unstructured.Unstructured{Object: content}
 		}
+		objs = append(objs, obj)
 	}
-	if clusterName == "" {
-		return "", ErrNoClusterResource
-	}
-	return
+	return objs, nil
 }
 
 // -----------------------------------------------------------------------------
 
+// ValidateGitDir validates the cluster template directory of a git
+// repository. The ClusterClass of a cluster and its templates that the
+// directory does not contain are looked up with getter if it is not nil.
 func ValidateGitDir(
 	creds *argocd.RepoCreds,
 	repoUrl string,
 	repoRevision string,
 	repoPath string,
+	getter ObjectGetter,
 ) (clusterName string, err error) {
-	repo, tmpDir, _, err := argocd.CloneRepo(creds, repoUrl, repoRevision)
+	err = cloneGitDir(creds, repoUrl, repoRevision, repoPath,
+		func(dirPath string, infos []os.FileInfo) error {
+			clusterName, err = validateDir(dirPath, infos, getter)
+			return err
+		})
+	return
+}
+
+// cloneGitDir clones the cluster template directory of a git repository,
+// and calls fn with its path and its file entries.
+func cloneGitDir(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	repoRevision string,
+	repoPath string,
+	fn func(dirPath string, infos []os.FileInfo) error,
+) error {
+	// only the cluster template directory is read
+	opts := &gitcache.Options{}
+	if dir := path.Clean(repoPath); dir != "." && dir != "/" {
+		opts.SparseDirs = []string{strings.TrimPrefix(dir, "/")}
+	}
+	repo, tmpDir, _, err := argocd.CloneRepoWithOptions(creds, repoUrl, repoRevision, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to clone repo: %s", err)
+		return fmt.Errorf("failed to clone repo: %s", err)
 	}
 	defer os.RemoveAll(tmpDir)
 	wt, err := repo.Worktree()
 	if err != nil {
-		return "", fmt.Errorf("failed to get repo worktree: %s", err)
+		return fmt.Errorf("failed to get repo worktree: %s", err)
 	}
 	fs := wt.Filesystem
 	infos, err := fs.ReadDir(repoPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to list repo directory: %s", err)
+		return fmt.Errorf("failed to list repo directory: %s", err)
 	}
-	dirPath := path.Join(tmpDir, repoPath)
-	return validateDir(dirPath, infos)
+	return fn(path.Join(tmpDir, repoPath), infos)
 }
 
 // -----------------------------------------------------------------------------
 
 // validateDir when given a list of file entries from a directory, validates whether
 // conditions are met for using the directory as a cluster template directory.
-func validateDir(dirPath string, infos []os.FileInfo) (clusterName string, err error) {
-	var kustomizationFound bool
+// The resources rendered by a kustomize build of the directory are validated,
+// so they may be split across several manifests and kustomize bases.
+func validateDir(dirPath string, infos []os.FileInfo, getter ObjectGetter) (clusterName string, err error) {
+	objs, err := buildTemplateDir(dirPath, infos)
+	if err != nil {
+		return "", err
+	}
+	return validateObjects(objs, getter)
+}
+
+// buildTemplateDir checks that a cluster template directory has the files
+// that arlon relies on, and returns the resources rendered by a kustomize
+// build of the directory.
+func buildTemplateDir(dirPath string, infos []os.FileInfo) ([]*unstructured.Unstructured, error) {
 	var configurationsFound bool
-	var manifestFile string
 	for _, info := range infos {
-		if info.IsDir() {
-			return "", fmt.Errorf("found subdirectory: %s", info.Name())
-		}
-		if info.Name() == "kustomization.yaml" {
-			kustomizationFound = true
-			continue
-		}
 		if info.Name() == "configurations.yaml" {
 			configurationsFound = true
-			continue
 		}
-		if manifestFile != "" {
-			return "", ErrMultipleManifests
-		}
-		manifestFile = info.Name()
 	}
-	if manifestFile == "" {
-		return "", ErrNoManifest
+	kustName, err := findKustomization(osfs.New(dirPath), ".")
+	if err != nil {
+		return nil, err
 	}
-	if !kustomizationFound {
-		return "", ErrNoKustomizationYaml
+	if kustName == "" {
+		return nil, ErrNoKustomizationYaml
 	}
 	if !configurationsFound {
-		return "", ErrNoConfigurationsYaml
+		return nil, ErrNoConfigurationsYaml
+	}
+	objs, _, err := buildDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, ErrNoManifest
 	}
-	manifestPath := path.Join(dirPath, manifestFile)
-	return Validate(manifestPath)
+	return objs, nil
 }
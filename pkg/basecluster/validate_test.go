@@ -7,7 +7,10 @@ import (
 	"path"
 	"testing"
 
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"gotest.tools/v3/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type testEntry struct {
@@ -33,9 +36,9 @@ var testData = []testEntry{
 		"",
 	},
 	{
-		"04_multiple_manifests",
-		ErrMultipleManifests,
-		"",
+		"04_multiple_manifests_ok",
+		nil,
+		"capi-quickstart",
 	},
 	{
 		"05_has_namespace",
@@ -59,9 +62,44 @@ var testData = []testEntry{
 	},
 	{
 		"09_invalid_manifest",
-		ErrBuilderFailedRun,
+		ErrKustomizeBuildFailed,
+		"",
+	},
+	{
+		"10_clusterclass_ok",
+		nil,
+		"capi-quickstart",
+	},
+	{
+		"11_no_cluster_class",
+		ErrNoClusterClass,
 		"",
 	},
+	{
+		"12_no_class_template",
+		ErrNoClassTemplate,
+		"",
+	},
+	{
+		"13_undefined_variable",
+		ErrInvalidTopology,
+		"",
+	},
+	{
+		"14_nested_bases",
+		nil,
+		"capi-quickstart",
+	},
+	{
+		"15_aws_clusterclass",
+		nil,
+		"capi-quickstart",
+	},
+	{
+		"16_kustomization_yml",
+		nil,
+		"capi-quickstart",
+	},
 }
 
 func TestValidation(t *testing.T) {
@@ -71,7 +109,7 @@ func TestValidation(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to read directory %s: %s", dirPath, err)
 		}
-		clustName, err := validateDir(dirPath, fileInfos)
+		clustName, err := validateDir(dirPath, fileInfos, nil)
 		if !errors.Is(err, testCase.ErrPattern) {
 			t.Fatalf("unexpected error in %s, expected: %v, got: %v", testCase.DirName, testCase.ErrPattern, err)
 		}
@@ -96,3 +134,68 @@ func readDir(dirPath string) (fileInfos []os.FileInfo, err error) {
 	}
 	return
 }
+
+// testGetter looks up the resources of a manifest, like the ones installed
+// in the management cluster.
+func testGetter(t *testing.T, manifestPath string) ObjectGetter {
+	objs, err := loadManifest(manifestPath)
+	assert.NilError(t, err)
+	return func(apiVersion string, kind string, name string) (*unstructured.Unstructured, error) {
+		return findObject(objs, nil, apiVersion, kind, name)
+	}
+}
+
+func TestValidationWithGetter(t *testing.T) {
+	getter := testGetter(t, "testdata/10_clusterclass_ok/manifest.yaml")
+	dirPath := path.Join("testdata", "11_no_cluster_class")
+	fileInfos, err := readDir(dirPath)
+	assert.NilError(t, err)
+	clustName, err := validateDir(dirPath, fileInfos, getter)
+	assert.NilError(t, err)
+	assert.Equal(t, clustName, "capi-quickstart")
+}
+
+func TestTopologyPatch(t *testing.T) {
+	objs, err := loadManifest("testdata/10_clusterclass_ok/manifest.yaml")
+	assert.NilError(t, err)
+	patch, err := TopologyPatch(objs, nil, &arlonv1.TopologyOverride{
+		Version: "v1.24.6",
+		Variables: []arlonv1.TopologyVariable{
+			{Name: "etcdImageTag", Value: apiextensionsv1.JSON{Raw: []byte(`"3.5.3-0"`)}},
+		},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, string(patch), `apiVersion: cluster.x-k8s.io/v1beta1
+kind: Cluster
+metadata:
+  name: capi-quickstart
+spec:
+  topology:
+    variables:
+    - name: imageRepository
+      value: ""
+    - name: etcdImageTag
+      value: 3.5.3-0
+    - name: coreDNSImageTag
+      value: ""
+    - name: podSecurityStandard
+      value:
+        audit: restricted
+        enabled: true
+        enforce: baseline
+        warn: restricted
+    version: v1.24.6
+`)
+
+	_, err = TopologyPatch(objs, nil, &arlonv1.TopologyOverride{
+		Variables: []arlonv1.TopologyVariable{
+			{Name: "kubeProxyMode", Value: apiextensionsv1.JSON{Raw: []byte(`"ipvs"`)}},
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidTopology)
+
+	objs, err = loadManifest("testdata/08_ok/manifest.yaml")
+	assert.NilError(t, err)
+	_, err = TopologyPatch(objs, nil, &arlonv1.TopologyOverride{Version: "v1.24.6"})
+	assert.ErrorIs(t, err, ErrNoTopology)
+}
@@ -2,48 +2,156 @@ package bundle
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
-	"github.com/arlonproj/arlon/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	corev1types "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Bundle struct {
 	Name string
 	Data []byte
+	// Set to sops on a static bundle whose data is encrypted by SOPS
+	Encryption string
 	// The following are only set on dynamic bundles
 	RepoUrl      string
 	RepoPath     string
 	RepoRevision string
 	SrcType      string
+	// Set on a dynamic bundle referencing a chart in a Helm repository or
+	// an OCI registry, in which case RepoUrl is the URL of the repository
+	// and RepoRevision the chart version
+	Chart string
+	// Deployment settings of the bundle's application, including the
+	// overrides of the profile the bundle was obtained from
+	Settings arlonv1.DeploySettings
 }
 
+var (
+	ErrNotFound         = errors.New("bundle not found")
+	ErrRevisionConflict = errors.New("bundle revision recorded concurrently")
+)
+
 // -----------------------------------------------------------------------------
 
+// Get returns a bundle. A bundle stored as a secret is converted without
+// being migrated.
+func Get(ctx context.Context, cli client.Client, ns string, name string) (*arlonv1.Bundle, error) {
+	b, _, err := get(ctx, cli, ns, name)
+	return b, err
+}
+
+// get returns a bundle, and its secret if it is stored as one.
+func get(ctx context.Context, cli client.Client, ns string, name string) (*arlonv1.Bundle, *corev1.Secret, error) {
+	var b arlonv1.Bundle
+	err := cli.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &b)
+	if err == nil {
+		return &b, nil, nil
+	}
+	if !apierr.IsNotFound(err) {
+		return nil, nil, fmt.Errorf("failed to get bundle %s: %s", name, err)
+	}
+	secr, err := getLegacySecret(ctx, cli, ns, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if secr == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	spec, err := specFromSecret(secr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &arlonv1.Bundle{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, CreationTimestamp: secr.CreationTimestamp},
+		Spec:       spec,
+		Status: arlonv1.BundleStatus{
+			Version:     legacyVersion(secr),
+			ContentHash: ContentHash(&spec),
+		},
+	}, secr, nil
+}
+
+// getForWrite returns the Bundle resource of a bundle, migrating the bundle
+// first if it is stored as a secret.
+func getForWrite(ctx context.Context, cli client.Client, ns string, name string) (*arlonv1.Bundle, error) {
+	b, secr, err := get(ctx, cli, ns, name)
+	if err != nil || secr == nil {
+		return b, err
+	}
+	return migrateSecret(ctx, cli, secr)
+}
+
+func bundleType(spec *arlonv1.BundleSpec) string {
+	if spec.Data != "" {
+		return "static"
+	}
+	return "dynamic"
+}
+
 func GetBundlesFromProfile(
 	profile *arlonv1.Profile,
-	corev1 corev1types.CoreV1Interface,
+	cli client.Client,
 	arlonNs string,
 ) (bundles []Bundle, err error) {
-	secretsApi := corev1.Secrets(arlonNs)
 	bundleList := profile.Spec.Bundles
 	if bundleList == nil {
 		return nil, nil
 	}
+	ctx := context.Background()
+	settingsOverrides := make(map[string]arlonv1.DeploySettings)
+	for _, bs := range profile.Spec.BundleSettings {
+		settingsOverrides[bs.Bundle] = MergeDeploySettings(settingsOverrides[bs.Bundle], bs.DeploySettings)
+	}
+	pinnedVersions := make(map[string]int32)
+	for _, bv := range profile.Spec.BundleVersions {
+		pinnedVersions[bv.Bundle] = bv.Version
+	}
 	for _, bundleName := range bundleList {
-		secr, err := secretsApi.Get(context.Background(), bundleName, metav1.GetOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get bundle secret %s: %s", bundleName, err)
+		var spec *arlonv1.BundleSpec
+		if version, pinned := pinnedVersions[bundleName]; pinned {
+			rev, err := GetRevision(ctx, cli, arlonNs, bundleName, version)
+			if err != nil {
+				return nil, err
+			}
+			spec = &rev.Spec.Content
+		} else {
+			b, err := Get(ctx, cli, arlonNs, bundleName)
+			if err != nil {
+				return nil, err
+			}
+			spec = &b.Spec
 		}
-		bundles = append(bundles, Bundle{
-			Name:         bundleName,
-			Data:         secr.Data["data"],
-			RepoUrl:      secr.Annotations[common.RepoUrlAnnotationKey],
-			RepoPath:     secr.Annotations[common.RepoPathAnnotationKey],
-			RepoRevision: secr.Annotations[common.RepoRevisionAnnotationKey],
-			SrcType:      secr.Annotations[common.SrcTypeAnnotationKey],
-		})
+		bundles = append(bundles, fromSpec(bundleName, spec, settingsOverrides[bundleName]))
+	}
+	if err := ApplyDependencies(bundles, profile.Spec.BundleDependencies); err != nil {
+		return nil, err
 	}
 	return
 }
+
+// fromSpec returns the bundle to deploy for the content of a bundle and the
+// settings overrides of a profile.
+func fromSpec(name string, spec *arlonv1.BundleSpec, override arlonv1.DeploySettings) Bundle {
+	b := Bundle{
+		Name:         name,
+		RepoUrl:      spec.RepoUrl,
+		RepoPath:     spec.RepoPath,
+		RepoRevision: spec.RepoRevision,
+		SrcType:      spec.SrcType,
+		Chart:        spec.Chart,
+	}
+	if spec.Data != "" {
+		b.Data = []byte(spec.Data)
+		b.Encryption = spec.Encryption
+	}
+	var settings arlonv1.DeploySettings
+	if spec.DeploySettings != nil {
+		settings = *spec.DeploySettings
+	}
+	b.Settings = MergeDeploySettings(settings, override)
+	return b
+}
@@ -7,6 +7,8 @@ import (
 
 const (
 	maxLenRFC1123 = 63
+	// OCIScheme prefixes the URL of a chart bundle stored in an OCI registry
+	OCIScheme = "oci://"
 )
 
 var (
@@ -2,74 +2,169 @@ package bundle
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/common"
-	v1 "k8s.io/api/core/v1"
-	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"os"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 )
 
 func Create(
-	kubeClient *kubernetes.Clientset,
+	cli client.Client,
 	ns string, bundleName string,
 	fromFile string,
 	repoUrl string,
 	repoPath string,
 	repoRevision string,
+	chart string,
 	srcType string,
 	desc string,
 	tags string,
+	settings arlonv1.DeploySettings,
 ) error {
 	if !IsValidK8sName(bundleName) {
 		return fmt.Errorf("%w: %s", ErrInvalidName, bundleName)
 	}
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(ns)
-	_, err := secretsApi.Get(context.Background(), bundleName, metav1.GetOptions{})
+	ctx := context.Background()
+	_, err := Get(ctx, cli, ns, bundleName)
 	if err == nil {
 		return fmt.Errorf("a bundle with that name already exists")
 	}
-	if !apierr.IsNotFound(err) {
+	if !errors.Is(err, ErrNotFound) {
 		return fmt.Errorf("failed to check for existence of bundle: %s", err)
 	}
-	secr := v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: bundleName,
-			Labels: map[string]string{
-				"managed-by": "arlon",
-				"arlon-type": "config-bundle",
-			},
-			Annotations: map[string]string{},
-		},
-		Data: map[string][]byte{
-			"description": []byte(desc),
-			"tags":        []byte(tags),
-		},
-	}
 	if fromFile != "" && repoUrl != "" {
 		return fmt.Errorf("file and repo cannot both be specified")
 	}
+	spec := arlonv1.BundleSpec{
+		Description: desc,
+		Tags:        splitTags(tags),
+	}
 	if fromFile != "" {
 		data, err := os.ReadFile(fromFile)
 		if err != nil {
 			return fmt.Errorf("failed to read file: %s", err)
 		}
-		secr.Labels["bundle-type"] = "static"
-		secr.Data["data"] = data
+		spec.Data = string(data)
+		if IsSopsEncrypted(data) {
+			spec.Encryption = EncryptionSops
+		}
 	} else if repoUrl != "" {
-		secr.Labels["bundle-type"] = "dynamic"
-		secr.ObjectMeta.Annotations[common.RepoUrlAnnotationKey] = repoUrl
-		secr.ObjectMeta.Annotations[common.RepoPathAnnotationKey] = repoPath
-		secr.ObjectMeta.Annotations[common.RepoRevisionAnnotationKey] = repoRevision
-		secr.ObjectMeta.Annotations[common.SrcTypeAnnotationKey] = srcType
+		if chart != "" && srcType == "" {
+			srcType = "helm"
+		}
+		spec.RepoUrl = repoUrl
+		spec.RepoPath = repoPath
+		spec.RepoRevision = repoRevision
+		spec.Chart = chart
+		spec.SrcType = srcType
 	} else {
 		return fmt.Errorf("the bundle must be created from a file or repo URL")
 	}
-	_, err = secretsApi.Create(context.Background(), &secr, metav1.CreateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create secret: %s", err)
+	if !reflect.DeepEqual(settings, arlonv1.DeploySettings{}) {
+		spec.DeploySettings = &settings
+	}
+	if errs := ValidateSpec(&spec, field.NewPath("spec")); len(errs) > 0 {
+		return fmt.Errorf("invalid bundle: %s", errs.ToAggregate())
+	}
+	b := &arlonv1.Bundle{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        bundleName,
+			Namespace:   ns,
+			Annotations: map[string]string{common.ChangeCauseAnnotationKey: "created"},
+		},
+		Spec: spec,
+	}
+	if err := cli.Create(ctx, b); err != nil {
+		return fmt.Errorf("failed to create bundle: %s", err)
+	}
+	if _, err := RecordRevision(ctx, cli, b); err != nil {
+		return err
 	}
 	return nil
 }
+
+var validSrcTypes = []string{"", "directory", "helm", "ksonnet", "kustomize"}
+
+// ValidateSpec checks the content of a bundle, reporting errors relative
+// to fldPath.
+func ValidateSpec(spec *arlonv1.BundleSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.Data != "" && spec.RepoUrl != "" {
+		errs = append(errs, field.Forbidden(fldPath.Child("repoUrl"), "data and repoUrl cannot both be set"))
+	} else if spec.Data == "" && spec.RepoUrl == "" {
+		errs = append(errs, field.Required(fldPath.Child("data"), "data or repoUrl must be set"))
+	}
+	if spec.Data != "" && (spec.RepoPath != "" || spec.RepoRevision != "" || spec.Chart != "" || spec.SrcType != "") {
+		errs = append(errs, field.Forbidden(fldPath, "a static bundle cannot reference a repository"))
+	}
+	errs = append(errs, validateEncryption(spec, fldPath)...)
+	if spec.Chart != "" {
+		repoUrl := spec.RepoUrl
+		if !strings.HasPrefix(repoUrl, "https://") && !strings.HasPrefix(repoUrl, "http://") &&
+			!strings.HasPrefix(repoUrl, OCIScheme) {
+			errs = append(errs, field.Invalid(fldPath.Child("repoUrl"), repoUrl,
+				fmt.Sprintf("the URL of a chart repository must start with https://, http:// or %s", OCIScheme)))
+		}
+		if spec.RepoPath != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("repoPath"), "a chart bundle cannot have a repo path"))
+		}
+		if spec.RepoRevision == "" {
+			errs = append(errs, field.Required(fldPath.Child("repoRevision"), "a chart bundle requires a chart version"))
+		}
+		if spec.SrcType != "" && spec.SrcType != "helm" {
+			errs = append(errs, field.Invalid(fldPath.Child("srcType"), spec.SrcType,
+				"the source type of a chart bundle must be helm"))
+		}
+	}
+	var validSrcType bool
+	for _, t := range validSrcTypes {
+		validSrcType = validSrcType || spec.SrcType == t
+	}
+	if !validSrcType {
+		errs = append(errs, field.NotSupported(fldPath.Child("srcType"), spec.SrcType, validSrcTypes[1:]))
+	}
+	if spec.DeploySettings != nil {
+		errs = append(errs, ValidateDeploySettings(spec.DeploySettings, fldPath.Child("deploySettings"))...)
+	}
+	return errs
+}
+
+// validateEncryption checks that the manifests of a static bundle are
+// encrypted as declared.
+func validateEncryption(spec *arlonv1.BundleSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch spec.Encryption {
+	case "":
+		if spec.Data != "" && IsSopsEncrypted([]byte(spec.Data)) {
+			errs = append(errs, field.Required(fldPath.Child("encryption"),
+				"the manifests are encrypted by SOPS, encryption must be sops"))
+		}
+	case EncryptionSops:
+		if spec.Data == "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("encryption"),
+				"only the manifests of a static bundle can be encrypted"))
+		} else if _, err := ParseSops([]byte(spec.Data)); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("data"), field.OmitValueType{}, err.Error()))
+		}
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("encryption"), spec.Encryption,
+			[]string{EncryptionSops}))
+	}
+	return errs
+}
+
+func splitTags(tags string) []string {
+	var out []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package bundle
+
+import (
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateSpec(t *testing.T) {
+	fldPath := field.NewPath("spec")
+	for _, spec := range []arlonv1.BundleSpec{
+		{Data: "kind: ConfigMap"},
+		{RepoUrl: "https://github.com/org/repo", RepoPath: "guestbook", SrcType: "kustomize"},
+		{RepoUrl: "https://charts.jetstack.io", Chart: "cert-manager", RepoRevision: "v1.11.0"},
+		{RepoUrl: "oci://ghcr.io/org/charts", Chart: "app", RepoRevision: "1.2.3", SrcType: "helm"},
+	} {
+		require.Empty(t, ValidateSpec(&spec, fldPath), "%+v", spec)
+	}
+	for _, spec := range []arlonv1.BundleSpec{
+		{},
+		{Data: "kind: ConfigMap", RepoUrl: "https://github.com/org/repo"},
+		{Data: "kind: ConfigMap", SrcType: "helm"},
+		{RepoUrl: "https://github.com/org/repo", SrcType: "jsonnet"},
+		{RepoUrl: "ghcr.io/org/charts", Chart: "app", RepoRevision: "1.2.3"},
+		{RepoUrl: "https://charts.jetstack.io", Chart: "cert-manager", RepoPath: "charts", RepoRevision: "v1.11.0"},
+		{RepoUrl: "https://charts.jetstack.io", Chart: "cert-manager"},
+		{RepoUrl: "https://charts.jetstack.io", Chart: "cert-manager", RepoRevision: "v1.11.0", SrcType: "kustomize"},
+		{Data: "kind: ConfigMap", DeploySettings: &arlonv1.DeploySettings{DestinationNamespace: "Bad_NS"}},
+	} {
+		require.NotEmpty(t, ValidateSpec(&spec, fldPath), "%+v", spec)
+	}
+}
@@ -3,19 +3,26 @@ package bundle
 import (
 	"context"
 	"fmt"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Delete deletes a bundle. The revisions of a Bundle resource are garbage
+// collected with it, those of a bundle stored as a secret are deleted
+// explicitly.
 func Delete(
-	kubeClient *kubernetes.Clientset,
+	cli client.Client,
 	ns string,
 	bundleName string,
 ) error {
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(ns)
-	err := secretsApi.Delete(context.Background(), bundleName, metav1.DeleteOptions{})
+	ctx := context.Background()
+	b, secr, err := get(ctx, cli, ns, bundleName)
 	if err != nil {
+		return err
+	}
+	if secr != nil {
+		return deleteLegacySecrets(ctx, cli, secr)
+	}
+	if err := cli.Delete(ctx, b); err != nil {
 		return fmt.Errorf("failed to delete bundle: %s", err)
 	}
 	return nil
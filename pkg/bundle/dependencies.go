@@ -0,0 +1,149 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// The dependencies between the bundles of a profile are turned into Argo CD
+// sync waves of the bundles' applications: a bundle is placed in a wave
+// after the waves of the bundles it depends on. Argo CD moves on to the next
+// wave once the applications of the current one are synced and healthy.
+
+// dependencyGraph returns the bundles that each bundle of a profile depends on.
+func dependencyGraph(deps []arlonv1.BundleDependency) map[string][]string {
+	graph := make(map[string][]string)
+	for _, dep := range deps {
+		graph[dep.Bundle] = append(graph[dep.Bundle], dep.DependsOn...)
+	}
+	return graph
+}
+
+// findCycle returns a dependency cycle between bundles as a path from a
+// bundle back to itself, or nil if there is none.
+func findCycle(bundles []string, graph map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range graph[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range bundles {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// ValidateDependencies checks the dependencies between the bundles of a
+// profile, reporting errors relative to fldPath.
+func ValidateDependencies(bundles []string, deps []arlonv1.BundleDependency, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	inProfile := make(map[string]bool)
+	for _, name := range bundles {
+		inProfile[name] = true
+	}
+	declared := make(map[string]bool)
+	for i, dep := range deps {
+		depPath := fldPath.Index(i)
+		if !inProfile[dep.Bundle] {
+			errs = append(errs, field.Invalid(depPath.Child("bundle"), dep.Bundle,
+				"dependency refers to a bundle that is not in spec.bundles"))
+		} else if declared[dep.Bundle] {
+			errs = append(errs, field.Duplicate(depPath.Child("bundle"), dep.Bundle))
+		}
+		declared[dep.Bundle] = true
+		for j, name := range dep.DependsOn {
+			if !inProfile[name] {
+				errs = append(errs, field.Invalid(depPath.Child("dependsOn").Index(j), name,
+					"dependency refers to a bundle that is not in spec.bundles"))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if cycle := findCycle(bundles, dependencyGraph(deps)); cycle != nil {
+		errs = append(errs, field.Invalid(fldPath, strings.Join(cycle, " -> "), "dependency cycle"))
+	}
+	return errs
+}
+
+// ApplyDependencies sets the sync waves of bundles so that each bundle is
+// deployed after the bundles it depends on. The sync wave of a bundle with
+// dependencies is the highest of its own sync wave and the sync waves of its
+// dependencies plus one.
+func ApplyDependencies(bundles []Bundle, deps []arlonv1.BundleDependency) error {
+	if len(deps) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(bundles))
+	byName := make(map[string]*Bundle)
+	for i := range bundles {
+		names = append(names, bundles[i].Name)
+		byName[bundles[i].Name] = &bundles[i]
+	}
+	graph := dependencyGraph(deps)
+	if cycle := findCycle(names, graph); cycle != nil {
+		return fmt.Errorf("dependency cycle between bundles: %s", strings.Join(cycle, " -> "))
+	}
+	done := make(map[string]bool)
+	var resolve func(name string) int32
+	resolve = func(name string) int32 {
+		b := byName[name]
+		if b == nil {
+			return 0
+		}
+		if !done[name] {
+			done[name] = true
+			if len(graph[name]) > 0 {
+				var wave int32
+				if b.Settings.SyncWave != nil {
+					wave = *b.Settings.SyncWave
+				}
+				for _, dep := range graph[name] {
+					if w := resolve(dep) + 1; w > wave {
+						wave = w
+					}
+				}
+				b.Settings.SyncWave = &wave
+			}
+		}
+		if b.Settings.SyncWave == nil {
+			return 0
+		}
+		return *b.Settings.SyncWave
+	}
+	for _, name := range names {
+		resolve(name)
+	}
+	return nil
+}
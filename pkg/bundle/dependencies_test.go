@@ -0,0 +1,46 @@
+package bundle
+
+import (
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDependencies(t *testing.T) {
+	wave := func(w int32) *int32 { return &w }
+	bundles := []Bundle{
+		{Name: "guestbook"},
+		{Name: "issuers"},
+		{Name: "cert-manager", Settings: arlonv1.DeploySettings{SyncWave: wave(2)}},
+		{Name: "monitoring", Settings: arlonv1.DeploySettings{SyncWave: wave(-1)}},
+		{Name: "logging", Settings: arlonv1.DeploySettings{SyncWave: wave(10)}},
+	}
+	deps := []arlonv1.BundleDependency{
+		{Bundle: "guestbook", DependsOn: []string{"issuers", "monitoring"}},
+		{Bundle: "issuers", DependsOn: []string{"cert-manager"}},
+		{Bundle: "logging", DependsOn: []string{"monitoring"}},
+	}
+	require.NoError(t, ApplyDependencies(bundles, deps))
+	waves := make(map[string]int32)
+	for _, b := range bundles {
+		waves[b.Name] = *b.Settings.SyncWave
+	}
+	require.Equal(t, map[string]int32{
+		"cert-manager": 2,
+		"issuers":      3,
+		"guestbook":    4,
+		"monitoring":   -1,
+		"logging":      10,
+	}, waves)
+
+	// Bundles without dependencies keep the default sync wave
+	bundles = []Bundle{{Name: "cert-manager"}, {Name: "issuers"}}
+	require.NoError(t, ApplyDependencies(bundles, deps[1:2]))
+	require.Nil(t, bundles[0].Settings.SyncWave)
+	require.Equal(t, int32(1), *bundles[1].Settings.SyncWave)
+
+	err := ApplyDependencies(bundles, append(deps[1:2],
+		arlonv1.BundleDependency{Bundle: "cert-manager", DependsOn: []string{"issuers"}}))
+	require.ErrorContains(t, err, "cert-manager -> issuers -> cert-manager")
+}
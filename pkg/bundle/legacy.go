@@ -0,0 +1,225 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Before the Bundle CRD, a bundle was stored as a secret labeled with
+// arlon-type=config-bundle, with its content spread across labels,
+// annotations and data keys, and each of its revisions as a secret labeled
+// with arlon-type=config-bundle-revision. Such bundles remain readable, and
+// are converted to Bundle resources when written to or by Migrate.
+
+const (
+	legacyArlonType         = "config-bundle"
+	legacyRevisionArlonType = "config-bundle-revision"
+)
+
+// getLegacySecret returns the secret of a bundle created before the Bundle
+// CRD, or nil if there is none.
+func getLegacySecret(ctx context.Context, cli client.Client, ns string, name string) (*corev1.Secret, error) {
+	var secr corev1.Secret
+	err := cli.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &secr)
+	if apierr.IsNotFound(err) || (err == nil && secr.Labels["arlon-type"] != legacyArlonType) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle secret %s: %s", name, err)
+	}
+	return &secr, nil
+}
+
+// listLegacySecrets returns the secrets of the bundles created before the
+// Bundle CRD, or of the revisions of one of those bundles if bundleName is
+// set, sorted by version then name.
+func listLegacySecrets(ctx context.Context, cli client.Client, ns string, bundleName string) ([]corev1.Secret, error) {
+	labels := client.MatchingLabels{"managed-by": "arlon", "arlon-type": legacyArlonType}
+	if bundleName != "" {
+		labels = client.MatchingLabels{"managed-by": "arlon", "arlon-type": legacyRevisionArlonType,
+			"bundle": bundleName}
+	}
+	var secrets corev1.SecretList
+	if err := cli.List(ctx, &secrets, client.InNamespace(ns), labels); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedList, err)
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool {
+		vi, vj := legacyVersion(&secrets.Items[i]), legacyVersion(&secrets.Items[j])
+		if vi != vj {
+			return vi < vj
+		}
+		return secrets.Items[i].Name < secrets.Items[j].Name
+	})
+	return secrets.Items, nil
+}
+
+// legacyVersion returns the version of a bundle or revision secret, 0 if
+// the bundle was created before versioning.
+func legacyVersion(secr *corev1.Secret) int32 {
+	version, err := strconv.ParseInt(secr.Annotations[common.BundleVersionAnnotationKey], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(version)
+}
+
+// specFromSecret converts the content of a bundle or revision secret.
+func specFromSecret(secr *corev1.Secret) (arlonv1.BundleSpec, error) {
+	spec := arlonv1.BundleSpec{
+		Description:  string(secr.Data["description"]),
+		Data:         string(secr.Data["data"]),
+		RepoUrl:      secr.Annotations[common.RepoUrlAnnotationKey],
+		RepoPath:     secr.Annotations[common.RepoPathAnnotationKey],
+		RepoRevision: secr.Annotations[common.RepoRevisionAnnotationKey],
+		Chart:        secr.Annotations[common.ChartAnnotationKey],
+		SrcType:      secr.Annotations[common.SrcTypeAnnotationKey],
+		Tags:         splitTags(string(secr.Data["tags"])),
+	}
+	if raw := secr.Annotations[common.DeploySettingsAnnotationKey]; raw != "" {
+		var settings arlonv1.DeploySettings
+		if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+			return spec, fmt.Errorf("failed to parse deploy settings of bundle secret %s: %s", secr.Name, err)
+		}
+		spec.DeploySettings = &settings
+	}
+	return spec, nil
+}
+
+// legacyRevision returns a revision recorded as a secret, or nil if there is none.
+func legacyRevision(ctx context.Context, cli client.Client, ns string, bundleName string,
+	version int32) (*arlonv1.BundleRevision, error) {
+	var secr corev1.Secret
+	err := cli.Get(ctx, client.ObjectKey{Namespace: ns, Name: RevisionName(bundleName, version)}, &secr)
+	if apierr.IsNotFound(err) || (err == nil && secr.Labels["arlon-type"] != legacyRevisionArlonType) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision secret: %s", err)
+	}
+	return revisionFromSecret(&secr, bundleName)
+}
+
+func revisionFromSecret(secr *corev1.Secret, bundleName string) (*arlonv1.BundleRevision, error) {
+	content, err := specFromSecret(secr)
+	if err != nil {
+		return nil, err
+	}
+	content.Description, content.Tags = "", nil
+	return &arlonv1.BundleRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: secr.Name, Namespace: secr.Namespace},
+		Spec: arlonv1.BundleRevisionSpec{
+			Bundle:      bundleName,
+			Version:     legacyVersion(secr),
+			ContentHash: ContentHash(&content),
+			ChangeCause: secr.Annotations[common.ChangeCauseAnnotationKey],
+			Content:     content,
+		},
+	}, nil
+}
+
+// Migrate converts the bundles stored as secrets in a namespace to Bundle
+// resources, along with their revisions, and returns their names. Bundles
+// that already have a Bundle resource are skipped. The secrets are deleted
+// if deleteSecrets is set, otherwise they are ignored from then on.
+func Migrate(ctx context.Context, cli client.Client, ns string, deleteSecrets bool) ([]string, error) {
+	secrets, err := listLegacySecrets(ctx, cli, ns, "")
+	if err != nil {
+		return nil, err
+	}
+	var migrated []string
+	for i := range secrets {
+		secr := &secrets[i]
+		var b arlonv1.Bundle
+		err := cli.Get(ctx, client.ObjectKeyFromObject(secr), &b)
+		if apierr.IsNotFound(err) {
+			if _, err := migrateSecret(ctx, cli, secr); err != nil {
+				return migrated, err
+			}
+			migrated = append(migrated, secr.Name)
+		} else if err != nil {
+			return migrated, fmt.Errorf("failed to get bundle %s: %s", secr.Name, err)
+		}
+		if deleteSecrets {
+			if err := deleteLegacySecrets(ctx, cli, secr); err != nil {
+				return migrated, err
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// migrateSecret creates the Bundle resource and revisions of a bundle
+// stored as a secret.
+func migrateSecret(ctx context.Context, cli client.Client, secr *corev1.Secret) (*arlonv1.Bundle, error) {
+	spec, err := specFromSecret(secr)
+	if err != nil {
+		return nil, err
+	}
+	b := &arlonv1.Bundle{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secr.Name,
+			Namespace:   secr.Namespace,
+			Annotations: map[string]string{common.ChangeCauseAnnotationKey: "migrated from secret"},
+		},
+		Spec: spec,
+	}
+	if err := cli.Create(ctx, b); err != nil {
+		return nil, fmt.Errorf("failed to create bundle %s: %s", secr.Name, err)
+	}
+	revSecrets, err := listLegacySecrets(ctx, cli, secr.Namespace, secr.Name)
+	if err != nil {
+		return nil, err
+	}
+	var lastVersion int32
+	for i := range revSecrets {
+		rev, err := revisionFromSecret(&revSecrets[i], secr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := createRevision(ctx, cli, b, rev); err != nil {
+			return nil, err
+		}
+		lastVersion = rev.Spec.Version
+		if rev.Spec.Version == legacyVersion(secr) {
+			b.Status.Version = rev.Spec.Version
+			b.Status.ContentHash = rev.Spec.ContentHash
+		}
+	}
+	if b.Status.ContentHash == "" {
+		// No revision of the current content, for a bundle created before
+		// versioning
+		b.Status.Version = lastVersion
+		if _, err := RecordRevision(ctx, cli, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	b.Status.ObservedGeneration = b.Generation
+	if err := cli.Status().Update(ctx, b); err != nil {
+		return nil, fmt.Errorf("failed to update status of bundle %s: %s", b.Name, err)
+	}
+	return b, nil
+}
+
+// deleteLegacySecrets deletes the secret of a bundle and of its revisions.
+func deleteLegacySecrets(ctx context.Context, cli client.Client, secr *corev1.Secret) error {
+	err := cli.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(secr.Namespace),
+		client.MatchingLabels{"managed-by": "arlon", "arlon-type": legacyRevisionArlonType, "bundle": secr.Name})
+	if err != nil {
+		return fmt.Errorf("failed to delete revision secrets of bundle %s: %s", secr.Name, err)
+	}
+	if err := cli.Delete(ctx, secr); err != nil && !apierr.IsNotFound(err) {
+		return fmt.Errorf("failed to delete bundle secret %s: %s", secr.Name, err)
+	}
+	return nil
+}
@@ -4,66 +4,85 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/arlonproj/arlon/pkg/common"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	restclient "k8s.io/client-go/rest"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
 )
 
 type ListItem struct {
 	Name        string `json:"name,omitempty"`
 	Type        string `json:"type,omitempty"`
+	Version     int32  `json:"version,omitempty"`
 	Tags        string `json:"tags,omitempty"`
 	Repo        string `json:"repo,omitempty"`
 	Path        string `json:"path,omitempty"`
+	Chart       string `json:"chart,omitempty"`
 	Revision    string `json:"revision,omitempty"`
 	SrcType     string `json:"src_type,omitempty"`
 	Description string `json:"description,omitempty"`
 }
 
 var (
-	ErrFailedList = errors.New("failed to list secrets")
+	ErrFailedList = errors.New("failed to list bundles")
 )
 
-func List(config *restclient.Config, namespace string) ([]ListItem, error) {
-	kubeClient := kubernetes.NewForConfigOrDie(config)
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(namespace)
-	opts := metav1.ListOptions{
-		LabelSelector: "managed-by=arlon,arlon-type=config-bundle",
+// List returns the bundles of a namespace, including those still stored as
+// secrets, sorted by name.
+func List(cli client.Client, namespace string) ([]ListItem, error) {
+	ctx := context.Background()
+	var bundleList arlonv1.BundleList
+	if err := cli.List(ctx, &bundleList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedList, err)
 	}
-	secrets, err := secretsApi.List(context.Background(), opts)
+	secrets, err := listLegacySecrets(ctx, cli, namespace, "")
 	if err != nil {
-		return nil, fmt.Errorf("%w: %s", ErrFailedList, err)
+		return nil, err
 	}
-	if len(secrets.Items) == 0 {
+	if len(bundleList.Items) == 0 && len(secrets) == 0 {
 		return nil, nil
 	}
 	var bundles = make([]ListItem, 0)
-	for _, secret := range secrets.Items {
-		labels := secret.GetLabels()
-		bundleType := labels["bundle-type"]
-		if bundleType == "" {
-			bundleType = "(undefined)"
-		}
-		repoUrl := secret.Annotations[common.RepoUrlAnnotationKey]
-		repoPath := secret.Annotations[common.RepoPathAnnotationKey]
-		srcType := secret.Annotations[common.SrcTypeAnnotationKey]
-		if bundleType != "dynamic" {
-			repoUrl = "(N/A)"
-			repoPath = "(N/A)"
+	names := make(map[string]bool)
+	for i := range bundleList.Items {
+		b := &bundleList.Items[i]
+		names[b.Name] = true
+		bundles = append(bundles, toListItem(b.Name, b.Status.Version, &b.Spec))
+	}
+	for i := range secrets {
+		secr := &secrets[i]
+		if names[secr.Name] {
+			// Already migrated
+			continue
 		}
-		bundle := ListItem{
-			Name:        secret.Name,
-			Type:        bundleType,
-			Tags:        string(secret.Data["tags"]),
-			Repo:        repoUrl,
-			Path:        repoPath,
-			Revision:    secret.Annotations[common.RepoRevisionAnnotationKey],
-			SrcType:     srcType,
-			Description: string(secret.Data["description"]),
+		spec, err := specFromSecret(secr)
+		if err != nil {
+			return nil, err
 		}
-		bundles = append(bundles, bundle)
+		bundles = append(bundles, toListItem(secr.Name, legacyVersion(secr), &spec))
 	}
+	sort.Slice(bundles, func(i, j int) bool {
+		return bundles[i].Name < bundles[j].Name
+	})
 	return bundles, nil
 }
+
+func toListItem(name string, version int32, spec *arlonv1.BundleSpec) ListItem {
+	item := ListItem{
+		Name:        name,
+		Type:        bundleType(spec),
+		Version:     version,
+		Tags:        strings.Join(spec.Tags, ","),
+		Repo:        spec.RepoUrl,
+		Path:        spec.RepoPath,
+		Chart:       spec.Chart,
+		Revision:    spec.RepoRevision,
+		SrcType:     spec.SrcType,
+		Description: spec.Description,
+	}
+	if item.Type != "dynamic" {
+		item.Repo = "(N/A)"
+		item.Path = "(N/A)"
+	}
+	return item
+}
@@ -0,0 +1,358 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/helmvalues"
+	gyaml "github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// The overrides of a profile customize the manifests of its bundles for the
+// clusters using the profile. Helm overrides are passed to the application
+// of a Helm or chart bundle as parameters and values. Kustomize images are
+// set on the application, and kustomize patches are applied by a
+// kustomization generated in git, next to the manifests of a static bundle or
+// as an overlay of a kustomize bundle.
+
+// Tools rendering the manifests of a dynamic bundle
+const (
+	ToolHelm      = "helm"
+	ToolKustomize = "kustomize"
+	ToolDirectory = "directory"
+	ToolKsonnet   = "ksonnet"
+)
+
+// Source describes the manifests of a dynamic bundle.
+type Source struct {
+	// Tool rendering the manifests, empty if unknown
+	Tool string
+	// Content of the values.yaml and values.schema.json files of a Helm
+	// chart, nil if the chart does not have them
+	Values       []byte
+	ValuesSchema []byte
+}
+
+// SourceFunc inspects the source of a dynamic bundle. It returns nil if the
+// source is of a kind that is never inspected, and an error if inspecting it
+// failed.
+type SourceFunc func(b *Bundle) (*Source, error)
+
+var regexImage = regexp.MustCompile(`^([^=\s]+=)?[^=\s]+$`)
+
+// IsHelmOverride returns whether an override sets a Helm parameter or values.
+func IsHelmOverride(o *arlonv1.Override) bool {
+	return o.Key != "" || o.Value != "" || o.Values != ""
+}
+
+// IsKustomizeOverride returns whether an override sets kustomize images or patches.
+func IsKustomizeOverride(o *arlonv1.Override) bool {
+	return len(o.Images) > 0 || len(o.Patches) > 0
+}
+
+// ValidateOverride checks an override independently of the bundle it applies
+// to, reporting errors relative to fldPath.
+func ValidateOverride(o *arlonv1.Override, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	helm, kustomize := IsHelmOverride(o), IsKustomizeOverride(o)
+	if !helm && !kustomize {
+		errs = append(errs, field.Required(fldPath,
+			"override must set a Helm parameter, Helm values, images or patches"))
+	} else if helm && kustomize {
+		child := "images"
+		if len(o.Images) == 0 {
+			child = "patches"
+		}
+		errs = append(errs, field.Forbidden(fldPath.Child(child),
+			"kustomize overrides cannot be combined with Helm overrides"))
+	}
+	if o.Value != "" && o.Key == "" {
+		errs = append(errs, field.Required(fldPath.Child("key"), "required with a value"))
+	}
+	if o.Key != "" {
+		if err := helmvalues.ValidateParameterName(o.Key); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("key"), o.Key,
+				fmt.Sprintf("must be a path of value names like the ones of helm --set: %s", err)))
+		}
+	}
+	if o.Values != "" {
+		if _, err := parseValues([]byte(o.Values)); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("values"), field.OmitValueType{}, err.Error()))
+		}
+	}
+	for i, image := range o.Images {
+		if !regexImage.MatchString(image) {
+			errs = append(errs, field.Invalid(fldPath.Child("images").Index(i), image,
+				"must be of the form [name=]newName[:newTag|@digest]"))
+		}
+	}
+	for i := range o.Patches {
+		errs = append(errs, validatePatch(&o.Patches[i], fldPath.Child("patches").Index(i))...)
+	}
+	return errs
+}
+
+func validatePatch(p *arlonv1.KustomizePatch, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	patchPath := fldPath.Child("patch")
+	if p.Patch == "" {
+		return append(errs, field.Required(patchPath, ""))
+	}
+	var obj interface{}
+	if err := gyaml.Unmarshal([]byte(p.Patch), &obj); err != nil {
+		return append(errs, field.Invalid(patchPath, field.OmitValueType{},
+			fmt.Sprintf("not valid YAML: %s", err)))
+	}
+	switch patch := obj.(type) {
+	case []interface{}:
+		// JSON 6902 patch
+		if p.Target == nil {
+			errs = append(errs, field.Required(fldPath.Child("target"),
+				"required with a JSON 6902 patch"))
+		}
+		for i, op := range patch {
+			m, ok := op.(map[string]interface{})
+			if !ok || m["op"] == nil || m["path"] == nil {
+				errs = append(errs, field.Invalid(patchPath, field.OmitValueType{},
+					fmt.Sprintf("operation %d must have an op and a path", i+1)))
+			}
+		}
+	case map[string]interface{}:
+		// strategic merge patch
+		if p.Target == nil {
+			var named struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}
+			_ = gyaml.Unmarshal([]byte(p.Patch), &named)
+			if named.Kind == "" || named.Metadata.Name == "" {
+				errs = append(errs, field.Invalid(patchPath, field.OmitValueType{},
+					"a patch without target must have a kind and a metadata.name"))
+			}
+		}
+	default:
+		errs = append(errs, field.Invalid(patchPath, field.OmitValueType{},
+			"must be a strategic merge patch or a list of JSON 6902 operations"))
+	}
+	return errs
+}
+
+// ValidateOverrides checks the overrides of a profile against the bundles of
+// the profile, reporting errors relative to fldPath. If source is not nil,
+// it inspects the sources of dynamic bundles to find the tool rendering them,
+// and the Helm values of a chart are validated against the values.schema.json
+// file of the chart. A source that cannot be inspected is reported as an
+// error of the first override of its bundle.
+func ValidateOverrides(
+	bundles []Bundle,
+	overrides []arlonv1.Override,
+	source SourceFunc,
+	fldPath *field.Path,
+) field.ErrorList {
+	var errs field.ErrorList
+	byName := make(map[string]*Bundle)
+	for i := range bundles {
+		byName[bundles[i].Name] = &bundles[i]
+	}
+	sources := make(map[string]*Source)
+	failedSources := make(map[string]bool)
+	helmOverrides := make(map[string][]int)
+	kustomizeOverrides := make(map[string]bool)
+	for i := range overrides {
+		o := &overrides[i]
+		ovrPath := fldPath.Index(i)
+		errs = append(errs, ValidateOverride(o, ovrPath)...)
+		b := byName[o.Bundle]
+		if b == nil {
+			errs = append(errs, field.Invalid(ovrPath.Child("bundle"), o.Bundle,
+				"override refers to a bundle that is not in the profile"))
+			continue
+		}
+		if failedSources[b.Name] {
+			continue
+		}
+		src, inspected := sources[b.Name]
+		if !inspected {
+			var err error
+			src, err = inspectSource(b, source)
+			if err != nil {
+				errs = append(errs, field.Invalid(ovrPath.Child("bundle"), o.Bundle,
+					fmt.Sprintf("failed to inspect the source of the bundle: %s", err)))
+				failedSources[b.Name] = true
+				continue
+			}
+			sources[b.Name] = src
+		}
+		if msg := overrideMismatch(b, src, o); msg != "" {
+			errs = append(errs, field.Invalid(ovrPath.Child("bundle"), o.Bundle, msg))
+			continue
+		}
+		if IsHelmOverride(o) {
+			helmOverrides[b.Name] = append(helmOverrides[b.Name], i)
+		} else {
+			kustomizeOverrides[b.Name] = true
+		}
+		if len(helmOverrides[b.Name]) > 0 && kustomizeOverrides[b.Name] {
+			errs = append(errs, field.Invalid(ovrPath.Child("bundle"), o.Bundle,
+				"Helm and kustomize overrides cannot apply to the same bundle"))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	for _, b := range bundles {
+		src := sources[b.Name]
+		indexes := helmOverrides[b.Name]
+		if src == nil || src.ValuesSchema == nil || len(indexes) == 0 {
+			continue
+		}
+		var ovrs []arlonv1.Override
+		for _, i := range indexes {
+			ovrs = append(ovrs, overrides[i])
+		}
+		if err := validateValues(src, ovrs); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Index(indexes[0]), field.OmitValueType{},
+				fmt.Sprintf("overrides of bundle %s do not match the values schema of the chart: %s",
+					b.Name, err)))
+		}
+	}
+	return errs
+}
+
+// inspectSource returns the source of a bundle's manifests, nil for a static
+// bundle.
+func inspectSource(b *Bundle, source SourceFunc) (*Source, error) {
+	if b.Data != nil {
+		return nil, nil
+	}
+	known := &Source{Tool: b.SrcType}
+	if b.Chart != "" {
+		known.Tool = ToolHelm
+	}
+	if known.Tool != "" && known.Tool != ToolHelm || source == nil {
+		// only the values of a chart are validated against its source
+		return known, nil
+	}
+	src, err := source(b)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return known, nil
+	}
+	if known.Tool != "" {
+		src.Tool = known.Tool
+	}
+	return src, nil
+}
+
+// overrideMismatch returns why an override can never apply to a bundle, or
+// an empty string if it can.
+func overrideMismatch(b *Bundle, src *Source, o *arlonv1.Override) string {
+	helm := IsHelmOverride(o)
+	switch {
+	case b.Data != nil && b.Encryption != "":
+		return "overrides cannot apply to a bundle encrypted by SOPS"
+	case b.Data != nil:
+		if helm {
+			return "Helm overrides cannot apply to a static bundle, only images and patches can"
+		}
+	case b.Chart != "" || src.Tool == ToolHelm:
+		if !helm {
+			return "kustomize overrides cannot apply to a Helm bundle, only parameters and values can"
+		}
+	case src.Tool == ToolKustomize:
+		if helm {
+			return "Helm overrides cannot apply to a kustomize bundle, only images and patches can"
+		}
+	case src.Tool != "":
+		return fmt.Sprintf("overrides cannot apply to a bundle of type %s", src.Tool)
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+
+// MergeHelmValues returns the blocks of Helm values of overrides merged in
+// order, nil if there is none.
+func MergeHelmValues(overrides []arlonv1.Override) (map[string]interface{}, error) {
+	var merged map[string]interface{}
+	for _, o := range overrides {
+		if o.Values == "" {
+			continue
+		}
+		values, err := parseValues([]byte(o.Values))
+		if err != nil {
+			return nil, fmt.Errorf("invalid values of bundle %s: %s", o.Bundle, err)
+		}
+		if merged == nil {
+			merged = make(map[string]interface{})
+		}
+		helmvalues.Merge(merged, values)
+	}
+	return merged, nil
+}
+
+func parseValues(data []byte) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := gyaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("not a YAML map: %s", err)
+	}
+	return values, nil
+}
+
+// validateValues validates the values of a chart with the Helm overrides of
+// a bundle against the values schema of the chart.
+func validateValues(src *Source, overrides []arlonv1.Override) error {
+	var schema spec.Schema
+	if err := json.Unmarshal(src.ValuesSchema, &schema); err != nil {
+		return fmt.Errorf("failed to parse values.schema.json: %s", err)
+	}
+	values := make(map[string]interface{})
+	if len(src.Values) > 0 {
+		chartValues, err := parseValues(src.Values)
+		if err != nil {
+			return fmt.Errorf("failed to parse values.yaml: %s", err)
+		}
+		helmvalues.Merge(values, chartValues)
+	}
+	ovrValues, err := MergeHelmValues(overrides)
+	if err != nil {
+		return err
+	}
+	helmvalues.Merge(values, ovrValues)
+	for _, o := range overrides {
+		if o.Key != "" {
+			if err := helmvalues.SetParameter(values, o.Key, o.Value, false); err != nil {
+				return fmt.Errorf("invalid parameter of bundle %s: %s", o.Bundle, err)
+			}
+		}
+	}
+	// the validator expects the types of decoded JSON
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal values: %s", err)
+	}
+	var obj interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("failed to unmarshal values: %s", err)
+	}
+	res := validate.NewSchemaValidator(&schema, nil, "", strfmt.Default).Validate(obj)
+	if !res.HasErrors() {
+		return nil
+	}
+	msgs := make([]string, 0, len(res.Errors))
+	for _, e := range res.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,154 @@
+package bundle
+
+import (
+	"errors"
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const testValuesSchema = `{
+  "$schema": "https://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["replicaCount"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "properties": {"tag": {"type": "string"}}
+    },
+    "ingress": {
+      "type": "object",
+      "properties": {
+        "hosts": {
+          "type": "array",
+          "items": {"type": "object", "properties": {"host": {"type": "string"}}}
+        }
+      }
+    },
+    "nodeSelector": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    }
+  }
+}`
+
+func TestValidateOverrides(t *testing.T) {
+	bundles := []Bundle{
+		{Name: "static", Data: []byte("kind: ConfigMap")},
+		{Name: "secret", Data: []byte("kind: Secret"), Encryption: EncryptionSops},
+		{Name: "podinfo", RepoUrl: "https://stefanprodan.github.io/podinfo", Chart: "podinfo"},
+		{Name: "guestbook", RepoUrl: "https://github.com/org/repo", RepoPath: "guestbook"},
+		{Name: "plain", RepoUrl: "https://github.com/org/repo", RepoPath: "plain", SrcType: ToolDirectory},
+	}
+	var inspected []string
+	source := func(b *Bundle) (*Source, error) {
+		inspected = append(inspected, b.Name)
+		if b.Name == "podinfo" {
+			return &Source{
+				Tool:         ToolHelm,
+				Values:       []byte("replicaCount: 1\nimage:\n  tag: 6.3.5\n"),
+				ValuesSchema: []byte(testValuesSchema),
+			}, nil
+		}
+		return &Source{Tool: ToolKustomize}, nil
+	}
+	fldPath := field.NewPath("spec", "overrides")
+	overrides := []arlonv1.Override{
+		{Bundle: "podinfo", Key: "replicaCount", Value: "3"},
+		{Bundle: "podinfo", Values: "image:\n  tag: 6.4.0\n"},
+		{Bundle: "guestbook", Images: []string{"guestbook=guestbook:v2"}},
+		{Bundle: "static", Patches: []arlonv1.KustomizePatch{
+			{Patch: "- op: add\n  path: /data/foo\n  value: bar\n",
+				Target: &arlonv1.KustomizeTarget{Kind: "ConfigMap"}},
+		}},
+	}
+	require.Empty(t, ValidateOverrides(bundles, overrides, source, fldPath))
+	// sources are inspected once per bundle, the ones of static bundles never
+	require.Equal(t, []string{"podinfo", "guestbook"}, inspected)
+
+	overrides = []arlonv1.Override{
+		{Bundle: "static", Key: "replicaCount", Value: "3"},
+		{Bundle: "secret", Images: []string{"nginx:1.25"}},
+		{Bundle: "podinfo", Images: []string{"podinfo:6.4.0"}},
+		{Bundle: "guestbook", Values: "replicas: 2"},
+		{Bundle: "plain", Images: []string{"nginx:1.25"}},
+		{Bundle: "missing", Key: "replicaCount", Value: "3"},
+	}
+	errs := ValidateOverrides(bundles, overrides, source, fldPath)
+	require.Len(t, errs, len(overrides))
+	for i, err := range errs {
+		require.Equal(t, fldPath.Index(i).Child("bundle").String(), err.Field)
+	}
+	require.Contains(t, errs[0].Detail, "Helm overrides cannot apply to a static bundle")
+	require.Contains(t, errs[1].Detail, "encrypted by SOPS")
+	require.Contains(t, errs[2].Detail, "kustomize overrides cannot apply to a Helm bundle")
+	require.Contains(t, errs[3].Detail, "Helm overrides cannot apply to a kustomize bundle")
+	require.Contains(t, errs[4].Detail, "bundle of type directory")
+
+	// Helm and kustomize overrides of a bundle whose tool is unknown
+	errs = ValidateOverrides(bundles, []arlonv1.Override{
+		{Bundle: "guestbook", Key: "replicas", Value: "2"},
+		{Bundle: "guestbook", Images: []string{"guestbook:v2"}},
+	}, nil, fldPath)
+	require.Len(t, errs, 1)
+	require.Equal(t, "spec.overrides[1].bundle", errs[0].Field)
+
+	// Values of a chart that do not match its schema
+	errs = ValidateOverrides(bundles, []arlonv1.Override{
+		{Bundle: "podinfo", Values: "image:\n  tag: 6.4.0\n"},
+		{Bundle: "podinfo", Key: "replicaCount", Value: "0"},
+		{Bundle: "podinfo", Values: "image:\n  tag: [6, 4]\n"},
+	}, source, fldPath)
+	require.Len(t, errs, 1)
+	require.Equal(t, "spec.overrides[0]", errs[0].Field)
+	require.Contains(t, errs[0].Detail, "replicaCount")
+	require.Contains(t, errs[0].Detail, "image.tag")
+
+	// Parameters indexing lists or with escaped dots are validated too
+	errs = ValidateOverrides(bundles, []arlonv1.Override{
+		{Bundle: "podinfo", Key: "ingress.hosts[0].host", Value: "1"},
+		{Bundle: "podinfo", Key: `nodeSelector.kubernetes\.io/os`, Value: "true"},
+	}, source, fldPath)
+	require.Len(t, errs, 1)
+	require.Equal(t, "spec.overrides[0]", errs[0].Field)
+	require.Contains(t, errs[0].Detail, "ingress.hosts[0].host")
+	require.Contains(t, errs[0].Detail, "nodeSelector.kubernetes.io/os")
+
+	// A source that cannot be inspected is reported once
+	inspected = nil
+	failing := func(b *Bundle) (*Source, error) {
+		inspected = append(inspected, b.Name)
+		return nil, errors.New("repository not found")
+	}
+	errs = ValidateOverrides(bundles, []arlonv1.Override{
+		{Bundle: "podinfo", Key: "replicaCount", Value: "2"},
+		{Bundle: "podinfo", Values: "image:\n  tag: 6.4.0\n"},
+	}, failing, fldPath)
+	require.Len(t, errs, 1)
+	require.Equal(t, "spec.overrides[0].bundle", errs[0].Field)
+	require.Contains(t, errs[0].Detail, "repository not found")
+	require.Equal(t, []string{"podinfo"}, inspected)
+}
+
+func TestMergeHelmValues(t *testing.T) {
+	values, err := MergeHelmValues([]arlonv1.Override{
+		{Bundle: "podinfo", Values: "replicaCount: 2\nimage:\n  repository: podinfo\n  tag: 6.3.5\n"},
+		{Bundle: "podinfo", Key: "ignored", Value: "true"},
+		{Bundle: "podinfo", Values: "image:\n  tag: 6.4.0\n"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"replicaCount": float64(2),
+		"image": map[string]interface{}{
+			"repository": "podinfo",
+			"tag":        "6.4.0",
+		},
+	}, values)
+
+	values, err = MergeHelmValues([]arlonv1.Override{{Bundle: "podinfo", Key: "a", Value: "b"}})
+	require.NoError(t, err)
+	require.Nil(t, values)
+}
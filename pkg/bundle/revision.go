@@ -0,0 +1,262 @@
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/common"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Each change of the content of a bundle is recorded as an immutable
+// BundleRevision, numbered from 1 and owned by the Bundle. The status of the
+// Bundle holds the version of its latest revision.
+
+// RevisionBundleLabel is the label of a BundleRevision holding the name of its bundle
+const RevisionBundleLabel = "arlon.io/bundle"
+
+type Revision struct {
+	Version     int32
+	ContentHash string
+	ChangeCause string
+	Created     time.Time
+	// Whether the bundle currently holds the content of the revision
+	Current bool
+}
+
+// RevisionName returns the name of a bundle revision.
+func RevisionName(bundleName string, version int32) string {
+	return fmt.Sprintf("%s.v%d", bundleName, version)
+}
+
+// ContentHash returns the hash of the content of a bundle, which excludes
+// its description and tags.
+func ContentHash(spec *arlonv1.BundleSpec) string {
+	content := spec.DeepCopy()
+	content.Description, content.Tags = "", nil
+	raw, _ := json.Marshal(content)
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// maxRecordAttempts is the number of times RecordRevision re-reads a bundle
+// whose next revision was recorded concurrently.
+const maxRecordAttempts = 5
+
+// RecordRevision records the content of a bundle as its next revision if it
+// changed since the latest one, and updates the status of the bundle
+// accordingly. The change cause is taken from the arlon.io/change-cause
+// annotation of the bundle. If another writer recorded the next revision
+// first, the bundle is read again and its latest content is recorded instead.
+// RecordRevision returns whether a revision was recorded.
+func RecordRevision(ctx context.Context, cli client.Client, b *arlonv1.Bundle) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		recorded, err := recordRevision(ctx, cli, b)
+		if !errors.Is(err, ErrRevisionConflict) || attempt == maxRecordAttempts {
+			return recorded, err
+		}
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(b), b); err != nil {
+			return false, fmt.Errorf("failed to get bundle %s: %s", b.Name, err)
+		}
+	}
+}
+
+func recordRevision(ctx context.Context, cli client.Client, b *arlonv1.Bundle) (bool, error) {
+	hash := ContentHash(&b.Spec)
+	if hash == b.Status.ContentHash {
+		if b.Status.ObservedGeneration == b.Generation {
+			return false, nil
+		}
+		b.Status.ObservedGeneration = b.Generation
+		return false, updateStatus(ctx, cli, b, hash)
+	}
+	cause := b.Annotations[common.ChangeCauseAnnotationKey]
+	if cause == "" {
+		cause = "updated"
+	}
+	content := b.Spec.DeepCopy()
+	content.Description, content.Tags = "", nil
+	rev := &arlonv1.BundleRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RevisionName(b.Name, b.Status.Version+1),
+			Namespace: b.Namespace,
+		},
+		Spec: arlonv1.BundleRevisionSpec{
+			Bundle:      b.Name,
+			Version:     b.Status.Version + 1,
+			ContentHash: hash,
+			ChangeCause: cause,
+			Content:     *content,
+		},
+	}
+	if err := createRevision(ctx, cli, b, rev); err != nil {
+		return false, err
+	}
+	b.Status.Version = rev.Spec.Version
+	b.Status.ContentHash = hash
+	b.Status.ObservedGeneration = b.Generation
+	return true, updateStatus(ctx, cli, b, hash)
+}
+
+// createRevision creates a revision owned by its bundle. A revision left over
+// by a status update that failed after creating it is replaced if its
+// content differs. Revisions are immutable otherwise, so if the bundle no
+// longer points at the preceding version, the revision was recorded by a
+// concurrent writer and ErrRevisionConflict is returned.
+func createRevision(ctx context.Context, cli client.Client, b *arlonv1.Bundle, rev *arlonv1.BundleRevision) error {
+	rev.ResourceVersion = ""
+	rev.Labels = map[string]string{RevisionBundleLabel: b.Name}
+	rev.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: arlonv1.GroupVersion.String(),
+		Kind:       "Bundle",
+		Name:       b.Name,
+		UID:        b.UID,
+	}}
+	err := cli.Create(ctx, rev)
+	if apierr.IsAlreadyExists(err) {
+		var existing arlonv1.BundleRevision
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(rev), &existing); err != nil {
+			return fmt.Errorf("failed to get revision %s: %s", rev.Name, err)
+		}
+		if existing.Spec.ContentHash == rev.Spec.ContentHash {
+			return nil
+		}
+		var latest arlonv1.Bundle
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(b), &latest); err != nil {
+			return fmt.Errorf("failed to get bundle %s: %s", b.Name, err)
+		}
+		if latest.Status.Version != rev.Spec.Version-1 {
+			return fmt.Errorf("%w: %s", ErrRevisionConflict, rev.Name)
+		}
+		if err := cli.Delete(ctx, &existing); err != nil {
+			return fmt.Errorf("failed to delete stale revision %s: %s", rev.Name, err)
+		}
+		rev.ResourceVersion = ""
+		err = cli.Create(ctx, rev)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create revision %s: %s", rev.Name, err)
+	}
+	return nil
+}
+
+// updateStatus saves the status of a bundle. A conflict is ignored if the
+// same content was recorded concurrently, for e.g. by the controller.
+func updateStatus(ctx context.Context, cli client.Client, b *arlonv1.Bundle, hash string) error {
+	err := cli.Status().Update(ctx, b)
+	if apierr.IsConflict(err) {
+		var latest arlonv1.Bundle
+		if getErr := cli.Get(ctx, client.ObjectKeyFromObject(b), &latest); getErr == nil &&
+			latest.Status.ContentHash == hash {
+			*b = latest
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update status of bundle %s: %s", b.Name, err)
+	}
+	return nil
+}
+
+// GetRevision returns a revision of a bundle.
+func GetRevision(ctx context.Context, cli client.Client, ns string, bundleName string,
+	version int32) (*arlonv1.BundleRevision, error) {
+	var rev arlonv1.BundleRevision
+	err := cli.Get(ctx, client.ObjectKey{Namespace: ns, Name: RevisionName(bundleName, version)}, &rev)
+	if err == nil {
+		return &rev, nil
+	}
+	if !apierr.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get revision %d of bundle %s: %s", version, bundleName, err)
+	}
+	legacy, err := legacyRevision(ctx, cli, ns, bundleName, version)
+	if err != nil {
+		return nil, err
+	}
+	if legacy == nil {
+		return nil, fmt.Errorf("%w: revision %d of bundle %s", ErrNotFound, version, bundleName)
+	}
+	return legacy, nil
+}
+
+// History returns the revisions of a bundle, oldest first.
+func History(ctx context.Context, cli client.Client, ns string, bundleName string) ([]Revision, error) {
+	b, err := getForWrite(ctx, cli, ns, bundleName)
+	if err != nil {
+		return nil, err
+	}
+	var revList arlonv1.BundleRevisionList
+	err = cli.List(ctx, &revList, client.InNamespace(ns), client.MatchingLabels{RevisionBundleLabel: bundleName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions of bundle %s: %s", bundleName, err)
+	}
+	sort.Slice(revList.Items, func(i, j int) bool {
+		return revList.Items[i].Spec.Version < revList.Items[j].Spec.Version
+	})
+	var revs []Revision
+	for _, rev := range revList.Items {
+		revs = append(revs, Revision{
+			Version:     rev.Spec.Version,
+			ContentHash: rev.Spec.ContentHash,
+			ChangeCause: rev.Spec.ChangeCause,
+			Created:     rev.CreationTimestamp.Time,
+			Current:     rev.Spec.Version == b.Status.Version,
+		})
+	}
+	return revs, nil
+}
+
+// Rollback restores the content of a bundle to one of its revisions, which
+// is recorded as a new revision. A version of 0 designates the revision
+// preceding the current one. Rollback returns the new version of the bundle.
+func Rollback(ctx context.Context, cli client.Client, ns string, bundleName string, version int32) (int32, error) {
+	b, err := getForWrite(ctx, cli, ns, bundleName)
+	if err != nil {
+		return 0, err
+	}
+	current := b.Status.Version
+	if version == 0 {
+		version = current - 1
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("bundle %s has no previous revision", bundleName)
+	}
+	if version == current {
+		return 0, fmt.Errorf("bundle %s is already at version %d", bundleName, version)
+	}
+	rev, err := GetRevision(ctx, cli, ns, bundleName, version)
+	if err != nil {
+		return 0, err
+	}
+	if (rev.Spec.Content.Data == "") != (b.Spec.Data == "") {
+		return 0, fmt.Errorf("cannot roll back a %s bundle to a %s revision",
+			bundleType(&b.Spec), bundleType(&rev.Spec.Content))
+	}
+	content := rev.Spec.Content.DeepCopy()
+	content.Description, content.Tags = b.Spec.Description, b.Spec.Tags
+	b.Spec = *content
+	setChangeCause(b, fmt.Sprintf("rollback to version %d", version))
+	if err := cli.Update(ctx, b); err != nil {
+		return 0, fmt.Errorf("failed to update bundle %s: %s", bundleName, err)
+	}
+	if _, err := RecordRevision(ctx, cli, b); err != nil {
+		return 0, err
+	}
+	return b.Status.Version, nil
+}
+
+func setChangeCause(b *arlonv1.Bundle, cause string) {
+	if b.Annotations == nil {
+		b.Annotations = map[string]string{}
+	}
+	b.Annotations[common.ChangeCauseAnnotationKey] = cause
+}
@@ -0,0 +1,207 @@
+package bundle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/common"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, arlonv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func writeManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestBundleRevisions(t *testing.T) {
+	cli := newFakeClient(t)
+	ctx := context.Background()
+	require.NoError(t, Create(cli, "arlon", "guestbook", writeManifest(t, "replicas: 1"),
+		"", "", "", "", "", "demo", "web", arlonv1.DeploySettings{}))
+	b, err := Get(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), b.Status.Version)
+	hashV1 := b.Status.ContentHash
+
+	// Changing the description only does not record a revision
+	require.NoError(t, Update(cli, "arlon", "guestbook", "", "", "", "", "guestbook demo", "", nil))
+	require.NoError(t, Update(cli, "arlon", "guestbook", writeManifest(t, "replicas: 2"),
+		"", "", "", "", "", nil))
+	b, err = Get(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), b.Status.Version)
+	require.NotEqual(t, hashV1, b.Status.ContentHash)
+
+	revs, err := History(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, int32(1), revs[0].Version)
+	require.Equal(t, hashV1, revs[0].ContentHash)
+	require.Equal(t, "created", revs[0].ChangeCause)
+	require.False(t, revs[0].Current)
+	require.Equal(t, "updated", revs[1].ChangeCause)
+	require.True(t, revs[1].Current)
+
+	// Pinned profiles get the content of the revision
+	prof := &arlonv1.Profile{Spec: arlonv1.ProfileSpec{
+		Bundles:        []string{"guestbook"},
+		BundleVersions: []arlonv1.BundleVersion{{Bundle: "guestbook", Version: 1}},
+	}}
+	bundles, err := GetBundlesFromProfile(prof, cli, "arlon")
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 1", string(bundles[0].Data))
+
+	version, err := Rollback(ctx, cli, "arlon", "guestbook", 0)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), version)
+	b, err = Get(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	require.Equal(t, "replicas: 1", b.Spec.Data)
+	require.Equal(t, "guestbook demo", b.Spec.Description)
+	require.Equal(t, hashV1, b.Status.ContentHash)
+	rev, err := GetRevision(ctx, cli, "arlon", "guestbook", 3)
+	require.NoError(t, err)
+	require.Equal(t, "rollback to version 1", rev.Spec.ChangeCause)
+
+	_, err = Rollback(ctx, cli, "arlon", "guestbook", 3)
+	require.Error(t, err)
+	_, err = Rollback(ctx, cli, "arlon", "guestbook", 7)
+	require.Error(t, err)
+}
+
+func TestConcurrentRevisions(t *testing.T) {
+	cli := newFakeClient(t)
+	ctx := context.Background()
+	require.NoError(t, Create(cli, "arlon", "guestbook", writeManifest(t, "replicas: 1"),
+		"", "", "", "", "", "demo", "web", arlonv1.DeploySettings{}))
+	stale, err := Get(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+
+	// The winner records version 2
+	require.NoError(t, Update(cli, "arlon", "guestbook", writeManifest(t, "replicas: 2"),
+		"", "", "", "", "", nil))
+	winner, err := GetRevision(ctx, cli, "arlon", "guestbook", 2)
+	require.NoError(t, err)
+
+	// The loser also computes version 2 from its stale read of the bundle,
+	// it must not replace the revision of the winner
+	stale.Spec.Data = "replicas: 3"
+	_, err = RecordRevision(ctx, cli, stale)
+	require.NoError(t, err)
+	rev, err := GetRevision(ctx, cli, "arlon", "guestbook", 2)
+	require.NoError(t, err)
+	require.Equal(t, winner.Spec.ContentHash, rev.Spec.ContentHash)
+	require.Equal(t, winner.UID, rev.UID)
+	require.Equal(t, int32(2), stale.Status.Version)
+	require.Equal(t, "replicas: 2", stale.Spec.Data)
+	_, err = GetRevision(ctx, cli, "arlon", "guestbook", 3)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// A revision left over by a failed status update is replaced
+	b, err := Get(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	leftover := &arlonv1.BundleRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: RevisionName("guestbook", 3), Namespace: "arlon"},
+		Spec:       arlonv1.BundleRevisionSpec{Bundle: "guestbook", Version: 3, ContentHash: "sha256:old"},
+	}
+	require.NoError(t, cli.Create(ctx, leftover))
+	b.Spec.Data = "replicas: 4"
+	require.NoError(t, cli.Update(ctx, b))
+	recorded, err := RecordRevision(ctx, cli, b)
+	require.NoError(t, err)
+	require.True(t, recorded)
+	rev, err = GetRevision(ctx, cli, "arlon", "guestbook", 3)
+	require.NoError(t, err)
+	require.Equal(t, ContentHash(&b.Spec), rev.Spec.ContentHash)
+}
+
+func TestMigrate(t *testing.T) {
+	labels := map[string]string{"managed-by": "arlon", "arlon-type": legacyArlonType, "bundle-type": "dynamic"}
+	annotations := map[string]string{
+		common.RepoUrlAnnotationKey:       "https://github.com/org/repo",
+		common.RepoPathAnnotationKey:      "guestbook",
+		common.RepoRevisionAnnotationKey:  "v2",
+		common.BundleVersionAnnotationKey: "2",
+	}
+	revision := func(version string, repoRevision string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "guestbook.v" + version,
+				Namespace: "arlon",
+				Labels: map[string]string{"managed-by": "arlon", "arlon-type": legacyRevisionArlonType,
+					"bundle": "guestbook"},
+				Annotations: map[string]string{
+					common.RepoUrlAnnotationKey:       "https://github.com/org/repo",
+					common.RepoPathAnnotationKey:      "guestbook",
+					common.RepoRevisionAnnotationKey:  repoRevision,
+					common.BundleVersionAnnotationKey: version,
+				},
+			},
+		}
+	}
+	cli := newFakeClient(t,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "guestbook", Namespace: "arlon", Labels: labels,
+				Annotations: annotations},
+			Data: map[string][]byte{"description": []byte("demo"), "tags": []byte("web,demo")},
+		},
+		revision("1", "v1"),
+		revision("2", "v2"),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "arlon", Labels: map[string]string{
+				"managed-by": "arlon", "arlon-type": legacyArlonType, "bundle-type": "static"}},
+			Data: map[string][]byte{"data": []byte("kind: ConfigMap")},
+		},
+	)
+	ctx := context.Background()
+
+	// Legacy bundles are readable before being migrated
+	items, err := List(cli, "arlon")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, int32(2), items[0].Version)
+	rev, err := GetRevision(ctx, cli, "arlon", "guestbook", 1)
+	require.NoError(t, err)
+	require.Equal(t, "v1", rev.Spec.Content.RepoRevision)
+
+	migrated, err := Migrate(ctx, cli, "arlon", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"legacy", "guestbook"}, migrated)
+
+	var b arlonv1.Bundle
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "arlon", Name: "guestbook"}, &b))
+	require.Equal(t, []string{"web", "demo"}, b.Spec.Tags)
+	require.Equal(t, int32(2), b.Status.Version)
+	revs, err := History(ctx, cli, "arlon", "guestbook")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.True(t, revs[1].Current)
+
+	// A bundle created before versioning gets its content as first revision
+	require.NoError(t, cli.Get(ctx, client.ObjectKey{Namespace: "arlon", Name: "legacy"}, &b))
+	require.Equal(t, int32(1), b.Status.Version)
+
+	var secrets corev1.SecretList
+	require.NoError(t, cli.List(ctx, &secrets, client.InNamespace("arlon")))
+	require.Empty(t, secrets.Items)
+	items, err = List(cli, "arlon")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+}
@@ -0,0 +1,92 @@
+package bundle
+
+import (
+	"strings"
+	"time"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// MergeDeploySettings returns the settings of base overridden by the fields
+// set in override. Lists replace the ones of base rather than extend them.
+func MergeDeploySettings(base, override arlonv1.DeploySettings) arlonv1.DeploySettings {
+	out := *base.DeepCopy()
+	o := override.DeepCopy()
+	if o.DestinationNamespace != "" {
+		out.DestinationNamespace = o.DestinationNamespace
+	}
+	if o.CreateNamespace != nil {
+		out.CreateNamespace = o.CreateNamespace
+	}
+	if o.Project != "" {
+		out.Project = o.Project
+	}
+	if o.Prune != nil {
+		out.Prune = o.Prune
+	}
+	if o.SelfHeal != nil {
+		out.SelfHeal = o.SelfHeal
+	}
+	if o.SyncOptions != nil {
+		out.SyncOptions = o.SyncOptions
+	}
+	if o.Retry != nil {
+		out.Retry = o.Retry
+	}
+	if o.IgnoreDifferences != nil {
+		out.IgnoreDifferences = o.IgnoreDifferences
+	}
+	if o.SyncWave != nil {
+		out.SyncWave = o.SyncWave
+	}
+	return out
+}
+
+// ValidateDeploySettings checks deployment settings, reporting errors
+// relative to fldPath.
+func ValidateDeploySettings(settings *arlonv1.DeploySettings, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if ns := settings.DestinationNamespace; ns != "" {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(fldPath.Child("destinationNamespace"), ns, msg))
+		}
+	}
+	if p := settings.Project; p != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(p) {
+			errs = append(errs, field.Invalid(fldPath.Child("project"), p, msg))
+		}
+	}
+	for i, opt := range settings.SyncOptions {
+		if strings.Index(opt, "=") < 1 {
+			errs = append(errs, field.Invalid(fldPath.Child("syncOptions").Index(i), opt,
+				"must have the form Name=value"))
+		}
+	}
+	if r := settings.Retry; r != nil && r.Backoff != nil {
+		backoffPath := fldPath.Child("retry", "backoff")
+		for _, d := range []struct{ name, value string }{
+			{"duration", r.Backoff.Duration},
+			{"maxDuration", r.Backoff.MaxDuration},
+		} {
+			if _, err := time.ParseDuration(d.value); d.value != "" && err != nil {
+				errs = append(errs, field.Invalid(backoffPath.Child(d.name), d.value, "not a valid duration"))
+			}
+		}
+		if f := r.Backoff.Factor; f != nil && *f < 1 {
+			errs = append(errs, field.Invalid(backoffPath.Child("factor"), *f, "must be at least 1"))
+		}
+	}
+	for i, id := range settings.IgnoreDifferences {
+		idPath := fldPath.Child("ignoreDifferences").Index(i)
+		if id.Kind == "" {
+			errs = append(errs, field.Required(idPath.Child("kind"), ""))
+		}
+		if len(id.JSONPointers) == 0 && len(id.JQPathExpressions) == 0 {
+			errs = append(errs, field.Required(idPath.Child("jsonPointers"),
+				"jsonPointers or jqPathExpressions must be set"))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,30 @@
+package bundle
+
+import (
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDeploySettings(t *testing.T) {
+	yes, no := true, false
+	base := arlonv1.DeploySettings{
+		DestinationNamespace: "monitoring",
+		CreateNamespace:      &yes,
+		SyncOptions:          []string{"ServerSideApply=true"},
+	}
+	merged := MergeDeploySettings(base, arlonv1.DeploySettings{
+		Project:     "platform",
+		Prune:       &no,
+		SyncOptions: []string{"Replace=true"},
+	})
+	require.Equal(t, arlonv1.DeploySettings{
+		DestinationNamespace: "monitoring",
+		CreateNamespace:      &yes,
+		Project:              "platform",
+		Prune:                &no,
+		SyncOptions:          []string{"Replace=true"},
+	}, merged)
+	require.Equal(t, []string{"ServerSideApply=true"}, base.SyncOptions)
+}
@@ -0,0 +1,219 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	gyaml "github.com/ghodss/yaml"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// The manifests of a static bundle can be encrypted by SOPS with age keys.
+// They are then committed to git as is, and the application of the bundle is
+// rendered by the arlon-sops Argo CD config management plugin, which runs as
+// a sidecar of the Argo CD repo server and decrypts them with the age key
+// mounted from a secret.
+
+const (
+	// EncryptionSops is the encryption of a static bundle encrypted by SOPS
+	EncryptionSops = "sops"
+	// SopsPluginName is the name of the Argo CD plugin decrypting SOPS bundles
+	SopsPluginName = "arlon-sops"
+	// DefaultSopsPluginImage is an image providing sh and sops for the plugin
+	DefaultSopsPluginImage = "mozilla/sops:v3.7.3-alpine"
+	// DefaultSopsKeySecret is the secret holding the age private keys in keys.txt
+	DefaultSopsKeySecret = "arlon-sops-age-key"
+)
+
+// SopsInfo is the SOPS metadata of an encrypted bundle.
+type SopsInfo struct {
+	// Public keys of the age recipients the bundle is encrypted for
+	AgeRecipients []string
+}
+
+type sopsMetadata struct {
+	Mac string `json:"mac"`
+	Age []struct {
+		Recipient string `json:"recipient"`
+	} `json:"age"`
+}
+
+// sopsDocuments returns the SOPS metadata of each YAML document of data, nil
+// for a document that is not encrypted.
+func sopsDocuments(data []byte) ([]*sopsMetadata, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var docs []*sopsMetadata
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read YAML document: %s", err)
+		}
+		if isEmptyDocument(doc) {
+			continue
+		}
+		var obj struct {
+			Sops *sopsMetadata `json:"sops"`
+		}
+		if err := gyaml.Unmarshal(doc, &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML document %d: %s", len(docs)+1, err)
+		}
+		docs = append(docs, obj.Sops)
+	}
+}
+
+// isEmptyDocument returns whether a YAML document only holds comments and
+// separators.
+func isEmptyDocument(doc []byte) bool {
+	for _, line := range strings.Split(string(doc), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && line != "---" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSopsEncrypted returns whether some YAML document of data is encrypted by SOPS.
+func IsSopsEncrypted(data []byte) bool {
+	docs, err := sopsDocuments(data)
+	if err != nil {
+		return false
+	}
+	for _, md := range docs {
+		if md != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSops checks that every YAML document of data is encrypted by SOPS
+// with age keys, and returns the SOPS metadata.
+func ParseSops(data []byte) (*SopsInfo, error) {
+	docs, err := sopsDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no YAML document found")
+	}
+	info := &SopsInfo{}
+	seen := map[string]bool{}
+	for i, md := range docs {
+		if md == nil || md.Mac == "" {
+			return nil, fmt.Errorf("YAML document %d is not encrypted by SOPS", i+1)
+		}
+		if len(md.Age) == 0 {
+			return nil, fmt.Errorf("YAML document %d is not encrypted with an age key", i+1)
+		}
+		for _, age := range md.Age {
+			if age.Recipient != "" && !seen[age.Recipient] {
+				seen[age.Recipient] = true
+				info.AgeRecipients = append(info.AgeRecipients, age.Recipient)
+			}
+		}
+	}
+	return info, nil
+}
+
+// -----------------------------------------------------------------------------
+
+const sopsPluginTmpl = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-plugin
+  namespace: {{ .Namespace }}
+  labels:
+    managed-by: arlon
+data:
+  plugin.yaml: |
+    apiVersion: argoproj.io/v1alpha1
+    kind: ConfigManagementPlugin
+    metadata:
+      name: {{ .Name }}
+    spec:
+      generate:
+        command: [sh, -c]
+        args:
+        - |
+          set -e
+          for f in $(find . -name '*.yaml' -o -name '*.yml' | sort); do
+            sops --decrypt --input-type yaml --output-type yaml "$f"
+            echo "---"
+          done
+`
+
+const sopsRepoServerPatchTmpl = `spec:
+  template:
+    spec:
+      containers:
+      - name: {{ .Name }}
+        image: {{ .Image }}
+        command: [/var/run/argocd/argocd-cmp-server]
+        securityContext:
+          runAsNonRoot: true
+          runAsUser: 999
+        env:
+        - name: SOPS_AGE_KEY_FILE
+          value: /home/argocd/sops/keys.txt
+        volumeMounts:
+        - mountPath: /var/run/argocd
+          name: var-files
+        - mountPath: /home/argocd/cmp-server/plugins
+          name: plugins
+        - mountPath: /home/argocd/cmp-server/config/plugin.yaml
+          subPath: plugin.yaml
+          name: {{ .Name }}-plugin
+        - mountPath: /home/argocd/sops
+          name: {{ .Name }}-age-key
+          readOnly: true
+        - mountPath: /tmp
+          name: {{ .Name }}-tmp
+      volumes:
+      - name: {{ .Name }}-plugin
+        configMap:
+          name: {{ .Name }}-plugin
+      - name: {{ .Name }}-age-key
+        secret:
+          secretName: {{ .KeySecret }}
+      - name: {{ .Name }}-tmp
+        emptyDir: {}
+`
+
+type sopsPluginSettings struct {
+	Name      string
+	Namespace string
+	KeySecret string
+	Image     string
+}
+
+// SopsPluginManifests returns the ConfigMap holding the configuration of the
+// arlon-sops plugin in the Argo CD namespace, and the strategic merge patch
+// adding the plugin's sidecar to the argocd-repo-server deployment.
+func SopsPluginManifests(argocdNs string, keySecret string, image string) (configMap string, patch string, err error) {
+	settings := sopsPluginSettings{
+		Name:      SopsPluginName,
+		Namespace: argocdNs,
+		KeySecret: keySecret,
+		Image:     image,
+	}
+	var out [2]strings.Builder
+	for i, text := range []string{sopsPluginTmpl, sopsRepoServerPatchTmpl} {
+		tmpl, err := template.New("sops").Parse(text)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create plugin template: %s", err)
+		}
+		if err := tmpl.Execute(&out[i], &settings); err != nil {
+			return "", "", fmt.Errorf("failed to render plugin template: %s", err)
+		}
+	}
+	return out[0].String(), out[1].String(), nil
+}
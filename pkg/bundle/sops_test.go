@@ -0,0 +1,93 @@
+package bundle
+
+import (
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	gyaml "github.com/ghodss/yaml"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const sopsSecret = `apiVersion: v1
+kind: Secret
+metadata:
+    name: registry-creds
+type: Opaque
+stringData:
+    password: ENC[AES256_GCM,data:Tr7o1VQ=,iv:1=,tag:2=,type:str]
+sops:
+    kms: []
+    age:
+        - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+          enc: |
+            -----BEGIN AGE ENCRYPTED FILE-----
+            YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBiVWQ4
+            -----END AGE ENCRYPTED FILE-----
+    lastmodified: "2023-03-01T10:00:00Z"
+    mac: ENC[AES256_GCM,data:3jE=,iv:3=,tag:4=,type:str]
+    encrypted_regex: ^(data|stringData)$
+    version: 3.7.3
+`
+
+const plainConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  level: debug
+`
+
+func TestParseSops(t *testing.T) {
+	info, err := ParseSops([]byte("# encrypted\n---\n" + sopsSecret + "---\n" + sopsSecret))
+	require.NoError(t, err)
+	require.Equal(t, []string{"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"}, info.AgeRecipients)
+	require.True(t, IsSopsEncrypted([]byte(sopsSecret)))
+	require.False(t, IsSopsEncrypted([]byte(plainConfigMap)))
+
+	_, err = ParseSops([]byte(plainConfigMap))
+	require.Error(t, err)
+	_, err = ParseSops([]byte(sopsSecret + "---\n" + plainConfigMap))
+	require.Error(t, err)
+	pgpOnly := `data: ENC[AES256_GCM,data:Tr7o1VQ=,iv:1=,tag:2=,type:str]
+sops:
+    pgp:
+        - fp: 85D77543B3D624B63CEA9E6DBC17301B491B3F21
+    mac: ENC[AES256_GCM,data:3jE=,iv:3=,tag:4=,type:str]
+`
+	_, err = ParseSops([]byte(pgpOnly))
+	require.Error(t, err)
+}
+
+func TestValidateEncryption(t *testing.T) {
+	fldPath := field.NewPath("spec")
+	require.Empty(t, ValidateSpec(&arlonv1.BundleSpec{Data: sopsSecret, Encryption: EncryptionSops}, fldPath))
+	for _, spec := range []arlonv1.BundleSpec{
+		{Data: sopsSecret},
+		{Data: plainConfigMap, Encryption: EncryptionSops},
+		{Data: sopsSecret, Encryption: "vault"},
+		{RepoUrl: "https://github.com/org/repo", Encryption: EncryptionSops},
+	} {
+		require.NotEmpty(t, ValidateSpec(&spec, fldPath), "%+v", spec)
+	}
+}
+
+func TestSopsPluginManifests(t *testing.T) {
+	configMap, patch, err := SopsPluginManifests("argocd", DefaultSopsKeySecret, DefaultSopsPluginImage)
+	require.NoError(t, err)
+	var cm struct {
+		Metadata struct{ Name, Namespace string }
+		Data     map[string]string
+	}
+	require.NoError(t, gyaml.Unmarshal([]byte(configMap), &cm))
+	require.Equal(t, "arlon-sops-plugin", cm.Metadata.Name)
+	require.Equal(t, "argocd", cm.Metadata.Namespace)
+	var plugin struct {
+		Metadata struct{ Name string }
+	}
+	require.NoError(t, gyaml.Unmarshal([]byte(cm.Data["plugin.yaml"]), &plugin))
+	require.Equal(t, SopsPluginName, plugin.Metadata.Name)
+	var p map[string]interface{}
+	require.NoError(t, gyaml.Unmarshal([]byte(patch), &p))
+	require.Contains(t, patch, "secretName: "+DefaultSopsKeySecret)
+}
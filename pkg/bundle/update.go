@@ -3,63 +3,92 @@ package bundle
 import (
 	"context"
 	"fmt"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"os"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 )
 
 func Update(
-	kubeClient *kubernetes.Clientset,
+	cli client.Client,
 	ns string, bundleName string,
 	fromFile string,
 	repoUrl string,
 	repoPath string,
+	chartVersion string,
 	desc string,
 	tags string,
+	settings *arlonv1.DeploySettings,
 ) error {
 	if !IsValidK8sName(bundleName) {
 		return fmt.Errorf("%w: %s", ErrInvalidName, bundleName)
 	}
-	corev1 := kubeClient.CoreV1()
-	secretsApi := corev1.Secrets(ns)
-	secr, err := secretsApi.Get(context.Background(), bundleName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get bundle secret: %s", err)
+	if fromFile != "" && repoUrl != "" {
+		return fmt.Errorf("file and repo cannot both be specified")
 	}
-	var dirty bool
-	if desc != "" && desc != string(secr.Data["description"]) {
-		secr.Data["description"] = []byte(desc)
-		dirty = true
+	ctx := context.Background()
+	b, err := getForWrite(ctx, cli, ns, bundleName)
+	if err != nil {
+		return err
 	}
-	if tags != "" && tags != string(secr.Data["tags"]) {
-		secr.Data["tags"] = []byte(tags)
-		dirty = true
+	spec := b.Spec.DeepCopy()
+	if desc != "" {
+		spec.Description = desc
 	}
-	if fromFile != "" && repoUrl != "" {
-		return fmt.Errorf("file and repo cannot both be specified")
+	if tags != "" && tags != strings.Join(spec.Tags, ",") {
+		spec.Tags = splitTags(tags)
 	}
 	if fromFile != "" {
-		if secr.Labels["bundle-type"] != "static" {
+		if spec.Data == "" {
 			return fmt.Errorf("manifest content can only be changed if bundle is static")
 		}
 		data, err := os.ReadFile(fromFile)
 		if err != nil {
 			return fmt.Errorf("failed to read file: %s", err)
 		}
-		secr.Data["data"] = data
-		dirty = true
+		spec.Data = string(data)
+		spec.Encryption = ""
+		if IsSopsEncrypted(data) {
+			spec.Encryption = EncryptionSops
+		}
 	} else if repoUrl != "" || repoPath != "" {
-		if secr.Labels["bundle-type"] == "dynamic" {
+		if spec.Data == "" {
 			return fmt.Errorf("cannot update git reference of a dynamic bundle")
 		}
 		return fmt.Errorf("cannot specify repo URL or path for an existing static bundle")
 	}
-	if !dirty {
+	if chartVersion != "" {
+		if spec.Chart == "" {
+			return fmt.Errorf("chart version can only be changed on a chart bundle")
+		}
+		spec.RepoRevision = chartVersion
+	}
+	if settings != nil {
+		var current arlonv1.DeploySettings
+		if spec.DeploySettings != nil {
+			current = *spec.DeploySettings
+		}
+		merged := MergeDeploySettings(current, *settings)
+		spec.DeploySettings = &merged
+		if reflect.DeepEqual(merged, arlonv1.DeploySettings{}) {
+			spec.DeploySettings = nil
+		}
+	}
+	if errs := ValidateSpec(spec, field.NewPath("spec")); len(errs) > 0 {
+		return fmt.Errorf("invalid bundle: %s", errs.ToAggregate())
+	}
+	if reflect.DeepEqual(*spec, b.Spec) {
 		return nil
 	}
-	_, err = secretsApi.Update(context.Background(), secr, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to update secret: %s", err)
+	b.Spec = *spec
+	setChangeCause(b, "updated")
+	if err := cli.Update(ctx, b); err != nil {
+		return fmt.Errorf("failed to update bundle: %s", err)
+	}
+	if _, err := RecordRevision(ctx, cli, b); err != nil {
+		return err
 	}
 	return nil
 }
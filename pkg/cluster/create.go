@@ -8,6 +8,7 @@ import (
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	grpccodes "google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
@@ -16,6 +17,8 @@ import (
 	restclient "k8s.io/client-go/rest"
 )
 
+// Create creates an arlon cluster. In pull request write mode, the URL of the
+// pull request opened for the files of a gen1 cluster is also returned.
 func Create(
 	appIf argoapp.ApplicationServiceClient,
 	config *restclient.Config,
@@ -31,24 +34,24 @@ func Create(
 	createInArgoCd bool,
 	managementClusterUrl string,
 	withCAS bool,
-) (*argoappv1.Application, error) {
+) (rootApp *argoappv1.Application, prUrl string, err error) {
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get kube client: %s", err)
+		return nil, "", fmt.Errorf("failed to get kube client: %s", err)
 	}
 	// FIXME: the following check is wrong for a gen2 cluster, whose name
 	//        is actually ${clusterName}-arlon
 	_, err = appIf.Get(context.Background(),
 		&argoapp.ApplicationQuery{Name: &clusterName})
 	if err == nil {
-		return nil, fmt.Errorf("arlon cluster already exists")
+		return nil, "", fmt.Errorf("arlon cluster already exists")
 	}
 	grpcStatus, ok := grpcstatus.FromError(err)
 	if !ok {
-		return nil, fmt.Errorf("failed to get grpc status from error")
+		return nil, "", fmt.Errorf("failed to get grpc status from error")
 	}
 	if grpcStatus.Code() != grpccodes.NotFound {
-		return nil, fmt.Errorf("unexpected cluster application error code: %d",
+		return nil, "", fmt.Errorf("unexpected cluster application error code: %d",
 			grpcStatus.Code())
 	}
 	var cm *v1.ConfigMap
@@ -57,7 +60,7 @@ func Create(
 		configMapsApi := corev1.ConfigMaps(arlonNs)
 		cm, err = configMapsApi.Get(context.Background(), clusterSpecName, metav1.GetOptions{})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get clusterspec configmap: %s", err)
+			return nil, "", fmt.Errorf("failed to get clusterspec configmap: %s", err)
 		}
 	}
 	repoPath := basePath // default for gen2
@@ -68,26 +71,29 @@ func Create(
 	if prof != nil {
 		profileName = prof.Name
 	}
-	rootApp, err := ConstructRootApp(argocdNs, clusterName, baseClusterName, repoUrl, repoBranch,
+	rootApp, err = ConstructRootApp(argocdNs, clusterName, baseClusterName, repoUrl, repoBranch,
 		repoPath, clusterSpecName, cm, profileName, managementClusterUrl, withCAS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to construct root app: %s", err)
+		return nil, "", fmt.Errorf("failed to construct root app: %s", err)
 	}
 	if clusterSpecName != "" {
 		// gen1 only: deploy cluster files to git
 		creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, repoUrl)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get repo credentials: %s", err)
+			return nil, "", fmt.Errorf("failed to get repo credentials: %s", err)
 		}
-		corev1 := kubeClient.CoreV1()
-		bundles, err := bundle.GetBundlesFromProfile(prof, corev1, arlonNs)
+		cli, err := ctrlruntimeclient.NewClient(config)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get controller runtime client: %s", err)
+		}
+		bundles, err := bundle.GetBundlesFromProfile(prof, cli, arlonNs)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get bundles from profile: %s", err)
+			return nil, "", fmt.Errorf("failed to get bundles from profile: %s", err)
 		}
-		err = DeployToGit(creds, argocdNs, bundles, clusterName,
+		prUrl, err = DeployToGit(creds, argocdNs, bundles, clusterName,
 			repoUrl, repoBranch, basePath, prof)
 		if err != nil {
-			return nil, fmt.Errorf("failed to deploy git tree: %s", err)
+			return nil, "", fmt.Errorf("failed to deploy git tree: %s", err)
 		}
 	}
 	if createInArgoCd {
@@ -96,10 +102,10 @@ func Create(
 		}
 		_, err := appIf.Create(context.Background(), &appCreateRequest)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create ArgoCD root application: %s", err)
+			return nil, "", fmt.Errorf("failed to create ArgoCD root application: %s", err)
 		}
 	}
-	return rootApp, nil
+	return rootApp, prUrl, nil
 }
 
 func CreatePatchDir(
@@ -111,20 +117,23 @@ func CreatePatchDir(
 	patchRepoRevision string,
 	baseRepoRevision string,
 	patchContent []byte,
+	patches []arlonv1.OverridePatch,
+	topologyPatch []byte,
 	baseRepoUrl string,
-	baseRepoPath string) error {
+	baseRepoPath string) (string, error) {
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to get kube client: %s", err)
+		return "", fmt.Errorf("failed to get kube client: %s", err)
 	}
 	creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, repoURL)
 	if err != nil {
-		return fmt.Errorf("failed to get repo credentials: %s", err)
+		return "", fmt.Errorf("failed to get repo credentials: %s", err)
 	}
-	err = DeployPatchToGit(creds, clusterName,
-		repoURL, patchRepoRevision, baseRepoRevision, basePath, patchContent, baseRepoUrl, baseRepoPath)
+	prUrl, err := DeployPatchToGit(creds, clusterName,
+		repoURL, patchRepoRevision, baseRepoRevision, basePath, patchContent, patches, topologyPatch,
+		baseRepoUrl, baseRepoPath)
 	if err != nil {
-		return fmt.Errorf("failed to deploy git tree: %s", err)
+		return "", fmt.Errorf("failed to deploy git tree: %s", err)
 	}
-	return nil
+	return prUrl, nil
 }
@@ -17,29 +17,32 @@ import (
 
 //------------------------------------------------------------------------------
 
+// Delete deletes an arlon cluster. In pull request write mode, the URL of
+// the pull request opened to delete the override directory of the cluster is
+// returned.
 func Delete(
 	// appIf argoapp.ApplicationServiceClient,
 	argoIf argoclient.Client,
 	config *restclient.Config,
 	argocdNs string,
 	name string,
-) error {
+) (prUrl string, err error) {
 	//log := logpkg.GetLogger()
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to get kube client: %s", err)
+		return "", fmt.Errorf("failed to get kube client: %s", err)
 	}
 	conn, appIf, err := argoIf.NewApplicationClient()
 	if err != nil {
-		return fmt.Errorf("failed to get argocd application client: %s", err)
+		return "", fmt.Errorf("failed to get argocd application client: %s", err)
 	}
 	defer conn.Close()
 	clust, err := Get(appIf, config, argocdNs, name)
 	if err != nil {
-		return fmt.Errorf("failed to get existing cluster: %s", err)
+		return "", fmt.Errorf("failed to get existing cluster: %s", err)
 	}
 	if clust.IsExternal {
-		return UnmanageExternal(argoIf, config, argocdNs, name)
+		return "", UnmanageExternal(argoIf, config, argocdNs, name)
 	}
 	if clust.BaseCluster == nil {
 		cascade := true
@@ -49,23 +52,23 @@ func Delete(
 				Name:    &name,
 				Cascade: &cascade,
 			})
-		return err
+		return "", err
 	}
 
 	clusterQuery := "arlon-cluster=" + name
 	apps, err := appIf.List(context.Background(),
 		&argoapp.ApplicationQuery{Selector: &clusterQuery})
 	if err != nil {
-		return fmt.Errorf("failed to list apps related to cluster: %s", err)
+		return "", fmt.Errorf("failed to list apps related to cluster: %s", err)
 	}
 
 	for _, app := range apps.Items {
 		if app.Labels["arlon-type"] == "cluster-app" {
 			overridden := app.Annotations[baseClusterOverridden]
 			if overridden == "true" {
-				err = DeleteOverridesDir(&app, kubeClient, argocdNs, name)
+				prUrl, err = DeleteOverridesDir(&app, kubeClient, argocdNs, name)
 				if err != nil {
-					return fmt.Errorf("failed to delete the overrides directory: %s", err)
+					return "", fmt.Errorf("failed to delete the overrides directory: %s", err)
 				}
 			}
 		}
@@ -77,60 +80,47 @@ func Delete(
 				Cascade: &cascade,
 			})
 		if err != nil {
-			return fmt.Errorf("failed to delete related app %s: %s",
+			return "", fmt.Errorf("failed to delete related app %s: %s",
 				app.Name, err)
 		}
 		fmt.Println("deleted related app:", app.Name)
 	}
-	return nil
+	return prUrl, nil
 }
 
-func DeleteOverridesDir(app *v1alpha1.Application, kubeClient *kubernetes.Clientset, argocdNs string, clusterName string) error {
+// DeleteOverridesDir deletes the override directory of a cluster from git.
+// In pull request write mode, the URL of the pull request is returned.
+func DeleteOverridesDir(app *v1alpha1.Application, kubeClient *kubernetes.Clientset, argocdNs string, clusterName string) (prUrl string, err error) {
 	log := logpkg.GetLogger()
 	repoUrl := app.Annotations[baseClusterRepoUrlAnnotation]
 	repoRevision := app.Annotations[baseClusterRepoRevisionAnnotation]
 	repoPath := app.Annotations[baseClusterRepoPathAnnotation] + "/" + clusterName
 	creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, repoUrl)
 	if err != nil {
-		return fmt.Errorf("failed to get repo credentials: %s", err)
+		return "", fmt.Errorf("failed to get repo credentials: %s", err)
 	}
-	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, repoRevision)
-	if err != nil {
-		return fmt.Errorf("failed to clone repo: %s", err)
-	}
-	wt, err := repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get working tree, %w", err)
-	}
-	fileInfo, err := wt.Filesystem.Lstat(repoPath)
-	if err != nil {
-		return fmt.Errorf("Failed to find the directory to delete: %s", err)
-	}
-	if !fileInfo.IsDir() {
-		return fmt.Errorf("unexpected file type for %s", repoPath)
-	}
-	_, err = wt.Remove(repoPath)
-	if err != nil {
-		return fmt.Errorf("failed to recursively delete cluster directory: %s", err)
-	}
-	commitMsg := "Deleted the files regarding to " + repoPath
-	changed, err := gitutils.CommitDeleteChanges(tmpDir, wt, commitMsg)
+	changed, prUrl, err := gitutils.WriteToGit(creds, repoUrl, repoRevision,
+		func(wt *gogit.Worktree, _ string) (string, error) {
+			fileInfo, err := wt.Filesystem.Lstat(repoPath)
+			if err != nil {
+				return "", fmt.Errorf("Failed to find the directory to delete: %s", err)
+			}
+			if !fileInfo.IsDir() {
+				return "", fmt.Errorf("unexpected file type for %s", repoPath)
+			}
+			_, err = wt.Remove(repoPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to recursively delete cluster directory: %s", err)
+			}
+			return "Deleted the files regarding to " + repoPath, nil
+		})
 	if err != nil {
-		return fmt.Errorf("failed to commit changes: %s", err)
+		return "", err
 	}
 	if !changed {
 		log.Info("no changed files, skipping commit & push")
-		return nil
-	}
-	err = repo.Push(&gogit.PushOptions{
-		RemoteName: gogit.DefaultRemoteName,
-		Auth:       auth,
-		Progress:   nil,
-		CABundle:   nil,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to push to remote repository: %s", err)
+		return "", nil
 	}
-	log.V(1).Info("successfully pushed working tree", "tmpDir", tmpDir)
-	return nil
+	log.V(1).Info("successfully pushed working tree", "repoPath", repoPath, "pullRequest", prUrl)
+	return prUrl, nil
 }
@@ -22,6 +22,8 @@ var content embed.FS
 
 // -----------------------------------------------------------------------------
 
+// DeployToGit writes the files of a gen1 cluster to git. In pull request
+// write mode, the URL of the pull request is returned.
 func DeployToGit(
 	creds *argocd.RepoCreds,
 	argocdNs string,
@@ -31,71 +33,99 @@ func DeployToGit(
 	repoBranch string,
 	basePath string,
 	prof *arlonv1.Profile,
-) error {
+) (prUrl string, err error) {
 	log := logpkg.GetLogger()
-	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, repoBranch)
+	clusterPath := clusterPathFromBasePath(basePath, clusterName)
+	changed, prUrl, err := gitutils.WriteToGit(creds, repoUrl, repoBranch,
+		deployChange(argocdNs, bundles, clusterName, repoUrl, basePath, prof))
 	if err != nil {
-		return fmt.Errorf("failed to clone repo: %s", err)
+		return "", err
+	}
+	if !changed {
+		log.Info("no changed files, skipping commit & push")
+		return "", nil
+	}
+	if prUrl != "" {
+		log.Info("opened pull request", "prUrl", prUrl)
 	}
+	log.V(1).Info("successfully pushed working tree", "clusterPath", clusterPath)
+	return prUrl, nil
+}
+
+// PlanDeployToGit returns the diff of the files that DeployToGit would
+// change, without changing them.
+func PlanDeployToGit(
+	creds *argocd.RepoCreds,
+	argocdNs string,
+	bundles []bundle.Bundle,
+	clusterName string,
+	repoUrl string,
+	repoBranch string,
+	basePath string,
+	prof *arlonv1.Profile,
+) (string, error) {
+	return gitutils.PlanToGit(creds, repoUrl, repoBranch,
+		deployChange(argocdNs, bundles, clusterName, repoUrl, basePath, prof))
+}
+
+// deployChange regenerates the directory of a cluster in git.
+func deployChange(
+	argocdNs string,
+	bundles []bundle.Bundle,
+	clusterName string,
+	repoUrl string,
+	basePath string,
+	prof *arlonv1.Profile,
+) gitutils.ChangeFunc {
 	clusterPath := clusterPathFromBasePath(basePath, clusterName)
 	mgmtPath := mgmtPathFromClusterPath(clusterPath)
 	workloadPath := workloadPathFromClusterPath(clusterPath)
-	wt, err := repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get repo worktree: %s", err)
-	}
-	// remove old data if directory exists, we'll regenerate everything
-	fileInfo, err := wt.Filesystem.Lstat(clusterPath)
-	if err == nil {
-		if !fileInfo.IsDir() {
-			return fmt.Errorf("unexpected file type for %s", clusterPath)
-		}
-		_, err = wt.Remove(clusterPath)
+	return func(wt *gogit.Worktree, _ string) (string, error) {
+		// remove old data if directory exists, we'll regenerate everything
+		err := removeDir(wt, clusterPath)
 		if err != nil {
-			return fmt.Errorf("failed to recursively delete cluster directory: %s", err)
+			return "", err
 		}
-	}
-	err = gitutils.CopyManifests(wt, content, ".", mgmtPath)
-	if err != nil {
-		return fmt.Errorf("failed to copy embedded content: %s", err)
-	}
-	profRepoUrl := prof.Spec.RepoUrl
-	if profRepoUrl != "" {
-		// dynamic profile: bundles not included in root app.
-		// create an Application for the profile.
-		profRepoPath := prof.Spec.RepoPath
-		appPath := path.Join(mgmtPath, "templates", "profile.yaml")
-		err = ProcessDynamicProfile(wt, clusterName, prof.Name, argocdNs,
-			profRepoUrl, profRepoPath, appPath)
+		err = gitutils.CopyManifests(wt, content, ".", mgmtPath)
 		if err != nil {
-			return fmt.Errorf("failed to process dynamic profile: %s", err)
+			return "", fmt.Errorf("failed to copy embedded content: %s", err)
 		}
-	} else {
-		// static profile: include bundles as individual Applications now
-		om := profile.MakeOverridesMap(prof)
-		err = gitutils.ProcessBundles(wt, clusterName, repoUrl, mgmtPath, workloadPath, bundles, om)
-		if err != nil {
-			return fmt.Errorf("failed to process bundles: %s", err)
+		profRepoUrl := prof.Spec.RepoUrl
+		if profRepoUrl != "" {
+			// dynamic profile: bundles not included in root app.
+			// create an Application for the profile.
+			profRepoPath := prof.Spec.RepoPath
+			appPath := path.Join(mgmtPath, "templates", "profile.yaml")
+			err = ProcessDynamicProfile(wt, clusterName, prof.Name, argocdNs,
+				profRepoUrl, profRepoPath, appPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to process dynamic profile: %s", err)
+			}
+		} else {
+			// static profile: include bundles as individual Applications now
+			om := profile.MakeOverridesMap(prof)
+			err = gitutils.ProcessBundles(wt, clusterName, repoUrl, mgmtPath, workloadPath, bundles, om)
+			if err != nil {
+				return "", fmt.Errorf("failed to process bundles: %s", err)
+			}
 		}
+		return "deploy arlon cluster " + clusterPath, nil
 	}
-	changed, err := gitutils.CommitChanges(tmpDir, wt, "deploy arlon cluster "+clusterPath)
+}
+
+// removeDir removes a directory from the worktree if it exists.
+func removeDir(wt *gogit.Worktree, dirPath string) error {
+	fileInfo, err := wt.Filesystem.Lstat(dirPath)
 	if err != nil {
-		return fmt.Errorf("failed to commit changes: %s", err)
-	}
-	if !changed {
-		log.Info("no changed files, skipping commit & push")
 		return nil
 	}
-	err = repo.Push(&gogit.PushOptions{
-		RemoteName: gogit.DefaultRemoteName,
-		Auth:       auth,
-		Progress:   nil,
-		CABundle:   nil,
-	})
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("unexpected file type for %s", dirPath)
+	}
+	_, err = wt.Remove(dirPath)
 	if err != nil {
-		return fmt.Errorf("failed to push to remote repository: %s", err)
+		return fmt.Errorf("failed to recursively delete directory %s: %s", dirPath, err)
 	}
-	log.V(1).Info("successfully pushed working tree", "tmpDir", tmpDir)
 	return nil
 }
 
@@ -172,6 +202,8 @@ func ProcessDynamicProfile(
 
 // -----------------------------------------------------------------------------
 
+// DeployPatchToGit generates the override directory of a cluster in git.
+// In pull request write mode, the URL of the pull request is returned.
 func DeployPatchToGit(
 	creds *argocd.RepoCreds,
 	clusterName string,
@@ -180,59 +212,45 @@ func DeployPatchToGit(
 	baseRepoRevision string,
 	basePath string,
 	patchContent []byte,
+	patches []arlonv1.OverridePatch,
+	topologyPatch []byte,
 	baseRepoUrl string,
 	baseRepoPath string,
-) error {
+) (prUrl string, err error) {
 	log := logpkg.GetLogger()
-	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, patchRepoRevision)
-	if err != nil {
-		return fmt.Errorf("failed to clone repo: %s", err)
-	}
 	clusterPath := clusterPathFromBasePath(basePath, clusterName)
-	wt, err := repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get repo worktree: %s", err)
-	}
-	// remove old data if directory exists, we'll regenerate everything
-	fileInfo, err := wt.Filesystem.Lstat(clusterPath)
-	if err == nil {
-		if !fileInfo.IsDir() {
-			return fmt.Errorf("unexpected file type for %s", clusterPath)
-		}
-		_, err = wt.Remove(clusterPath)
-		if err != nil {
-			return fmt.Errorf("failed to recursively delete cluster directory: %s", err)
-		}
-	}
-	err = gitutils.CopyPatchManifests(wt, patchContent, clusterPath, baseRepoUrl, baseRepoPath, baseRepoRevision)
-	if err != nil {
-		return fmt.Errorf("failed to copy embedded content: %s", err)
-	}
-	var file billy.File
-	fs := wt.Filesystem
-	file, err = fs.Create(path.Join(clusterPath, "configurations.yaml"))
-	if err != nil {
-		return fmt.Errorf("failed to create configurations.yaml: %s", err)
-	}
-	_, err = file.Write([]byte(bcl.ConfigurationsYaml))
-	_ = file.Close()
+	changed, prUrl, err := gitutils.WriteToGit(creds, repoUrl, patchRepoRevision,
+		func(wt *gogit.Worktree, _ string) (string, error) {
+			// remove old data if directory exists, we'll regenerate everything
+			err := removeDir(wt, clusterPath)
+			if err != nil {
+				return "", err
+			}
+			err = gitutils.CopyPatchManifests(wt, patchContent, patches, topologyPatch, clusterPath,
+				baseRepoUrl, baseRepoPath, baseRepoRevision)
+			if err != nil {
+				return "", fmt.Errorf("failed to copy embedded content: %s", err)
+			}
+			var file billy.File
+			fs := wt.Filesystem
+			file, err = fs.Create(path.Join(clusterPath, "configurations.yaml"))
+			if err != nil {
+				return "", fmt.Errorf("failed to create configurations.yaml: %s", err)
+			}
+			_, err = file.Write([]byte(bcl.ConfigurationsYaml))
+			_ = file.Close()
+			if err != nil {
+				return "", fmt.Errorf("failed to write to configurations.yaml: %s", err)
+			}
+			return "deploy patches of the arlon cluster in " + clusterPath, nil
+		})
 	if err != nil {
-		return fmt.Errorf("failed to write to configurations.yaml: %s", err)
+		return "", err
 	}
-
-	_, err = gitutils.CommitChanges(tmpDir, wt, "deploy patches of the arlon cluster in "+clusterPath)
-	if err != nil {
-		return fmt.Errorf("failed to commit changes: %s", err)
-	}
-	err = repo.Push(&gogit.PushOptions{
-		RemoteName: gogit.DefaultRemoteName,
-		Auth:       auth,
-		Progress:   nil,
-		CABundle:   nil,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to push to remote repository: %s", err)
+	if !changed {
+		log.Info("no changed files, skipping commit & push")
+		return "", nil
 	}
-	log.V(1).Info("successfully pushed working tree", "tmpDir", tmpDir)
-	return nil
+	log.V(1).Info("successfully pushed working tree", "clusterPath", clusterPath, "pullRequest", prUrl)
+	return prUrl, nil
 }
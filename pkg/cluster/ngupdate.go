@@ -6,10 +6,14 @@ import (
 
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/arlonproj/arlon/pkg/profile"
 	restclient "k8s.io/client-go/rest"
 )
 
+// NgUpdate replaces the profile app of a next-gen cluster with the one of
+// another profile. If pl is not nil, no profile app is deleted or created:
+// the changes are recorded in pl instead.
 func NgUpdate(
 	appIf argoapp.ApplicationServiceClient,
 	config *restclient.Config,
@@ -18,6 +22,7 @@ func NgUpdate(
 	clusterName,
 	profileName string,
 	updateInArgoCd bool,
+	pl *plan.Plan,
 ) (*argoappv1.Application, error) {
 
 	prof, err := profile.Get(config, profileName, arlonNs)
@@ -27,11 +32,19 @@ func NgUpdate(
 	if prof.Spec.RepoUrl == "" {
 		return nil, errors.New("RepoUrl empty, static profiles are unsupported")
 	}
+	profileAppName := fmt.Sprintf("%s-profile-%s", clusterName, prof.Name)
+	if pl != nil {
+		profileApp := constructProfileApp(profileAppName, argocdNs, clusterName, prof)
+		err = planProfileApps(appIf, clusterName, profileApp, pl)
+		if err != nil {
+			return nil, err
+		}
+		return profileApp, nil
+	}
 	err = DestroyProfileApps(appIf, clusterName)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to delete profile app: %s", err)
 	}
-	profileAppName := fmt.Sprintf("%s-profile-%s", clusterName, prof.Name)
 	profileApp, err := CreateProfileApp(profileAppName,
 		appIf, argocdNs, clusterName, prof, updateInArgoCd)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/plan"
 )
 
 // CreateProfileApp creates a profile-app that accompanies an arlon-app for gen2 clusters
@@ -55,3 +56,37 @@ func DestroyProfileApps(
 	}
 	return err
 }
+
+// planProfileApps records in pl the replacement of the profile apps of a
+// cluster by a new one. An existing app with the name of the new one is
+// recorded as updated rather than deleted and created again.
+func planProfileApps(
+	appIf argoapp.ApplicationServiceClient,
+	clusterName string,
+	profileApp *argoappv1.Application,
+	pl *plan.Plan,
+) error {
+	selector := "arlon-cluster=" + clusterName + ",arlon-type=profile-app"
+	apps, err := appIf.List(context.Background(),
+		&argoapp.ApplicationQuery{Selector: &selector})
+	if err != nil {
+		return fmt.Errorf("failed to list profile apps: %s", err)
+	}
+	replaced := false
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if app.Name == profileApp.Name {
+			replaced = true
+			err = pl.AddApplicationDiff(app, profileApp)
+		} else {
+			err = pl.AddApplicationDiff(app, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if replaced {
+		return nil
+	}
+	return pl.AddApplicationDiff(nil, profileApp)
+}
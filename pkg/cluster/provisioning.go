@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	restclient "k8s.io/client-go/rest"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//------------------------------------------------------------------------------
+
+// CAPIClusterName returns the name of the CAPI Cluster resource deployed by
+// the cluster app of a gen2 cluster. The cluster app prefixes the names of all
+// resources of the cluster template with the arlon cluster name.
+func CAPIClusterName(clusterName string, innerClusterName string) string {
+	return fmt.Sprintf("%s-%s", clusterName, innerClusterName)
+}
+
+//------------------------------------------------------------------------------
+
+// GetProvisioningStatus summarizes the state of the CAPI Cluster resource and
+// MachineDeployments of a gen2 cluster. The resources are deployed by the
+// cluster app in a namespace with the same name as the arlon cluster.
+// If the CAPI Cluster does not exist yet, an empty status is returned.
+func GetProvisioningStatus(
+	ctx context.Context,
+	cli client.Client,
+	clusterName string,
+	innerClusterName string,
+) (status arlonv1.ProvisioningStatus, err error) {
+	capiClusterName := CAPIClusterName(clusterName, innerClusterName)
+	var capiCluster capi.Cluster
+	err = cli.Get(ctx, types.NamespacedName{
+		Namespace: clusterName,
+		Name:      capiClusterName,
+	}, &capiCluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return status, nil
+		}
+		return status, fmt.Errorf("failed to get CAPI cluster %s: %s",
+			capiClusterName, err)
+	}
+	status.Phase = capiCluster.Status.Phase
+	status.InfrastructureReady = capiCluster.Status.InfrastructureReady
+	status.ControlPlaneReady = capiCluster.Status.ControlPlaneReady
+	var mds capi.MachineDeploymentList
+	err = cli.List(ctx, &mds, client.InNamespace(clusterName),
+		client.MatchingLabels{capi.ClusterLabelName: capiClusterName})
+	if err != nil {
+		return status, fmt.Errorf("failed to list machine deployments of %s: %s",
+			capiClusterName, err)
+	}
+	for _, md := range mds.Items {
+		if md.Spec.Replicas != nil {
+			status.Workers.Desired += *md.Spec.Replicas
+		}
+		status.Workers.Ready += md.Status.ReadyReplicas
+		status.Workers.Available += md.Status.AvailableReplicas
+		status.Workers.Updated += md.Status.UpdatedReplicas
+	}
+	return status, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ApplicationHealthFromApp returns the sync and health status of an Argo CD
+// application. A nil application results in an empty status.
+func ApplicationHealthFromApp(app *argoappv1.Application) arlonv1.ApplicationHealth {
+	if app == nil {
+		return arlonv1.ApplicationHealth{}
+	}
+	return arlonv1.ApplicationHealth{
+		Sync:         string(app.Status.Sync.Status),
+		Health:       string(app.Status.Health.Status),
+		ReconciledAt: app.Status.ReconciledAt,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// GetClusterResourceStatus returns the status of the declarative Cluster
+// resource with the specified name, or nil if the cluster was not created
+// from a Cluster resource.
+func GetClusterResourceStatus(
+	config *restclient.Config,
+	arlonNs string,
+	name string,
+) (*arlonv1.ClusterStatus, error) {
+	cli, err := ctrlruntimeclient.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get controller runtime client: %s", err)
+	}
+	var cl arlonv1.Cluster
+	err = cli.Get(context.Background(), types.NamespacedName{
+		Namespace: arlonNs,
+		Name:      name,
+	}, &cl)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get cluster resource: %s", err)
+	}
+	return &cl.Status, nil
+}
@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func machineDeployment(name string, capiClusterName string, desired int32, ready int32) *capi.MachineDeployment {
+	return &capi.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "c1",
+			Labels:    map[string]string{capi.ClusterLabelName: capiClusterName},
+		},
+		Spec: capi.MachineDeploymentSpec{Replicas: int32Ptr(desired)},
+		Status: capi.MachineDeploymentStatus{
+			ReadyReplicas:     ready,
+			AvailableReplicas: ready,
+			UpdatedReplicas:   desired,
+		},
+	}
+}
+
+func TestGetProvisioningStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, capi.AddToScheme(scheme))
+	ctx := context.Background()
+
+	// CAPI cluster not created yet
+	cli := fake.NewClientBuilder().WithScheme(scheme).Build()
+	status, err := GetProvisioningStatus(ctx, cli, "c1", "capi-quickstart")
+	assert.NoError(t, err)
+	assert.Equal(t, "", status.Phase)
+
+	capiCluster := &capi.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-capi-quickstart", Namespace: "c1"},
+		Status: capi.ClusterStatus{
+			Phase:               string(capi.ClusterPhaseProvisioned),
+			InfrastructureReady: true,
+			ControlPlaneReady:   true,
+		},
+	}
+	cli = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		capiCluster,
+		machineDeployment("c1-md-0", "c1-capi-quickstart", 2, 2),
+		machineDeployment("c1-md-1", "c1-capi-quickstart", 3, 1),
+		machineDeployment("other-md", "other", 5, 5),
+	).Build()
+	status, err = GetProvisioningStatus(ctx, cli, "c1", "capi-quickstart")
+	assert.NoError(t, err)
+	assert.Equal(t, string(capi.ClusterPhaseProvisioned), status.Phase)
+	assert.True(t, status.InfrastructureReady)
+	assert.True(t, status.ControlPlaneReady)
+	assert.Equal(t, int32(5), status.Workers.Desired)
+	assert.Equal(t, int32(3), status.Workers.Ready)
+	assert.Equal(t, int32(3), status.Workers.Available)
+	assert.Equal(t, int32(5), status.Workers.Updated)
+}
@@ -0,0 +1,336 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultRolloutProgressDeadline is the time the Clusters of a wave have to
+// become healthy when the rollout does not specify a progress deadline
+const DefaultRolloutProgressDeadline = 30 * time.Minute
+
+// RolloutPollDelay is how often the Clusters of a wave are checked while
+// they are being upgraded
+var RolloutPollDelay = 30 * time.Second
+
+//------------------------------------------------------------------------------
+
+// AdvanceRollout moves a ClusterRollout forward and updates its status.
+// Clusters are selected when the rollout is first processed or when its
+// target revision changes. The Clusters of the current wave are then moved to
+// the target revision, and the next wave starts once they are all healthy.
+// If a Cluster of the wave fails, or the wave is not healthy before the
+// progress deadline, spec.paused is set on the rollout, which the caller must
+// persist along with the status. It returns the delay after which the rollout
+// must be advanced again, or zero if it is paused or completed.
+func AdvanceRollout(
+	ctx context.Context,
+	cli client.Client,
+	ro *arlonv1.ClusterRollout,
+	now time.Time,
+) (requeueAfter time.Duration, err error) {
+	st := &ro.Status
+	if st.Phase == "" || st.TargetRevision != ro.Spec.TargetRevision {
+		if err := PlanRollout(ctx, cli, ro); err != nil {
+			return 0, err
+		}
+	}
+	if st.Phase == arlonv1.RolloutPhaseCompleted {
+		return 0, nil
+	}
+	if ro.Spec.Paused {
+		if st.Phase != arlonv1.RolloutPhasePaused {
+			st.Phase = arlonv1.RolloutPhasePaused
+			st.Message = fmt.Sprintf("paused before completing wave %d of %d",
+				st.CurrentWave, st.Waves)
+		}
+		// the wave gets a new deadline when resumed
+		st.WaveStartTime = nil
+		return 0, nil
+	}
+	for ; st.CurrentWave <= st.Waves; st.CurrentWave++ {
+		if st.WaveStartTime == nil {
+			st.WaveStartTime = &metav1.Time{Time: now}
+		}
+		done, failed, err := advanceWave(ctx, cli, ro)
+		if err != nil {
+			return 0, err
+		}
+		if len(failed) > 0 {
+			ro.Spec.Paused = true
+			st.Phase = arlonv1.RolloutPhasePaused
+			st.Message = fmt.Sprintf("wave %d failed: %s", st.CurrentWave,
+				strings.Join(failed, "; "))
+			st.WaveStartTime = nil
+			return 0, nil
+		}
+		if !done {
+			deadline := DefaultRolloutProgressDeadline
+			if ro.Spec.ProgressDeadlineSeconds != nil {
+				deadline = time.Duration(*ro.Spec.ProgressDeadlineSeconds) * time.Second
+			}
+			if now.Sub(st.WaveStartTime.Time) > deadline {
+				failed = failUpgrading(ro, fmt.Sprintf("not healthy after %s", deadline))
+				ro.Spec.Paused = true
+				st.Phase = arlonv1.RolloutPhasePaused
+				st.Message = fmt.Sprintf("wave %d failed: %s", st.CurrentWave,
+					strings.Join(failed, "; "))
+				st.WaveStartTime = nil
+				return 0, nil
+			}
+			st.Phase = arlonv1.RolloutPhaseProgressing
+			st.Message = fmt.Sprintf("upgrading wave %d of %d to revision %s",
+				st.CurrentWave, st.Waves, ro.Spec.TargetRevision)
+			return RolloutPollDelay, nil
+		}
+		st.WaveStartTime = nil
+	}
+	st.CurrentWave = st.Waves
+	st.Phase = arlonv1.RolloutPhaseCompleted
+	st.Message = fmt.Sprintf("all clusters are at revision %s", ro.Spec.TargetRevision)
+	return 0, nil
+}
+
+//------------------------------------------------------------------------------
+
+// PlanRollout selects the Clusters of a rollout and assigns them to waves in
+// the order of their names. Clusters already at the target revision are
+// skipped.
+func PlanRollout(ctx context.Context, cli client.Client, ro *arlonv1.ClusterRollout) error {
+	selector, err := metav1.LabelSelectorAsSelector(&ro.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %s", err)
+	}
+	var clusters arlonv1.ClusterList
+	err = cli.List(ctx, &clusters, client.InNamespace(ro.Namespace),
+		client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %s", err)
+	}
+	sort.Slice(clusters.Items, func(i, j int) bool {
+		return clusters.Items[i].Name < clusters.Items[j].Name
+	})
+	waveSize := ro.Spec.WaveSize
+	if waveSize < 1 {
+		waveSize = 1
+	}
+	st := &ro.Status
+	st.TargetRevision = ro.Spec.TargetRevision
+	st.Clusters = nil
+	st.WaveStartTime = nil
+	var upgraded int32
+	for _, cl := range clusters.Items {
+		rcs := arlonv1.RolloutClusterStatus{
+			Name:             cl.Name,
+			PreviousRevision: cl.Spec.ClusterTemplate.Revision,
+			State:            arlonv1.RolloutClusterPending,
+		}
+		if cl.Spec.ClusterTemplate.Revision == ro.Spec.TargetRevision {
+			rcs.State = arlonv1.RolloutClusterSkipped
+			rcs.Message = "already at the target revision"
+		} else {
+			rcs.Wave = upgraded/waveSize + 1
+			upgraded++
+		}
+		st.Clusters = append(st.Clusters, rcs)
+	}
+	st.Waves = (upgraded + waveSize - 1) / waveSize
+	st.CurrentWave = 1
+	st.Phase = arlonv1.RolloutPhaseProgressing
+	st.Message = fmt.Sprintf("selected %d clusters to upgrade in %d waves", upgraded, st.Waves)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// advanceWave moves the Clusters of the current wave to the target revision
+// and checks their health. It returns true if all of them are upgraded, and
+// the descriptions of the failed ones.
+func advanceWave(
+	ctx context.Context,
+	cli client.Client,
+	ro *arlonv1.ClusterRollout,
+) (done bool, failed []string, err error) {
+	st := &ro.Status
+	done = true
+	for i := range st.Clusters {
+		rcs := &st.Clusters[i]
+		if rcs.Wave != st.CurrentWave || rcs.State == arlonv1.RolloutClusterUpgraded ||
+			rcs.State == arlonv1.RolloutClusterSkipped {
+			continue
+		}
+		var cl arlonv1.Cluster
+		err = cli.Get(ctx, types.NamespacedName{Namespace: ro.Namespace, Name: rcs.Name}, &cl)
+		if apierrors.IsNotFound(err) {
+			rcs.State = arlonv1.RolloutClusterSkipped
+			rcs.Message = "cluster was deleted"
+			continue
+		}
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to get cluster %s: %s", rcs.Name, err)
+		}
+		if rcs.State == arlonv1.RolloutClusterPending {
+			cl.Spec.ClusterTemplate.Revision = ro.Spec.TargetRevision
+			if err = cli.Update(ctx, &cl); err != nil {
+				return false, nil, fmt.Errorf("failed to update revision of cluster %s: %s",
+					rcs.Name, err)
+			}
+			rcs.Generation = cl.Generation
+			rcs.State = arlonv1.RolloutClusterUpgrading
+			rcs.Message = ""
+			done = false
+			continue
+		}
+		// Upgrading, or Failed in a wave that is being retried
+		rcs.State, rcs.Message = ClusterUpgradeState(&cl, rcs, ro.Spec.TargetRevision,
+			st.WaveStartTime.Time)
+		switch rcs.State {
+		case arlonv1.RolloutClusterFailed:
+			failed = append(failed, fmt.Sprintf("%s: %s", rcs.Name, rcs.Message))
+		case arlonv1.RolloutClusterUpgrading:
+			done = false
+		}
+	}
+	return done, failed, nil
+}
+
+// failUpgrading marks the Clusters of the current wave that are still
+// upgrading as failed.
+func failUpgrading(ro *arlonv1.ClusterRollout, msg string) (failed []string) {
+	for i := range ro.Status.Clusters {
+		rcs := &ro.Status.Clusters[i]
+		if rcs.Wave == ro.Status.CurrentWave && rcs.State == arlonv1.RolloutClusterUpgrading {
+			rcs.State = arlonv1.RolloutClusterFailed
+			rcs.Message = msg
+			failed = append(failed, fmt.Sprintf("%s: %s", rcs.Name, msg))
+		}
+	}
+	return
+}
+
+//------------------------------------------------------------------------------
+
+// ClusterUpgradeState returns the rollout state of a Cluster whose revision
+// was changed to the target revision at the generation recorded in rcs.
+// The Cluster is upgraded once the cluster controller has processed the new
+// revision, the Cluster is ready, and Argo CD has reported the cluster app as
+// synced and healthy since the wave started. It is failed if the new template
+// cannot be validated, if provisioning failed or if the cluster app is
+// degraded.
+func ClusterUpgradeState(
+	cl *arlonv1.Cluster,
+	rcs *arlonv1.RolloutClusterStatus,
+	targetRevision string,
+	waveStartTime time.Time,
+) (state string, msg string) {
+	if cl.Spec.ClusterTemplate.Revision != targetRevision {
+		return arlonv1.RolloutClusterFailed, fmt.Sprintf(
+			"revision was changed to %s outside of the rollout",
+			cl.Spec.ClusterTemplate.Revision)
+	}
+	if cl.Status.ObservedGeneration < rcs.Generation {
+		return arlonv1.RolloutClusterUpgrading, "waiting for the cluster controller"
+	}
+	cond := meta.FindStatusCondition(cl.Status.Conditions,
+		arlonv1.ClusterTemplateValidatedCondition)
+	if cond != nil && cond.Status == metav1.ConditionFalse && cond.ObservedGeneration >= rcs.Generation {
+		return arlonv1.RolloutClusterFailed, cond.Message
+	}
+	cond = meta.FindStatusCondition(cl.Status.Conditions, arlonv1.ClusterProvisionedCondition)
+	if cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == "ProvisioningFailed" {
+		return arlonv1.RolloutClusterFailed, cond.Message
+	}
+	prov := &cl.Status.Provisioning
+	if prov.ClusterApp.Health == "Degraded" {
+		return arlonv1.RolloutClusterFailed, "cluster app is degraded"
+	}
+	vt := cl.Status.ValidatedTemplate
+	if vt == nil || vt.Revision != targetRevision {
+		return arlonv1.RolloutClusterUpgrading, "waiting for the new cluster template to be validated"
+	}
+	if !meta.IsStatusConditionTrue(cl.Status.Conditions, arlonv1.ClusterReadyCondition) {
+		return arlonv1.RolloutClusterUpgrading, "waiting for the cluster to be ready"
+	}
+	if prov.ClusterApp.ReconciledAt == nil || prov.ClusterApp.ReconciledAt.Time.Before(waveStartTime) ||
+		prov.ClusterApp.Sync != "Synced" || prov.ClusterApp.Health != "Healthy" {
+		return arlonv1.RolloutClusterUpgrading, "waiting for the cluster app to be synced and healthy"
+	}
+	if prov.Workers.Updated < prov.Workers.Desired {
+		return arlonv1.RolloutClusterUpgrading, fmt.Sprintf("%d/%d workers updated",
+			prov.Workers.Updated, prov.Workers.Desired)
+	}
+	return arlonv1.RolloutClusterUpgraded, ""
+}
+
+//------------------------------------------------------------------------------
+
+// GetRollout returns the ClusterRollout with the specified name.
+func GetRollout(ctx context.Context, cli client.Client, ns string, name string) (*arlonv1.ClusterRollout, error) {
+	var ro arlonv1.ClusterRollout
+	err := cli.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, &ro)
+	if err != nil {
+		return nil, err
+	}
+	return &ro, nil
+}
+
+// CreateRollout creates a ClusterRollout moving the Clusters matching the
+// label selector to the target revision.
+func CreateRollout(
+	ctx context.Context,
+	cli client.Client,
+	ns string,
+	name string,
+	selector string,
+	targetRevision string,
+	waveSize int32,
+	progressDeadline time.Duration,
+) (*arlonv1.ClusterRollout, error) {
+	labelSelector, err := metav1.ParseToLabelSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %s", err)
+	}
+	ro := &arlonv1.ClusterRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec: arlonv1.ClusterRolloutSpec{
+			Selector:       *labelSelector,
+			TargetRevision: targetRevision,
+			WaveSize:       waveSize,
+		},
+	}
+	if progressDeadline > 0 {
+		seconds := int32(progressDeadline.Seconds())
+		ro.Spec.ProgressDeadlineSeconds = &seconds
+	}
+	if err := cli.Create(ctx, ro); err != nil {
+		return nil, fmt.Errorf("failed to create cluster rollout: %s", err)
+	}
+	return ro, nil
+}
+
+// SetRolloutPaused pauses or resumes a ClusterRollout.
+func SetRolloutPaused(ctx context.Context, cli client.Client, ns string, name string, paused bool) error {
+	ro, err := GetRollout(ctx, cli, ns, name)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster rollout: %s", err)
+	}
+	if ro.Spec.Paused == paused {
+		return nil
+	}
+	patch := client.MergeFrom(ro.DeepCopy())
+	ro.Spec.Paused = paused
+	if err := cli.Patch(ctx, ro, patch); err != nil {
+		return fmt.Errorf("failed to update cluster rollout: %s", err)
+	}
+	return nil
+}
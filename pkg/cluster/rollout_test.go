@@ -0,0 +1,167 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func arlonCluster(name string, env string, revision string) *arlonv1.Cluster {
+	return &arlonv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "arlon",
+			Labels:    map[string]string{"env": env},
+		},
+		Spec: arlonv1.ClusterSpec{
+			ClusterTemplate: arlonv1.RepoSpec{
+				Url:      "https://github.com/org/repo",
+				Path:     "templates/capi",
+				Revision: revision,
+			},
+		},
+	}
+}
+
+// setClusterState simulates the cluster controller processing the current
+// revision of a cluster, and Argo CD reconciling the cluster app at a time.
+func setClusterState(t *testing.T, cli client.Client, name string, reconciledAt time.Time,
+	provisioned metav1.ConditionStatus, reason string) {
+	var cl arlonv1.Cluster
+	assert.NoError(t, cli.Get(context.Background(), types.NamespacedName{Namespace: "arlon", Name: name}, &cl))
+	cl.Status.ObservedGeneration = cl.Generation
+	cl.Status.ValidatedTemplate = cl.Spec.ClusterTemplate.DeepCopy()
+	meta.SetStatusCondition(&cl.Status.Conditions, metav1.Condition{
+		Type: arlonv1.ClusterProvisionedCondition, Status: provisioned, Reason: reason,
+	})
+	meta.SetStatusCondition(&cl.Status.Conditions, metav1.Condition{
+		Type: arlonv1.ClusterReadyCondition, Status: provisioned, Reason: reason,
+	})
+	cl.Status.Provisioning.ClusterApp = arlonv1.ApplicationHealth{
+		Sync:         "Synced",
+		Health:       "Healthy",
+		ReconciledAt: &metav1.Time{Time: reconciledAt},
+	}
+	assert.NoError(t, cli.Update(context.Background(), &cl))
+}
+
+func rolloutClusterStates(ro *arlonv1.ClusterRollout) map[string]string {
+	states := map[string]string{}
+	for _, rcs := range ro.Status.Clusters {
+		states[rcs.Name] = rcs.State
+	}
+	return states
+}
+
+func TestAdvanceRollout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, arlonv1.AddToScheme(scheme))
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		arlonCluster("c1", "prod", "v1"),
+		arlonCluster("c2", "prod", "v1"),
+		arlonCluster("c3", "prod", "v1"),
+		arlonCluster("c4", "prod", "v2"),
+		arlonCluster("c5", "dev", "v1"),
+	).Build()
+	ro := &arlonv1.ClusterRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "arlon"},
+		Spec: arlonv1.ClusterRolloutSpec{
+			Selector:       metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			TargetRevision: "v2",
+			WaveSize:       2,
+		},
+	}
+	t0 := time.Now()
+
+	// the first wave is moved to the target revision
+	requeueAfter, err := AdvanceRollout(ctx, cli, ro, t0)
+	assert.NoError(t, err)
+	assert.Equal(t, RolloutPollDelay, requeueAfter)
+	assert.Equal(t, arlonv1.RolloutPhaseProgressing, ro.Status.Phase)
+	assert.Equal(t, int32(2), ro.Status.Waves)
+	assert.Equal(t, int32(1), ro.Status.CurrentWave)
+	assert.Equal(t, map[string]string{
+		"c1": arlonv1.RolloutClusterUpgrading,
+		"c2": arlonv1.RolloutClusterUpgrading,
+		"c3": arlonv1.RolloutClusterPending,
+		"c4": arlonv1.RolloutClusterSkipped,
+	}, rolloutClusterStates(ro))
+	var cl arlonv1.Cluster
+	assert.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "arlon", Name: "c1"}, &cl))
+	assert.Equal(t, "v2", cl.Spec.ClusterTemplate.Revision)
+	assert.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "arlon", Name: "c3"}, &cl))
+	assert.Equal(t, "v1", cl.Spec.ClusterTemplate.Revision)
+
+	// a cluster app reconciled before the wave started is not trusted
+	setClusterState(t, cli, "c1", t0.Add(-time.Minute), metav1.ConditionTrue, "Provisioned")
+	setClusterState(t, cli, "c2", t0.Add(time.Minute), metav1.ConditionTrue, "Provisioned")
+	_, err = AdvanceRollout(ctx, cli, ro, t0.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), ro.Status.CurrentWave)
+	assert.Equal(t, arlonv1.RolloutClusterUpgraded, rolloutClusterStates(ro)["c2"])
+
+	// the second wave starts once the first one is healthy
+	setClusterState(t, cli, "c1", t0.Add(3*time.Minute), metav1.ConditionTrue, "Provisioned")
+	t1 := t0.Add(4 * time.Minute)
+	_, err = AdvanceRollout(ctx, cli, ro, t1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), ro.Status.CurrentWave)
+	assert.Equal(t, arlonv1.RolloutClusterUpgrading, rolloutClusterStates(ro)["c3"])
+
+	// a failure pauses the rollout
+	setClusterState(t, cli, "c3", t1.Add(time.Minute), metav1.ConditionFalse, "ProvisioningFailed")
+	requeueAfter, err = AdvanceRollout(ctx, cli, ro, t1.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Zero(t, requeueAfter)
+	assert.True(t, ro.Spec.Paused)
+	assert.Equal(t, arlonv1.RolloutPhasePaused, ro.Status.Phase)
+	assert.Equal(t, arlonv1.RolloutClusterFailed, rolloutClusterStates(ro)["c3"])
+
+	// resuming retries the failed wave
+	ro.Spec.Paused = false
+	t2 := t1.Add(10 * time.Minute)
+	setClusterState(t, cli, "c3", t2.Add(time.Minute), metav1.ConditionTrue, "Provisioned")
+	_, err = AdvanceRollout(ctx, cli, ro, t2)
+	assert.NoError(t, err)
+	assert.Equal(t, arlonv1.RolloutPhaseCompleted, ro.Status.Phase)
+	assert.Equal(t, arlonv1.RolloutClusterUpgraded, rolloutClusterStates(ro)["c3"])
+}
+
+func TestAdvanceRolloutDeadline(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, arlonv1.AddToScheme(scheme))
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		arlonCluster("c1", "prod", "v1"),
+	).Build()
+	deadline := int32(600)
+	ro := &arlonv1.ClusterRollout{
+		ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "arlon"},
+		Spec: arlonv1.ClusterRolloutSpec{
+			Selector:                metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			TargetRevision:          "v2",
+			ProgressDeadlineSeconds: &deadline,
+		},
+	}
+	t0 := time.Now()
+	_, err := AdvanceRollout(ctx, cli, ro, t0)
+	assert.NoError(t, err)
+	_, err = AdvanceRollout(ctx, cli, ro, t0.Add(5*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, ro.Spec.Paused)
+	_, err = AdvanceRollout(ctx, cli, ro, t0.Add(11*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, ro.Spec.Paused)
+	assert.Equal(t, arlonv1.RolloutClusterFailed, rolloutClusterStates(ro)["c1"])
+	assert.Contains(t, ro.Status.Message, "not healthy after 10m0s")
+}
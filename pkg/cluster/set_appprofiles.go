@@ -5,14 +5,18 @@ import (
 	"fmt"
 	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	arlonapp "github.com/arlonproj/arlon/pkg/app"
+	"github.com/arlonproj/arlon/pkg/plan"
 )
 
 //------------------------------------------------------------------------------
 
+// SetAppProfiles sets the app profiles of a cluster. If pl is not nil, the
+// cluster is not changed: the change is recorded in pl instead.
 func SetAppProfiles(
 	appIf argoapp.ApplicationServiceClient,
 	name string,
 	commaSeparatedAppProfiles string,
+	pl *plan.Plan,
 ) error {
 	app, err := appIf.Get(context.Background(),
 		&argoapp.ApplicationQuery{
@@ -24,6 +28,14 @@ func SetAppProfiles(
 	if app.Labels["arlon-type"] != "cluster-app" {
 		return fmt.Errorf("application resource is not an Arlon cluster")
 	}
+	if pl != nil {
+		before := app.DeepCopy()
+		if app.Annotations == nil {
+			app.Annotations = make(map[string]string)
+		}
+		app.Annotations[arlonapp.ProfilesAnnotationKey] = commaSeparatedAppProfiles
+		return pl.AddApplicationDiff(before, app)
+	}
 	app.Annotations[arlonapp.ProfilesAnnotationKey] = commaSeparatedAppProfiles
 	_, err = appIf.Update(context.Background(), &argoapp.ApplicationUpdateRequest{
 		Application: app,
@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	argoapp "github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+)
+
+// ClusterAppAnnotationKeys lists the annotations of a cluster app that are
+// derived from its source, and must be kept in sync with it.
+var ClusterAppAnnotationKeys = []string{
+	baseClusterNameAnnotation,
+	baseClusterRepoUrlAnnotation,
+	baseClusterRepoRevisionAnnotation,
+	baseClusterRepoPathAnnotation,
+	baseClusterOverridden,
+}
+
+//------------------------------------------------------------------------------
+
+// AppNeedsSync returns true if the source, ignored differences, or any of the
+// specified annotations of an existing application differ from the desired
+// application.
+func AppNeedsSync(
+	existing *argoappv1.Application,
+	desired *argoappv1.Application,
+	annotationKeys []string,
+) bool {
+	if !apiequality.Semantic.DeepEqual(existing.Spec.Source, desired.Spec.Source) {
+		return true
+	}
+	if !apiequality.Semantic.DeepEqual(existing.Spec.IgnoreDifferences,
+		desired.Spec.IgnoreDifferences) {
+		return true
+	}
+	for _, key := range annotationKeys {
+		if existing.Annotations[key] != desired.Annotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+// SyncApp updates an existing application in Argo CD so that its source,
+// ignored differences and the specified annotations match the desired
+// application. Other fields, such as the remaining annotations, are preserved.
+// It returns true if an update was necessary.
+func SyncApp(
+	appIf argoapp.ApplicationServiceClient,
+	existing *argoappv1.Application,
+	desired *argoappv1.Application,
+	annotationKeys []string,
+) (bool, error) {
+	if !AppNeedsSync(existing, desired, annotationKeys) {
+		return false, nil
+	}
+	updated := existing.DeepCopy()
+	updated.Spec.Source = desired.Spec.Source
+	updated.Spec.IgnoreDifferences = desired.Spec.IgnoreDifferences
+	if len(annotationKeys) > 0 && updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	for _, key := range annotationKeys {
+		updated.Annotations[key] = desired.Annotations[key]
+	}
+	_, err := appIf.Update(context.Background(), &argoapp.ApplicationUpdateRequest{
+		Application: updated,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to update application %s: %s",
+			existing.Name, err)
+	}
+	return true, nil
+}
+
+//------------------------------------------------------------------------------
+
+// OverrideMoved returns true if the old cluster app used an override directory
+// that the new cluster app no longer uses.
+func OverrideMoved(oldApp *argoappv1.Application, newApp *argoappv1.Application) bool {
+	if oldApp.Annotations[baseClusterOverridden] != "true" {
+		return false
+	}
+	return oldApp.Spec.Source.RepoURL != newApp.Spec.Source.RepoURL ||
+		oldApp.Spec.Source.Path != newApp.Spec.Source.Path ||
+		oldApp.Spec.Source.TargetRevision != newApp.Spec.Source.TargetRevision
+}
+
+//------------------------------------------------------------------------------
+
+// ConstructClusterApp returns the desired cluster-app of a gen2 cluster
+// without creating it in Argo CD.
+func ConstructClusterApp(
+	argocdNs string,
+	clusterName string,
+	baseClusterName string,
+	repoUrl string, // source repo
+	repoRevision string, // source revision
+	repoPath string, // source path
+	overridden bool,
+) *argoappv1.Application {
+	return constructClusterApp(argocdNs, clusterName, baseClusterName,
+		repoUrl, repoRevision, repoPath, overridden)
+}
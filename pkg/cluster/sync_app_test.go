@@ -0,0 +1,35 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterAppNeedsSync(t *testing.T) {
+	existing := ConstructClusterApp("argocd", "c1", "capi-quickstart",
+		"https://github.com/org/repo.git", "main", "templates/t1", false)
+	same := ConstructClusterApp("argocd", "c1", "capi-quickstart",
+		"https://github.com/org/repo.git", "main", "templates/t1", false)
+	assert.False(t, AppNeedsSync(existing, same, ClusterAppAnnotationKeys))
+
+	// Moving the template to a new revision requires an update
+	newRevision := ConstructClusterApp("argocd", "c1", "capi-quickstart",
+		"https://github.com/org/repo.git", "v2", "templates/t1", false)
+	assert.True(t, AppNeedsSync(existing, newRevision, ClusterAppAnnotationKeys))
+	assert.False(t, OverrideMoved(existing, newRevision))
+
+	// Annotations that are not listed are ignored
+	existing.Annotations["arlon.io/profiles"] = "p1"
+	assert.False(t, AppNeedsSync(existing, same, ClusterAppAnnotationKeys))
+
+	// Switching to an override changes the source path and annotations
+	overridden := ConstructClusterApp("argocd", "c1", "capi-quickstart",
+		"https://github.com/org/overrides.git", "main", "patches", true)
+	assert.True(t, AppNeedsSync(existing, overridden, ClusterAppAnnotationKeys))
+	assert.Equal(t, "patches/c1", overridden.Spec.Source.Path)
+
+	// Removing the override moves the cluster app away from the override directory
+	assert.True(t, OverrideMoved(overridden, existing))
+	assert.False(t, OverrideMoved(overridden, overridden))
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/arlonproj/arlon/pkg/bundle"
 	"github.com/arlonproj/arlon/pkg/clusterspec"
 	"github.com/arlonproj/arlon/pkg/common"
+	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/arlonproj/arlon/pkg/plan"
 	"github.com/arlonproj/arlon/pkg/profile"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -22,6 +24,9 @@ import (
 // There are no restrictions on the new profile, if one is specified.
 // Bundles associated with the old profile will automatically be removed from
 // the cluster.
+// If pl is not nil, neither git nor Argo CD are changed: the changes are
+// recorded in pl instead. In pull request write mode, the URL of the pull
+// request is also returned.
 func Update(
 	appIf argoapp.ApplicationServiceClient,
 	config *restclient.Config,
@@ -32,42 +37,43 @@ func Update(
 	profileName string,
 	updateInArgoCd bool,
 	managementClusterUrl string,
-) (*argoappv1.Application, error) {
+	pl *plan.Plan,
+) (rootApp *argoappv1.Application, prUrl string, err error) {
 	oldApp, err := appIf.Get(context.Background(),
 		&argoapp.ApplicationQuery{Name: &clusterName})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get argocd app: %s", err)
+		return nil, "", fmt.Errorf("failed to get argocd app: %s", err)
 	}
 	if clusterSpecName == "" {
 		clusterSpecName = oldApp.Annotations[common.ClusterSpecAnnotationKey]
 		if clusterSpecName == "" {
-			return nil, fmt.Errorf("existing cluster root app is missing clusterspec annotation")
+			return nil, "", fmt.Errorf("existing cluster root app is missing clusterspec annotation")
 		}
 	}
 	if profileName == "" {
 		profileName = oldApp.Annotations[common.ProfileAnnotationKey]
 		if profileName == "" {
-			return nil, fmt.Errorf("existing cluster root app is missing profile annotation")
+			return nil, "", fmt.Errorf("existing cluster root app is missing profile annotation")
 		}
 	}
 	prof, err := profile.Get(config, profileName, arlonNs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get profile: %s", err)
+		return nil, "", fmt.Errorf("failed to get profile: %s", err)
 	}
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get kube client: %s", err)
+		return nil, "", fmt.Errorf("failed to get kube client: %s", err)
 	}
 	corev1 := kubeClient.CoreV1()
 	configMapsApi := corev1.ConfigMaps(arlonNs)
 	clusterSpecCm, err := configMapsApi.Get(context.Background(), clusterSpecName, metav1.GetOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clusterspec configmap: %s", err)
+		return nil, "", fmt.Errorf("failed to get clusterspec configmap: %s", err)
 	}
 	// Ensure subchart name (api, cloud, clustertype) hasn't changed
 	subchartName, err := clusterspec.SubchartName(clusterSpecCm)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	helmParamName := fmt.Sprintf("tags.%s", subchartName)
 	found := false
@@ -78,41 +84,57 @@ func Update(
 		}
 	}
 	if !found {
-		return nil, fmt.Errorf("the api provider, cloud provider, or cluster type cannot change")
+		return nil, "", fmt.Errorf("the api provider, cloud provider, or cluster type cannot change")
 	}
 	repoUrl := oldApp.Spec.Source.RepoURL
 	repoBranch := oldApp.Spec.Source.TargetRevision
 	repoPath := oldApp.Spec.Source.Path
 	basePath, clstName, err := decomposePath(repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decompose repo path: %s", err)
+		return nil, "", fmt.Errorf("failed to decompose repo path: %s", err)
 	}
 	if clstName != clusterName {
-		return nil, fmt.Errorf("unexpected cluster name extracted from repo path: %s",
+		return nil, "", fmt.Errorf("unexpected cluster name extracted from repo path: %s",
 			clstName)
 	}
-	rootApp, err := ConstructRootApp(argocdNs, clusterName, "", repoUrl,
+	rootApp, err = ConstructRootApp(argocdNs, clusterName, "", repoUrl,
 		repoBranch, repoPath, clusterSpecName, clusterSpecCm, prof.Name,
 		managementClusterUrl, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to construct root app: %s", err)
+		return nil, "", fmt.Errorf("failed to construct root app: %s", err)
 	}
 	if oldApp.Spec.Source.RepoURL != rootApp.Spec.Source.RepoURL ||
 		oldApp.Spec.Source.Path != rootApp.Spec.Source.Path {
-		return nil, fmt.Errorf("git repo reference cannot change")
+		return nil, "", fmt.Errorf("git repo reference cannot change")
 	}
-	bundles, err := bundle.GetBundlesFromProfile(prof, corev1, arlonNs)
+	cli, err := ctrlruntimeclient.NewClient(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get bundles: %s", err)
+		return nil, "", fmt.Errorf("failed to get controller runtime client: %s", err)
+	}
+	bundles, err := bundle.GetBundlesFromProfile(prof, cli, arlonNs)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get bundles: %s", err)
 	}
 	creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, repoUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository credentials: %s", err)
+		return nil, "", fmt.Errorf("failed to get repository credentials: %s", err)
+	}
+	if pl != nil {
+		diff, err := PlanDeployToGit(creds, argocdNs, bundles, clusterName,
+			repoUrl, repoBranch, basePath, prof)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to plan git tree: %s", err)
+		}
+		pl.AddGitDiff(repoUrl, repoBranch, diff)
+		if err := pl.AddApplicationDiff(oldApp, rootApp); err != nil {
+			return nil, "", err
+		}
+		return rootApp, "", nil
 	}
-	err = DeployToGit(creds, argocdNs, bundles, clusterName,
+	prUrl, err = DeployToGit(creds, argocdNs, bundles, clusterName,
 		repoUrl, repoBranch, basePath, prof)
 	if err != nil {
-		return nil, fmt.Errorf("failed to deploy git tree: %s", err)
+		return nil, "", fmt.Errorf("failed to deploy git tree: %s", err)
 	}
 	if updateInArgoCd {
 		appUpdateRequest := argoapp.ApplicationUpdateRequest{
@@ -120,8 +142,8 @@ func Update(
 		}
 		_, err := appIf.Update(context.Background(), &appUpdateRequest)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update ArgoCD root application: %s", err)
+			return nil, "", fmt.Errorf("failed to update ArgoCD root application: %s", err)
 		}
 	}
-	return rootApp, nil
+	return rootApp, prUrl, nil
 }
@@ -8,4 +8,14 @@ const (
 	ProfileAnnotationKey      = "arlon.io/profile"
 	ProfileAppAnnotationKey   = "arlon.io/profile-app"
 	ClusterSpecAnnotationKey  = "arlon.io/clusterspec"
+	// Deployment settings of a bundle, as JSON
+	DeploySettingsAnnotationKey = "arlon.io/deploy-settings"
+	// Name of the Helm chart of a bundle stored in a Helm repository or an
+	// OCI registry, in which case the repo revision is the chart version
+	ChartAnnotationKey = "arlon.io/chart"
+	// Version number and content hash of the current revision of a bundle
+	BundleVersionAnnotationKey = "arlon.io/bundle-version"
+	ContentHashAnnotationKey   = "arlon.io/content-hash"
+	// Reason of the change recorded by a bundle revision
+	ChangeCauseAnnotationKey = "arlon.io/change-cause"
 )
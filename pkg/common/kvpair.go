@@ -4,5 +4,3 @@ type KVPair struct {
 	Key   string
 	Value string
 }
-
-type KVPairMap map[string][]KVPair
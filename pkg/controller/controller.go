@@ -13,6 +13,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -28,6 +29,7 @@ func init() {
 	utilruntime.Must(arlonv1.AddToScheme(scheme))
 	utilruntime.Must(argoapp.AddToScheme(scheme))
 	utilruntime.Must(argoapp.AddToScheme(scheme))
+	utilruntime.Must(capi.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -76,6 +78,15 @@ func StartController(argocdConfigPath string, metricsAddr string, probeAddr stri
 		setupLog.Error(err, "unable to create controller", "controller", "CallHomeConfig")
 		os.Exit(1)
 	}
+	// the controller deployed by the standard install records the revisions
+	// of the bundles changed without the arlon CLI
+	if err = (&controllers.BundleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Bundle")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -240,6 +251,13 @@ func StartClusterController(
 		setupLog.Error(err, "unable to set up controller", "controller", "AppProfile")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterRolloutReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to set up controller", "controller", "ClusterRollout")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
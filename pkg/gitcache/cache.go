@@ -0,0 +1,488 @@
+package gitcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logpkg "github.com/arlonproj/arlon/pkg/log"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// The cache keeps one bare repository per repository URL under Dir, and
+// fetches the requested branch into it on every use. Each checkout is a new
+// repository in a temporary worktree directory whose object database borrows
+// the objects of the cached repository (git "alternates"), so only the files
+// of the branch are written, and commits made in the worktree can be pushed
+// as usual. The cache is shared by all the goroutines of the process.
+
+var (
+	// Dir is the root directory of the cache
+	Dir = filepath.Join(os.TempDir(), "arlon-git-cache")
+	// MaxSize is the size in bytes above which the least recently used
+	// repositories are evicted from the cache
+	MaxSize int64 = 1 << 30
+	// DefaultDepth is the fetch depth used when the options of a checkout
+	// do not specify one. Zero fetches the full history.
+	DefaultDepth = 0
+	// Disabled makes Checkout clone the repository directly into the
+	// worktree directory, without caching it
+	Disabled = false
+	// EvictionGracePeriod is the time during which a repository is never
+	// evicted after its last use, so that the worktrees borrowing its
+	// objects remain valid
+	EvictionGracePeriod = 10 * time.Minute
+	// WorktreeMaxAge is the age after which a worktree left behind by its
+	// caller is removed
+	WorktreeMaxAge = time.Hour
+)
+
+const (
+	reposDirName     = "repos"
+	worktreesDirName = "worktrees"
+	evictionInterval = time.Minute
+)
+
+// Options are the options of a checkout.
+type Options struct {
+	// Depth limits the fetched history to the specified number of commits
+	Depth int
+	// SparseDirs restricts the checked out files to these directories.
+	// A sparse worktree must only be read: its index is empty.
+	SparseDirs []string
+}
+
+var (
+	repoLocks   sync.Map
+	evictMu     sync.Mutex
+	lastEvicted time.Time
+)
+
+func lockKey(key string) *sync.Mutex {
+	mu, _ := repoLocks.LoadOrStore(key, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// cacheKey returns the name of the cache directory of a repository URL.
+func cacheKey(repoUrl string) string {
+	normalized := strings.TrimSuffix(strings.ToLower(repoUrl), ".git")
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(normalized)))[:24]
+}
+
+// -----------------------------------------------------------------------------
+
+// Checkout checks out a branch of a repository into a new temporary directory,
+// after fetching the branch into the cache. The caller owns the directory and
+// must remove it once done with the repository. The remote "origin" of the
+// returned repository is the repository URL.
+func Checkout(
+	ctx context.Context,
+	repoUrl string,
+	branch string,
+	auth transport.AuthMethod,
+	opts *Options,
+) (repo *gogit.Repository, tmpDir string, err error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	depth := opts.Depth
+	if depth == 0 {
+		depth = DefaultDepth
+	}
+	if Disabled {
+		return clone(ctx, repoUrl, branch, auth, depth, opts.SparseDirs)
+	}
+	worktreesDir := filepath.Join(Dir, worktreesDirName)
+	if err := os.MkdirAll(worktreesDir, 0700); err != nil {
+		log := logpkg.GetLogger()
+		log.Info("git cache unavailable, cloning repository",
+			"dir", Dir, "error", err.Error())
+		return clone(ctx, repoUrl, branch, auth, depth, opts.SparseDirs)
+	}
+	key := cacheKey(repoUrl)
+	mu := lockKey(key)
+	mu.Lock()
+	repoDir, hash, shallows, err := fetch(ctx, key, repoUrl, branch, auth, depth)
+	if err != nil {
+		mu.Unlock()
+		return nil, "", err
+	}
+	tmpDir, err = os.MkdirTemp(worktreesDir, "arlon-")
+	if err != nil {
+		mu.Unlock()
+		return nil, "", err
+	}
+	repo, err = newWorktree(tmpDir, repoDir, repoUrl, branch, hash, shallows, opts.SparseDirs)
+	mu.Unlock()
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, "", err
+	}
+	evict()
+	return repo, tmpDir, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// clone clones the branch directly into a temporary directory.
+func clone(
+	ctx context.Context,
+	repoUrl string,
+	branch string,
+	auth transport.AuthMethod,
+	depth int,
+	sparseDirs []string,
+) (repo *gogit.Repository, tmpDir string, err error) {
+	tmpDir, err = os.MkdirTemp("", "arlon-")
+	if err != nil {
+		return nil, "", err
+	}
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	repo, err = gogit.PlainCloneContext(ctx, tmpDir, false, &gogit.CloneOptions{
+		URL:           repoUrl,
+		Auth:          auth,
+		RemoteName:    gogit.DefaultRemoteName,
+		ReferenceName: branchRef,
+		SingleBranch:  true,
+		NoCheckout:    len(sparseDirs) > 0,
+		Depth:         depth,
+		Tags:          gogit.NoTags,
+	})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, "", fmt.Errorf("failed to clone repository: %s", err)
+	}
+	if len(sparseDirs) > 0 {
+		var head *plumbing.Reference
+		head, err = repo.Head()
+		if err == nil {
+			err = checkoutSparse(repo, tmpDir, head.Hash(), sparseDirs)
+		}
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, "", err
+		}
+	}
+	return repo, tmpDir, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// fetch fetches the branch into the cached repository, creating the
+// repository if needed. A corrupted cached repository is recreated once.
+// It returns the directory of the repository, the hash of the branch and the
+// shallow commits of the repository.
+func fetch(
+	ctx context.Context,
+	key string,
+	repoUrl string,
+	branch string,
+	auth transport.AuthMethod,
+	depth int,
+) (repoDir string, hash plumbing.Hash, shallows []plumbing.Hash, err error) {
+	repoDir = filepath.Join(Dir, reposDirName, key)
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, branchRef))
+	for attempt := 1; ; attempt++ {
+		var repo *gogit.Repository
+		var ref *plumbing.Reference
+		var corrupted bool
+		repo, corrupted, err = openOrInit(repoDir, repoUrl)
+		if err == nil {
+			err = repo.FetchContext(ctx, &gogit.FetchOptions{
+				RemoteName: gogit.DefaultRemoteName,
+				RefSpecs:   []config.RefSpec{refSpec},
+				Depth:      depth,
+				Auth:       auth,
+				Tags:       gogit.NoTags,
+				Force:      true,
+			})
+			if err == gogit.NoErrAlreadyUpToDate {
+				err = nil
+			}
+			corrupted = isCorrupted(err)
+		}
+		if err == nil {
+			ref, err = repo.Reference(branchRef, true)
+			if err == nil {
+				shallows, err = repo.Storer.Shallow()
+			}
+			corrupted = err != nil
+		}
+		if err == nil {
+			now := time.Now()
+			_ = os.Chtimes(repoDir, now, now)
+			return repoDir, ref.Hash(), shallows, nil
+		}
+		if !corrupted || attempt > 1 {
+			return "", plumbing.ZeroHash, nil,
+				fmt.Errorf("failed to fetch repository: %s", err)
+		}
+		log := logpkg.GetLogger()
+		log.Info("recreating corrupted cached git repository",
+			"repoUrl", repoUrl, "dir", repoDir, "error", err.Error())
+		if err := os.RemoveAll(repoDir); err != nil {
+			return "", plumbing.ZeroHash, nil,
+				fmt.Errorf("failed to remove cached repository: %s", err)
+		}
+	}
+}
+
+// isCorrupted returns true if a fetch error is caused by the local
+// repository rather than by the remote. A cached repository is never removed
+// because of remote errors, as worktrees may be borrowing its objects.
+func isCorrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gogit.ErrRemoteNotFound) || errors.Is(err, plumbing.ErrObjectNotFound) ||
+		errors.Is(err, plumbing.ErrInvalidType) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "packfile") || strings.Contains(msg, "malformed") ||
+		strings.Contains(msg, "zlib")
+}
+
+// openOrInit opens the cached repository, or creates it if it does not exist.
+// It returns whether an existing directory could not be opened.
+func openOrInit(repoDir string, repoUrl string) (repo *gogit.Repository, corrupted bool, err error) {
+	repo, err = gogit.PlainOpen(repoDir)
+	if err == nil {
+		return repo, false, nil
+	}
+	if _, statErr := os.Stat(repoDir); statErr == nil {
+		// unusable leftover
+		return nil, true, err
+	}
+	repo, err = gogit.PlainInit(repoDir, true)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cached repository: %s", err)
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: gogit.DefaultRemoteName,
+		URLs: []string{repoUrl},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create remote: %s", err)
+	}
+	return repo, false, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// newWorktree creates a repository in tmpDir borrowing the objects of the
+// cached repository, with the branch tracking origin and checked out.
+func newWorktree(
+	tmpDir string,
+	repoDir string,
+	repoUrl string,
+	branch string,
+	hash plumbing.Hash,
+	shallows []plumbing.Hash,
+	sparseDirs []string,
+) (*gogit.Repository, error) {
+	repo, err := gogit.PlainInit(tmpDir, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository: %s", err)
+	}
+	infoDir := filepath.Join(tmpDir, gogit.GitDirName, "objects", "info")
+	if err := os.MkdirAll(infoDir, 0700); err != nil {
+		return nil, err
+	}
+	objectsDir, err := filepath.Abs(filepath.Join(repoDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(infoDir, "alternates"), []byte(objectsDir+"\n"), 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write alternates: %s", err)
+	}
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	remoteRef := plumbing.NewRemoteReferenceName(gogit.DefaultRemoteName, branch)
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name:  gogit.DefaultRemoteName,
+		URLs:  []string{repoUrl},
+		Fetch: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, remoteRef))},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote: %s", err)
+	}
+	err = repo.CreateBranch(&config.Branch{
+		Name:   branch,
+		Remote: gogit.DefaultRemoteName,
+		Merge:  branchRef,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create branch: %s", err)
+	}
+	for _, ref := range []*plumbing.Reference{
+		plumbing.NewHashReference(remoteRef, hash),
+		plumbing.NewHashReference(branchRef, hash),
+		plumbing.NewSymbolicReference(plumbing.HEAD, branchRef),
+	} {
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return nil, fmt.Errorf("failed to set reference: %s", err)
+		}
+	}
+	if len(shallows) > 0 {
+		if err := repo.Storer.SetShallow(shallows); err != nil {
+			return nil, fmt.Errorf("failed to set shallow commits: %s", err)
+		}
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo worktree: %s", err)
+	}
+	if len(sparseDirs) > 0 {
+		return repo, checkoutSparse(repo, tmpDir, hash, sparseDirs)
+	}
+	err = wt.Checkout(&gogit.CheckoutOptions{
+		Branch: branchRef,
+		Force:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkout branch: %s", err)
+	}
+	return repo, nil
+}
+
+// checkoutSparse writes the files of the directories of a commit into the
+// worktree, leaving the index empty. The sparse checkout of go-git does not
+// support checking out into an empty worktree.
+func checkoutSparse(repo *gogit.Repository, rootDir string, hash plumbing.Hash, dirs []string) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return fmt.Errorf("failed to get commit: %s", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to get commit tree: %s", err)
+	}
+	for _, dir := range dirs {
+		subtree, err := tree.Tree(dir)
+		if err == object.ErrDirectoryNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get tree of %s: %s", dir, err)
+		}
+		err = subtree.Files().ForEach(func(f *object.File) error {
+			if f.Mode == filemode.Submodule {
+				return nil
+			}
+			contents, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			filePath := filepath.Join(rootDir, dir, f.Name)
+			if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+				return err
+			}
+			mode, err := f.Mode.ToOSFileMode()
+			if err != nil || !mode.IsRegular() {
+				mode = 0600
+			}
+			return os.WriteFile(filePath, []byte(contents), mode)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to checkout %s: %s", dir, err)
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// evict removes the worktrees older than WorktreeMaxAge, then the least
+// recently used repositories until the size of the cache is below MaxSize.
+// It runs at most once per minute.
+func evict() {
+	evictMu.Lock()
+	defer evictMu.Unlock()
+	if time.Since(lastEvicted) < evictionInterval {
+		return
+	}
+	lastEvicted = time.Now()
+	Evict()
+}
+
+// Evict applies the size bound of the cache and removes stale worktrees.
+func Evict() {
+	log := logpkg.GetLogger()
+	worktreesDir := filepath.Join(Dir, worktreesDirName)
+	if entries, err := os.ReadDir(worktreesDir); err == nil {
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err == nil && time.Since(info.ModTime()) > WorktreeMaxAge {
+				_ = os.RemoveAll(filepath.Join(worktreesDir, entry.Name()))
+			}
+		}
+	}
+	reposDir := filepath.Join(Dir, reposDirName)
+	entries, err := os.ReadDir(reposDir)
+	if err != nil {
+		return
+	}
+	type cachedRepo struct {
+		key      string
+		size     int64
+		lastUsed time.Time
+	}
+	var repos []cachedRepo
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		size := dirSize(filepath.Join(reposDir, entry.Name()))
+		repos = append(repos, cachedRepo{entry.Name(), size, info.ModTime()})
+		total += size
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].lastUsed.Before(repos[j].lastUsed)
+	})
+	for _, r := range repos {
+		if total <= MaxSize {
+			return
+		}
+		if time.Since(r.lastUsed) < EvictionGracePeriod {
+			continue
+		}
+		mu := lockKey(r.key)
+		if !mu.TryLock() {
+			continue
+		}
+		err := os.RemoveAll(filepath.Join(reposDir, r.key))
+		mu.Unlock()
+		if err != nil {
+			log.Info("failed to evict cached git repository",
+				"key", r.key, "error", err.Error())
+			continue
+		}
+		total -= r.size
+	}
+}
+
+func dirSize(dir string) (size int64) {
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,123 @@
+package gitcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func commitFile(t *testing.T, repo *gogit.Repository, dir string, fileName string, data string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, fileName)), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, fileName), []byte(data), 0600))
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = wt.Add(fileName)
+	assert.NoError(t, err)
+	_, err = wt.Commit("write "+fileName, &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+}
+
+func initRemoteRepo(t *testing.T) (*gogit.Repository, string, string) {
+	remoteDir := t.TempDir()
+	remote, err := gogit.PlainInit(remoteDir, false)
+	assert.NoError(t, err)
+	commitFile(t, remote, remoteDir, "README.md", "hello")
+	return remote, remoteDir, "file://" + remoteDir
+}
+
+func TestCheckout(t *testing.T) {
+	Dir = t.TempDir()
+	remote, remoteDir, repoUrl := initRemoteRepo(t)
+
+	repo, tmpDir, err := Checkout(context.Background(), repoUrl, "master", nil, nil)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tmpDir, "README.md"))
+	assert.NoError(t, os.RemoveAll(tmpDir))
+
+	// new commits are fetched into the cache
+	commitFile(t, remote, remoteDir, "clusters/a/cluster.yaml", "a")
+	commitFile(t, remote, remoteDir, "clusters/b/cluster.yaml", "b")
+	repo, tmpDir, err = Checkout(context.Background(), repoUrl, "master", nil, nil)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tmpDir, "clusters", "b", "cluster.yaml"))
+	entries, err := os.ReadDir(filepath.Join(Dir, reposDirName))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// commits of the worktree can be pushed
+	commitFile(t, repo, tmpDir, "clusters/c/cluster.yaml", "c")
+	assert.NoError(t, repo.Push(&gogit.PushOptions{RemoteName: gogit.DefaultRemoteName}))
+	assert.Equal(t, head(t, repo), head(t, remote))
+	assert.NoError(t, os.RemoveAll(tmpDir))
+
+	// sparse checkout
+	_, tmpDir, err = Checkout(context.Background(), repoUrl, "master", nil,
+		&Options{SparseDirs: []string{"clusters/a"}})
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tmpDir, "clusters", "a", "cluster.yaml"))
+	assert.NoFileExists(t, filepath.Join(tmpDir, "clusters", "b", "cluster.yaml"))
+	assert.NoError(t, os.RemoveAll(tmpDir))
+
+	_, _, err = Checkout(context.Background(), repoUrl, "missing", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestCheckoutRecreatesCorruptCache(t *testing.T) {
+	Dir = t.TempDir()
+	_, _, repoUrl := initRemoteRepo(t)
+	repoDir := filepath.Join(Dir, reposDirName, cacheKey(repoUrl))
+	assert.NoError(t, os.MkdirAll(repoDir, 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(repoDir, "HEAD"), []byte("garbage"), 0600))
+
+	_, tmpDir, err := Checkout(context.Background(), repoUrl, "master", nil, nil)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(tmpDir, "README.md"))
+}
+
+func TestEvict(t *testing.T) {
+	Dir = t.TempDir()
+	_, _, repoUrl1 := initRemoteRepo(t)
+	_, _, repoUrl2 := initRemoteRepo(t)
+	for _, repoUrl := range []string{repoUrl1, repoUrl2} {
+		_, tmpDir, err := Checkout(context.Background(), repoUrl, "master", nil, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}
+	// a leftover worktree
+	_, leftover, err := Checkout(context.Background(), repoUrl1, "master", nil, nil)
+	assert.NoError(t, err)
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(leftover, old, old))
+	repoDir1 := filepath.Join(Dir, reposDirName, cacheKey(repoUrl1))
+	repoDir2 := filepath.Join(Dir, reposDirName, cacheKey(repoUrl2))
+	assert.NoError(t, os.Chtimes(repoDir1, old, old))
+
+	// both repositories are within the grace period
+	MaxSize = 1
+	EvictionGracePeriod = 3 * time.Hour
+	Evict()
+	assert.NoDirExists(t, leftover)
+	assert.DirExists(t, repoDir1)
+	assert.DirExists(t, repoDir2)
+
+	// the least recently used repository is evicted first
+	MaxSize = dirSize(repoDir2)
+	EvictionGracePeriod = time.Hour
+	Evict()
+	assert.NoDirExists(t, repoDir1)
+	assert.DirExists(t, repoDir2)
+}
+
+func head(t *testing.T, repo *gogit.Repository) string {
+	ref, err := repo.Head()
+	assert.NoError(t, err)
+	return ref.Hash().String()
+}
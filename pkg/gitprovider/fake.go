@@ -0,0 +1,51 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-process provider that records the pull requests it is asked
+// to open, for testing without a git hosting service.
+type Fake struct {
+	mu           sync.Mutex
+	PullRequests []PullRequest
+}
+
+// RegisterFake registers a new Fake provider with the specified type and
+// returns it.
+func RegisterFake(providerType string) *Fake {
+	f := &Fake{}
+	Register(providerType, func(cfg *Config) (Provider, error) {
+		return f, nil
+	})
+	return f
+}
+
+func (f *Fake) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.PullRequests = append(f.PullRequests, *pr)
+	return f.url(pr.RepoUrl, len(f.PullRequests)), nil
+}
+
+// FindPullRequest returns the last pull request opened from the source
+// branch into the target branch of pr. Fake pull requests are never merged
+// nor closed.
+func (f *Fake) FindPullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.PullRequests) - 1; i >= 0; i-- {
+		opened := &f.PullRequests[i]
+		if opened.RepoUrl == pr.RepoUrl && opened.SourceBranch == pr.SourceBranch &&
+			opened.TargetBranch == pr.TargetBranch {
+			return f.url(pr.RepoUrl, i+1), nil
+		}
+	}
+	return "", nil
+}
+
+func (f *Fake) url(repoUrl string, n int) string {
+	return fmt.Sprintf("fake://%s/pulls/%d", repoUrl, n)
+}
@@ -0,0 +1,69 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type gitea struct {
+	cfg Config
+}
+
+func newGitea(cfg *Config) (Provider, error) {
+	return &gitea{cfg: *cfg}, nil
+}
+
+func (g *gitea) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := defaultApiUrl(&g.cfg, pr.RepoUrl, func(host string) string {
+		return fmt.Sprintf("https://%s/api/v1", host)
+	})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		HtmlUrl string `json:"html_url"`
+	}
+	err = postJSON(ctx, fmt.Sprintf("%s/repos/%s/pulls", apiUrl, repoPath),
+		map[string]string{"Authorization": "token " + g.cfg.Token},
+		map[string]string{
+			"title": pr.Title,
+			"head":  pr.SourceBranch,
+			"base":  pr.TargetBranch,
+			"body":  pr.Description,
+		}, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gitea pull request: %s", err)
+	}
+	return result.HtmlUrl, nil
+}
+
+func (g *gitea) FindPullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := defaultApiUrl(&g.cfg, pr.RepoUrl, func(host string) string {
+		return fmt.Sprintf("https://%s/api/v1", host)
+	})
+	if err != nil {
+		return "", err
+	}
+	type branch struct {
+		Ref string `json:"ref"`
+	}
+	var result []struct {
+		HtmlUrl string `json:"html_url"`
+		Head    branch `json:"head"`
+		Base    branch `json:"base"`
+	}
+	// gitea does not filter pull requests by branch
+	err = getJSON(ctx, fmt.Sprintf("%s/repos/%s/pulls", apiUrl, repoPath),
+		url.Values{"state": {"open"}, "limit": {"50"}},
+		map[string]string{"Authorization": "token " + g.cfg.Token}, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to list gitea pull requests: %s", err)
+	}
+	for _, r := range result {
+		if r.Head.Ref == pr.SourceBranch && r.Base.Ref == pr.TargetBranch {
+			return r.HtmlUrl, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,79 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type gitHub struct {
+	cfg Config
+}
+
+func newGitHub(cfg *Config) (Provider, error) {
+	return &gitHub{cfg: *cfg}, nil
+}
+
+func (g *gitHub) apiUrl(repoUrl string) (string, string, error) {
+	return defaultApiUrl(&g.cfg, repoUrl, func(host string) string {
+		if host == "github.com" {
+			return "https://api.github.com"
+		}
+		// GitHub Enterprise Server
+		return fmt.Sprintf("https://%s/api/v3", host)
+	})
+}
+
+func (g *gitHub) headers() map[string]string {
+	return map[string]string{
+		"Authorization": "token " + g.cfg.Token,
+		"Accept":        "application/vnd.github+json",
+	}
+}
+
+func (g *gitHub) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := g.apiUrl(pr.RepoUrl)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		HtmlUrl string `json:"html_url"`
+	}
+	err = postJSON(ctx, fmt.Sprintf("%s/repos/%s/pulls", apiUrl, repoPath), g.headers(),
+		map[string]string{
+			"title": pr.Title,
+			"head":  pr.SourceBranch,
+			"base":  pr.TargetBranch,
+			"body":  pr.Description,
+		}, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to create github pull request: %s", err)
+	}
+	return result.HtmlUrl, nil
+}
+
+func (g *gitHub) FindPullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := g.apiUrl(pr.RepoUrl)
+	if err != nil {
+		return "", err
+	}
+	var result []struct {
+		HtmlUrl string `json:"html_url"`
+	}
+	// the head branch is qualified by the owner of the repository
+	owner := strings.SplitN(repoPath, "/", 2)[0]
+	err = getJSON(ctx, fmt.Sprintf("%s/repos/%s/pulls", apiUrl, repoPath),
+		url.Values{
+			"state": {"open"},
+			"head":  {owner + ":" + pr.SourceBranch},
+			"base":  {pr.TargetBranch},
+		}, g.headers(), &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to list github pull requests: %s", err)
+	}
+	if len(result) == 0 {
+		return "", nil
+	}
+	return result[0].HtmlUrl, nil
+}
@@ -0,0 +1,69 @@
+package gitprovider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type gitLab struct {
+	cfg Config
+}
+
+func newGitLab(cfg *Config) (Provider, error) {
+	return &gitLab{cfg: *cfg}, nil
+}
+
+func (g *gitLab) CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := defaultApiUrl(&g.cfg, pr.RepoUrl, func(host string) string {
+		return fmt.Sprintf("https://%s/api/v4", host)
+	})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		WebUrl string `json:"web_url"`
+	}
+	// the project is identified by its URL-encoded path, which may
+	// include nested groups
+	err = postJSON(ctx, fmt.Sprintf("%s/projects/%s/merge_requests", apiUrl,
+		url.PathEscape(repoPath)),
+		map[string]string{"Authorization": "Bearer " + g.cfg.Token},
+		map[string]string{
+			"title":         pr.Title,
+			"source_branch": pr.SourceBranch,
+			"target_branch": pr.TargetBranch,
+			"description":   pr.Description,
+		}, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to create gitlab merge request: %s", err)
+	}
+	return result.WebUrl, nil
+}
+
+func (g *gitLab) FindPullRequest(ctx context.Context, pr *PullRequest) (string, error) {
+	apiUrl, repoPath, err := defaultApiUrl(&g.cfg, pr.RepoUrl, func(host string) string {
+		return fmt.Sprintf("https://%s/api/v4", host)
+	})
+	if err != nil {
+		return "", err
+	}
+	var result []struct {
+		WebUrl string `json:"web_url"`
+	}
+	err = getJSON(ctx, fmt.Sprintf("%s/projects/%s/merge_requests", apiUrl,
+		url.PathEscape(repoPath)),
+		url.Values{
+			"state":         {"opened"},
+			"source_branch": {pr.SourceBranch},
+			"target_branch": {pr.TargetBranch},
+		},
+		map[string]string{"Authorization": "Bearer " + g.cfg.Token}, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to list gitlab merge requests: %s", err)
+	}
+	if len(result) == 0 {
+		return "", nil
+	}
+	return result[0].WebUrl, nil
+}
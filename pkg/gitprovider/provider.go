@@ -0,0 +1,205 @@
+package gitprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PullRequest describes a pull request (or merge request) to open.
+type PullRequest struct {
+	// URL of the git repository, as registered in argocd
+	RepoUrl string
+	// Branch containing the changes
+	SourceBranch string
+	// Branch that the changes should be merged into
+	TargetBranch string
+	Title        string
+	Description  string
+}
+
+// Provider opens pull requests on a git hosting service.
+type Provider interface {
+	// CreatePullRequest opens the pull request and returns its web URL.
+	CreatePullRequest(ctx context.Context, pr *PullRequest) (string, error)
+	// FindPullRequest returns the web URL of the open pull request from the
+	// source branch into the target branch of pr, or an empty string if
+	// there is none.
+	FindPullRequest(ctx context.Context, pr *PullRequest) (string, error)
+}
+
+// Config selects and configures a Provider.
+type Config struct {
+	// Type of the provider, for e.g. github, gitlab or gitea. If empty, it is
+	// guessed from the host of the repository URL.
+	Type string
+	// Base URL of the provider's API. If empty, the default API location of
+	// the repository host is used.
+	ApiUrl string
+	// Token used to authenticate with the API
+	Token string
+}
+
+// Factory creates a Provider from its configuration.
+type Factory func(cfg *Config) (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register makes a provider type available to New. Registering a type
+// again replaces the previous factory.
+func Register(providerType string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[providerType] = factory
+}
+
+// New returns the provider for the configuration and repository URL.
+func New(cfg *Config, repoUrl string) (Provider, error) {
+	providerType := cfg.Type
+	if providerType == "" {
+		host, _, err := ParseRepoUrl(repoUrl)
+		if err != nil {
+			return nil, err
+		}
+		switch host {
+		case "github.com":
+			providerType = "github"
+		case "gitlab.com":
+			providerType = "gitlab"
+		default:
+			return nil, fmt.Errorf("cannot determine git provider of %s, it must be configured", host)
+		}
+	}
+	factoriesMu.RLock()
+	factory := factories[providerType]
+	factoriesMu.RUnlock()
+	if factory == nil {
+		return nil, fmt.Errorf("unknown git provider type: %s", providerType)
+	}
+	return factory(cfg)
+}
+
+func init() {
+	Register("github", newGitHub)
+	Register("gitlab", newGitLab)
+	Register("gitea", newGitea)
+}
+
+// -----------------------------------------------------------------------------
+
+// ParseRepoUrl returns the host and the repository path (without the .git
+// suffix) of https and ssh git repository URLs.
+func ParseRepoUrl(repoUrl string) (host string, repoPath string, err error) {
+	if !strings.Contains(repoUrl, "://") {
+		// scp-like syntax, for e.g. git@github.com:org/repo.git
+		at := strings.Index(repoUrl, "@")
+		colon := strings.Index(repoUrl, ":")
+		if colon < 0 || colon < at {
+			return "", "", fmt.Errorf("invalid repository url: %s", repoUrl)
+		}
+		host = repoUrl[at+1 : colon]
+		repoPath = repoUrl[colon+1:]
+	} else {
+		u, err := url.Parse(repoUrl)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid repository url: %s", err)
+		}
+		host = u.Hostname()
+		repoPath = u.Path
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || !strings.Contains(repoPath, "/") {
+		return "", "", fmt.Errorf("invalid repository url: %s", repoUrl)
+	}
+	return host, repoPath, nil
+}
+
+// -----------------------------------------------------------------------------
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON sends a JSON request and decodes the JSON response into result.
+func postJSON(
+	ctx context.Context,
+	apiUrl string,
+	headers map[string]string,
+	body interface{},
+	result interface{},
+) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %s", err)
+	}
+	return doJSON(ctx, http.MethodPost, apiUrl, headers, bytes.NewReader(data), result)
+}
+
+// getJSON sends a GET request with the query parameters and decodes the
+// JSON response into result.
+func getJSON(
+	ctx context.Context,
+	apiUrl string,
+	query url.Values,
+	headers map[string]string,
+	result interface{},
+) error {
+	return doJSON(ctx, http.MethodGet, apiUrl+"?"+query.Encode(), headers, nil, result)
+}
+
+func doJSON(
+	ctx context.Context,
+	method string,
+	apiUrl string,
+	headers map[string]string,
+	body io.Reader,
+	result interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, method, apiUrl, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %s", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %s", err)
+	}
+	defer resp.Body.Close()
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %s", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request to %s failed with status %d: %s",
+			apiUrl, resp.StatusCode, strings.TrimSpace(string(respData)))
+	}
+	if err := json.Unmarshal(respData, result); err != nil {
+		return fmt.Errorf("failed to decode response: %s", err)
+	}
+	return nil
+}
+
+func defaultApiUrl(cfg *Config, repoUrl string, defaultUrl func(host string) string) (string, string, error) {
+	host, repoPath, err := ParseRepoUrl(repoUrl)
+	if err != nil {
+		return "", "", err
+	}
+	if cfg.ApiUrl != "" {
+		return strings.TrimSuffix(cfg.ApiUrl, "/"), repoPath, nil
+	}
+	return defaultUrl(host), repoPath, nil
+}
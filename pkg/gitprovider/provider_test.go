@@ -0,0 +1,140 @@
+package gitprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepoUrl(t *testing.T) {
+	for _, tc := range []struct {
+		url  string
+		host string
+		path string
+	}{
+		{"https://github.com/org/repo.git", "github.com", "org/repo"},
+		{"https://gitlab.example.com/group/subgroup/repo", "gitlab.example.com", "group/subgroup/repo"},
+		{"git@github.com:org/repo.git", "github.com", "org/repo"},
+		{"ssh://git@gitea.example.com:2222/org/repo.git", "gitea.example.com", "org/repo"},
+	} {
+		host, repoPath, err := ParseRepoUrl(tc.url)
+		assert.NoError(t, err, tc.url)
+		assert.Equal(t, tc.host, host, tc.url)
+		assert.Equal(t, tc.path, repoPath, tc.url)
+	}
+	_, _, err := ParseRepoUrl("https://github.com/repo")
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(&Config{}, "https://github.com/org/repo")
+	assert.NoError(t, err)
+	assert.IsType(t, &gitHub{}, p)
+	_, err = New(&Config{}, "https://git.example.com/org/repo")
+	assert.Error(t, err)
+	p, err = New(&Config{Type: "gitea"}, "https://git.example.com/org/repo")
+	assert.NoError(t, err)
+	assert.IsType(t, &gitea{}, p)
+	_, err = New(&Config{Type: "bitbucket"}, "https://git.example.com/org/repo")
+	assert.Error(t, err)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	for _, tc := range []struct {
+		providerType string
+		path         string
+		authHeader   string
+		branchKey    string
+		response     string
+	}{
+		{"github", "/repos/org/repo/pulls", "token secret", "head",
+			`{"html_url": "https://github.com/org/repo/pull/1"}`},
+		{"gitlab", "/projects/org%2Frepo/merge_requests", "Bearer secret", "source_branch",
+			`{"web_url": "https://github.com/org/repo/pull/1"}`},
+		{"gitea", "/repos/org/repo/pulls", "token secret", "head",
+			`{"html_url": "https://github.com/org/repo/pull/1"}`},
+	} {
+		var body map[string]string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, tc.path, r.URL.EscapedPath(), tc.providerType)
+			assert.Equal(t, tc.authHeader, r.Header.Get("Authorization"), tc.providerType)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(tc.response))
+		}))
+		p, err := New(&Config{Type: tc.providerType, ApiUrl: srv.URL, Token: "secret"},
+			"https://git.example.com/org/repo.git")
+		assert.NoError(t, err)
+		prUrl, err := p.CreatePullRequest(context.Background(), &PullRequest{
+			RepoUrl:      "https://git.example.com/org/repo.git",
+			SourceBranch: "arlon/1234",
+			TargetBranch: "main",
+			Title:        "deploy arlon cluster c1",
+		})
+		srv.Close()
+		assert.NoError(t, err, tc.providerType)
+		assert.Equal(t, "https://github.com/org/repo/pull/1", prUrl)
+		assert.Equal(t, "arlon/1234", body[tc.branchKey], tc.providerType)
+		assert.Equal(t, "deploy arlon cluster c1", body["title"], tc.providerType)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = w.Write([]byte(`{"message": "Validation Failed"}`))
+	}))
+	defer srv.Close()
+	p, _ := New(&Config{Type: "github", ApiUrl: srv.URL}, "https://github.com/org/repo")
+	_, err := p.CreatePullRequest(context.Background(), &PullRequest{
+		RepoUrl: "https://github.com/org/repo",
+	})
+	assert.ErrorContains(t, err, "Validation Failed")
+}
+
+func TestFindPullRequest(t *testing.T) {
+	for _, tc := range []struct {
+		providerType string
+		query        string
+		response     string
+	}{
+		{"github", "base=main&head=org%3Aarlon%2F1234&state=open",
+			`[{"html_url": "https://github.com/org/repo/pull/1"}]`},
+		{"gitlab", "source_branch=arlon%2F1234&state=opened&target_branch=main",
+			`[{"web_url": "https://github.com/org/repo/pull/1"}]`},
+		{"gitea", "limit=50&state=open",
+			`[{"html_url": "https://github.com/org/repo/pull/2", "head": {"ref": "arlon/5678"}, "base": {"ref": "main"}},
+			  {"html_url": "https://github.com/org/repo/pull/1", "head": {"ref": "arlon/1234"}, "base": {"ref": "main"}}]`},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method, tc.providerType)
+			assert.Equal(t, tc.query, r.URL.RawQuery, tc.providerType)
+			_, _ = w.Write([]byte(tc.response))
+		}))
+		p, err := New(&Config{Type: tc.providerType, ApiUrl: srv.URL, Token: "secret"},
+			"https://git.example.com/org/repo.git")
+		assert.NoError(t, err)
+		pr := &PullRequest{
+			RepoUrl:      "https://git.example.com/org/repo.git",
+			SourceBranch: "arlon/1234",
+			TargetBranch: "main",
+		}
+		prUrl, err := p.FindPullRequest(context.Background(), pr)
+		assert.NoError(t, err, tc.providerType)
+		assert.Equal(t, "https://github.com/org/repo/pull/1", prUrl, tc.providerType)
+		srv.Close()
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	p, _ := New(&Config{Type: "github", ApiUrl: srv.URL}, "https://github.com/org/repo")
+	prUrl, err := p.FindPullRequest(context.Background(), &PullRequest{
+		RepoUrl: "https://github.com/org/repo",
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, prUrl)
+}
@@ -3,12 +3,16 @@ package gitutils
 import (
 	"bytes"
 	"fmt"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/bundle"
 	"github.com/arlonproj/arlon/pkg/common"
+	gyaml "github.com/ghodss/yaml"
 	gogit "github.com/go-git/go-git/v5"
 	"io"
 	"io/fs"
 	"path"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -20,22 +24,50 @@ kind: Application
 metadata:
   name: {{.AppName}}
   namespace: {{.AppNamespace}}
+{{- if .SyncWave }}
+  annotations:
+    argocd.argoproj.io/sync-wave: "{{ .SyncWave }}"
+{{- end }}
   finalizers:
   # This solves issue #17
   - resources-finalizer.argocd.argoproj.io/foreground
 spec:
   syncPolicy:
     automated:
-      prune: true
+      prune: {{ not .DisablePrune }}
+{{- if .SelfHeal }}
+      selfHeal: true
+{{- end }}
+{{- if .SyncOptions }}
+    syncOptions:
+	{{- range .SyncOptions }}
+    - {{ printf "%q" . }}
+	{{- end }}
+{{- end }}
+{{- if .Retry }}
+    retry:
+{{ .Retry }}
+{{- end }}
   destination:
     name: {{.ClusterName}}
     namespace: {{.DestinationNamespace}}
-  project: default
+  project: {{ or .Project "default" }}
+{{- if .IgnoreDifferences }}
+  ignoreDifferences:
+{{ .IgnoreDifferences }}
+{{- end }}
   source:
     repoURL: {{.RepoUrl}}
+{{- if .Chart }}
+    chart: {{.Chart}}
+{{- else }}
     path: {{.RepoPath}}
+{{- end }}
     targetRevision: {{.RepoRevision}}
-{{- if eq .SrcType "helm" }}
+{{- if .Plugin }}
+    plugin:
+      name: {{.Plugin}}
+{{- else if or (eq .SrcType "helm") .Overrides .HelmValues }}
     helm:
       parameters:
       # Pass cluster name to the bundle in case it needs it and is a Helm chart.
@@ -47,6 +79,16 @@ spec:
       - name: {{ .Key }}
         value: {{ .Value }}
 	{{- end }}
+{{- if .HelmValues }}
+      values: |
+{{ .HelmValues }}
+{{- end }}
+{{- else if .KustomizeImages }}
+    kustomize:
+      images:
+	{{- range .KustomizeImages }}
+      - {{ printf "%q" . }}
+	{{- end }}
 {{- else if eq .SrcType "kustomize" }}
     kustomize: {}
 {{- else if eq .SrcType "ksonnet" }}
@@ -62,10 +104,130 @@ type AppSettings struct {
 	RepoUrl              string
 	RepoPath             string
 	RepoRevision         string
+	Chart                string
 	SrcType              string
+	Plugin               string // Argo CD config management plugin rendering the app
 	AppNamespace         string
 	DestinationNamespace string
-	Overrides            []common.KVPair
+	Overrides            []common.KVPair // Helm parameters
+	HelmValues           string          // YAML, indented under source.helm.values
+	KustomizeImages      []string
+	// The following are set from the bundle's deployment settings
+	Project           string
+	DisablePrune      bool
+	SelfHeal          bool
+	SyncOptions       []string
+	SyncWave          string
+	Retry             string // YAML, indented under syncPolicy.retry
+	IgnoreDifferences string // YAML, indented under spec.ignoreDifferences
+}
+
+// applyDeploySettings sets the fields of an app from the deployment settings
+// of its bundle.
+func applyDeploySettings(app *AppSettings, settings *arlonv1.DeploySettings) error {
+	if settings.DestinationNamespace != "" {
+		app.DestinationNamespace = settings.DestinationNamespace
+	}
+	app.Project = settings.Project
+	app.DisablePrune = settings.Prune != nil && !*settings.Prune
+	app.SelfHeal = settings.SelfHeal != nil && *settings.SelfHeal
+	if settings.CreateNamespace != nil && *settings.CreateNamespace {
+		app.SyncOptions = append(app.SyncOptions, "CreateNamespace=true")
+	}
+	app.SyncOptions = append(app.SyncOptions, settings.SyncOptions...)
+	if settings.SyncWave != nil {
+		app.SyncWave = strconv.Itoa(int(*settings.SyncWave))
+	}
+	var err error
+	if settings.Retry != nil {
+		app.Retry, err = indentedYaml(settings.Retry, 6)
+		if err != nil {
+			return fmt.Errorf("failed to marshal retry policy: %s", err)
+		}
+	}
+	if len(settings.IgnoreDifferences) > 0 {
+		app.IgnoreDifferences, err = indentedYaml(settings.IgnoreDifferences, 2)
+		if err != nil {
+			return fmt.Errorf("failed to marshal ignored differences: %s", err)
+		}
+	}
+	return nil
+}
+
+// indentedYaml marshals obj to YAML with every line indented by the given
+// number of spaces, and without a trailing newline.
+func indentedYaml(obj interface{}, indent int) (string, error) {
+	out, err := gyaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	prefix := strings.Repeat(" ", indent)
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// applyOverrides sets the Helm parameters and values and the kustomize images
+// of an app from the overrides of its bundle, and returns the kustomize
+// patches. Overrides that cannot apply to the app's source type are ignored.
+func applyOverrides(app *AppSettings, overrides []arlonv1.Override) ([]arlonv1.KustomizePatch, error) {
+	var helmOverrides []arlonv1.Override
+	var patches []arlonv1.KustomizePatch
+	for _, o := range overrides {
+		if bundle.IsHelmOverride(&o) && (app.SrcType == "" || app.SrcType == bundle.ToolHelm) {
+			helmOverrides = append(helmOverrides, o)
+			if o.Key != "" {
+				app.Overrides = append(app.Overrides, common.KVPair{Key: o.Key, Value: o.Value})
+			}
+		} else if app.SrcType == "" || app.SrcType == bundle.ToolKustomize {
+			app.KustomizeImages = append(app.KustomizeImages, o.Images...)
+			patches = append(patches, o.Patches...)
+		}
+	}
+	values, err := bundle.MergeHelmValues(helmOverrides)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > 0 {
+		app.HelmValues, err = indentedYaml(values, 8)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Helm values: %s", err)
+		}
+	}
+	return patches, nil
+}
+
+type kustomization struct {
+	APIVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Resources  []string                 `json:"resources"`
+	Patches    []arlonv1.KustomizePatch `json:"patches,omitempty"`
+}
+
+// writeKustomization writes a kustomization.yaml file patching a resource
+// in a directory of the working tree.
+func writeKustomization(wt *gogit.Worktree, dirPath string, resource string, patches []arlonv1.KustomizePatch) error {
+	data, err := gyaml.Marshal(&kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  []string{resource},
+		Patches:    patches,
+	})
+	if err != nil {
+		return err
+	}
+	if err := wt.Filesystem.MkdirAll(dirPath, fs.ModeDir|0700); err != nil {
+		return err
+	}
+	dst, err := wt.Filesystem.Create(path.Join(dirPath, "kustomization.yaml"))
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(data)
+	_ = dst.Close()
+	return err
 }
 
 func ProcessBundles(
@@ -75,7 +237,7 @@ func ProcessBundles(
 	mgmtPath string,
 	workloadPath string,
 	bundles []bundle.Bundle,
-	overrides common.KVPairMap,
+	overrides map[string][]arlonv1.Override,
 ) error {
 	if len(bundles) == 0 {
 		return nil
@@ -90,7 +252,10 @@ func ProcessBundles(
 			ClusterName:          clusterName,
 			AppName:              fmt.Sprintf("%s-%s", clusterName, b.Name),
 			AppNamespace:         "argocd",
-			DestinationNamespace: "default", // FIXME: make configurable
+			DestinationNamespace: "default",
+		}
+		if err := applyDeploySettings(&app, &b.Settings); err != nil {
+			return fmt.Errorf("bundle %s: %s", b.Name, err)
 		}
 		if b.RepoRevision == "" {
 			app.RepoRevision = "HEAD"
@@ -105,8 +270,31 @@ func ProcessBundles(
 			app.RepoUrl = b.RepoUrl
 			app.RepoPath = b.RepoPath
 			app.SrcType = b.SrcType
-			o := overrides[b.Name]
-			app.Overrides = append(app.Overrides, o...)
+			if b.Chart != "" {
+				// Argo CD expects the URL of an OCI registry without scheme
+				app.RepoUrl = strings.TrimPrefix(b.RepoUrl, bundle.OCIScheme)
+				app.Chart = b.Chart
+				app.SrcType = "helm"
+			}
+			patches, err := applyOverrides(&app, overrides[b.Name])
+			if err != nil {
+				return err
+			}
+			if len(patches) > 0 {
+				// patch the bundle's manifests with a kustomize overlay
+				dirPath := path.Join(workloadPath, b.Name)
+				base := "git::" + b.RepoUrl + "//" + b.RepoPath
+				if b.RepoRevision != "" {
+					base += "?ref=" + b.RepoRevision
+				}
+				if err := writeKustomization(wt, dirPath, base, patches); err != nil {
+					return fmt.Errorf("failed to write kustomization of bundle %s: %s", b.Name, err)
+				}
+				app.RepoUrl = repoUrl
+				app.RepoPath = dirPath
+				app.RepoRevision = "HEAD"
+				app.SrcType = bundle.ToolKustomize
+			}
 		} else if b.RepoUrl != "" {
 			return fmt.Errorf("b %s has both data and repoUrl set", b.Name)
 		} else {
@@ -128,6 +316,28 @@ func ProcessBundles(
 			}
 			app.RepoUrl = repoUrl
 			app.RepoPath = path.Join(workloadPath, b.Name)
+			if b.Encryption == bundle.EncryptionSops {
+				// The data is committed encrypted, for the plugin to decrypt
+				app.Plugin = bundle.SopsPluginName
+			} else {
+				var ovrs []arlonv1.Override
+				for _, o := range overrides[b.Name] {
+					if bundle.IsKustomizeOverride(&o) {
+						ovrs = append(ovrs, o)
+					}
+				}
+				patches, err := applyOverrides(&app, ovrs)
+				if err != nil {
+					return err
+				}
+				if len(ovrs) > 0 {
+					// the overrides are applied by kustomize
+					err = writeKustomization(wt, dirPath, bundleFileName, patches)
+					if err != nil {
+						return fmt.Errorf("failed to write kustomization of bundle %s: %s", b.Name, err)
+					}
+				}
+			}
 		}
 		appPath := path.Join(mgmtPath, "templates", bundleFileName)
 		dst, err := wt.Filesystem.Create(appPath)
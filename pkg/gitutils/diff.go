@@ -0,0 +1,137 @@
+package gitutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// PlanToGit clones the branch of a repository and applies the change like
+// WriteToGit, but neither commits nor pushes it. It returns the unified diff
+// of the files changed, or an empty string if the change results in no
+// commit.
+func PlanToGit(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	branch string,
+	change ChangeFunc,
+) (diff string, err error) {
+	repo, tmpDir, _, err := argocd.CloneRepo(creds, repoUrl, branch)
+	if tmpDir != "" {
+		defer os.RemoveAll(tmpDir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to clone repo: %s", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get repo worktree: %s", err)
+	}
+	if _, err = change(wt, tmpDir); err != nil {
+		return "", err
+	}
+	return DiffChanges(repo, wt, tmpDir)
+}
+
+// DiffChanges returns the unified diff between the head commit of a
+// repository and the files of its worktree that have changed, in the order
+// of their paths.
+func DiffChanges(repo *gogit.Repository, wt *gogit.Worktree, tmpDir string) (string, error) {
+	status, err := wt.Status()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree status: %s", err)
+	}
+	var tree *object.Tree
+	head, err := repo.Head()
+	if err == nil {
+		commit, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return "", fmt.Errorf("failed to get head commit: %s", err)
+		}
+		if tree, err = commit.Tree(); err != nil {
+			return "", fmt.Errorf("failed to get head tree: %s", err)
+		}
+	}
+	files := make([]string, 0, len(status))
+	for file := range status {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var sb strings.Builder
+	for _, file := range files {
+		oldContent, oldExists, err := headContent(tree, file)
+		if err != nil {
+			return "", err
+		}
+		newData, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("failed to read %s: %s", file, err)
+		}
+		newExists := err == nil
+		newContent := string(newData)
+		if oldExists == newExists && oldContent == newContent {
+			continue
+		}
+		ud := difflib.UnifiedDiff{
+			A:        splitLines(oldContent),
+			B:        splitLines(newContent),
+			FromFile: "a/" + file,
+			ToFile:   "b/" + file,
+			Context:  3,
+		}
+		if !oldExists {
+			ud.FromFile = "/dev/null"
+		}
+		if !newExists {
+			ud.ToFile = "/dev/null"
+		}
+		text, err := difflib.GetUnifiedDiffString(ud)
+		if err != nil {
+			return "", fmt.Errorf("failed to diff %s: %s", file, err)
+		}
+		sb.WriteString(text)
+	}
+	return sb.String(), nil
+}
+
+// splitLines splits text into lines ending with a newline, which the last
+// line of a file may lack.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// headContent returns the content of a file in the head tree, and whether
+// it exists there.
+func headContent(tree *object.Tree, file string) (string, bool, error) {
+	if tree == nil {
+		return "", false, nil
+	}
+	f, err := tree.File(file)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get %s from head tree: %s", file, err)
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s from head tree: %s", file, err)
+	}
+	return content, true, nil
+}
@@ -0,0 +1,60 @@
+package gitutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanToGit(t *testing.T) {
+	remote, repoUrl := initRemoteRepo(t)
+	creds := &argocd.RepoCreds{}
+	_, _, err := WriteToGit(creds, repoUrl, "master", writeFile("cluster.yaml", "kind: Cluster\nname: c1\n"))
+	assert.NoError(t, err)
+	head, err := remote.Head()
+	assert.NoError(t, err)
+
+	diff, err := PlanToGit(creds, repoUrl, "master",
+		func(wt *gogit.Worktree, rootDir string) (string, error) {
+			if _, err := wt.Remove("README.md"); err != nil {
+				return "", err
+			}
+			if _, err := writeFile("cluster.yaml", "kind: Cluster\nname: c2\n")(wt, rootDir); err != nil {
+				return "", err
+			}
+			if err := os.MkdirAll(filepath.Join(rootDir, "mgmt"), 0700); err != nil {
+				return "", err
+			}
+			return writeFile("mgmt/app.yaml", "kind: Application\n")(wt, rootDir)
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, `--- a/README.md
++++ /dev/null
+@@ -1 +0,0 @@
+-hello
+--- a/cluster.yaml
++++ b/cluster.yaml
+@@ -1,2 +1,2 @@
+ kind: Cluster
+-name: c1
++name: c2
+--- /dev/null
++++ b/mgmt/app.yaml
+@@ -0,0 +1 @@
++kind: Application
+`, diff)
+
+	// the remote is unchanged
+	newHead, err := remote.Head()
+	assert.NoError(t, err)
+	assert.Equal(t, head.Hash(), newHead.Hash())
+
+	// writing the same content results in no diff
+	diff, err = PlanToGit(creds, repoUrl, "master", writeFile("cluster.yaml", "kind: Cluster\nname: c1\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, diff)
+}
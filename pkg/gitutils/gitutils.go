@@ -24,6 +24,8 @@ func CommitChanges(tmpDir string, wt *gogit.Worktree, commitMsg string) (changed
 	// change to a broken symlink: so, detect and skip those.
 	for file, sts := range status {
 		if sts.Staging == gogit.Deleted {
+			// already staged by Worktree.Remove()
+			changed = true
 			continue
 		}
 		abspath := filepath.Join(tmpDir, file)
@@ -59,29 +61,3 @@ func CommitChanges(tmpDir string, wt *gogit.Worktree, commitMsg string) (changed
 	}
 	return
 }
-
-func CommitDeleteChanges(tmpDir string, wt *gogit.Worktree, commitMsg string) (changed bool, err error) {
-	status, err := wt.Status()
-	if err != nil {
-		return false, fmt.Errorf("failed to get worktree status: %s", err)
-	}
-	for file := range status {
-		_, _ = wt.Add(file)
-		changed = true
-	}
-	if !changed {
-		return false, nil
-	}
-	commitOpts := &gogit.CommitOptions{
-		Author: &object.Signature{
-			Name:  "arlon automation",
-			Email: "arlon@arlon.io",
-			When:  time.Now(),
-		},
-	}
-	_, err = wt.Commit(commitMsg, commitOpts)
-	if err != nil {
-		return changed, fmt.Errorf("failed to commit change: %s", err)
-	}
-	return
-}
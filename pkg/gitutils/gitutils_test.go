@@ -1,6 +1,7 @@
 package gitutils
 
 import (
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/common"
 	"strings"
 	"testing"
@@ -69,3 +70,317 @@ func TestAppTemplate(t *testing.T) {
 		t.Fatalf("template output doesn't match: %s", b.String())
 	}
 }
+
+var expectedDeploySettingsOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: testApp
+  namespace: argocd
+  annotations:
+    argocd.argoproj.io/sync-wave: "-1"
+  finalizers:
+  # This solves issue #17
+  - resources-finalizer.argocd.argoproj.io/foreground
+spec:
+  syncPolicy:
+    automated:
+      prune: false
+      selfHeal: true
+    syncOptions:
+    - "CreateNamespace=true"
+    - "ServerSideApply=true"
+    retry:
+      backoff:
+        duration: 5s
+      limit: 3
+  destination:
+    name: testCluster
+    namespace: monitoring
+  project: platform
+  ignoreDifferences:
+  - group: apps
+    jsonPointers:
+    - /spec/replicas
+    kind: Deployment
+  source:
+    repoURL: testRepoUrl
+    path: testRepoPath
+    targetRevision: testRepoRevision
+    directory: {}
+`
+
+func TestAppTemplateDeploySettings(t *testing.T) {
+	yes, no := true, false
+	wave := int32(-1)
+	appSettings := AppSettings{
+		AppName:              "testApp",
+		ClusterName:          "testCluster",
+		RepoUrl:              "testRepoUrl",
+		RepoPath:             "testRepoPath",
+		RepoRevision:         "testRepoRevision",
+		SrcType:              "directory",
+		AppNamespace:         "argocd",
+		DestinationNamespace: "default",
+	}
+	err := applyDeploySettings(&appSettings, &arlonv1.DeploySettings{
+		DestinationNamespace: "monitoring",
+		CreateNamespace:      &yes,
+		Project:              "platform",
+		Prune:                &no,
+		SelfHeal:             &yes,
+		SyncOptions:          []string{"ServerSideApply=true"},
+		Retry: &arlonv1.SyncRetry{
+			Limit:   3,
+			Backoff: &arlonv1.SyncRetryBackoff{Duration: "5s"},
+		},
+		IgnoreDifferences: []arlonv1.IgnoreDifference{
+			{Group: "apps", Kind: "Deployment", JSONPointers: []string{"/spec/replicas"}},
+		},
+		SyncWave: &wave,
+	})
+	if err != nil {
+		t.Fatalf("failed to apply deploy settings: %s", err)
+	}
+	tmpl, err := template.New("app").Parse(appTmpl)
+	if err != nil {
+		t.Fatalf("failed to create template: %s", err)
+	}
+	b := new(strings.Builder)
+	err = tmpl.Execute(b, &appSettings)
+	if err != nil {
+		t.Fatalf("failed to execute template: %s", err)
+	}
+	if b.String() != expectedDeploySettingsOutput {
+		t.Fatalf("template output doesn't match: %s", b.String())
+	}
+}
+
+var expectedChartTemplateOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: testApp
+  namespace: argocd
+  finalizers:
+  # This solves issue #17
+  - resources-finalizer.argocd.argoproj.io/foreground
+spec:
+  syncPolicy:
+    automated:
+      prune: true
+  destination:
+    name: testCluster
+    namespace: default
+  project: default
+  source:
+    repoURL: https://charts.jetstack.io
+    chart: cert-manager
+    targetRevision: v1.11.0
+    helm:
+      parameters:
+      # Pass cluster name to the bundle in case it needs it and is a Helm chart.
+      # Example: this is required by the CAPI cluster autoscaler.
+      # Use arlon prefix to avoid any conflicts with the bundle's own values.
+      - name: arlon.clusterName
+        value: testCluster
+      - name: installCRDs
+        value: true
+`
+
+func TestAppTemplateChart(t *testing.T) {
+	appSettings := AppSettings{
+		AppName:              "testApp",
+		ClusterName:          "testCluster",
+		RepoUrl:              "https://charts.jetstack.io",
+		Chart:                "cert-manager",
+		RepoRevision:         "v1.11.0",
+		SrcType:              "helm",
+		AppNamespace:         "argocd",
+		DestinationNamespace: "default",
+		Overrides: []common.KVPair{
+			{Key: "installCRDs", Value: "true"},
+		},
+	}
+	tmpl, err := template.New("app").Parse(appTmpl)
+	if err != nil {
+		t.Fatalf("failed to create template: %s", err)
+	}
+	b := new(strings.Builder)
+	err = tmpl.Execute(b, &appSettings)
+	if err != nil {
+		t.Fatalf("failed to execute template: %s", err)
+	}
+	if b.String() != expectedChartTemplateOutput {
+		t.Fatalf("template output doesn't match: %s", b.String())
+	}
+}
+
+var expectedPluginTemplateOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: testApp
+  namespace: argocd
+  finalizers:
+  # This solves issue #17
+  - resources-finalizer.argocd.argoproj.io/foreground
+spec:
+  syncPolicy:
+    automated:
+      prune: true
+  destination:
+    name: testCluster
+    namespace: default
+  project: default
+  source:
+    repoURL: testRepoUrl
+    path: workload/testBundle
+    targetRevision: HEAD
+    plugin:
+      name: arlon-sops
+`
+
+func TestAppTemplatePlugin(t *testing.T) {
+	appSettings := AppSettings{
+		AppName:              "testApp",
+		ClusterName:          "testCluster",
+		RepoUrl:              "testRepoUrl",
+		RepoPath:             "workload/testBundle",
+		RepoRevision:         "HEAD",
+		Plugin:               "arlon-sops",
+		AppNamespace:         "argocd",
+		DestinationNamespace: "default",
+	}
+	tmpl, err := template.New("app").Parse(appTmpl)
+	if err != nil {
+		t.Fatalf("failed to create template: %s", err)
+	}
+	b := new(strings.Builder)
+	err = tmpl.Execute(b, &appSettings)
+	if err != nil {
+		t.Fatalf("failed to execute template: %s", err)
+	}
+	if b.String() != expectedPluginTemplateOutput {
+		t.Fatalf("template output doesn't match: %s", b.String())
+	}
+}
+
+var expectedOverridesTemplateOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: testApp
+  namespace: argocd
+  finalizers:
+  # This solves issue #17
+  - resources-finalizer.argocd.argoproj.io/foreground
+spec:
+  syncPolicy:
+    automated:
+      prune: true
+  destination:
+    name: testCluster
+    namespace: default
+  project: default
+  source:
+    repoURL: testRepoUrl
+    path: testRepoPath
+    targetRevision: HEAD
+    helm:
+      parameters:
+      # Pass cluster name to the bundle in case it needs it and is a Helm chart.
+      # Example: this is required by the CAPI cluster autoscaler.
+      # Use arlon prefix to avoid any conflicts with the bundle's own values.
+      - name: arlon.clusterName
+        value: testCluster
+      - name: replicaCount
+        value: 3
+      values: |
+        image:
+          repository: podinfo
+          tag: 6.4.0
+`
+
+var expectedImagesTemplateOutput = `
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: testApp
+  namespace: argocd
+  finalizers:
+  # This solves issue #17
+  - resources-finalizer.argocd.argoproj.io/foreground
+spec:
+  syncPolicy:
+    automated:
+      prune: true
+  destination:
+    name: testCluster
+    namespace: default
+  project: default
+  source:
+    repoURL: testRepoUrl
+    path: testRepoPath
+    targetRevision: HEAD
+    kustomize:
+      images:
+      - "guestbook=guestbook:v2"
+`
+
+func TestAppTemplateOverrides(t *testing.T) {
+	tmpl, err := template.New("app").Parse(appTmpl)
+	if err != nil {
+		t.Fatalf("failed to create template: %s", err)
+	}
+	for _, tc := range []struct {
+		srcType   string
+		overrides []arlonv1.Override
+		expected  string
+	}{
+		{
+			// the source type is unknown, Argo CD uses Helm for Helm overrides
+			overrides: []arlonv1.Override{
+				{Bundle: "testBundle", Values: "image:\n  repository: podinfo\n  tag: 6.3.5\n"},
+				{Bundle: "testBundle", Key: "replicaCount", Value: "3"},
+				{Bundle: "testBundle", Values: "image:\n  tag: 6.4.0\n"},
+			},
+			expected: expectedOverridesTemplateOutput,
+		},
+		{
+			srcType: "kustomize",
+			overrides: []arlonv1.Override{
+				{Bundle: "testBundle", Images: []string{"guestbook=guestbook:v2"}},
+				// ignored, as it cannot apply to a kustomize bundle
+				{Bundle: "testBundle", Key: "replicaCount", Value: "3"},
+			},
+			expected: expectedImagesTemplateOutput,
+		},
+	} {
+		appSettings := AppSettings{
+			AppName:              "testApp",
+			ClusterName:          "testCluster",
+			RepoUrl:              "testRepoUrl",
+			RepoPath:             "testRepoPath",
+			RepoRevision:         "HEAD",
+			SrcType:              tc.srcType,
+			AppNamespace:         "argocd",
+			DestinationNamespace: "default",
+		}
+		patches, err := applyOverrides(&appSettings, tc.overrides)
+		if err != nil {
+			t.Fatalf("failed to apply overrides: %s", err)
+		}
+		if len(patches) > 0 {
+			t.Fatalf("unexpected patches: %v", patches)
+		}
+		b := new(strings.Builder)
+		err = tmpl.Execute(b, &appSettings)
+		if err != nil {
+			t.Fatalf("failed to execute template: %s", err)
+		}
+		if b.String() != tc.expected {
+			t.Fatalf("template output doesn't match: %s", b.String())
+		}
+	}
+}
@@ -7,22 +7,25 @@ import (
 	"io"
 	"path"
 	"strings"
-	"text/template"
 
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/log"
 	"github.com/go-git/go-billy"
 	gogit "github.com/go-git/go-git/v5"
 	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
 )
 
 // // -----------------------------------------------------------------------------
 
 type kustomizeyaml struct {
-	APIVersion     string   `yaml:"apiVersion"`
-	Kind           string   `yaml:"kind"`
-	Resources      []string `yaml:"resources"`
-	Configurations []string `yaml:"configurations"`
-	Patches        []string `yaml:"patchesStrategicMerge"`
+	APIVersion     string        `yaml:"apiVersion"`
+	Kind           string        `yaml:"kind"`
+	Resources      []string      `yaml:"resources"`
+	Configurations []string      `yaml:"configurations"`
+	Patches        []string      `yaml:"patchesStrategicMerge"`
+	InlinePatches  []types.Patch `yaml:"patches,omitempty"`
 }
 
 func CopyManifests(wt *gogit.Worktree, fs embed.FS, root string, mgmtPath string) error {
@@ -65,11 +68,47 @@ func CopyManifests(wt *gogit.Worktree, fs embed.FS, root string, mgmtPath string
 
 // -----------------------------------------------------------------------------
 
-func CopyPatchManifests(wt *gogit.Worktree, patchContent []byte, clusterPath string,
-	baseRepoUrl string, baseRepoPath string, baseRepoRevision string) error {
+// KustomizePatches returns the kustomize patches of override patches.
+func KustomizePatches(patches []arlonv1.OverridePatch) []types.Patch {
+	var res []types.Patch
+	for _, p := range patches {
+		kp := types.Patch{Patch: p.Patch}
+		if t := p.Target; t != nil {
+			kp.Target = &types.Selector{
+				ResId: resid.ResId{
+					Gvk:       resid.Gvk{Group: t.Group, Version: t.Version, Kind: t.Kind},
+					Name:      t.Name,
+					Namespace: t.Namespace,
+				},
+				LabelSelector:      t.LabelSelector,
+				AnnotationSelector: t.AnnotationSelector,
+			}
+		}
+		res = append(res, kp)
+	}
+	return res
+}
+
+// CopyPatchManifests writes the override directory of a cluster: a
+// kustomization of the cluster template with the patch and the topology
+// patch, each written only if not empty, and the override patches inline.
+func CopyPatchManifests(wt *gogit.Worktree, patchContent []byte, patches []arlonv1.OverridePatch,
+	topologyPatch []byte, clusterPath string, baseRepoUrl string, baseRepoPath string,
+	baseRepoRevision string) error {
 	log := log.GetLogger()
-	src := bytes.NewReader(patchContent)
-	fileName := "patches.yaml"
+	patchFiles := []struct {
+		name    string
+		content []byte
+	}{
+		{"patches.yaml", patchContent},
+		{"topology.yaml", topologyPatch},
+	}
+	var patchFileNames []string
+	for _, pf := range patchFiles {
+		if len(pf.content) > 0 {
+			patchFileNames = append(patchFileNames, pf.name)
+		}
+	}
 	resourcestring := "git::" + baseRepoUrl + "//" + baseRepoPath + "?ref=" + baseRepoRevision
 	kustomizeresult := kustomizeyaml{
 		APIVersion: "kustomize.config.k8s.io/v1beta1",
@@ -80,19 +119,15 @@ func CopyPatchManifests(wt *gogit.Worktree, patchContent []byte, clusterPath str
 		Configurations: []string{
 			"configurations.yaml",
 		},
-		Patches: []string{
-			fileName,
-		},
+		Patches:       patchFileNames,
+		InlinePatches: KustomizePatches(patches),
 	}
-	var tmpl *template.Template
 	yamlData, err := yaml.Marshal(&kustomizeresult)
 	if err != nil {
 		return fmt.Errorf("Failed to marshal the kustomization file: %s", err)
 	}
-	tmpl, err = template.New("kust").Parse(string(yamlData))
-	if err != nil {
-		return fmt.Errorf("failed to create kustomization template: %s", err)
-	}
+	// the patches may contain template delimiters, for e.g. the cloud-init
+	// variables of kubeadm configurations, so the file is written as is
 	var file billy.File
 	fs := wt.Filesystem
 	file, err = fs.Create(path.Join(clusterPath, "kustomization.yaml"))
@@ -100,23 +135,25 @@ func CopyPatchManifests(wt *gogit.Worktree, patchContent []byte, clusterPath str
 		return fmt.Errorf("failed to create kustomization.yaml: %s", err)
 	}
 	defer file.Close()
-	err = tmpl.Execute(file, yamlData)
-	if err != nil {
-		return fmt.Errorf("failed to execute kustomization.yaml manifest")
-	}
+	_, err = file.Write(yamlData)
 	if err != nil {
 		return fmt.Errorf("failed to write to kustomization.yaml: %s", err)
 	}
-	dstPath := path.Join(clusterPath, fileName)
-	dst, err := wt.Filesystem.Create(dstPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file %s: %s", dstPath, err)
-	}
-	defer dst.Close()
-	_, err = io.Copy(dst, src)
-	if err != nil {
-		return fmt.Errorf("failed to copy embedded file: %s", err)
+	for _, pf := range patchFiles {
+		if len(pf.content) == 0 {
+			continue
+		}
+		dstPath := path.Join(clusterPath, pf.name)
+		dst, err := wt.Filesystem.Create(dstPath)
+		if err != nil {
+			return fmt.Errorf("failed to create destination file %s: %s", dstPath, err)
+		}
+		_, err = io.Copy(dst, bytes.NewReader(pf.content))
+		_ = dst.Close()
+		if err != nil {
+			return fmt.Errorf("failed to copy embedded file: %s", err)
+		}
+		log.V(1).Info("copied embedded file", "destination", dstPath)
 	}
-	log.V(1).Info("copied embedded file", "destination", dstPath)
 	return nil
 }
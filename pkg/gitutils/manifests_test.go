@@ -0,0 +1,67 @@
+package gitutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const expectedPatchKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- git::https://github.com/org/templates.git//aws?ref=main
+configurations:
+- configurations.yaml
+patchesStrategicMerge:
+- patches.yaml
+patches:
+- patch: |
+    - op: add
+      path: /spec/template/spec/preKubeadmCommands/-
+      value: hostname {{ ds.meta_data.local_hostname }}
+  target:
+    group: bootstrap.cluster.x-k8s.io
+    kind: KubeadmConfigTemplate
+    labelSelector: pool=workers
+- patch: |
+    kind: AWSCluster
+    metadata:
+      name: capi-quickstart
+    spec:
+      region: eu-west-1
+`
+
+func TestCopyPatchManifests(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	assert.NoError(t, err)
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	patches := []arlonv1.OverridePatch{
+		{
+			Type: arlonv1.PatchTypeJSON6902,
+			Target: &arlonv1.KustomizeTarget{
+				Group:         "bootstrap.cluster.x-k8s.io",
+				Kind:          "KubeadmConfigTemplate",
+				LabelSelector: "pool=workers",
+			},
+			Patch: "- op: add\n  path: /spec/template/spec/preKubeadmCommands/-\n" +
+				"  value: hostname {{ ds.meta_data.local_hostname }}\n",
+		},
+		{Patch: "kind: AWSCluster\nmetadata:\n  name: capi-quickstart\nspec:\n  region: eu-west-1\n"},
+	}
+	err = CopyPatchManifests(wt, []byte("kind: Cluster\n"), patches, nil, "clusters/c1",
+		"https://github.com/org/templates.git", "aws", "main")
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "clusters/c1/kustomization.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, expectedPatchKustomization, string(data))
+	_, err = os.Stat(filepath.Join(dir, "clusters/c1/patches.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "clusters/c1/topology.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
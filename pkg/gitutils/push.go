@@ -0,0 +1,113 @@
+package gitutils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/gitprovider"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// FeatureBranchPrefix is the prefix of the branches created in pull request
+// write mode.
+const FeatureBranchPrefix = "arlon/"
+
+// PushChanges pushes the commits of the local branch to the remote
+// repository, according to the write mode of the repository's credentials.
+// In push mode, the commits are pushed to the same branch of the remote.
+// In pull request mode, they are pushed to a feature branch named after the
+// tree of the last commit, and a pull request is opened against the branch
+// unless one is already open for the feature branch, so that retrying a
+// write reuses the pull request of the previous attempt. The title of the
+// pull request is the message of the last commit. The URL of the pull
+// request is returned, or an empty string in push mode.
+func PushChanges(
+	repo *gogit.Repository,
+	creds *argocd.RepoCreds,
+	auth transport.AuthMethod,
+	branch string,
+) (prUrl string, err error) {
+	if creds.WriteMode != argocd.GitWriteModePullRequest {
+		err = repo.Push(&gogit.PushOptions{
+			RemoteName: gogit.DefaultRemoteName,
+			Auth:       auth,
+			Progress:   nil,
+			CABundle:   nil,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to push to remote repository: %s", err)
+		}
+		return "", nil
+	}
+	repoUrl := creds.Url
+	if repoUrl == "" {
+		remote, err := repo.Remote(gogit.DefaultRemoteName)
+		if err != nil {
+			return "", fmt.Errorf("failed to get remote: %s", err)
+		}
+		repoUrl = remote.Config().URLs[0]
+	}
+	token := creds.GitProviderToken
+	if basicAuth, ok := auth.(*githttp.BasicAuth); ok && token == "" {
+		token = basicAuth.Password
+	}
+	if token == "" {
+		return "", fmt.Errorf("no token to open pull requests against %s, set %s in its repository secret",
+			repoUrl, argocd.GitProviderTokenKey)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get head of local repository: %s", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to get head commit: %s", err)
+	}
+	// a retried write has the same tree, the branch is replaced
+	featureBranch := FeatureBranchPrefix + commit.TreeHash.String()[:12]
+	refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", head.Name(),
+		plumbing.NewBranchReferenceName(featureBranch)))
+	err = repo.Push(&gogit.PushOptions{
+		RemoteName: gogit.DefaultRemoteName,
+		RefSpecs:   []config.RefSpec{refSpec},
+		Auth:       auth,
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return "", fmt.Errorf("failed to push branch %s to remote repository: %s",
+			featureBranch, err)
+	}
+	provider, err := gitprovider.New(&gitprovider.Config{
+		Type:   creds.GitProvider,
+		ApiUrl: creds.GitProviderApiUrl,
+		Token:  token,
+	}, repoUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to get git provider: %s", err)
+	}
+	pr := &gitprovider.PullRequest{
+		RepoUrl:      repoUrl,
+		SourceBranch: featureBranch,
+		TargetBranch: branch,
+		Title:        commit.Message,
+		Description:  fmt.Sprintf("Changes generated by arlon in commit %s.", head.Hash()),
+	}
+	prUrl, err = provider.FindPullRequest(context.Background(), pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up pull request for branch %s: %s",
+			featureBranch, err)
+	}
+	if prUrl != "" {
+		return prUrl, nil
+	}
+	prUrl, err = provider.CreatePullRequest(context.Background(), pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to open pull request for branch %s: %s",
+			featureBranch, err)
+	}
+	return prUrl, nil
+}
@@ -0,0 +1,89 @@
+package gitutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/gitprovider"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushChangesPullRequest(t *testing.T) {
+	// remote repository with an initial commit on master
+	remote, repoUrl := initRemoteRepo(t)
+	initialHead, err := remote.Head()
+	assert.NoError(t, err)
+
+	fake := gitprovider.RegisterFake("fake")
+	creds := &argocd.RepoCreds{
+		WriteMode:        argocd.GitWriteModePullRequest,
+		GitProvider:      "fake",
+		GitProviderToken: "secret",
+	}
+	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, "master")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "cluster.yaml"), []byte("kind: Cluster"), 0600))
+	wt, err := repo.Worktree()
+	assert.NoError(t, err)
+	_, err = CommitChanges(tmpDir, wt, "deploy arlon cluster c1")
+	assert.NoError(t, err)
+
+	prUrl, err := PushChanges(repo, creds, auth, "master")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, prUrl)
+	assert.Len(t, fake.PullRequests, 1)
+	pr := fake.PullRequests[0]
+	assert.Equal(t, "master", pr.TargetBranch)
+	assert.True(t, strings.HasPrefix(pr.SourceBranch, FeatureBranchPrefix))
+	assert.Equal(t, "deploy arlon cluster c1", pr.Title)
+
+	// the target branch is unchanged, and the feature branch has the commit
+	masterRef, err := remote.Reference(plumbing.NewBranchReferenceName("master"), true)
+	assert.NoError(t, err)
+	assert.Equal(t, initialHead.Hash(), masterRef.Hash())
+	featureRef, err := remote.Reference(plumbing.NewBranchReferenceName(pr.SourceBranch), true)
+	assert.NoError(t, err)
+	localHead, err := repo.Head()
+	assert.NoError(t, err)
+	assert.Equal(t, localHead.Hash(), featureRef.Hash())
+
+	// a retried write with the same changes reuses the pull request
+	retryRepo, retryDir, _, err := argocd.CloneRepo(creds, repoUrl, "master")
+	assert.NoError(t, err)
+	defer os.RemoveAll(retryDir)
+	assert.NoError(t, os.WriteFile(filepath.Join(retryDir, "cluster.yaml"), []byte("kind: Cluster"), 0600))
+	retryWt, err := retryRepo.Worktree()
+	assert.NoError(t, err)
+	_, err = CommitChanges(retryDir, retryWt, "deploy arlon cluster c1 again")
+	assert.NoError(t, err)
+	retryUrl, err := PushChanges(retryRepo, creds, auth, "master")
+	assert.NoError(t, err)
+	assert.Equal(t, prUrl, retryUrl)
+	assert.Len(t, fake.PullRequests, 1)
+	retryHead, err := retryRepo.Head()
+	assert.NoError(t, err)
+	featureRef, err = remote.Reference(plumbing.NewBranchReferenceName(pr.SourceBranch), true)
+	assert.NoError(t, err)
+	assert.Equal(t, retryHead.Hash(), featureRef.Hash())
+}
+
+func TestPushChangesPullRequestNoToken(t *testing.T) {
+	_, repoUrl := initRemoteRepo(t)
+	fake := gitprovider.RegisterFake("fake")
+	// for e.g. a repository with an SSH private key
+	creds := &argocd.RepoCreds{
+		WriteMode:   argocd.GitWriteModePullRequest,
+		GitProvider: "fake",
+	}
+	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, "master")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	_, err = PushChanges(repo, creds, auth, "master")
+	assert.ErrorContains(t, err, argocd.GitProviderTokenKey)
+	assert.Empty(t, fake.PullRequests)
+}
@@ -0,0 +1,128 @@
+package gitutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	logpkg "github.com/arlonproj/arlon/pkg/log"
+	gogit "github.com/go-git/go-git/v5"
+)
+
+// ChangeFunc applies a change to the worktree of a fresh clone of a
+// repository. rootDir is the path of the worktree on the local file system.
+// It returns the commit message for the change.
+type ChangeFunc func(wt *gogit.Worktree, rootDir string) (commitMsg string, err error)
+
+var (
+	// MaxWriteAttempts is the number of times WriteToGit applies a change
+	// before giving up on push conflicts
+	MaxWriteAttempts = 5
+	// WriteRetryDelay is the delay before the first retry. It doubles
+	// with every attempt.
+	WriteRetryDelay = 500 * time.Millisecond
+)
+
+// repoLocks serializes the writes of this process to the same branch of a
+// repository, which would otherwise conflict with each other.
+var repoLocks sync.Map
+
+func lockRepo(repoUrl string, branch string) func() {
+	key := strings.TrimSuffix(strings.ToLower(repoUrl), ".git") + "#" + branch
+	mu, _ := repoLocks.LoadOrStore(key, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// -----------------------------------------------------------------------------
+
+// WriteToGit clones the branch of a repository, applies the change, commits
+// it and pushes it using PushChanges. If the push is rejected because the
+// remote branch has moved since the clone, for e.g. because another writer
+// pushed in between, the change is replayed on top of a new clone of the
+// branch, up to MaxWriteAttempts times. The change must therefore regenerate
+// its files from scratch rather than depend on the state of a previous
+// attempt. It returns whether the change resulted in a commit, and the URL of
+// the pull request in pull request write mode.
+func WriteToGit(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	branch string,
+	change ChangeFunc,
+) (changed bool, prUrl string, err error) {
+	log := logpkg.GetLogger()
+	unlock := lockRepo(repoUrl, branch)
+	defer unlock()
+	delay := WriteRetryDelay
+	for attempt := 1; ; attempt++ {
+		changed, prUrl, err = writeOnce(creds, repoUrl, branch, change)
+		if err == nil || !IsPushConflict(err) || attempt >= MaxWriteAttempts {
+			return
+		}
+		log.Info("push conflict, replaying change on latest revision",
+			"repoUrl", repoUrl, "branch", branch, "attempt", attempt, "error", err.Error())
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func writeOnce(
+	creds *argocd.RepoCreds,
+	repoUrl string,
+	branch string,
+	change ChangeFunc,
+) (changed bool, prUrl string, err error) {
+	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, branch)
+	if tmpDir != "" {
+		defer os.RemoveAll(tmpDir)
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed to clone repo: %s", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get repo worktree: %s", err)
+	}
+	commitMsg, err := change(wt, tmpDir)
+	if err != nil {
+		return false, "", err
+	}
+	changed, err = CommitChanges(tmpDir, wt, commitMsg)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to commit changes: %s", err)
+	}
+	if !changed {
+		return false, "", nil
+	}
+	prUrl, err = PushChanges(repo, creds, auth, branch)
+	if err != nil {
+		return false, "", err
+	}
+	return true, prUrl, nil
+}
+
+// -----------------------------------------------------------------------------
+
+// IsPushConflict returns true if a push error indicates that the remote
+// branch has commits that are missing from the local branch.
+func IsPushConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{
+		"non-fast-forward",
+		"fetch first",
+		"failed to update ref",
+		"cannot lock ref",
+		"stale info",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package gitutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/gitcache"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func initRemoteRepo(t *testing.T) (*gogit.Repository, string) {
+	gitcache.Dir = t.TempDir()
+	remoteDir := t.TempDir()
+	remote, err := gogit.PlainInit(remoteDir, false)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(remoteDir, "README.md"), []byte("hello"), 0600))
+	wt, err := remote.Worktree()
+	assert.NoError(t, err)
+	_, err = CommitChanges(remoteDir, wt, "initial commit")
+	assert.NoError(t, err)
+	return remote, "file://" + remoteDir
+}
+
+func writeFile(fileName string, data string) ChangeFunc {
+	return func(wt *gogit.Worktree, rootDir string) (string, error) {
+		err := os.WriteFile(filepath.Join(rootDir, fileName), []byte(data), 0600)
+		return "write " + fileName, err
+	}
+}
+
+func headFiles(t *testing.T, repo *gogit.Repository) []string {
+	head, err := repo.Head()
+	assert.NoError(t, err)
+	commit, err := repo.CommitObject(head.Hash())
+	assert.NoError(t, err)
+	tree, err := commit.Tree()
+	assert.NoError(t, err)
+	var files []string
+	_ = tree.Files().ForEach(func(f *object.File) error {
+		files = append(files, f.Name)
+		return nil
+	})
+	return files
+}
+
+func TestWriteToGitReplaysOnConflict(t *testing.T) {
+	WriteRetryDelay = time.Millisecond
+	remote, repoUrl := initRemoteRepo(t)
+	creds := &argocd.RepoCreds{}
+	attempts := 0
+	changed, prUrl, err := WriteToGit(creds, repoUrl, "master",
+		func(wt *gogit.Worktree, rootDir string) (string, error) {
+			attempts++
+			if attempts == 1 {
+				// another writer pushes after our clone
+				_, _, err := writeOnce(creds, repoUrl, "master", writeFile("other.yaml", "other"))
+				assert.NoError(t, err)
+			}
+			return writeFile("cluster.yaml", "cluster")(wt, rootDir)
+		})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Empty(t, prUrl)
+	assert.Equal(t, 2, attempts)
+	assert.ElementsMatch(t, []string{"README.md", "other.yaml", "cluster.yaml"}, headFiles(t, remote))
+
+	// writing the same content again results in no commit
+	changed, _, err = WriteToGit(creds, repoUrl, "master", writeFile("cluster.yaml", "cluster"))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestWriteToGitConcurrent(t *testing.T) {
+	remote, repoUrl := initRemoteRepo(t)
+	creds := &argocd.RepoCreds{}
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	expected := []string{"README.md"}
+	for i := range errs {
+		fileName := fmt.Sprintf("cluster-%d.yaml", i)
+		expected = append(expected, fileName)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = WriteToGit(creds, repoUrl, "master", writeFile(fileName, "data"))
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.ElementsMatch(t, expected, headFiles(t, remote))
+}
+
+func TestIsPushConflict(t *testing.T) {
+	assert.True(t, IsPushConflict(fmt.Errorf("failed to push to remote repository: non-fast-forward update: refs/heads/main")))
+	assert.True(t, IsPushConflict(fmt.Errorf("command error on refs/heads/main: failed to update ref")))
+	assert.False(t, IsPushConflict(fmt.Errorf("authentication required")))
+	assert.False(t, IsPushConflict(nil))
+}
@@ -0,0 +1,153 @@
+// Package helmvalues merges Helm values and sets Helm parameters in them the
+// way helm does with values files and --set.
+package helmvalues
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Merge merges src into dst the way Helm merges values files: maps are
+// merged recursively, any other value replaces the existing one.
+func Merge(dst map[string]interface{}, src map[string]interface{}) {
+	for key, srcVal := range src {
+		srcMap, srcIsMap := srcVal.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			Merge(dstMap, srcMap)
+		} else {
+			dst[key] = srcVal
+		}
+	}
+}
+
+// maxParameterIndex is the largest list index of a parameter name, like
+// the one of helm's --set, so that a typo can't allocate a huge list.
+const maxParameterIndex = 65536
+
+// pathElem is an element of the path of a parameter: the key of a map
+// value, or the index of a list value if index is not negative.
+type pathElem struct {
+	key   string
+	index int
+}
+
+// ValidateParameterName checks that a parameter name is a path like the
+// ones of helm's --set.
+func ValidateParameterName(name string) error {
+	_, err := parseParameterName(name)
+	return err
+}
+
+// parseParameterName splits a parameter name like the ones of helm's
+// --set into the elements of its path: keys separated by dots, in which a
+// dot may be escaped as \., and list indices like in a[0].b.
+func parseParameterName(name string) ([]pathElem, error) {
+	var path []pathElem
+	var key strings.Builder
+	// afterIndex is set right after a list index, which must be followed by
+	// a dot, another index or the end of the name
+	afterIndex := false
+	endKey := func() error {
+		if key.Len() == 0 {
+			return fmt.Errorf("empty key")
+		}
+		path = append(path, pathElem{key: key.String(), index: -1})
+		key.Reset()
+		return nil
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if afterIndex && c != '.' && c != '[' {
+			return nil, fmt.Errorf("unexpected %q after list index", c)
+		}
+		switch c {
+		case '\\':
+			if i+1 == len(name) {
+				return nil, fmt.Errorf("trailing escape character")
+			}
+			i++
+			key.WriteByte(name[i])
+		case '.':
+			if afterIndex {
+				afterIndex = false
+			} else if err := endKey(); err != nil {
+				return nil, err
+			}
+			if i+1 == len(name) {
+				return nil, fmt.Errorf("empty key")
+			}
+		case '[':
+			if !afterIndex {
+				if err := endKey(); err != nil {
+					return nil, err
+				}
+			}
+			end := strings.IndexByte(name[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated list index")
+			}
+			index, err := strconv.Atoi(name[i+1 : i+end])
+			if err != nil || index < 0 || index > maxParameterIndex {
+				return nil, fmt.Errorf("invalid list index %s", name[i+1:i+end])
+			}
+			path = append(path, pathElem{index: index})
+			i += end
+			afterIndex = true
+		default:
+			key.WriteByte(c)
+		}
+	}
+	if !afterIndex {
+		if err := endKey(); err != nil {
+			return nil, err
+		}
+	}
+	return path, nil
+}
+
+// SetParameter sets the value at the path of a parameter, interpreting
+// booleans and numbers like helm's --set unless forceString is set.
+func SetParameter(values map[string]interface{}, name string, value string, forceString bool) error {
+	path, err := parseParameterName(name)
+	if err != nil {
+		return fmt.Errorf("invalid parameter name %s: %s", name, err)
+	}
+	var typed interface{} = value
+	if !forceString {
+		if value == "true" || value == "false" {
+			typed = value == "true"
+		} else if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			typed = i
+		} else if value == "null" {
+			typed = nil
+		}
+	}
+	values[path[0].key] = setPath(values[path[0].key], path[1:], typed)
+	return nil
+}
+
+// setPath sets the value at a path in current, and returns current or
+// its replacement if it is not a map or a list as expected by the path.
+// Like with helm's --set, a list is padded with nulls up to an index.
+func setPath(current interface{}, path []pathElem, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	elem := path[0]
+	if elem.index < 0 {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		m[elem.key] = setPath(m[elem.key], path[1:], value)
+		return m
+	}
+	list, _ := current.([]interface{})
+	for len(list) <= elem.index {
+		list = append(list, nil)
+	}
+	list[elem.index] = setPath(list[elem.index], path[1:], value)
+	return list
+}
@@ -0,0 +1,33 @@
+package helmvalues
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetParameter(t *testing.T) {
+	values := map[string]interface{}{}
+	Merge(values, map[string]interface{}{
+		"image": map[string]interface{}{"repository": "nginx", "tag": "1.23"},
+	})
+	assert.NoError(t, SetParameter(values, "image.tag", "1.25", true))
+	assert.NoError(t, SetParameter(values, "replicaCount", "2", false))
+	assert.NoError(t, SetParameter(values, "ingress.hosts[1].host", "example.com", false))
+	assert.NoError(t, SetParameter(values, `nodeSelector.kubernetes\.io/os`, "linux", false))
+	assert.NoError(t, SetParameter(values, "matrix[0][1]", "true", false))
+	assert.Equal(t, map[string]interface{}{
+		"image":        map[string]interface{}{"repository": "nginx", "tag": "1.25"},
+		"replicaCount": int64(2),
+		"ingress": map[string]interface{}{
+			"hosts": []interface{}{nil, map[string]interface{}{"host": "example.com"}},
+		},
+		"nodeSelector": map[string]interface{}{"kubernetes.io/os": "linux"},
+		"matrix":       []interface{}{[]interface{}{nil, true}},
+	}, values)
+
+	for _, name := range []string{"", "a..b", ".a", "a.", "[0]", "a[x]", "a[0", "a[0]b", "a[-1]", "a[65537]", `a\`} {
+		assert.Error(t, ValidateParameterName(name), name)
+		assert.Error(t, SetParameter(values, name, "1", false), name)
+	}
+}
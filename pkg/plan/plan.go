@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	gyaml "github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Plan holds the changes that an operation would make to git repositories
+// and to Argo CD, as unified diffs, instead of making them.
+type Plan struct {
+	Changes []Change
+}
+
+// Change is the diff of one git repository branch or one object.
+type Change struct {
+	// Kind is "Git" for a repository branch, or the kind of the object
+	Kind string
+	// Name is the URL and branch of a repository, or the name of the object
+	Name string
+	Diff string
+}
+
+// AddGitDiff records the diff of the files of a repository branch.
+// An empty diff is ignored.
+func (p *Plan) AddGitDiff(repoUrl string, branch string, diff string) {
+	if diff == "" {
+		return
+	}
+	p.Changes = append(p.Changes, Change{
+		Kind: "Git",
+		Name: fmt.Sprintf("%s (%s)", repoUrl, branch),
+		Diff: diff,
+	})
+}
+
+// AddObjectDiff records the change of an object from before to after, both
+// serialized to YAML. A nil before is a creation, and a nil after a deletion.
+// Nothing is recorded if the object is unchanged.
+func (p *Plan) AddObjectDiff(kind string, name string, before interface{}, after interface{}) error {
+	beforeYaml, err := toYaml(before)
+	if err != nil {
+		return err
+	}
+	afterYaml, err := toYaml(after)
+	if err != nil {
+		return err
+	}
+	if beforeYaml == afterYaml {
+		return nil
+	}
+	ud := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(strings.TrimSuffix(beforeYaml, "\n")),
+		B:        difflib.SplitLines(strings.TrimSuffix(afterYaml, "\n")),
+		FromFile: "a/" + kind + "/" + name,
+		ToFile:   "b/" + kind + "/" + name,
+		Context:  3,
+	}
+	if before == nil {
+		ud.A = nil
+		ud.FromFile = "/dev/null"
+	}
+	if after == nil {
+		ud.B = nil
+		ud.ToFile = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s %s: %s", kind, name, err)
+	}
+	p.Changes = append(p.Changes, Change{Kind: kind, Name: name, Diff: diff})
+	return nil
+}
+
+// AddApplicationDiff records the change of an Argo CD application, limited
+// to the fields that arlon sets: labels, annotations and spec.
+func (p *Plan) AddApplicationDiff(before *argoappv1.Application, after *argoappv1.Application) error {
+	var name string
+	var beforeObj, afterObj interface{}
+	if before != nil {
+		name = before.Name
+		beforeObj = appView(before)
+	}
+	if after != nil {
+		name = after.Name
+		afterObj = appView(after)
+	}
+	return p.AddObjectDiff("Application", name, beforeObj, afterObj)
+}
+
+func appView(app *argoappv1.Application) interface{} {
+	metadata := map[string]interface{}{
+		"name":      app.Name,
+		"namespace": app.Namespace,
+	}
+	if len(app.Labels) > 0 {
+		metadata["labels"] = app.Labels
+	}
+	if len(app.Annotations) > 0 {
+		metadata["annotations"] = app.Annotations
+	}
+	return map[string]interface{}{
+		"metadata": metadata,
+		"spec":     app.Spec,
+	}
+}
+
+// Empty returns true if the plan has no change.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Print writes the changes of the plan, each one preceded by a comment line
+// naming the changed repository or object.
+func (p *Plan) Print(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "# no changes")
+		return err
+	}
+	for _, c := range p.Changes {
+		if _, err := fmt.Fprintf(w, "# %s %s\n%s", c.Kind, c.Name, c.Diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toYaml(obj interface{}) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	data, err := gyaml.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize object: %s", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,73 @@
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPlan(t *testing.T) {
+	var pl Plan
+	var buf bytes.Buffer
+	assert.NoError(t, pl.Print(&buf))
+	assert.Equal(t, "# no changes\n", buf.String())
+
+	pl.AddGitDiff("https://github.com/org/repo", "main", "")
+	assert.NoError(t, pl.AddObjectDiff("Profile", "p1", map[string]string{"a": "1"}, map[string]string{"a": "1"}))
+	assert.True(t, pl.Empty())
+
+	pl.AddGitDiff("https://github.com/org/repo", "main", "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n+b\n")
+	before := &argoappv1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "c1",
+			Namespace:   "argocd",
+			Annotations: map[string]string{"arlon.io/profiles": "qa"},
+			// not part of the diff
+			ResourceVersion: "42",
+		},
+	}
+	after := before.DeepCopy()
+	after.ResourceVersion = ""
+	after.Annotations["arlon.io/profiles"] = "qa,prod"
+	assert.NoError(t, pl.AddApplicationDiff(before, after))
+	assert.NoError(t, pl.AddApplicationDiff(nil, &argoappv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1-profile-p1", Namespace: "argocd"},
+	}))
+	assert.Len(t, pl.Changes, 3)
+
+	buf.Reset()
+	assert.NoError(t, pl.Print(&buf))
+	assert.Equal(t, `# Git https://github.com/org/repo (main)
+--- a/f
++++ b/f
+@@ -1 +1 @@
+-a
++b
+# Application c1
+--- a/Application/c1
++++ b/Application/c1
+@@ -1,6 +1,6 @@
+ metadata:
+   annotations:
+-    arlon.io/profiles: qa
++    arlon.io/profiles: qa,prod
+   name: c1
+   namespace: argocd
+ spec:
+# Application c1-profile-p1
+--- /dev/null
++++ b/Application/c1-profile-p1
+@@ -0,0 +1,8 @@
++metadata:
++  name: c1-profile-p1
++  namespace: argocd
++spec:
++  destination: {}
++  project: ""
++  source:
++    repoURL: ""
+`, buf.String())
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/arlonproj/arlon/pkg/bundle"
 	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"path"
 )
 
+// Create creates a profile. In pull request write mode, the URL of the pull
+// request opened for the files of a dynamic profile is returned.
 func Create(
 	config *restclient.Config,
 	argocdNs string,
@@ -25,23 +28,34 @@ func Create(
 	desc string,
 	tags string,
 	overrides []arlonv1.Override,
-) error {
+	versions []arlonv1.BundleVersion,
+	dependencies []arlonv1.BundleDependency,
+) (prUrl string, err error) {
 	for _, name := range bundles {
 		if !bundle.IsValidK8sName(name) {
-			return fmt.Errorf("%w: %s", bundle.ErrInvalidName, name)
+			return "", fmt.Errorf("%w: %s", bundle.ErrInvalidName, name)
 		}
 	}
+	for _, bv := range versions {
+		if !isSubset([]string{bv.Bundle}, bundles) {
+			return "", fmt.Errorf("pinned bundle %s is not in the bundle list", bv.Bundle)
+		}
+	}
+	errs := bundle.ValidateDependencies(bundles, dependencies, field.NewPath("dependencies"))
+	if len(errs) > 0 {
+		return "", fmt.Errorf("invalid bundle dependencies: %s", errs.ToAggregate())
+	}
 	cli, err := ctrlruntimeclient.NewClient(config)
 	if err != nil {
-		return fmt.Errorf("failed to get controller runtime client: %s", err)
+		return "", fmt.Errorf("failed to get controller runtime client: %s", err)
 	}
-	bundlesList, err := bundle.List(config, arlonNs)
+	bundlesList, err := bundle.List(cli, arlonNs)
 	if err != nil {
-		return err
+		return "", err
 	}
 	existingBundleNames := bundleListToNameSlice(bundlesList)
 	if !isSubset(bundles, existingBundleNames) {
-		return ErrMissingBundles
+		return "", ErrMissingBundles
 	}
 	var repoPath string
 	if repoUrl == "" {
@@ -56,37 +70,46 @@ func Create(
 			Namespace: arlonNs,
 		},
 		Spec: arlonv1.ProfileSpec{
-			Description:  desc,
-			Bundles:      bundles,
-			Tags:         tagList,
-			RepoUrl:      repoUrl,
-			RepoPath:     repoPath,
-			RepoRevision: repoRevision,
-			Overrides:    overrides,
+			Description:        desc,
+			Bundles:            bundles,
+			Tags:               tagList,
+			RepoUrl:            repoUrl,
+			RepoPath:           repoPath,
+			RepoRevision:       repoRevision,
+			Overrides:          overrides,
+			BundleVersions:     versions,
+			BundleDependencies: dependencies,
 		},
 	}
-	if repoUrl != "" {
-		kubeClient, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			return fmt.Errorf("failed to get kubernetes client: %s", err)
-		}
-		corev1 := kubeClient.CoreV1()
-		bundles, err := bundle.GetBundlesFromProfile(&p, corev1, arlonNs)
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to get kubernetes client: %s", err)
+	}
+	var profBundles []bundle.Bundle
+	if repoUrl != "" || len(overrides) > 0 {
+		profBundles, err = bundle.GetBundlesFromProfile(&p, cli, arlonNs)
 		if err != nil {
-			return fmt.Errorf("failed to get bundles: %s", err)
+			return "", fmt.Errorf("failed to get bundles: %s", err)
 		}
+	}
+	errs = bundle.ValidateOverrides(profBundles, overrides, bundleSource(kubeClient, argocdNs),
+		field.NewPath("overrides"))
+	if len(errs) > 0 {
+		return "", fmt.Errorf("invalid overrides: %s", errs.ToAggregate())
+	}
+	if repoUrl != "" {
 		creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, repoUrl)
 		if err != nil {
-			return fmt.Errorf("failed to get repository credentials: %s", err)
+			return "", fmt.Errorf("failed to get repository credentials: %s", err)
 		}
-		err = createInGit(creds, &p, argocdNs, bundles)
+		prUrl, err = createInGit(creds, &p, argocdNs, profBundles)
 		if err != nil {
-			return fmt.Errorf("failed to create dynamic profile in git: %s", err)
+			return "", fmt.Errorf("failed to create dynamic profile in git: %s", err)
 		}
 	}
 	err = cli.Create(context.Background(), &p)
 	if err != nil {
-		return fmt.Errorf("failed to create profile configmap: %s", err)
+		return "", fmt.Errorf("failed to create profile configmap: %s", err)
 	}
-	return nil
+	return prUrl, nil
 }
@@ -6,7 +6,6 @@ import (
 	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/bundle"
-	"github.com/arlonproj/arlon/pkg/common"
 	"github.com/arlonproj/arlon/pkg/gitutils"
 	"github.com/arlonproj/arlon/pkg/log"
 	gogit "github.com/go-git/go-git/v5"
@@ -16,78 +15,83 @@ import (
 //go:embed manifests/*
 var content embed.FS
 
+// createInGit writes the files of a dynamic profile to git. In pull request
+// write mode, the URL of the pull request is returned.
 func createInGit(
 	creds *argocd.RepoCreds,
 	profile *arlonv1.Profile,
 	arlonNs string,
 	bundles []bundle.Bundle,
-) error {
+) (prUrl string, err error) {
 	log := log.GetLogger()
-	repoUrl := profile.Spec.RepoUrl
 	repoPath := profile.Spec.RepoPath
-	repoRevision := profile.Spec.RepoRevision
-	repo, tmpDir, auth, err := argocd.CloneRepo(creds, repoUrl, repoRevision)
+	changed, prUrl, err := gitutils.WriteToGit(creds, profile.Spec.RepoUrl,
+		profile.Spec.RepoRevision, createChange(profile, bundles))
 	if err != nil {
-		return fmt.Errorf("failed to clone repo: %s", err)
+		return "", err
 	}
-	wt, err := repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get repo worktree: %s", err)
+	if !changed {
+		log.Info("no changed files, skipping commit & push")
+		return "", nil
 	}
-	// remove old data if directory exists, we'll regenerate everything
-	fileInfo, err := wt.Filesystem.Lstat(repoPath)
-	if err == nil {
-		if !fileInfo.IsDir() {
-			return fmt.Errorf("unexpected file type for %s", repoPath)
+	if prUrl != "" {
+		log.Info("opened pull request", "prUrl", prUrl)
+	}
+	log.V(1).Info("successfully pushed working tree", "repoPath", repoPath)
+	return prUrl, nil
+}
+
+// planInGit returns the diff of the files that createInGit would change,
+// without changing them.
+func planInGit(
+	creds *argocd.RepoCreds,
+	profile *arlonv1.Profile,
+	bundles []bundle.Bundle,
+) (string, error) {
+	return gitutils.PlanToGit(creds, profile.Spec.RepoUrl,
+		profile.Spec.RepoRevision, createChange(profile, bundles))
+}
+
+// createChange regenerates the directory of a dynamic profile in git.
+func createChange(profile *arlonv1.Profile, bundles []bundle.Bundle) gitutils.ChangeFunc {
+	repoUrl := profile.Spec.RepoUrl
+	repoPath := profile.Spec.RepoPath
+	return func(wt *gogit.Worktree, _ string) (string, error) {
+		// remove old data if directory exists, we'll regenerate everything
+		fileInfo, err := wt.Filesystem.Lstat(repoPath)
+		if err == nil {
+			if !fileInfo.IsDir() {
+				return "", fmt.Errorf("unexpected file type for %s", repoPath)
+			}
+			_, err = wt.Remove(repoPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to recursively delete cluster directory: %s", err)
+			}
 		}
-		_, err = wt.Remove(repoPath)
+		mgmtPath := path.Join(repoPath, "mgmt")
+		err = gitutils.CopyManifests(wt, content, ".", mgmtPath)
 		if err != nil {
-			return fmt.Errorf("failed to recursively delete cluster directory: %s", err)
+			return "", fmt.Errorf("failed to copy embedded content: %s", err)
 		}
+		workloadPath := path.Join(repoPath, "workload")
+		om := MakeOverridesMap(profile)
+		err = gitutils.ProcessBundles(wt, "{{ .Values.clusterName }}", repoUrl,
+			mgmtPath, workloadPath, bundles, om)
+		if err != nil {
+			return "", fmt.Errorf("failed to process bundles: %s", err)
+		}
+		return "manage arlon profile " + repoPath, nil
 	}
-	mgmtPath := path.Join(repoPath, "mgmt")
-	err = gitutils.CopyManifests(wt, content, ".", mgmtPath)
-	if err != nil {
-		return fmt.Errorf("failed to copy embedded content: %s", err)
-	}
-	workloadPath := path.Join(repoPath, "workload")
-	om := MakeOverridesMap(profile)
-	err = gitutils.ProcessBundles(wt, "{{ .Values.clusterName }}", repoUrl,
-		mgmtPath, workloadPath, bundles, om)
-	if err != nil {
-		return fmt.Errorf("failed to process bundles: %s", err)
-	}
-	changed, err := gitutils.CommitChanges(tmpDir, wt, "manage arlon profile "+repoPath)
-	if err != nil {
-		return fmt.Errorf("failed to commit changes: %s", err)
-	}
-	if !changed {
-		log.Info("no changed files, skipping commit & push")
-		return nil
-	}
-	err = repo.Push(&gogit.PushOptions{
-		RemoteName: gogit.DefaultRemoteName,
-		Auth:       auth,
-		Progress:   nil,
-		CABundle:   nil,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to push to remote repository: %s", err)
-	}
-	log.V(1).Info("successfully pushed working tree", "tmpDir", tmpDir)
-	return nil
 }
 
-func MakeOverridesMap(profile *arlonv1.Profile) (om common.KVPairMap) {
+// MakeOverridesMap returns the overrides of a profile by bundle name.
+func MakeOverridesMap(profile *arlonv1.Profile) (om map[string][]arlonv1.Override) {
 	if len(profile.Spec.Overrides) == 0 {
 		return
 	}
-	om = make(common.KVPairMap)
+	om = make(map[string][]arlonv1.Override)
 	for _, item := range profile.Spec.Overrides {
-		om[item.Bundle] = append(om[item.Bundle], common.KVPair{
-			Key:   item.Key,
-			Value: item.Value,
-		})
+		om[item.Bundle] = append(om[item.Bundle], item)
 	}
 	return
 }
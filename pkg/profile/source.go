@@ -0,0 +1,246 @@
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/gitcache"
+	gyaml "github.com/ghodss/yaml"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxDownloadSize limits the size of the index of a Helm repository and of a chart
+const maxDownloadSize = 64 << 20
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// errUnauthorized is returned when a Helm repository requires credentials
+var errUnauthorized = errors.New("unauthorized")
+
+// bundleSource returns a function inspecting the sources of dynamic bundles,
+// with the credentials of git repositories registered in Argo CD. Charts in
+// OCI registries and in Helm repositories requiring credentials are not
+// inspected: the overrides of their bundle are only checked against the
+// bundle's type. Any other source that cannot be inspected is an error.
+func bundleSource(kubeClient kubernetes.Interface, argocdNs string) bundle.SourceFunc {
+	return func(b *bundle.Bundle) (*bundle.Source, error) {
+		if b.Chart != "" {
+			return chartSource(b)
+		}
+		return gitSource(kubeClient, argocdNs, b)
+	}
+}
+
+// gitSource inspects the directory of a bundle in a git repository, checking
+// out only the directory through the git cache. The revision of the bundle
+// must be a branch, or HEAD for the default branch.
+func gitSource(kubeClient kubernetes.Interface, argocdNs string, b *bundle.Bundle) (*bundle.Source, error) {
+	creds, err := argocd.GetRepoCredsFromArgoCd(kubeClient, argocdNs, b.RepoUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository credentials: %s", err)
+	}
+	branch, err := resolveBranch(creds, b.RepoUrl, b.RepoRevision)
+	if err != nil {
+		return nil, err
+	}
+	opts := &gitcache.Options{Depth: 1}
+	if dir := path.Clean(b.RepoPath); dir != "." && dir != "/" {
+		opts.SparseDirs = []string{strings.TrimPrefix(dir, "/")}
+	}
+	_, tmpDir, _, err := argocd.CloneRepoWithOptions(creds, b.RepoUrl, branch, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	return dirSource(filepath.Join(tmpDir, b.RepoPath), b.SrcType)
+}
+
+// resolveBranch returns the branch of a git repository that a bundle
+// revision designates, the default branch of the repository for HEAD.
+func resolveBranch(creds *argocd.RepoCreds, repoUrl string, revision string) (string, error) {
+	auth, err := creds.AuthMethod(repoUrl)
+	if err != nil {
+		return "", fmt.Errorf("failed to get git credentials: %s", err)
+	}
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: gogit.DefaultRemoteName,
+		URLs: []string{repoUrl},
+	})
+	refs, err := remote.List(&gogit.ListOptions{Auth: auth})
+	if err != nil {
+		return "", fmt.Errorf("failed to list references of repository: %s", err)
+	}
+	want := plumbing.NewBranchReferenceName(revision)
+	if revision == "" || revision == "HEAD" {
+		want = plumbing.HEAD
+	}
+	for _, ref := range refs {
+		if ref.Name() != want {
+			continue
+		}
+		if want == plumbing.HEAD {
+			if ref.Type() != plumbing.SymbolicReference || !ref.Target().IsBranch() {
+				return "", fmt.Errorf("cannot determine the default branch of the repository")
+			}
+			return ref.Target().Short(), nil
+		}
+		return revision, nil
+	}
+	return "", fmt.Errorf("revision %s is not a branch of the repository, "+
+		"only the sources of bundles at a branch can be inspected", revision)
+}
+
+// dirSource inspects a directory of manifests rendered by a tool, detecting
+// the tool as Argo CD does if it is unset.
+func dirSource(dir string, tool string) (*bundle.Source, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read bundle directory: %s", err)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	src := &bundle.Source{Tool: tool}
+	if src.Tool == "" {
+		switch {
+		case exists("Chart.yaml"):
+			src.Tool = bundle.ToolHelm
+		case exists("kustomization.yaml") || exists("kustomization.yml") || exists("Kustomization"):
+			src.Tool = bundle.ToolKustomize
+		case exists("app.yaml") && exists("components"):
+			src.Tool = bundle.ToolKsonnet
+		default:
+			src.Tool = bundle.ToolDirectory
+		}
+	}
+	if src.Tool != bundle.ToolHelm {
+		return src, nil
+	}
+	for name, dst := range map[string]*[]byte{"values.yaml": &src.Values, "values.schema.json": &src.ValuesSchema} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %s", name, err)
+		}
+		*dst = data
+	}
+	return src, nil
+}
+
+// chartSource inspects the chart of a bundle in a Helm repository. It
+// returns nil for charts in OCI registries and in Helm repositories requiring
+// credentials, which are not inspected. Version ranges are not supported.
+func chartSource(b *bundle.Bundle) (*bundle.Source, error) {
+	if strings.HasPrefix(b.RepoUrl, bundle.OCIScheme) {
+		return nil, nil
+	}
+	repoUrl, err := url.Parse(strings.TrimSuffix(b.RepoUrl, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL: %s", err)
+	}
+	data, err := httpGet(repoUrl.JoinPath("index.yaml").String())
+	if errors.Is(err, errUnauthorized) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index struct {
+		Entries map[string][]struct {
+			Version string   `json:"version"`
+			URLs    []string `json:"urls"`
+		} `json:"entries"`
+	}
+	if err := gyaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse repository index: %s", err)
+	}
+	var chartUrl string
+	for _, entry := range index.Entries[b.Chart] {
+		if strings.TrimPrefix(entry.Version, "v") == strings.TrimPrefix(b.RepoRevision, "v") &&
+			len(entry.URLs) > 0 {
+			chartUrl = entry.URLs[0]
+			break
+		}
+	}
+	if chartUrl == "" {
+		return nil, fmt.Errorf("version %s of chart %s not found in repository index", b.RepoRevision, b.Chart)
+	}
+	u, err := repoUrl.Parse(chartUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart URL: %s", err)
+	}
+	data, err = httpGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return chartArchiveSource(data)
+}
+
+// chartArchiveSource reads the values files of a packaged chart.
+func chartArchiveSource(data []byte) (*bundle.Source, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart archive: %s", err)
+	}
+	defer gz.Close()
+	src := &bundle.Source{Tool: bundle.ToolHelm}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return src, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chart archive: %s", err)
+		}
+		// files of the chart itself are in its top directory
+		dir, name := path.Split(hdr.Name)
+		if strings.Count(dir, "/") != 1 {
+			continue
+		}
+		switch name {
+		case "values.yaml":
+			src.Values, err = io.ReadAll(tr)
+		case "values.schema.json":
+			src.ValuesSchema, err = io.ReadAll(tr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", hdr.Name, err)
+		}
+	}
+}
+
+func httpGet(u string) ([]byte, error) {
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %s", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("%w: %s: %s", errUnauthorized, u, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", u, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arlonproj/arlon/pkg/argocd"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBranch(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello"), 0600))
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Add("README.md")
+	require.NoError(t, err)
+	hash, err := wt.Commit("initial commit", &gogit.CommitOptions{
+		Author: &object.Signature{Name: "arlon", Email: "arlon@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", hash, nil)
+	require.NoError(t, err)
+	repoUrl := "file://" + dir
+	creds := &argocd.RepoCreds{}
+
+	for _, revision := range []string{"", "HEAD", "master"} {
+		branch, err := resolveBranch(creds, repoUrl, revision)
+		require.NoError(t, err, revision)
+		require.Equal(t, "master", branch, revision)
+	}
+	_, err = resolveBranch(creds, repoUrl, "v1.0.0")
+	require.ErrorContains(t, err, "not a branch")
+}
@@ -7,13 +7,27 @@ import (
 	"github.com/arlonproj/arlon/pkg/argocd"
 	"github.com/arlonproj/arlon/pkg/bundle"
 	"github.com/arlonproj/arlon/pkg/ctrlruntimeclient"
+	"github.com/arlonproj/arlon/pkg/plan"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"reflect"
 )
 
 // Update updates a profile to the specified set of bundles. Tags and description
 // may also be updated.
 // If bundlesPtr is nil, no change is made to the bundle set. Otherwise,
 // *bundlesPtr specifies the new set.
+// Bundles in pins are pinned to the specified version, replacing any
+// existing pin, and bundles in unpins return to their latest version.
+// A Helm parameter override replaces the existing one of its bundle with
+// the same key, other overrides replace the existing overrides of their
+// bundle that are not Helm parameters.
+// Each dependency replaces the dependencies of its bundle, an empty one
+// removes them.
+// If pl is not nil, neither git nor the profile are changed: the changes
+// are recorded in pl instead. In pull request write mode, the URL of the
+// pull request opened for the files of a dynamic profile is returned.
 func Update(
 	config *restclient.Config,
 	argocdNs string,
@@ -23,27 +37,36 @@ func Update(
 	desc string,
 	tags string,
 	overrides []arlonv1.Override,
-) (dirty bool, err error) {
+	pins []arlonv1.BundleVersion,
+	unpins []string,
+	dependencies []arlonv1.BundleDependency,
+	pl *plan.Plan,
+) (dirty bool, prUrl string, err error) {
 	for _, name := range bundlesPtr {
 		if !bundle.IsValidK8sName(name) {
-			return false, fmt.Errorf("%w: %s", bundle.ErrInvalidName, name)
+			return false, "", fmt.Errorf("%w: %s", bundle.ErrInvalidName, name)
 		}
 	}
-	existingBundles, err := bundle.List(config, arlonNs)
+	cli, err := ctrlruntimeclient.NewClient(config)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to get new controller runtime client: %s", err)
+	}
+	existingBundles, err := bundle.List(cli, arlonNs)
 	if err != nil {
-		return false, fmt.Errorf("failed to list bundles: %w", err)
+		return false, "", fmt.Errorf("failed to list bundles: %w", err)
 	}
 	existingBundleNames := bundleListToNameSlice(existingBundles)
 	if !isSubset(bundlesPtr, existingBundleNames) {
-		return false, ErrMissingBundles
+		return false, "", ErrMissingBundles
 	}
 	prof, err := GetAugmented(config, profileName, arlonNs)
 	if err != nil {
-		return false, fmt.Errorf("failed to get augmented profile: %s", err)
+		return false, "", fmt.Errorf("failed to get augmented profile: %s", err)
 	}
 	if prof.Legacy {
-		return false, fmt.Errorf("cannot update a legacy (gen1) profile")
+		return false, "", fmt.Errorf("cannot update a legacy (gen1) profile")
 	}
+	before := prof.Profile.DeepCopy()
 	if desc != "" && desc != prof.Spec.Description {
 		prof.Spec.Description = desc
 		dirty = true
@@ -63,54 +86,172 @@ func Update(
 		prof.Spec.Bundles = StringListFromCommaSeparated(bundles)
 		dirty = true
 	}
-	// A new override replaces an existing one if bundle and key match,
-	// otherwise it is added to the list.
-	for _, o := range overrides {
-		found := false
-		for i, x := range prof.Spec.Overrides {
-			if o.Bundle == x.Bundle && o.Key == x.Key {
-				found = true
-				if o.Value != x.Value {
-					// Replace existing value
-					//x.Value = o.Value
-					prof.Spec.Overrides[i].Value = o.Value
-					dirty = true
-				} // else, nothing has changed
-				break
-			}
+	bundleOverrides, err := updateOverrides(prof.Spec.Bundles, prof.Spec.Overrides, overrides)
+	if err != nil {
+		return false, "", err
+	}
+	if !reflect.DeepEqual(bundleOverrides, prof.Spec.Overrides) {
+		prof.Spec.Overrides = bundleOverrides
+		dirty = true
+	}
+	versions := make(map[string]int32)
+	for _, bv := range prof.Spec.BundleVersions {
+		versions[bv.Bundle] = bv.Version
+	}
+	for _, bv := range pins {
+		versions[bv.Bundle] = bv.Version
+	}
+	for _, name := range unpins {
+		delete(versions, name)
+	}
+	var bundleVersions []arlonv1.BundleVersion
+	for _, name := range prof.Spec.Bundles {
+		if version, pinned := versions[name]; pinned {
+			bundleVersions = append(bundleVersions, arlonv1.BundleVersion{Bundle: name, Version: version})
+			delete(versions, name)
 		}
-		if !found {
-			// Didn't find a matching one, so append it to the list
-			prof.Spec.Overrides = append(prof.Spec.Overrides, o)
-			dirty = true
+	}
+	for _, bv := range pins {
+		if _, notInProfile := versions[bv.Bundle]; notInProfile {
+			return false, "", fmt.Errorf("pinned bundle %s is not in the profile", bv.Bundle)
 		}
 	}
+	if !reflect.DeepEqual(bundleVersions, prof.Spec.BundleVersions) {
+		prof.Spec.BundleVersions = bundleVersions
+		dirty = true
+	}
+	bundleDependencies, err := updateDependencies(prof.Spec.Bundles, prof.Spec.BundleDependencies, dependencies)
+	if err != nil {
+		return false, "", err
+	}
+	if !reflect.DeepEqual(bundleDependencies, prof.Spec.BundleDependencies) {
+		prof.Spec.BundleDependencies = bundleDependencies
+		dirty = true
+	}
 	if !dirty {
 		return
 	}
-	if prof.Spec.RepoUrl != "" {
-		// Dynamic profile needs updating in git
-		kubeClient, creds, err := argocd.GetKubeclientAndRepoCreds(config, argocdNs, prof.Spec.RepoUrl)
+	var bndl []bundle.Bundle
+	if prof.Spec.RepoUrl != "" || len(prof.Spec.Overrides) > 0 {
+		bndl, err = bundle.GetBundlesFromProfile(&prof.Profile, cli, arlonNs)
 		if err != nil {
-			return false, fmt.Errorf("failed to get kubeclient and repository credentials: %s", err)
+			return false, "", fmt.Errorf("failed to get bundles: %s", err)
 		}
-		corev1 := kubeClient.CoreV1()
-		bndl, err := bundle.GetBundlesFromProfile(&prof.Profile, corev1, arlonNs)
+	}
+	if len(prof.Spec.Overrides) > 0 {
+		kubeClient, err := kubernetes.NewForConfig(config)
 		if err != nil {
-			return false, fmt.Errorf("failed to get bundles: %s", err)
+			return false, "", fmt.Errorf("failed to get kubernetes client: %s", err)
 		}
-		err = createInGit(creds, &prof.Profile, argocdNs, bndl)
+		errs := bundle.ValidateOverrides(bndl, prof.Spec.Overrides, bundleSource(kubeClient, argocdNs),
+			field.NewPath("overrides"))
+		if len(errs) > 0 {
+			return false, "", fmt.Errorf("invalid overrides: %s", errs.ToAggregate())
+		}
+	}
+	if prof.Spec.RepoUrl != "" {
+		// Dynamic profile needs updating in git
+		_, creds, err := argocd.GetKubeclientAndRepoCreds(config, argocdNs, prof.Spec.RepoUrl)
 		if err != nil {
-			return false, fmt.Errorf("failed to update dynamic profile in git: %s", err)
+			return false, "", fmt.Errorf("failed to get kubeclient and repository credentials: %s", err)
+		}
+		if pl != nil {
+			diff, err := planInGit(creds, &prof.Profile, bndl)
+			if err != nil {
+				return false, "", fmt.Errorf("failed to plan dynamic profile in git: %s", err)
+			}
+			pl.AddGitDiff(prof.Spec.RepoUrl, prof.Spec.RepoRevision, diff)
+		} else {
+			prUrl, err = createInGit(creds, &prof.Profile, argocdNs, bndl)
+			if err != nil {
+				return false, "", fmt.Errorf("failed to update dynamic profile in git: %s", err)
+			}
 		}
 	}
-	cli, err := ctrlruntimeclient.NewClient(config)
-	if err != nil {
-		return false, fmt.Errorf("failed to get new controller runtime client: %s", err)
+	if pl != nil {
+		err = pl.AddObjectDiff("Profile", prof.Name, before.Spec, prof.Spec)
+		return
 	}
 	err = cli.Update(context.Background(), &prof.Profile)
 	if err != nil {
-		return false, fmt.Errorf("failed to update profile: %s", err)
+		return false, "", fmt.Errorf("failed to update profile: %s", err)
 	}
 	return
 }
+
+// updateDependencies returns the dependencies between the bundles of a
+// profile after replacing some of them. Dependencies on bundles that left
+// the profile are dropped.
+func updateDependencies(
+	bundles []string,
+	current []arlonv1.BundleDependency,
+	updates []arlonv1.BundleDependency,
+) ([]arlonv1.BundleDependency, error) {
+	dependsOn := make(map[string][]string)
+	for _, dep := range current {
+		dependsOn[dep.Bundle] = dep.DependsOn
+	}
+	for _, dep := range updates {
+		if !isSubset([]string{dep.Bundle}, bundles) {
+			return nil, fmt.Errorf("bundle %s with dependencies is not in the profile", dep.Bundle)
+		}
+		dependsOn[dep.Bundle] = dep.DependsOn
+	}
+	var res []arlonv1.BundleDependency
+	for _, name := range bundles {
+		var deps []string
+		for _, dep := range dependsOn[name] {
+			if isSubset([]string{dep}, bundles) {
+				deps = append(deps, dep)
+			}
+		}
+		if len(deps) > 0 {
+			res = append(res, arlonv1.BundleDependency{Bundle: name, DependsOn: deps})
+		}
+	}
+	errs := bundle.ValidateDependencies(bundles, res, field.NewPath("dependencies"))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid bundle dependencies: %s", errs.ToAggregate())
+	}
+	return res, nil
+}
+
+// updateOverrides returns the overrides of the bundles of a profile after
+// applying some updates. Overrides of bundles that left the profile are
+// dropped.
+func updateOverrides(
+	bundles []string,
+	current []arlonv1.Override,
+	updates []arlonv1.Override,
+) ([]arlonv1.Override, error) {
+	replaced := make(map[string]bool)
+	for _, o := range updates {
+		if !isSubset([]string{o.Bundle}, bundles) {
+			return nil, fmt.Errorf("overridden bundle %s is not in the profile", o.Bundle)
+		}
+		if o.Key == "" {
+			replaced[o.Bundle] = true
+		}
+	}
+	var res []arlonv1.Override
+	for _, o := range current {
+		if !isSubset([]string{o.Bundle}, bundles) || o.Key == "" && replaced[o.Bundle] {
+			continue
+		}
+		res = append(res, o)
+	}
+	for _, o := range updates {
+		found := false
+		for i := range res {
+			if o.Key != "" && res[i].Bundle == o.Bundle && res[i].Key == o.Key {
+				res[i] = o
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, o)
+		}
+	}
+	return res, nil
+}
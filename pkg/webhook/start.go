@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"fmt"
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
 	"github.com/arlonproj/arlon/pkg/controller"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
@@ -18,6 +19,7 @@ func Start(port int) error {
 	utilruntime.Must(admissionv1.AddToScheme(scheme))
 	utilruntime.Must(admissionregistrationv1.AddToScheme(scheme))
 	utilruntime.Must(capi.AddToScheme(scheme))
+	utilruntime.Must(arlonv1.AddToScheme(scheme))
 
 	cfg := ctrl.GetConfigOrDie()
 	cli, err := controller.NewClient(cfg)
@@ -33,7 +35,8 @@ func Start(port int) error {
 		return fmt.Errorf("failed to get new manager: %s", err)
 	}
 	wh := newWebhook(cli, scheme)
-	mgr.GetWebhookServer().Register("/rewrite", wh)
+	mgr.GetWebhookServer().Register("/rewrite", wh.handlerFor(wh.Rewrite))
+	mgr.GetWebhookServer().Register("/validate", wh.handlerFor(wh.Validate))
 	if err := mgr.AddHealthzCheck("healthz", mgr.GetWebhookServer().StartedChecker()); err != nil {
 		return fmt.Errorf("failed to set up health check: %s", err)
 	}
@@ -0,0 +1,463 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	bcl "github.com/arlonproj/arlon/pkg/basecluster"
+	"github.com/arlonproj/arlon/pkg/bundle"
+	"github.com/arlonproj/arlon/pkg/helmvalues"
+	"github.com/blang/semver"
+	gyaml "github.com/ghodss/yaml"
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate rejects arlon resources with a spec that the controllers would
+// never be able to process. An update is only validated when it changes the
+// spec, so that the controllers can still update the metadata and the status
+// of resources that were created before the webhook or with an older version
+// of it, for example to remove their finalizer when they are deleted.
+func (wh *webhook) Validate(ctx context.Context, ar v1.AdmissionReview) *v1.AdmissionResponse {
+	req := ar.Request
+	allowed := &v1.AdmissionResponse{Allowed: true}
+	if req.Kind.Group != arlonv1.GroupVersion.Group || req.Operation == v1.Delete {
+		return allowed
+	}
+	var obj runtime.Object
+	var validateFn func() field.ErrorList
+	switch req.Kind.Kind {
+	case "Cluster":
+		cl := &arlonv1.Cluster{}
+		obj, validateFn = cl, func() field.ErrorList { return ValidateCluster(cl) }
+	case "Profile":
+		prof := &arlonv1.Profile{}
+		obj, validateFn = prof, func() field.ErrorList { return ValidateProfile(prof) }
+	case "AppProfile":
+		ap := &arlonv1.AppProfile{}
+		obj, validateFn = ap, func() field.ErrorList { return ValidateAppProfile(ap) }
+	case "ClusterRegistration":
+		cr := &arlonv1.ClusterRegistration{}
+		obj, validateFn = cr, func() field.ErrorList { return ValidateClusterRegistration(cr) }
+	case "CallHomeConfig":
+		chc := &arlonv1.CallHomeConfig{}
+		obj, validateFn = chc, func() field.ErrorList { return ValidateCallHomeConfig(chc) }
+	case "ClusterRollout":
+		ro := &arlonv1.ClusterRollout{}
+		obj, validateFn = ro, func() field.ErrorList { return ValidateClusterRollout(ro) }
+	case "Bundle":
+		b := &arlonv1.Bundle{}
+		obj, validateFn = b, func() field.ErrorList { return ValidateBundle(b) }
+	default:
+		wh.log.Info("no validation for kind", "kind", req.Kind.Kind)
+		return allowed
+	}
+	if _, _, err := wh.decoder.Decode(req.Object.Raw, nil, obj); err != nil {
+		return wh.toV1AdmissionResponse("failed to decode "+req.Kind.Kind, err)
+	}
+	if req.Operation == v1.Update {
+		if accessor, err := meta.Accessor(obj); err == nil && accessor.GetDeletionTimestamp() != nil {
+			return allowed
+		}
+		changed, err := specChanged(req.OldObject.Raw, req.Object.Raw)
+		if err != nil {
+			return wh.toV1AdmissionResponse("failed to compare "+req.Kind.Kind, err)
+		}
+		if !changed {
+			return allowed
+		}
+	}
+	errs := validateFn()
+	if len(errs) == 0 {
+		return allowed
+	}
+	wh.log.Info("rejecting invalid resource", "kind", req.Kind.Kind,
+		"name", req.Name, "errors", errs.ToAggregate().Error())
+	return &v1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: fmt.Sprintf("%s %s is invalid: %s", req.Kind.Kind, req.Name, errs.ToAggregate()),
+		},
+	}
+}
+
+// specChanged returns whether the spec of a resource differs between its old
+// and new serialized objects.
+func specChanged(oldRaw []byte, newRaw []byte) (bool, error) {
+	if len(oldRaw) == 0 {
+		return true, nil
+	}
+	var oldObj, newObj struct {
+		Spec interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(oldRaw, &oldObj); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(newRaw, &newObj); err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(oldObj.Spec, newObj.Spec), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateCluster checks the spec of an arlon Cluster.
+func ValidateCluster(cl *arlonv1.Cluster) field.ErrorList {
+	var errs field.ErrorList
+	// The cluster name is used as a namespace and as a prefix for resource names
+	for _, msg := range validation.IsDNS1123Label(cl.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), cl.Name, msg))
+	}
+	specPath := field.NewPath("spec")
+	errs = append(errs, validateRepoSpec(&cl.Spec.ClusterTemplate, specPath.Child("clusterTemplate"))...)
+	if ovr := cl.Spec.Override; ovr != nil {
+		ovrPath := specPath.Child("override")
+		if strings.TrimSpace(ovr.Patch) == "" && len(ovr.Patches) == 0 && ovr.Topology == nil {
+			errs = append(errs, field.Required(ovrPath.Child("patch"),
+				"override must contain a patch, patches or a topology"))
+		}
+		for i := range ovr.Patches {
+			patchPath := ovrPath.Child("patches").Index(i)
+			if strings.TrimSpace(ovr.Patches[i].Patch) == "" {
+				errs = append(errs, field.Required(patchPath.Child("patch"), ""))
+				continue
+			}
+			if err := bcl.CheckPatch(&ovr.Patches[i]); err != nil {
+				errs = append(errs, field.Invalid(patchPath, field.OmitValueType{}, err.Error()))
+			}
+		}
+		errs = append(errs, validateRepoSpec(&ovr.Repo, ovrPath.Child("repo"))...)
+		if ovr.Repo.Path == "" {
+			errs = append(errs, field.Required(ovrPath.Child("repo", "path"), ""))
+		}
+		if ovr.Topology != nil {
+			errs = append(errs, validateTopology(ovr.Topology, ovrPath.Child("topology"))...)
+		}
+	}
+	if cas := cl.Spec.Autoscaler; cas != nil && cas.MgmtClusterHost == "" {
+		errs = append(errs, field.Required(specPath.Child("autoscaler", "host"), ""))
+	}
+	if chart := cl.Spec.ArlonHelmChart; chart != nil {
+		chartPath := specPath.Child("arlonHelmChart")
+		errs = append(errs, validateRepoSpec(chart, chartPath)...)
+		if chart.Path == "" {
+			errs = append(errs, field.Required(chartPath.Child("path"), ""))
+		}
+	}
+	errs = append(errs, validateAppValues(cl.Spec.AppValues, specPath.Child("appValues"))...)
+	return errs
+}
+
+func validateTopology(topology *arlonv1.TopologyOverride, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if topology.Version == "" && len(topology.Variables) == 0 {
+		errs = append(errs, field.Required(fldPath, "topology must set a version or variables"))
+	}
+	if topology.Version != "" {
+		// CAPI expects a v prefixed semantic version
+		if _, err := semver.Parse(strings.TrimPrefix(topology.Version, "v")); err != nil ||
+			!strings.HasPrefix(topology.Version, "v") {
+			errs = append(errs, field.Invalid(fldPath.Child("version"), topology.Version,
+				"must be a semantic version prefixed with v, for e.g. v1.24.6"))
+		}
+	}
+	names := make(map[string]bool)
+	for i, v := range topology.Variables {
+		varPath := fldPath.Child("variables").Index(i)
+		if v.Name == "" {
+			errs = append(errs, field.Required(varPath.Child("name"), ""))
+		} else if names[v.Name] {
+			errs = append(errs, field.Duplicate(varPath.Child("name"), v.Name))
+		}
+		names[v.Name] = true
+		if len(v.Value.Raw) == 0 {
+			errs = append(errs, field.Required(varPath.Child("value"), ""))
+		}
+	}
+	return errs
+}
+
+func validateRepoSpec(rs *arlonv1.RepoSpec, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if rs.Url == "" {
+		errs = append(errs, field.Required(fldPath.Child("url"), ""))
+	} else if !isGitUrl(rs.Url) {
+		errs = append(errs, field.Invalid(fldPath.Child("url"), rs.Url, "not a valid git repository URL"))
+	}
+	if rs.Revision == "" {
+		errs = append(errs, field.Required(fldPath.Child("revision"), ""))
+	}
+	if strings.HasPrefix(rs.Path, "/") || strings.Contains(rs.Path, "..") {
+		errs = append(errs, field.Invalid(fldPath.Child("path"), rs.Path,
+			"must be a relative path inside the repository"))
+	}
+	return errs
+}
+
+// isGitUrl accepts URLs with a scheme as well as the scp-like syntax of ssh,
+// for e.g. git@github.com:org/repo.git
+func isGitUrl(s string) bool {
+	if strings.ContainsAny(s, " \t\n") {
+		return false
+	}
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" {
+		return u.Scheme == "file" || u.Host != ""
+	}
+	at := strings.Index(s, "@")
+	colon := strings.Index(s, ":")
+	return at > 0 && colon > at+1 && colon < len(s)-1
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateProfile checks the spec of a Profile.
+func ValidateProfile(prof *arlonv1.Profile) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	bundles := make(map[string]bool)
+	for i, name := range prof.Spec.Bundles {
+		fldPath := specPath.Child("bundles").Index(i)
+		if !bundle.IsValidK8sName(name) {
+			errs = append(errs, field.Invalid(fldPath, name, bundle.ErrInvalidName.Error()))
+		}
+		if bundles[name] {
+			errs = append(errs, field.Duplicate(fldPath, name))
+		}
+		bundles[name] = true
+	}
+	for i, o := range prof.Spec.Overrides {
+		fldPath := specPath.Child("overrides").Index(i)
+		if !bundles[o.Bundle] {
+			errs = append(errs, field.Invalid(fldPath.Child("bundle"), o.Bundle,
+				"override refers to a bundle that is not in spec.bundles"))
+		}
+		errs = append(errs, bundle.ValidateOverride(&prof.Spec.Overrides[i], fldPath)...)
+	}
+	for i, bs := range prof.Spec.BundleSettings {
+		fldPath := specPath.Child("bundleSettings").Index(i)
+		if !bundles[bs.Bundle] {
+			errs = append(errs, field.Invalid(fldPath.Child("bundle"), bs.Bundle,
+				"settings refer to a bundle that is not in spec.bundles"))
+		}
+		errs = append(errs, bundle.ValidateDeploySettings(&bs.DeploySettings, fldPath)...)
+	}
+	pinned := make(map[string]bool)
+	for i, bv := range prof.Spec.BundleVersions {
+		fldPath := specPath.Child("bundleVersions").Index(i)
+		if !bundles[bv.Bundle] {
+			errs = append(errs, field.Invalid(fldPath.Child("bundle"), bv.Bundle,
+				"version refers to a bundle that is not in spec.bundles"))
+		} else if pinned[bv.Bundle] {
+			errs = append(errs, field.Duplicate(fldPath.Child("bundle"), bv.Bundle))
+		}
+		pinned[bv.Bundle] = true
+		if bv.Version < 1 {
+			errs = append(errs, field.Invalid(fldPath.Child("version"), bv.Version,
+				"must be at least 1"))
+		}
+	}
+	errs = append(errs, bundle.ValidateDependencies(prof.Spec.Bundles, prof.Spec.BundleDependencies,
+		specPath.Child("bundleDependencies"))...)
+	if prof.Spec.RepoUrl != "" || prof.Spec.RepoPath != "" {
+		// dynamic profile
+		if prof.Spec.RepoUrl == "" {
+			errs = append(errs, field.Required(specPath.Child("repoUrl"),
+				"required for a dynamic profile"))
+		} else if !isGitUrl(prof.Spec.RepoUrl) {
+			errs = append(errs, field.Invalid(specPath.Child("repoUrl"), prof.Spec.RepoUrl,
+				"not a valid git repository URL"))
+		}
+		if prof.Spec.RepoPath == "" {
+			errs = append(errs, field.Required(specPath.Child("repoPath"),
+				"required for a dynamic profile"))
+		}
+	}
+	return errs
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateAppProfile checks the spec of an AppProfile.
+func ValidateAppProfile(ap *arlonv1.AppProfile) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]bool)
+	for i, name := range ap.Spec.AppNames {
+		fldPath := field.NewPath("spec", "appNames").Index(i)
+		if !bundle.IsValidK8sName(name) {
+			errs = append(errs, field.Invalid(fldPath, name,
+				"app name must be a valid RFC 1123 name"))
+		}
+		if names[name] {
+			errs = append(errs, field.Duplicate(fldPath, name))
+		}
+		names[name] = true
+	}
+	if sel := ap.Spec.ClusterSelector; sel != nil {
+		errs = append(errs, metav1validation.ValidateLabelSelector(sel,
+			field.NewPath("spec", "clusterSelector"))...)
+		if len(sel.MatchLabels) == 0 && len(sel.MatchExpressions) == 0 {
+			// an empty selector would select every cluster
+			errs = append(errs, field.Required(field.NewPath("spec", "clusterSelector"),
+				"selector must not be empty"))
+		}
+	}
+	errs = append(errs, validateAppValues(ap.Spec.AppValues, field.NewPath("spec", "appValues"))...)
+	return errs
+}
+
+func validateAppValues(appValues []arlonv1.AppHelmValues, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, hv := range appValues {
+		hvPath := fldPath.Index(i)
+		if !bundle.IsValidK8sName(hv.AppName) {
+			errs = append(errs, field.Invalid(hvPath.Child("appName"), hv.AppName,
+				"app name must be a valid RFC 1123 name"))
+		}
+		if hv.Values != "" {
+			var values map[string]interface{}
+			if err := gyaml.Unmarshal([]byte(hv.Values), &values); err != nil {
+				errs = append(errs, field.Invalid(hvPath.Child("values"), hv.Values,
+					fmt.Sprintf("values must be a YAML map: %s", err)))
+			}
+		}
+		for j, param := range hv.Parameters {
+			if err := helmvalues.ValidateParameterName(param.Name); err != nil {
+				errs = append(errs, field.Invalid(hvPath.Child("parameters").Index(j).Child("name"),
+					param.Name, fmt.Sprintf("must be a path of value names like the ones of helm --set: %s", err)))
+			}
+		}
+	}
+	return errs
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateClusterRegistration checks the spec of a ClusterRegistration.
+func ValidateClusterRegistration(cr *arlonv1.ClusterRegistration) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	errs = append(errs, validateSecretName(cr.Spec.KubeconfigSecretName,
+		specPath.Child("kubeconfigSecretName"))...)
+	errs = append(errs, validateSecretKey(cr.Spec.KubeconfigSecretKeyName,
+		specPath.Child("kubeconfigSecretKeyName"))...)
+	return errs
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateCallHomeConfig checks the spec of a CallHomeConfig.
+func ValidateCallHomeConfig(chc *arlonv1.CallHomeConfig) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := &chc.Spec
+	if spec.ServiceAccountName == "" {
+		errs = append(errs, field.Required(specPath.Child("serviceAccountName"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.ServiceAccountName) {
+			errs = append(errs, field.Invalid(specPath.Child("serviceAccountName"),
+				spec.ServiceAccountName, msg))
+		}
+	}
+	errs = append(errs, validateSecretName(spec.KubeconfigSecretName,
+		specPath.Child("kubeconfigSecretName"))...)
+	errs = append(errs, validateSecretKey(spec.KubeconfigSecretKeyName,
+		specPath.Child("kubeconfigSecretKeyName"))...)
+	if spec.TargetNamespace == "" {
+		errs = append(errs, field.Required(specPath.Child("targetNamespace"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Label(spec.TargetNamespace) {
+			errs = append(errs, field.Invalid(specPath.Child("targetNamespace"),
+				spec.TargetNamespace, msg))
+		}
+	}
+	errs = append(errs, validateSecretName(spec.TargetSecretName,
+		specPath.Child("targetSecretName"))...)
+	errs = append(errs, validateSecretKey(spec.TargetSecretKeyName,
+		specPath.Child("targetSecretKeyName"))...)
+	if spec.ManagementClusterUrl == "" {
+		errs = append(errs, field.Required(specPath.Child("managementClusterUrl"), ""))
+	} else if u, err := url.Parse(spec.ManagementClusterUrl); err != nil ||
+		(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(specPath.Child("managementClusterUrl"),
+			spec.ManagementClusterUrl, "must be an http or https URL"))
+	}
+	return errs
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateClusterRollout checks the spec of a ClusterRollout.
+func ValidateClusterRollout(ro *arlonv1.ClusterRollout) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := &ro.Spec
+	errs = append(errs, metav1validation.ValidateLabelSelector(&spec.Selector,
+		specPath.Child("selector"))...)
+	if len(spec.Selector.MatchLabels) == 0 && len(spec.Selector.MatchExpressions) == 0 {
+		// an empty selector would upgrade every cluster of the namespace
+		errs = append(errs, field.Required(specPath.Child("selector"),
+			"selector must not be empty"))
+	}
+	if spec.TargetRevision == "" {
+		errs = append(errs, field.Required(specPath.Child("targetRevision"), ""))
+	}
+	if spec.WaveSize < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("waveSize"), spec.WaveSize,
+			"must be greater than 0"))
+	}
+	if d := spec.ProgressDeadlineSeconds; d != nil && *d <= 0 {
+		errs = append(errs, field.Invalid(specPath.Child("progressDeadlineSeconds"), *d,
+			"must be greater than 0"))
+	}
+	return errs
+}
+
+// -----------------------------------------------------------------------------
+
+// ValidateBundle checks the spec of a Bundle.
+func ValidateBundle(b *arlonv1.Bundle) field.ErrorList {
+	var errs field.ErrorList
+	if !bundle.IsValidK8sName(b.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), b.Name,
+			"must be a valid resource name"))
+	}
+	return append(errs, bundle.ValidateSpec(&b.Spec, field.NewPath("spec"))...)
+}
+
+// -----------------------------------------------------------------------------
+
+func validateSecretName(name string, fldPath *field.Path) field.ErrorList {
+	if name == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		errs = append(errs, field.Invalid(fldPath, name, msg))
+	}
+	return errs
+}
+
+func validateSecretKey(key string, fldPath *field.Path) field.ErrorList {
+	if key == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	var errs field.ErrorList
+	for _, msg := range validation.IsConfigMapKey(key) {
+		errs = append(errs, field.Invalid(fldPath, key, msg))
+	}
+	return errs
+}
@@ -0,0 +1,358 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	arlonv1 "github.com/arlonproj/arlon/api/v1"
+	"github.com/arlonproj/arlon/pkg/log"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validCluster() *arlonv1.Cluster {
+	return &arlonv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "c1", Namespace: "arlon"},
+		Spec: arlonv1.ClusterSpec{
+			ClusterTemplate: arlonv1.RepoSpec{
+				Url:      "https://github.com/org/repo.git",
+				Path:     "templates/t1",
+				Revision: "main",
+			},
+		},
+	}
+}
+
+func errFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestValidateCluster(t *testing.T) {
+	assert.Empty(t, ValidateCluster(validCluster()))
+
+	cl := validCluster()
+	cl.Name = "My_Cluster"
+	cl.Spec.ClusterTemplate.Url = ""
+	assert.Equal(t, []string{"metadata.name", "spec.clusterTemplate.url"},
+		errFields(ValidateCluster(cl)))
+
+	cl = validCluster()
+	cl.Spec.ClusterTemplate.Url = "git@github.com:org/repo.git"
+	assert.Empty(t, ValidateCluster(cl))
+	cl.Spec.ClusterTemplate.Path = "../other"
+	assert.Equal(t, []string{"spec.clusterTemplate.path"}, errFields(ValidateCluster(cl)))
+
+	cl = validCluster()
+	cl.Spec.Override = &arlonv1.OverrideSpec{
+		Repo: arlonv1.RepoSpec{
+			Url:      "https://github.com/org/overrides.git",
+			Revision: "main",
+		},
+	}
+	assert.Equal(t, []string{"spec.override.patch", "spec.override.repo.path"},
+		errFields(ValidateCluster(cl)))
+	cl.Spec.Override.Repo.Path = "clusters"
+	cl.Spec.Override.Topology = &arlonv1.TopologyOverride{
+		Version: "v1.24.6",
+		Variables: []arlonv1.TopologyVariable{
+			{Name: "etcdImageTag", Value: apiextensionsv1.JSON{Raw: []byte(`"3.5.3-0"`)}},
+		},
+	}
+	assert.Empty(t, ValidateCluster(cl))
+	cl.Spec.Override.Topology.Version = "1.24"
+	cl.Spec.Override.Topology.Variables = append(cl.Spec.Override.Topology.Variables,
+		arlonv1.TopologyVariable{Name: "etcdImageTag"})
+	assert.Equal(t, []string{
+		"spec.override.topology.version",
+		"spec.override.topology.variables[1].name",
+		"spec.override.topology.variables[1].value",
+	}, errFields(ValidateCluster(cl)))
+
+	cl.Spec.Override.Topology = nil
+	cl.Spec.Override.Patches = []arlonv1.OverridePatch{
+		{Patch: "apiVersion: cluster.x-k8s.io/v1beta1\nkind: MachineDeployment\nmetadata:\n  name: md-0\nspec:\n  replicas: 3\n"},
+		{Type: arlonv1.PatchTypeJSON6902, Target: &arlonv1.KustomizeTarget{Kind: "MachineDeployment"},
+			Patch: "- op: replace\n  path: /spec/replicas\n  value: 3\n"},
+	}
+	assert.Empty(t, ValidateCluster(cl))
+	cl.Spec.Override.Patches = append(cl.Spec.Override.Patches,
+		arlonv1.OverridePatch{Patch: "spec:\n  replicas: 3\n"},
+		arlonv1.OverridePatch{Type: arlonv1.PatchTypeJSON6902, Patch: "- op: remove\n  path: /spec/replicas\n"},
+		arlonv1.OverridePatch{Type: arlonv1.PatchTypeJSON6902, Target: &arlonv1.KustomizeTarget{Kind: "Cluster"},
+			Patch: "spec:\n  paused: true\n"},
+		arlonv1.OverridePatch{Type: arlonv1.PatchTypeStrategicMerge})
+	assert.Equal(t, []string{
+		"spec.override.patches[2]",
+		"spec.override.patches[3]",
+		"spec.override.patches[4]",
+		"spec.override.patches[5].patch",
+	}, errFields(ValidateCluster(cl)))
+
+	cl = validCluster()
+	cl.Spec.Autoscaler = &arlonv1.AutoscalerSpec{}
+	assert.Equal(t, []string{"spec.autoscaler.host"}, errFields(ValidateCluster(cl)))
+}
+
+func TestValidateProfile(t *testing.T) {
+	prof := &arlonv1.Profile{
+		Spec: arlonv1.ProfileSpec{
+			Bundles: []string{"guestbook", "calico"},
+			Overrides: []arlonv1.Override{
+				{Bundle: "guestbook", Key: "replicas", Value: "2"},
+			},
+		},
+	}
+	assert.Empty(t, ValidateProfile(prof))
+
+	prof.Spec.Bundles = append(prof.Spec.Bundles, "calico", "Bad_Name")
+	prof.Spec.Overrides = append(prof.Spec.Overrides,
+		arlonv1.Override{Bundle: "missing", Value: "2"})
+	assert.Equal(t, []string{
+		"spec.bundles[2]",
+		"spec.bundles[3]",
+		"spec.overrides[1].bundle",
+		"spec.overrides[1].key",
+	}, errFields(ValidateProfile(prof)))
+
+	prof.Spec.Bundles = []string{"guestbook"}
+	prof.Spec.Overrides = []arlonv1.Override{
+		{Bundle: "guestbook", Values: "replicas: 2\nimage:\n  tag: v2\n"},
+		{Bundle: "guestbook", Images: []string{"guestbook=gcr.io/heptio-images/ks-guestbook-demo:0.2"}},
+		{Bundle: "guestbook", Values: "- not a map"},
+		{Bundle: "guestbook", Key: "replicas", Value: "3", Images: []string{"not an image"}},
+		{Bundle: "guestbook", Patches: []arlonv1.KustomizePatch{
+			{Patch: "- op: replace\n  path: /spec/replicas\n  value: 3\n"},
+			{Patch: "spec:\n  replicas: 3\n"},
+		}},
+		{Bundle: "guestbook", Key: "ingress.hosts[0].host", Value: "guestbook.example.com"},
+		{Bundle: "guestbook", Key: "ingress.hosts[x]", Value: "guestbook.example.com"},
+	}
+	assert.Equal(t, []string{
+		"spec.overrides[2].values",
+		"spec.overrides[3].images",
+		"spec.overrides[3].images[0]",
+		"spec.overrides[4].patches[0].target",
+		"spec.overrides[4].patches[1].patch",
+		"spec.overrides[6].key",
+	}, errFields(ValidateProfile(prof)))
+
+	prof.Spec.Overrides = nil
+	prof.Spec.BundleSettings = []arlonv1.BundleSettings{
+		{Bundle: "guestbook", DeploySettings: arlonv1.DeploySettings{
+			DestinationNamespace: "guestbook",
+			SyncOptions:          []string{"ServerSideApply=true"},
+		}},
+		{Bundle: "missing", DeploySettings: arlonv1.DeploySettings{
+			DestinationNamespace: "Not_A_Namespace",
+			SyncOptions:          []string{"Replace"},
+			IgnoreDifferences:    []arlonv1.IgnoreDifference{{Kind: "Deployment"}},
+		}},
+	}
+	assert.Equal(t, []string{
+		"spec.bundleSettings[1].bundle",
+		"spec.bundleSettings[1].destinationNamespace",
+		"spec.bundleSettings[1].syncOptions[0]",
+		"spec.bundleSettings[1].ignoreDifferences[0].jsonPointers",
+	}, errFields(ValidateProfile(prof)))
+
+	prof.Spec.BundleSettings = nil
+	prof.Spec.BundleVersions = []arlonv1.BundleVersion{
+		{Bundle: "guestbook", Version: 2},
+		{Bundle: "guestbook", Version: 0},
+		{Bundle: "missing", Version: 1},
+	}
+	assert.Equal(t, []string{
+		"spec.bundleVersions[1].bundle",
+		"spec.bundleVersions[1].version",
+		"spec.bundleVersions[2].bundle",
+	}, errFields(ValidateProfile(prof)))
+
+	prof.Spec.BundleVersions = nil
+	prof.Spec.Bundles = []string{"cert-manager", "issuers", "guestbook"}
+	prof.Spec.BundleDependencies = []arlonv1.BundleDependency{
+		{Bundle: "issuers", DependsOn: []string{"cert-manager"}},
+		{Bundle: "guestbook", DependsOn: []string{"issuers", "cert-manager"}},
+	}
+	assert.Empty(t, ValidateProfile(prof))
+	prof.Spec.BundleDependencies = append(prof.Spec.BundleDependencies,
+		arlonv1.BundleDependency{Bundle: "cert-manager", DependsOn: []string{"guestbook"}})
+	errs := ValidateProfile(prof)
+	assert.Equal(t, []string{"spec.bundleDependencies"}, errFields(errs))
+	assert.Contains(t, errs[0].Error(), "cert-manager -> guestbook -> issuers -> cert-manager")
+	prof.Spec.BundleDependencies = []arlonv1.BundleDependency{
+		{Bundle: "missing", DependsOn: []string{"guestbook"}},
+		{Bundle: "guestbook", DependsOn: []string{"missing"}},
+	}
+	assert.Equal(t, []string{
+		"spec.bundleDependencies[0].bundle",
+		"spec.bundleDependencies[1].dependsOn[0]",
+	}, errFields(ValidateProfile(prof)))
+	prof.Spec.BundleDependencies = nil
+
+	dynamic := &arlonv1.Profile{Spec: arlonv1.ProfileSpec{RepoPath: "profiles/p1"}}
+	assert.Equal(t, []string{"spec.repoUrl"}, errFields(ValidateProfile(dynamic)))
+}
+
+func TestValidateAppProfile(t *testing.T) {
+	ap := &arlonv1.AppProfile{
+		Spec: arlonv1.AppProfileSpec{AppNames: []string{"guestbook", "nginx"}},
+	}
+	assert.Empty(t, ValidateAppProfile(ap))
+
+	ap.Spec.AppNames = append(ap.Spec.AppNames, "nginx", "Not.Valid_")
+	assert.Equal(t, []string{"spec.appNames[2]", "spec.appNames[3]"},
+		errFields(ValidateAppProfile(ap)))
+
+	ap.Spec.AppNames = []string{"guestbook"}
+	ap.Spec.ClusterSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "env", Operator: metav1.LabelSelectorOpIn},
+	}}
+	assert.Equal(t, []string{"spec.clusterSelector.matchExpressions[0].values"},
+		errFields(ValidateAppProfile(ap)))
+
+	// an empty selector would select every cluster
+	ap.Spec.ClusterSelector = &metav1.LabelSelector{}
+	assert.Equal(t, []string{"spec.clusterSelector"}, errFields(ValidateAppProfile(ap)))
+
+	ap.Spec.ClusterSelector = nil
+	ap.Spec.AppValues = []arlonv1.AppHelmValues{
+		{AppName: "guestbook", Values: "replicaCount: 2"},
+		{AppName: "nginx", Values: "- not a map", Parameters: []arlonv1.HelmParameter{
+			{Name: "controller..replicaCount", Value: "2"},
+			{Name: "controller.tolerations[0].key", Value: "dedicated"},
+			{Name: `podAnnotations.prometheus\.io/scrape`, Value: "true"},
+			{Name: "controller.tolerations[x]", Value: "dedicated"},
+		}},
+	}
+	assert.Equal(t, []string{"spec.appValues[1].values", "spec.appValues[1].parameters[0].name",
+		"spec.appValues[1].parameters[3].name"},
+		errFields(ValidateAppProfile(ap)))
+}
+
+func TestValidateCallHomeConfig(t *testing.T) {
+	chc := &arlonv1.CallHomeConfig{
+		Spec: arlonv1.CallHomeConfigSpec{
+			ServiceAccountName:      "arlon-callhome",
+			KubeconfigSecretName:    "c1-kubeconfig",
+			KubeconfigSecretKeyName: "value",
+			TargetNamespace:         "arlon",
+			TargetSecretName:        "mgmt-kubeconfig",
+			TargetSecretKeyName:     "config",
+			ManagementClusterUrl:    "https://mgmt.example.com:6443",
+		},
+	}
+	assert.Empty(t, ValidateCallHomeConfig(chc))
+
+	chc.Spec.TargetNamespace = ""
+	chc.Spec.ManagementClusterUrl = "mgmt.example.com"
+	assert.Equal(t, []string{"spec.targetNamespace", "spec.managementClusterUrl"},
+		errFields(ValidateCallHomeConfig(chc)))
+}
+
+func TestValidateClusterRollout(t *testing.T) {
+	ro := &arlonv1.ClusterRollout{
+		Spec: arlonv1.ClusterRolloutSpec{
+			Selector:       metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			TargetRevision: "v2",
+			WaveSize:       2,
+		},
+	}
+	assert.Empty(t, ValidateClusterRollout(ro))
+
+	deadline := int32(0)
+	ro.Spec.Selector = metav1.LabelSelector{}
+	ro.Spec.TargetRevision = ""
+	ro.Spec.ProgressDeadlineSeconds = &deadline
+	assert.Equal(t, []string{"spec.selector", "spec.targetRevision", "spec.progressDeadlineSeconds"},
+		errFields(ValidateClusterRollout(ro)))
+}
+
+func TestValidateBundle(t *testing.T) {
+	b := &arlonv1.Bundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "cert-manager"},
+		Spec: arlonv1.BundleSpec{
+			RepoUrl:      "https://charts.jetstack.io",
+			Chart:        "cert-manager",
+			RepoRevision: "v1.11.0",
+		},
+	}
+	assert.Empty(t, ValidateBundle(b))
+
+	b.Name = "Cert_Manager"
+	b.Spec.RepoPath = "charts"
+	b.Spec.DeploySettings = &arlonv1.DeploySettings{Project: "Bad_Project"}
+	assert.Equal(t, []string{"metadata.name", "spec.repoPath", "spec.deploySettings.project"},
+		errFields(ValidateBundle(b)))
+}
+
+func TestValidateAdmission(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, arlonv1.AddToScheme(scheme))
+	wh := &webhook{
+		scheme:  scheme,
+		decoder: serializer.NewCodecFactory(scheme).UniversalDeserializer(),
+		log:     log.GetLogger(),
+	}
+	marshal := func(cl *arlonv1.Cluster) []byte {
+		cl.APIVersion = arlonv1.GroupVersion.String()
+		cl.Kind = "Cluster"
+		raw, err := json.Marshal(cl)
+		assert.NoError(t, err)
+		return raw
+	}
+	review := func(cl *arlonv1.Cluster, op v1.Operation) v1.AdmissionReview {
+		return v1.AdmissionReview{Request: &v1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "core.arlon.io", Version: "v1", Kind: "Cluster"},
+			Name:      cl.Name,
+			Operation: op,
+			Object:    runtime.RawExtension{Raw: marshal(cl)},
+		}}
+	}
+	update := func(oldCl *arlonv1.Cluster, cl *arlonv1.Cluster) v1.AdmissionReview {
+		ar := review(cl, v1.Update)
+		ar.Request.OldObject = runtime.RawExtension{Raw: marshal(oldCl)}
+		return ar
+	}
+
+	resp := wh.Validate(context.Background(), review(validCluster(), v1.Create))
+	assert.True(t, resp.Allowed)
+
+	invalid := validCluster()
+	invalid.Spec.ClusterTemplate.Url = ""
+	resp = wh.Validate(context.Background(), review(invalid, v1.Update))
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, metav1.StatusReasonInvalid, resp.Result.Reason)
+	assert.Contains(t, resp.Result.Message, "spec.clusterTemplate.url")
+
+	// Deletion is never blocked
+	resp = wh.Validate(context.Background(), review(invalid, v1.Delete))
+	assert.True(t, resp.Allowed)
+
+	// An update that changes the spec to an invalid one is rejected
+	resp = wh.Validate(context.Background(), update(validCluster(), invalid))
+	assert.False(t, resp.Allowed)
+
+	// An invalid resource created before the webhook can still be updated
+	// without changing its spec, e.g. to remove its finalizer
+	legacy := invalid.DeepCopy()
+	legacy.Finalizers = []string{"arlon.io/finalizer"}
+	resp = wh.Validate(context.Background(), update(legacy, invalid))
+	assert.True(t, resp.Allowed)
+
+	// As can a resource being deleted, even when its spec changes
+	deleting := invalid.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+	resp = wh.Validate(context.Background(), update(validCluster(), deleting))
+	assert.True(t, resp.Allowed)
+}
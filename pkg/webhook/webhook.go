@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"fmt"
 	"github.com/arlonproj/arlon/pkg/log"
 	"github.com/go-logr/logr"
@@ -29,8 +30,22 @@ func newWebhook(localClient client.Client, runtimeScheme *runtime.Scheme) *webho
 	}
 }
 
-func (wh *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	err, code := wh.serveHTTP(w, r)
+// admitFunc handles an admission review and returns the response.
+type admitFunc func(context.Context, v1.AdmissionReview) *v1.AdmissionResponse
+
+// handler serves admission reviews received on one path of the webhook server.
+type handler struct {
+	wh    *webhook
+	admit admitFunc
+}
+
+func (wh *webhook) handlerFor(admit admitFunc) *handler {
+	return &handler{wh: wh, admit: admit}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wh := h.wh
+	err, code := wh.serveHTTP(w, r, h.admit)
 	if err != nil {
 		msg := fmt.Sprintf("error: %s", err)
 		wh.log.Info(msg)
@@ -39,7 +54,7 @@ func (wh *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (wh *webhook) serveHTTP(w http.ResponseWriter, r *http.Request) (error, int) {
+func (wh *webhook) serveHTTP(w http.ResponseWriter, r *http.Request, admit admitFunc) (error, int) {
 	var body []byte
 	if r.Body != nil {
 		if data, err := io.ReadAll(r.Body); err == nil {
@@ -72,7 +87,7 @@ func (wh *webhook) serveHTTP(w http.ResponseWriter, r *http.Request) (error, int
 		}
 		responseAdmissionReview := &v1.AdmissionReview{}
 		responseAdmissionReview.SetGroupVersionKind(*gvk)
-		responseAdmissionReview.Response = wh.Rewrite(r.Context(), *requestedAdmissionReview)
+		responseAdmissionReview.Response = admit(r.Context(), *requestedAdmissionReview)
 		responseAdmissionReview.Response.UID = requestedAdmissionReview.Request.UID
 		responseObj = responseAdmissionReview
 	default: